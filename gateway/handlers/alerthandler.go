@@ -11,6 +11,7 @@ import (
 	"math"
 	"net/http"
 
+	"github.com/openfaas/faas/gateway/pkg/httperror"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
 	"github.com/openfaas/faas/gateway/requests"
 	"github.com/openfaas/faas/gateway/scaling"
@@ -21,7 +22,7 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		if r.Body == nil {
-			http.Error(w, "A body is required for this endpoint", http.StatusBadRequest)
+			httperror.Write(w, r, http.StatusBadRequest, "A body is required for this endpoint")
 			return
 		}
 
@@ -29,8 +30,7 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Unable to read alert."))
+			httperror.Write(w, r, http.StatusBadRequest, "Unable to read alert.")
 
 			log.Println(err)
 			return
@@ -38,8 +38,7 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 
 		var req requests.PrometheusAlert
 		if err := json.Unmarshal(body, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Unable to parse alert, bad format."))
+			httperror.Write(w, r, http.StatusBadRequest, "Unable to parse alert, bad format.")
 			log.Println(err)
 			return
 		}
@@ -51,8 +50,7 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 			for d, err := range errors {
 				errorOutput += fmt.Sprintf("[%d] %s\n", d, err)
 			}
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(errorOutput))
+			httperror.Write(w, r, http.StatusInternalServerError, errorOutput)
 			return
 		}
 
@@ -0,0 +1,127 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// MakeBodyLimitHandler rejects request bodies over maxBodyBytes, or the
+// function's com.faas.max_body_bytes label, with a 413. A limit of 0 means
+// no limit. Requests without a Content-Length are buffered up to the limit,
+// so that the 413 is sent before the function is invoked.
+//
+// When limitResponse is set, a function response whose Content-Length is
+// over the limit is replaced with a 502. Streamed responses are not limited.
+func MakeBodyLimitHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, maxBodyBytes int64, limitResponse bool, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := maxBodyBytes
+
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+		if res, err := functionQuery.Get(functionName, namespace); err == nil && res.MaxBodyBytes > 0 {
+			limit = int64(res.MaxBodyBytes)
+		}
+
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit {
+				rejectRequestBody(w, r, limit)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			if r.ContentLength < 0 {
+				buffered, err := tracing.ReadRequestBody(r)
+				if err != nil {
+					var maxBytesErr *http.MaxBytesError
+					if errors.As(err, &maxBytesErr) {
+						rejectRequestBody(w, r, limit)
+						return
+					}
+
+					httperror.Write(w, r, http.StatusBadRequest, err.Error())
+					return
+				}
+
+				r.ContentLength = int64(len(buffered))
+			}
+		}
+
+		if limitResponse && !isWebSocketUpgrade(r) && !strings.HasPrefix(r.Header.Get("Accept"), "text/event-stream") {
+			w = &limitedResponseWriter{ResponseWriter: w, r: r, limit: limit}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func rejectRequestBody(w http.ResponseWriter, r *http.Request, limit int64) {
+	tracing.SetBodyLimitExceeded(r.Context(), "request", limit)
+
+	httperror.Write(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds the limit of %d bytes", limit))
+}
+
+// limitedResponseWriter replaces a response which declares a Content-Length
+// over limit, before any of it is written to the client
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	limit       int64
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *limitedResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && length > w.limit {
+		w.rejected = true
+		tracing.SetBodyLimitExceeded(w.r.Context(), "response", w.limit)
+
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Encoding")
+		httperror.Write(w.ResponseWriter, w.r, http.StatusBadGateway, fmt.Sprintf("function response exceeds the limit of %d bytes", w.limit))
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		// discard the function's response, as if it had been written
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *limitedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (w *limitedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
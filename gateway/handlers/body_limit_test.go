@@ -0,0 +1,166 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// unknownLength hides the length of a body, as with a chunked request
+type unknownLength struct {
+	io.Reader
+}
+
+func Test_MakeBodyLimitHandler_RequestBody(t *testing.T) {
+	const limit = 10
+
+	cases := []struct {
+		name     string
+		body     string
+		chunked  bool
+		wantCode int
+	}{
+		{name: "just under the limit", body: strings.Repeat("a", limit-1), wantCode: http.StatusOK},
+		{name: "at the limit", body: strings.Repeat("a", limit), wantCode: http.StatusOK},
+		{name: "just over the limit", body: strings.Repeat("a", limit+1), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked just under the limit", body: strings.Repeat("a", limit-1), chunked: true, wantCode: http.StatusOK},
+		{name: "chunked just over the limit", body: strings.Repeat("a", limit+1), chunked: true, wantCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received string
+			invoked := false
+			next := func(w http.ResponseWriter, r *http.Request) {
+				invoked = true
+				body, _ := io.ReadAll(r.Body)
+				received = string(body)
+			}
+
+			handler := MakeBodyLimitHandler(next, fakeFunctionQuery{}, limit, false, "openfaas-fn")
+
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.chunked {
+				body = unknownLength{body}
+			}
+			req := httptest.NewRequest(http.MethodPost, "/function/figlet", body)
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("want status %d, got: %d", tc.wantCode, rr.Code)
+			}
+
+			if tc.wantCode == http.StatusOK && received != tc.body {
+				t.Errorf("want the function to receive the whole body, got %d bytes", len(received))
+			}
+			if tc.wantCode == http.StatusRequestEntityTooLarge && invoked {
+				t.Errorf("want the function not to be invoked")
+			}
+		})
+	}
+}
+
+func Test_MakeBodyLimitHandler_LabelOverridesDefault(t *testing.T) {
+	query := fakeFunctionQuery{res: scaling.ServiceQueryResponse{MaxBodyBytes: 100}}
+	handler := MakeBodyLimitHandler(func(w http.ResponseWriter, r *http.Request) {}, query, 10, false, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader(strings.Repeat("a", 50))))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want the label's limit to allow the body, got status: %d", rr.Code)
+	}
+}
+
+func Test_MakeBodyLimitHandler_NoLimit(t *testing.T) {
+	handler := MakeBodyLimitHandler(func(w http.ResponseWriter, r *http.Request) {}, fakeFunctionQuery{}, 0, true, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader(strings.Repeat("a", 1024))))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status %d, got: %d", http.StatusOK, rr.Code)
+	}
+}
+
+func Test_MakeBodyLimitHandler_ResponseBody(t *testing.T) {
+	const limit = 10
+
+	cases := []struct {
+		name          string
+		body          string
+		limitResponse bool
+		wantCode      int
+	}{
+		{name: "just under the limit", body: strings.Repeat("a", limit-1), limitResponse: true, wantCode: http.StatusOK},
+		{name: "just over the limit", body: strings.Repeat("a", limit+1), limitResponse: true, wantCode: http.StatusBadGateway},
+		{name: "over the limit when not enabled", body: strings.Repeat("a", limit+1), wantCode: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(tc.body)))
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tc.body))
+			}
+
+			handler := MakeBodyLimitHandler(next, fakeFunctionQuery{}, limit, tc.limitResponse, "openfaas-fn")
+
+			rr := httptest.NewRecorder()
+			handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("want status %d, got: %d", tc.wantCode, rr.Code)
+			}
+			if tc.wantCode == http.StatusOK && rr.Body.String() != tc.body {
+				t.Errorf("want body: %q, got: %q", tc.body, rr.Body.String())
+			}
+			if tc.wantCode == http.StatusBadGateway && strings.Contains(rr.Body.String(), tc.body) {
+				t.Errorf("want the function's response to be discarded, got: %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func Test_MakeBodyLimitHandler_RecordsSpanAttribute(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	handler := tracing.Middleware(MakeBodyLimitHandler(func(w http.ResponseWriter, r *http.Request) {}, fakeFunctionQuery{}, 10, false, "openfaas-fn"))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader(strings.Repeat("a", 11))))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	if got := attrs[string(tracing.BodyLimitExceededKey)]; got != "request" {
+		t.Errorf("want %s: request, got: %q", tracing.BodyLimitExceededKey, got)
+	}
+	if got := attrs[string(tracing.BodyLimitKey)]; got != "10" {
+		t.Errorf("want %s: 10, got: %q", tracing.BodyLimitKey, got)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// The versions of a function with a canary, recorded as faas.variant
+const (
+	StableVariant = "stable"
+	CanaryVariant = "canary"
+)
+
+// MakeCanaryHandler sends the com.faas.canary_weight percentage of calls to
+// a function to the function named by its com.faas.canary label, by
+// rewriting the path so that the handlers after it, and the resolver, call
+// the canary. The version which served the call is recorded on the span.
+//
+// Calls with the sessionHeader are split by a hash of its value, so that a
+// caller keeps the same version for as long as the weight is unchanged.
+// Other calls are split at random.
+func MakeCanaryHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, sessionHeader, defaultNamespace string) http.HandlerFunc {
+	source := rand.New(rand.NewSource(time.Now().UnixNano()))
+	lock := sync.Mutex{}
+
+	random := func() uint64 {
+		lock.Lock()
+		defer lock.Unlock()
+		return uint64(source.Intn(100))
+	}
+
+	return makeCanaryHandler(next, functionQuery, sessionHeader, defaultNamespace, random)
+}
+
+// makeCanaryHandler splits calls without a session with random, which
+// returns a value in [0, 100)
+func makeCanaryHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, sessionHeader, defaultNamespace string, random func() uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceName := middleware.GetServiceName(r.URL.Path)
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, serviceName)
+
+		res, err := functionQuery.Get(functionName, namespace)
+		if err != nil || len(res.Canary) == 0 || res.Canary == functionName {
+			next(w, r)
+			return
+		}
+
+		bucket := uint64(0)
+		if session := r.Header.Get(sessionHeader); len(sessionHeader) > 0 && len(session) > 0 {
+			bucket = canaryBucket(functionName, session)
+		} else {
+			bucket = random()
+		}
+
+		if bucket >= res.CanaryWeight {
+			tracing.SetVariant(r.Context(), StableVariant)
+			next(w, r)
+			return
+		}
+
+		tracing.SetVariant(r.Context(), CanaryVariant)
+
+		canary := res.Canary
+		if strings.Contains(serviceName, ".") {
+			canary += "." + namespace
+		}
+
+		prefix := "/function/" + serviceName
+		r.URL.Path = "/function/" + canary + strings.TrimPrefix(r.URL.Path, prefix)
+		r.URL.RawPath = ""
+
+		next(w, r)
+	}
+}
+
+// canaryBucket hashes a session to a value in [0, 100), the function name
+// is included so that a session is not sent to the canary of every function
+func canaryBucket(functionName, session string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(functionName))
+	h.Write([]byte{0})
+	h.Write([]byte(session))
+	return h.Sum64() % 100
+}
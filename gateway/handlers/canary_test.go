@@ -0,0 +1,136 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel"
+)
+
+// canaryQuery is a function with 10% of its calls sent to figlet-canary
+var canaryQuery = fakeFunctionQuery{res: scaling.ServiceQueryResponse{Canary: "figlet-canary", CanaryWeight: 10}}
+
+// calledPaths records the path of each call which reaches it
+func calledPaths() (http.HandlerFunc, map[string]int) {
+	paths := map[string]int{}
+	return func(w http.ResponseWriter, r *http.Request) {
+		paths[r.URL.Path]++
+	}, paths
+}
+
+func Test_MakeCanaryHandler_SplitsByWeight(t *testing.T) {
+	next, paths := calledPaths()
+	handler := MakeCanaryHandler(next, canaryQuery, "X-Session-Id", "openfaas-fn")
+
+	const calls = 10000
+	for i := 0; i < calls; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	}
+
+	ratio := float64(paths["/function/figlet-canary"]) / calls
+	if math.Abs(ratio-0.1) > 0.02 {
+		t.Errorf("want 10%% of calls sent to the canary, within 2%%, got: %.2f%% %v", ratio*100, paths)
+	}
+	if paths["/function/figlet"]+paths["/function/figlet-canary"] != calls {
+		t.Errorf("want every call sent to one of the versions, got: %v", paths)
+	}
+}
+
+func Test_MakeCanaryHandler_SplitsSessionsByWeight(t *testing.T) {
+	next, paths := calledPaths()
+	handler := MakeCanaryHandler(next, canaryQuery, "X-Session-Id", "openfaas-fn")
+
+	const sessions = 10000
+	for i := 0; i < sessions; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+		req.Header.Set("X-Session-Id", fmt.Sprintf("session-%d", i))
+		handler(httptest.NewRecorder(), req)
+	}
+
+	ratio := float64(paths["/function/figlet-canary"]) / sessions
+	if math.Abs(ratio-0.1) > 0.02 {
+		t.Errorf("want 10%% of sessions sent to the canary, within 2%%, got: %.2f%%", ratio*100)
+	}
+}
+
+func Test_MakeCanaryHandler_SessionKeepsVersion(t *testing.T) {
+	next, paths := calledPaths()
+
+	// every call without a session would go to the canary
+	handler := makeCanaryHandler(next, canaryQuery, "X-Session-Id", "openfaas-fn", func() uint64 { return 0 })
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/function/figlet.openfaas-fn/greet", nil)
+		req.Header.Set("X-Session-Id", "alex")
+		handler(httptest.NewRecorder(), req)
+	}
+
+	if len(paths) != 1 {
+		t.Fatalf("want every call in a session sent to the same version, got: %v", paths)
+	}
+
+	want := "/function/figlet.openfaas-fn/greet"
+	if canaryBucket("figlet", "alex") < canaryQuery.res.CanaryWeight {
+		want = "/function/figlet-canary.openfaas-fn/greet"
+	}
+	if paths[want] != 20 {
+		t.Errorf("want the calls sent to %s, got: %v", want, paths)
+	}
+}
+
+func Test_MakeCanaryHandler_RecordsVariant(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	next, _ := calledPaths()
+
+	for _, tc := range []struct {
+		bucket uint64
+		want   string
+	}{
+		{bucket: 5, want: CanaryVariant},
+		{bucket: 50, want: StableVariant},
+	} {
+		handler := makeCanaryHandler(next, canaryQuery, "X-Session-Id", "openfaas-fn", func() uint64 { return tc.bucket })
+
+		ctx, span := otel.Tracer("test").Start(context.Background(), tc.want)
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil).WithContext(ctx))
+		span.End()
+
+		spans := recorder.Named(tc.want)
+		if len(spans) != 1 {
+			t.Fatalf("want 1 span, got: %d", len(spans))
+		}
+
+		got := ""
+		for _, kv := range spans[0].Attributes() {
+			if kv.Key == tracing.VariantKey {
+				got = kv.Value.AsString()
+			}
+		}
+		if got != tc.want {
+			t.Errorf("bucket %d: want %s: %q, got: %q", tc.bucket, tracing.VariantKey, tc.want, got)
+		}
+	}
+}
+
+func Test_MakeCanaryHandler_WithoutCanary(t *testing.T) {
+	next, paths := calledPaths()
+	handler := makeCanaryHandler(next, fakeFunctionQuery{}, "X-Session-Id", "openfaas-fn", func() uint64 { return 0 })
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if paths["/function/figlet"] != 1 {
+		t.Errorf("want the call passed through, got: %v", paths)
+	}
+}
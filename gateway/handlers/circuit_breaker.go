@@ -0,0 +1,67 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/circuit"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// NewCircuitBreaker creates a circuit.Breaker which logs state changes and
+// records them in the gateway_circuit_breaker_state metric.
+func NewCircuitBreaker(config circuit.Config) *circuit.Breaker {
+	config.OnStateChange = func(name string, from, to circuit.State) {
+		log.Printf("[Circuit] function=%s %s=>%s\n", name, from, to)
+		metrics.SetCircuitState(name, float64(to))
+	}
+
+	return circuit.NewBreaker(config)
+}
+
+// MakeCircuitBreakerHandler rejects requests to a function with a 503 while
+// its circuit is open. Responses with a 5xx status count as failures, and
+// requests whose caller went away, or which panicked, are not counted.
+func MakeCircuitBreakerHandler(next http.HandlerFunc, breaker *circuit.Breaker, retryAfterSeconds int, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+		key := functionName + "." + namespace
+
+		token, state, allowed := breaker.Allow(key)
+		tracing.SetCircuitState(r.Context(), state.String())
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			httperror.WriteCode(w, r, http.StatusServiceUnavailable, "circuit_open",
+				fmt.Sprintf("function %s.%s is unavailable, circuit is %s", functionName, namespace, state))
+			return
+		}
+
+		done := false
+		defer func() {
+			if !done {
+				token.Cancel()
+			}
+		}()
+
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		next(ww, r)
+
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			return
+		}
+		done = true
+		token.Done(ww.Status() < http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/circuit"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+func Test_MakeCircuitBreakerHandler_OpensOnFailures(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	breaker := NewCircuitBreaker(circuit.Config{
+		FailureRatio: 0.5,
+		MinRequests:  2,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+	})
+
+	calls := 0
+	handler := tracing.Middleware(MakeCircuitBreakerHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}, breaker, 60, "openfaas-fn"))
+
+	for i := 0; i < 2; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want status %d with the circuit open, got: %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("want Retry-After: 60, got: %q", got)
+	}
+	if calls != 2 {
+		t.Errorf("want the function called twice, got: %d", calls)
+	}
+
+	states := []string{}
+	for _, span := range recorder.Ended() {
+		for _, kv := range span.Attributes() {
+			if kv.Key == tracing.CircuitStateKey {
+				states = append(states, kv.Value.AsString())
+			}
+		}
+	}
+
+	want := []string{"closed", "closed", "open"}
+	if len(states) != len(want) {
+		t.Fatalf("want %s: %v, got: %v", tracing.CircuitStateKey, want, states)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Errorf("request %d: want %s: %s, got: %s", i, tracing.CircuitStateKey, want[i], states[i])
+		}
+	}
+}
+
+func Test_MakeCircuitBreakerHandler_CancelledProbeFreesSlot(t *testing.T) {
+	breaker := NewCircuitBreaker(circuit.Config{
+		FailureRatio: 0.5,
+		MinRequests:  1,
+		Window:       time.Minute,
+	})
+
+	status := http.StatusInternalServerError
+	handler := MakeCircuitBreakerHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}, breaker, 60, "openfaas-fn")
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	if got := breaker.State("figlet.openfaas-fn"); got != circuit.Open {
+		t.Fatalf("want the circuit %s, got: %s", circuit.Open, got)
+	}
+
+	// the probe's caller goes away before the function responds
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil).WithContext(ctx))
+	if got := breaker.State("figlet.openfaas-fn"); got != circuit.HalfOpen {
+		t.Fatalf("want the circuit %s after a cancelled probe, got: %s", circuit.HalfOpen, got)
+	}
+
+	status = http.StatusOK
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want another probe let through, got: %d", rr.Code)
+	}
+	if got := breaker.State("figlet.openfaas-fn"); got != circuit.Closed {
+		t.Errorf("want the circuit %s after a successful probe, got: %s", circuit.Closed, got)
+	}
+}
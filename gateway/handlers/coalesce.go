@@ -0,0 +1,100 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+	"golang.org/x/sync/singleflight"
+)
+
+// MakeCoalescingHandler shares one call to next between concurrent GET
+// requests with the same path and query, for functions with the
+// com.faas.coalesce label. The response is buffered and copied to each
+// caller. Headers are not part of the key, so the label should only be set
+// on functions whose response is the same for every caller.
+func MakeCoalescingHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, defaultNamespace string) http.HandlerFunc {
+	group := &singleflight.Group{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || isWebSocketUpgrade(r) || isEventStream(r) {
+			next(w, r)
+			return
+		}
+
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+		if res, err := functionQuery.Get(functionName, namespace); err != nil || !res.Coalesce {
+			next(w, r)
+			return
+		}
+
+		leader := false
+		v, _, _ := group.Do(r.Method+" "+r.URL.RequestURI(), func() (interface{}, error) {
+			leader = true
+
+			// the call is shared, so it is not cancelled when the first
+			// caller goes away while others are still waiting for it
+			rec := &coalescedResponse{header: http.Header{}, status: http.StatusOK}
+			next(rec, r.WithContext(context.WithoutCancel(r.Context())))
+			return rec, nil
+		})
+
+		if !leader {
+			tracing.AddCoalescedEvent(r.Context())
+		}
+
+		v.(*coalescedResponse).writeTo(w)
+	}
+}
+
+// isEventStream reports whether the caller asked for server-sent events,
+// which are streamed so can not be buffered and shared
+func isEventStream(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// coalescedResponse buffers a response so that it can be written to each
+// caller which shared it
+type coalescedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *coalescedResponse) Header() http.Header {
+	return c.header
+}
+
+func (c *coalescedResponse) WriteHeader(statusCode int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.status = statusCode
+}
+
+func (c *coalescedResponse) Write(b []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	return c.body.Write(b)
+}
+
+// Flush does nothing, the response is written to the callers once it is
+// complete. It is here for the handlers further in which assert
+// http.Flusher.
+func (c *coalescedResponse) Flush() {}
+
+func (c *coalescedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(c.status)
+	w.Write(c.body.Bytes())
+}
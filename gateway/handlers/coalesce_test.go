@@ -0,0 +1,178 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel"
+)
+
+// arrivingFunctionQuery marks each caller done on arrived once it has
+// looked up the function
+type arrivingFunctionQuery struct {
+	fakeFunctionQuery
+	arrived *sync.WaitGroup
+}
+
+func (f arrivingFunctionQuery) Get(name, namespace string) (scaling.ServiceQueryResponse, error) {
+	defer f.arrived.Done()
+	return f.fakeFunctionQuery.Get(name, namespace)
+}
+
+func Test_MakeCoalescingHandler_SharesOneUpstreamCall(t *testing.T) {
+	const callers = 10
+
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	var calls int32
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-unblock
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+	}
+
+	arrived := &sync.WaitGroup{}
+	arrived.Add(callers)
+	query := arrivingFunctionQuery{fakeFunctionQuery: fakeFunctionQuery{res: scaling.ServiceQueryResponse{Coalesce: true}}, arrived: arrived}
+	handler := MakeCoalescingHandler(next, query, "openfaas-fn")
+
+	results := make(chan *httptest.ResponseRecorder, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			ctx, span := otel.Tracer("test").Start(context.Background(), "caller")
+
+			rr := httptest.NewRecorder()
+			handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet?name=openfaas", nil).WithContext(ctx))
+			span.End()
+			results <- rr
+		}()
+	}
+
+	// hold the upstream call until every caller is waiting on it
+	<-started
+	arrived.Wait()
+	time.Sleep(time.Millisecond * 20)
+	close(unblock)
+
+	for i := 0; i < callers; i++ {
+		rr := <-results
+		if rr.Code != http.StatusOK || rr.Body.String() != "hello" || rr.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("want every caller to get the response, got: %d %q %q", rr.Code, rr.Body.String(), rr.Header().Get("Content-Type"))
+		}
+	}
+
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("want 1 upstream call for %d identical requests, got: %d", callers, calls)
+	}
+
+	coalesced := 0
+	for _, span := range recorder.Named("caller") {
+		for _, event := range span.Events() {
+			if event.Name == tracing.CoalescedEvent {
+				coalesced++
+			}
+		}
+	}
+	if coalesced != callers-1 {
+		t.Errorf("want %s on the %d callers which shared the call, got: %d", tracing.CoalescedEvent, callers-1, coalesced)
+	}
+}
+
+func Test_MakeCoalescingHandler_DisabledWithoutLabel(t *testing.T) {
+	var calls int32
+	next := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}
+
+	handler := MakeCoalescingHandler(next, fakeFunctionQuery{}, "openfaas-fn")
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if calls != 2 {
+		t.Errorf("want each request passed on without the label, got: %d calls", calls)
+	}
+}
+
+func Test_MakeCoalescingHandler_OnlyGETs(t *testing.T) {
+	var calls int32
+	next := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}
+
+	handler := MakeCoalescingHandler(next, fakeFunctionQuery{res: scaling.ServiceQueryResponse{Coalesce: true}}, "openfaas-fn")
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/function/figlet", nil))
+
+	if calls != 1 {
+		t.Errorf("want a POST passed on, got: %d calls", calls)
+	}
+}
+
+func Test_MakeCoalescingHandler_FlushesThroughWriteInterceptor(t *testing.T) {
+	// the circuit breaker and metrics wrap the writer further in, and assert
+	// http.Flusher when the function streams its response
+	next := func(w http.ResponseWriter, r *http.Request) {
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		ww.Write([]byte("part 1"))
+		ww.Flush()
+		ww.Write([]byte(", part 2"))
+	}
+
+	handler := MakeCoalescingHandler(next, fakeFunctionQuery{res: scaling.ServiceQueryResponse{Coalesce: true}}, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if rr.Body.String() != "part 1, part 2" {
+		t.Errorf("want the whole response, got: %q", rr.Body.String())
+	}
+}
+
+func Test_MakeCoalescingHandler_StreamsEventStreams(t *testing.T) {
+	var calls int32
+	next := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+	}
+
+	handler := MakeCoalescingHandler(next, fakeFunctionQuery{res: scaling.ServiceQueryResponse{Coalesce: true}}, "openfaas-fn")
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+		req.Header.Set("Accept", "text/event-stream")
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if !rr.Flushed {
+			t.Errorf("want the event stream flushed straight to the caller")
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("want each event stream passed on, got: %d calls", calls)
+	}
+}
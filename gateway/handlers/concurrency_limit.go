@@ -0,0 +1,81 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// concurrencyRetryAfter is sent to throttled callers in the Retry-After header
+const concurrencyRetryAfter = time.Second
+
+// inflightCounter tracks the requests in progress for each function
+type inflightCounter struct {
+	lock     sync.Mutex
+	inflight map[string]uint64
+}
+
+// acquire reserves a slot for key, returning false if limit is reached
+func (c *inflightCounter) acquire(key string, limit uint64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.inflight[key] >= limit {
+		return false
+	}
+	c.inflight[key]++
+	return true
+}
+
+func (c *inflightCounter) release(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.inflight[key]--
+	if c.inflight[key] == 0 {
+		delete(c.inflight, key)
+	}
+}
+
+// MakeConcurrencyLimitHandler limits the number of requests proxied to each
+// function at once to the value of its com.faas.max_inflight label. Requests
+// over the limit are rejected with a 429 and a Retry-After header. Functions
+// without the label, or which cannot be queried, are not limited.
+func MakeConcurrencyLimitHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, defaultNamespace string) http.HandlerFunc {
+	counter := &inflightCounter{inflight: map[string]uint64{}}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+
+		res, err := functionQuery.Get(functionName, namespace)
+		if err != nil || res.MaxInflight == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := functionName + "." + namespace
+		if !counter.acquire(key, res.MaxInflight) {
+			log.Printf("[Throttle] function=%s.%s reached max_inflight=%d\n", functionName, namespace, res.MaxInflight)
+			tracing.AddThrottledEvent(r.Context(), res.MaxInflight)
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(concurrencyRetryAfter.Seconds())))
+			httperror.WriteCode(w, r, http.StatusTooManyRequests, "concurrency_limited",
+				fmt.Sprintf("function %s.%s has reached its limit of %d concurrent requests", functionName, namespace, res.MaxInflight))
+			return
+		}
+		defer counter.release(key)
+
+		next.ServeHTTP(w, r)
+	}
+}
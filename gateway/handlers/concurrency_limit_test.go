@@ -0,0 +1,172 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+type fakeFunctionQuery struct {
+	res scaling.ServiceQueryResponse
+	err error
+}
+
+func (f fakeFunctionQuery) Get(name, namespace string) (scaling.ServiceQueryResponse, error) {
+	return f.res, f.err
+}
+
+func (f fakeFunctionQuery) GetAnnotations(name, namespace string) (map[string]string, error) {
+	return map[string]string{}, f.err
+}
+
+func Test_MakeConcurrencyLimitHandler_RejectsOverLimit(t *testing.T) {
+	const limit = 3
+
+	unblock := make(chan struct{})
+	started := make(chan struct{}, limit+1)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	}
+
+	handler := MakeConcurrencyLimitHandler(next, fakeFunctionQuery{res: scaling.ServiceQueryResponse{MaxInflight: limit}}, "openfaas-fn")
+
+	type result struct {
+		code       int
+		retryAfter string
+	}
+	results := make(chan result, limit+1)
+
+	// fire N+1 requests at once, the N which get a slot are held until the
+	// one over the limit has been rejected
+	for i := 0; i < limit+1; i++ {
+		go func() {
+			rr := httptest.NewRecorder()
+			handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+			results <- result{code: rr.Code, retryAfter: rr.Header().Get("Retry-After")}
+		}()
+	}
+
+	rejected := <-results
+	for i := 0; i < limit; i++ {
+		<-started
+	}
+	close(unblock)
+
+	all := []result{rejected}
+	for i := 0; i < limit; i++ {
+		all = append(all, <-results)
+	}
+
+	throttled := 0
+	for _, res := range all {
+		switch res.code {
+		case http.StatusTooManyRequests:
+			throttled++
+			if res.retryAfter != "1" {
+				t.Errorf("want Retry-After: 1, got: %q", res.retryAfter)
+			}
+		case http.StatusOK:
+		default:
+			t.Errorf("want status %d or %d, got: %d", http.StatusOK, http.StatusTooManyRequests, res.code)
+		}
+	}
+
+	if throttled != 1 {
+		t.Errorf("want exactly 1 request throttled, got: %d", throttled)
+	}
+}
+
+func Test_MakeConcurrencyLimitHandler_ReleasesSlots(t *testing.T) {
+	handler := MakeConcurrencyLimitHandler(func(w http.ResponseWriter, r *http.Request) {},
+		fakeFunctionQuery{res: scaling.ServiceQueryResponse{MaxInflight: 1}}, "openfaas-fn")
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: want status %d after the previous request finished, got: %d", i, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+func Test_MakeConcurrencyLimitHandler_NoLimit(t *testing.T) {
+	cases := []struct {
+		name  string
+		query fakeFunctionQuery
+	}{
+		{name: "label not set", query: fakeFunctionQuery{}},
+		{name: "query fails", query: fakeFunctionQuery{res: scaling.ServiceQueryResponse{MaxInflight: 1}, err: errors.New("not found")}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unblock := make(chan struct{})
+			started := make(chan struct{})
+			handler := MakeConcurrencyLimitHandler(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				<-unblock
+			}, tc.query, "openfaas-fn")
+
+			go handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+			<-started
+
+			done := make(chan int)
+			go func() {
+				rr := httptest.NewRecorder()
+				handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+				done <- rr.Code
+			}()
+			// the second request gets in while the first is still running
+			<-started
+			close(unblock)
+
+			if code := <-done; code != http.StatusOK {
+				t.Errorf("want status %d, got: %d", http.StatusOK, code)
+			}
+		})
+	}
+}
+
+func Test_MakeConcurrencyLimitHandler_RecordsThrottledEvent(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+	limited := MakeConcurrencyLimitHandler(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	}, fakeFunctionQuery{res: scaling.ServiceQueryResponse{MaxInflight: 1}}, "openfaas-fn")
+	handler := tracing.Middleware(limited)
+
+	go handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	<-started
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	close(unblock)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("want status %d, got: %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatal("want the throttled request's span to have ended")
+	}
+
+	events := spans[0].Events()
+	if len(events) != 1 || events[0].Name != tracing.ThrottledEvent {
+		t.Fatalf("want a %s event, got: %v", tracing.ThrottledEvent, events)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// DefaultVariant is recorded as faas.variant when a function has content
+// routes and none of them matched the call
+const DefaultVariant = "default"
+
+// MakeContentRouteHandler sends calls to a function with a
+// com.faas.content_routes label to the function named by the first route
+// matching the request's Content-Type, by rewriting the path so that the
+// handlers after it, and the resolver, call that function. Calls which match
+// no route, or have no Content-Type, are passed through to the function
+// itself. The matched route and the function chosen are recorded on the span.
+func MakeContentRouteHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceName := middleware.GetServiceName(r.URL.Path)
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, serviceName)
+
+		res, err := functionQuery.Get(functionName, namespace)
+		if err != nil || len(res.ContentRoutes) == 0 {
+			next(w, r)
+			return
+		}
+
+		route, ok := scaling.MatchContentRoute(res.ContentRoutes, r.Header.Get("Content-Type"))
+		if !ok {
+			tracing.SetVariant(r.Context(), DefaultVariant)
+			next(w, r)
+			return
+		}
+
+		tracing.SetContentRoute(r.Context(), route.MediaType)
+		tracing.SetVariant(r.Context(), route.Function)
+		if route.Function == functionName {
+			next(w, r)
+			return
+		}
+
+		target := route.Function
+		if strings.Contains(serviceName, ".") {
+			target += "." + namespace
+		}
+
+		prefix := "/function/" + serviceName
+		r.URL.Path = "/function/" + target + strings.TrimPrefix(r.URL.Path, prefix)
+		r.URL.RawPath = ""
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// contentRouteQuery is a function with JSON and multipart calls sent on to
+// their own functions
+var contentRouteQuery = fakeFunctionQuery{res: scaling.ServiceQueryResponse{
+	ContentRoutes: []scaling.ContentRoute{
+		{MediaType: "application/json", Function: "figlet-json"},
+		{MediaType: "multipart/*", Function: "figlet-upload"},
+	},
+}}
+
+func Test_MakeContentRouteHandler(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	cases := []struct {
+		name        string
+		contentType string
+		path        string
+		wantPath    string
+		wantRoute   string
+		wantVariant string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			path:        "/function/figlet/greet",
+			wantPath:    "/function/figlet-json/greet",
+			wantRoute:   "application/json",
+			wantVariant: "figlet-json",
+		},
+		{
+			name:        "multipart wildcard",
+			contentType: "multipart/form-data; boundary=xyz",
+			path:        "/function/figlet.openfaas-fn",
+			wantPath:    "/function/figlet-upload.openfaas-fn",
+			wantRoute:   "multipart/*",
+			wantVariant: "figlet-upload",
+		},
+		{
+			name:        "no match",
+			contentType: "text/plain",
+			path:        "/function/figlet",
+			wantPath:    "/function/figlet",
+			wantVariant: DefaultVariant,
+		},
+		{
+			name:        "absent header",
+			path:        "/function/figlet",
+			wantPath:    "/function/figlet",
+			wantVariant: DefaultVariant,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next, paths := calledPaths()
+			handler := MakeContentRouteHandler(next, contentRouteQuery, "openfaas-fn")
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+			if len(tc.contentType) > 0 {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			ctx, span := otel.Tracer("test").Start(context.Background(), tc.name)
+			handler(httptest.NewRecorder(), req.WithContext(ctx))
+			span.End()
+
+			if paths[tc.wantPath] != 1 {
+				t.Errorf("want call to: %s, got: %v", tc.wantPath, paths)
+			}
+
+			spans := recorder.Named(tc.name)
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got: %d", len(spans))
+			}
+
+			attrs := map[attribute.Key]string{}
+			for _, kv := range spans[0].Attributes() {
+				attrs[kv.Key] = kv.Value.AsString()
+			}
+			if got := attrs[tracing.ContentRouteKey]; got != tc.wantRoute {
+				t.Errorf("want %s: %q, got: %q", tracing.ContentRouteKey, tc.wantRoute, got)
+			}
+			if got := attrs[tracing.VariantKey]; got != tc.wantVariant {
+				t.Errorf("want %s: %q, got: %q", tracing.VariantKey, tc.wantVariant, got)
+			}
+		})
+	}
+}
+
+func Test_MakeContentRouteHandler_WithoutRoutes(t *testing.T) {
+	next, paths := calledPaths()
+	handler := MakeContentRouteHandler(next, fakeFunctionQuery{}, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodPost, "/function/figlet", nil)
+	req.Header.Set("Content-Type", "application/json")
+	handler(httptest.NewRecorder(), req)
+
+	if paths["/function/figlet"] != 1 {
+		t.Errorf("want the call passed through, got: %v", paths)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// RequestTimeoutHeader carries the caller's budget for a call to a
+// function, in seconds or as a duration such as 1.5s
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// grpcTimeoutHeader is the budget of a gRPC call, i.e. 500m
+const grpcTimeoutHeader = "Grpc-Timeout"
+
+// MakeExecTimeoutHandler gives each call to a function execTimeout, or the
+// function's com.faas.exec_timeout label, to respond. A caller with less
+// time to spare can shorten it with an X-Request-Timeout, or Grpc-Timeout,
+// header, and the timeout left is passed on to the function in the same
+// header. The request context passed to next is cancelled at the deadline,
+// which aborts the upstream call, and the caller gets a 504. A timeout of 0
+// means no limit. WebSocket connections are long-lived, so are not limited.
+func MakeExecTimeoutHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, execTimeout time.Duration, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := execTimeout
+
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+		if res, err := functionQuery.Get(functionName, namespace); err == nil && res.ExecTimeout > 0 {
+			timeout = res.ExecTimeout
+		}
+
+		if budget, ok := requestTimeout(r.Header); ok && (timeout <= 0 || budget < timeout) {
+			timeout = budget
+		}
+
+		if timeout <= 0 || isWebSocketUpgrade(r) {
+			next(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		tracing.SetDeadline(r.Context(), deadline, timeout)
+		setRequestTimeout(r.Header, time.Until(deadline))
+
+		tw := &timeoutResponseWriter{ResponseWriter: w, ctx: ctx}
+		next(tw, r.WithContext(ctx))
+
+		if tw.expired() {
+			log.Printf("function %s.%s did not respond within %s", functionName, namespace, timeout)
+			tracing.AddTimeoutEvent(r.Context(), timeout)
+
+			httperror.Write(w, r, http.StatusGatewayTimeout, fmt.Sprintf("function did not respond within %s", timeout))
+		}
+	}
+}
+
+// timeoutResponseWriter drops the error response written by the proxy when
+// its call was cancelled by the deadline, so that a 504 can be sent instead.
+// Once a response has started it is passed through untouched.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+
+	lock        sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutResponseWriter) WriteHeader(statusCode int) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.wroteHeader || tw.timedOut {
+		return
+	}
+
+	if errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+		return
+	}
+
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timeoutResponseWriter) Write(b []byte) (int, error) {
+	tw.WriteHeader(http.StatusOK)
+
+	tw.lock.Lock()
+	timedOut := tw.timedOut
+	tw.lock.Unlock()
+
+	if timedOut {
+		return 0, tw.ctx.Err()
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutResponseWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (tw *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// expired reports whether the deadline passed before a response was started
+func (tw *timeoutResponseWriter) expired() bool {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	return tw.timedOut || (!tw.wroteHeader && errors.Is(tw.ctx.Err(), context.DeadlineExceeded))
+}
+
+// requestTimeout is the caller's budget from the X-Request-Timeout header,
+// or else the Grpc-Timeout header. Missing, invalid and non-positive values
+// are ignored.
+func requestTimeout(header http.Header) (time.Duration, bool) {
+	if val := header.Get(RequestTimeoutHeader); len(val) > 0 {
+		var timeout time.Duration
+		if seconds, err := strconv.ParseFloat(val, 64); err == nil {
+			timeout = time.Duration(seconds * float64(time.Second))
+		} else if duration, err := time.ParseDuration(val); err == nil {
+			timeout = duration
+		}
+		if timeout > 0 {
+			return timeout, true
+		}
+	}
+
+	if val := header.Get(grpcTimeoutHeader); len(val) > 0 {
+		if timeout, ok := parseGRPCTimeout(val); ok && timeout > 0 {
+			return timeout, true
+		}
+	}
+
+	return 0, false
+}
+
+// grpcTimeoutUnits are the units of a Grpc-Timeout header
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout reads a Grpc-Timeout value, of up to 8 digits then a unit
+func parseGRPCTimeout(val string) (time.Duration, bool) {
+	if len(val) < 2 || len(val) > 9 {
+		return 0, false
+	}
+
+	unit, ok := grpcTimeoutUnits[val[len(val)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(val[:len(val)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// setRequestTimeout passes the time left to the function as
+// X-Request-Timeout, and as Grpc-Timeout when the caller sent one
+func setRequestTimeout(header http.Header, remaining time.Duration) {
+	if remaining < time.Millisecond {
+		remaining = time.Millisecond
+	}
+
+	header.Set(RequestTimeoutHeader, remaining.Round(time.Millisecond).String())
+
+	if len(header.Get(grpcTimeoutHeader)) > 0 {
+		header.Set(grpcTimeoutHeader, formatGRPCTimeout(remaining))
+	}
+}
+
+// formatGRPCTimeout writes d in the finest unit which fits in 8 digits,
+// from milliseconds up, rounding up so that the budget is not cut short
+func formatGRPCTimeout(d time.Duration) string {
+	const maxValue = 99999999
+
+	for _, unit := range []struct {
+		size   time.Duration
+		suffix string
+	}{
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	} {
+		if n := (d + unit.size - 1) / unit.size; n <= maxValue {
+			return strconv.FormatInt(int64(n), 10) + unit.suffix
+		}
+	}
+
+	return strconv.FormatInt(int64((d+time.Hour-1)/time.Hour), 10) + "H"
+}
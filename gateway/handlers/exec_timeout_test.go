@@ -0,0 +1,283 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+// makeTimeoutProxy proxies to a function which takes delay to respond, or
+// until its request is cancelled
+func makeTimeoutProxy(t *testing.T, delay time.Duration, cancelled chan struct{}) http.HandlerFunc {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+			close(cancelled)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	baseURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Minute, 10, 10)
+
+	return MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil)
+}
+
+func Test_MakeExecTimeoutHandler_RespondsInTime(t *testing.T) {
+	proxy := makeTimeoutProxy(t, 20*time.Millisecond, make(chan struct{}))
+	handler := MakeExecTimeoutHandler(proxy, fakeFunctionQuery{}, time.Second, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/sleep", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+	if body, _ := io.ReadAll(rr.Body); string(body) != "done" {
+		t.Errorf("want body: done, got: %q", string(body))
+	}
+}
+
+func Test_MakeExecTimeoutHandler_CancelsSlowFunction(t *testing.T) {
+	cancelled := make(chan struct{})
+	proxy := makeTimeoutProxy(t, 10*time.Second, cancelled)
+
+	// the label overrides the gateway's default
+	functionQuery := fakeFunctionQuery{res: scaling.ServiceQueryResponse{ExecTimeout: 50 * time.Millisecond}}
+	handler := MakeExecTimeoutHandler(proxy, functionQuery, time.Minute, "openfaas-fn")
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/sleep", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("want status: %d, got: %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("want the call aborted at the deadline, took: %s", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("want the upstream request to be cancelled")
+	}
+}
+
+func Test_MakeExecTimeoutHandler_NoTimeout(t *testing.T) {
+	called := false
+	handler := MakeExecTimeoutHandler(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("want no deadline when the timeout is 0")
+		}
+	}, fakeFunctionQuery{}, 0, "openfaas-fn")
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/sleep", nil))
+
+	if !called {
+		t.Error("want the function to be invoked")
+	}
+}
+
+func Test_MakeExecTimeoutHandler_RecordsTimeoutEvent(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	proxy := makeTimeoutProxy(t, 10*time.Second, make(chan struct{}))
+	handler := tracing.Middleware(MakeExecTimeoutHandler(proxy, fakeFunctionQuery{}, 50*time.Millisecond, "openfaas-fn"))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/sleep", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	events := spans[0].Events()
+	if len(events) != 1 || events[0].Name != tracing.TimeoutEvent {
+		t.Fatalf("want a %s event, got: %v", tracing.TimeoutEvent, events)
+	}
+	if got := events[0].Attributes[0]; got.Key != tracing.TimeoutKey || got.Value.AsFloat64() != 0.05 {
+		t.Errorf("want %s: 0.05, got %s: %s", tracing.TimeoutKey, got.Key, got.Value.Emit())
+	}
+}
+
+// deadlineOf calls handler and returns the timeout left on the request
+// passed to the function, and the budget sent on to it
+func deadlineOf(t *testing.T, handler func(http.HandlerFunc) http.HandlerFunc, header http.Header) (time.Duration, bool, http.Header) {
+	t.Helper()
+
+	var remaining time.Duration
+	var hasDeadline bool
+	var forwarded http.Header
+
+	req := httptest.NewRequest(http.MethodGet, "/function/sleep", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	handler(func(w http.ResponseWriter, r *http.Request) {
+		if deadline, ok := r.Context().Deadline(); ok {
+			remaining, hasDeadline = time.Until(deadline), true
+		}
+		forwarded = r.Header.Clone()
+	})(httptest.NewRecorder(), req)
+
+	return remaining, hasDeadline, forwarded
+}
+
+func Test_MakeExecTimeoutHandler_RequestTimeout(t *testing.T) {
+	cases := []struct {
+		name        string
+		execTimeout time.Duration
+		header      http.Header
+		want        time.Duration
+	}{
+		{
+			name:   "header only",
+			header: http.Header{RequestTimeoutHeader: []string{"2s"}},
+			want:   2 * time.Second,
+		},
+		{
+			name:   "header only in seconds",
+			header: http.Header{RequestTimeoutHeader: []string{"1.5"}},
+			want:   1500 * time.Millisecond,
+		},
+		{
+			name:   "grpc header only",
+			header: http.Header{"Grpc-Timeout": []string{"3000m"}},
+			want:   3 * time.Second,
+		},
+		{
+			name:        "config only",
+			execTimeout: 4 * time.Second,
+			want:        4 * time.Second,
+		},
+		{
+			name:        "invalid header falls back to config",
+			execTimeout: 4 * time.Second,
+			header:      http.Header{RequestTimeoutHeader: []string{"soon"}},
+			want:        4 * time.Second,
+		},
+		{
+			name:        "header shorter than config",
+			execTimeout: time.Minute,
+			header:      http.Header{RequestTimeoutHeader: []string{"2s"}},
+			want:        2 * time.Second,
+		},
+		{
+			name:        "config shorter than header",
+			execTimeout: 2 * time.Second,
+			header:      http.Header{RequestTimeoutHeader: []string{"1m"}},
+			want:        2 * time.Second,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			remaining, ok, forwarded := deadlineOf(t, func(next http.HandlerFunc) http.HandlerFunc {
+				return MakeExecTimeoutHandler(next, fakeFunctionQuery{}, tc.execTimeout, "openfaas-fn")
+			}, tc.header)
+
+			if !ok {
+				t.Fatalf("want a deadline")
+			}
+			if remaining > tc.want || remaining < tc.want-time.Second {
+				t.Errorf("want about %s left, got: %s", tc.want, remaining)
+			}
+
+			budget, err := time.ParseDuration(forwarded.Get(RequestTimeoutHeader))
+			if err != nil {
+				t.Fatalf("want the budget passed on as %s, got: %q", RequestTimeoutHeader, forwarded.Get(RequestTimeoutHeader))
+			}
+			if budget > tc.want || budget < tc.want-time.Second {
+				t.Errorf("want about %s passed on, got: %s", tc.want, budget)
+			}
+		})
+	}
+}
+
+func Test_MakeExecTimeoutHandler_PassesOnGRPCTimeout(t *testing.T) {
+	_, _, forwarded := deadlineOf(t, func(next http.HandlerFunc) http.HandlerFunc {
+		return MakeExecTimeoutHandler(next, fakeFunctionQuery{}, 2*time.Second, "openfaas-fn")
+	}, http.Header{"Grpc-Timeout": []string{"1M"}})
+
+	got, ok := parseGRPCTimeout(forwarded.Get("Grpc-Timeout"))
+	if !ok || got > 2*time.Second || got < time.Second {
+		t.Errorf("want about 2s passed on as Grpc-Timeout, got: %q", forwarded.Get("Grpc-Timeout"))
+	}
+}
+
+func Test_MakeExecTimeoutHandler_NoDeadlineWithoutHeaderOrConfig(t *testing.T) {
+	_, ok, forwarded := deadlineOf(t, func(next http.HandlerFunc) http.HandlerFunc {
+		return MakeExecTimeoutHandler(next, fakeFunctionQuery{}, 0, "openfaas-fn")
+	}, http.Header{RequestTimeoutHeader: []string{"0"}})
+
+	if ok {
+		t.Errorf("want no deadline")
+	}
+	if got := forwarded.Get(RequestTimeoutHeader); got != "0" {
+		t.Errorf("want the header passed through untouched, got: %q", got)
+	}
+}
+
+func Test_MakeExecTimeoutHandler_RecordsDeadline(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	handler := tracing.Middleware(MakeExecTimeoutHandler(func(w http.ResponseWriter, r *http.Request) {},
+		fakeFunctionQuery{}, time.Minute, "openfaas-fn"))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/sleep", nil)
+	req.Header.Set(RequestTimeoutHeader, "5s")
+	handler(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range spans[0].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if got := attrs[string(tracing.DeadlineTimeoutKey)]; got != "5" {
+		t.Errorf("want %s: 5, got: %q", tracing.DeadlineTimeoutKey, got)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, attrs[string(tracing.DeadlineKey)]); err != nil {
+		t.Errorf("want %s as a timestamp, got: %q", tracing.DeadlineKey, attrs[string(tracing.DeadlineKey)])
+	}
+}
+
+func Test_formatGRPCTimeout(t *testing.T) {
+	cases := map[time.Duration]string{
+		1500 * time.Millisecond: "1500m",
+		time.Microsecond:        "1m",
+		48 * time.Hour:          "172800S",
+		30000 * time.Hour:       "1800000M",
+	}
+
+	for d, want := range cases {
+		if got := formatGRPCTimeout(d); got != want {
+			t.Errorf("%s: want %s, got: %s", d, want, got)
+		}
+	}
+}
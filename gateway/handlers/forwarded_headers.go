@@ -0,0 +1,107 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// MakeForwardedHeadersHandler tells functions who called them.
+// X-Forwarded-For has the caller's address appended, X-Real-IP is set to
+// the client's address and X-Forwarded-Proto to the scheme the client used.
+// The client's address is recorded on the span as client.address.
+//
+// The forwarded headers are only kept when the caller is one of
+// trustedProxies, such as a load balancer in front of the gateway. Then the
+// client is the last address in X-Forwarded-For which is not a trusted
+// proxy. Headers from any other caller are replaced, as they could be set
+// to anything.
+func MakeForwardedHeadersHandler(next http.HandlerFunc, trustedProxies []*net.IPNet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		remote := remoteIP(r)
+
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+
+		forwardedFor := []string{}
+		client := remote
+
+		if isTrustedProxy(remote, trustedProxies) {
+			forwardedFor = forwardedAddresses(r.Header)
+			client = forwardedClient(forwardedFor, trustedProxies, remote)
+
+			if forwardedProto := r.Header.Get("X-Forwarded-Proto"); len(forwardedProto) > 0 {
+				proto = forwardedProto
+			}
+		}
+
+		forwardedFor = append(forwardedFor, remote)
+
+		r.Header.Set("X-Forwarded-For", strings.Join(forwardedFor, ", "))
+		r.Header.Set("X-Real-IP", client)
+		r.Header.Set("X-Forwarded-Proto", proto)
+
+		tracing.SetClientAddress(r.Context(), client)
+
+		next(w, r)
+	}
+}
+
+// remoteIP is the address of the connection, without its port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedAddresses reads every X-Forwarded-For header, in order
+func forwardedAddresses(header http.Header) []string {
+	addresses := []string{}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); len(address) > 0 {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// forwardedClient walks back through the proxies which forwarded the
+// request, each of which appended the address of its caller, to the first
+// one which is not trusted
+func forwardedClient(forwardedFor []string, trustedProxies []*net.IPNet, remote string) string {
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		if !isTrustedProxy(forwardedFor[i], trustedProxies) {
+			return forwardedFor[i]
+		}
+	}
+
+	if len(forwardedFor) > 0 {
+		return forwardedFor[0]
+	}
+	return remote
+}
+
+func isTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
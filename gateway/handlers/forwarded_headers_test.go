@@ -0,0 +1,189 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func trustedNetworks(t *testing.T, cidrs ...string) []*net.IPNet {
+	t.Helper()
+
+	networks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// forwardedHeaders returns the headers received by the function
+func forwardedHeaders(trusted []*net.IPNet, req *http.Request) http.Header {
+	var got http.Header
+	handler := MakeForwardedHeadersHandler(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}, trusted)
+
+	handler(httptest.NewRecorder(), req)
+	return got
+}
+
+func Test_MakeForwardedHeadersHandler_DirectClient(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+
+	got := forwardedHeaders(nil, req)
+
+	if v := got.Get("X-Forwarded-For"); v != "203.0.113.7" {
+		t.Errorf("want X-Forwarded-For: 203.0.113.7, got: %q", v)
+	}
+	if v := got.Get("X-Real-IP"); v != "203.0.113.7" {
+		t.Errorf("want X-Real-IP: 203.0.113.7, got: %q", v)
+	}
+	if v := got.Get("X-Forwarded-Proto"); v != "http" {
+		t.Errorf("want X-Forwarded-Proto: http, got: %q", v)
+	}
+}
+
+func Test_MakeForwardedHeadersHandler_DirectClientOverTLS(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.TLS = &tls.ConnectionState{}
+
+	if v := forwardedHeaders(nil, req).Get("X-Forwarded-Proto"); v != "https" {
+		t.Errorf("want X-Forwarded-Proto: https, got: %q", v)
+	}
+}
+
+func Test_MakeForwardedHeadersHandler_ReplacesUntrustedHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	got := forwardedHeaders(trustedNetworks(t, "10.0.0.0/8"), req)
+
+	if v := got.Get("X-Forwarded-For"); v != "203.0.113.7" {
+		t.Errorf("want the caller's X-Forwarded-For replaced, got: %q", v)
+	}
+	if v := got.Get("X-Real-IP"); v != "203.0.113.7" {
+		t.Errorf("want X-Real-IP: 203.0.113.7, got: %q", v)
+	}
+	if v := got.Get("X-Forwarded-Proto"); v != "http" {
+		t.Errorf("want the caller's X-Forwarded-Proto replaced, got: %q", v)
+	}
+}
+
+func Test_MakeForwardedHeadersHandler_ChainedProxies(t *testing.T) {
+	trusted := trustedNetworks(t, "10.0.0.0/8", "192.168.0.0/16")
+
+	cases := []struct {
+		name         string
+		forwardedFor []string
+		wantFor      string
+		wantClient   string
+	}{
+		{
+			name:         "one proxy",
+			forwardedFor: []string{"198.51.100.20"},
+			wantFor:      "198.51.100.20, 10.0.0.5",
+			wantClient:   "198.51.100.20",
+		},
+		{
+			name:         "two trusted proxies",
+			forwardedFor: []string{"198.51.100.20, 192.168.1.1"},
+			wantFor:      "198.51.100.20, 192.168.1.1, 10.0.0.5",
+			wantClient:   "198.51.100.20",
+		},
+		{
+			// the leftmost address was set by the client, so is not believed
+			name:         "spoofed by the client",
+			forwardedFor: []string{"127.0.0.1, 198.51.100.20"},
+			wantFor:      "127.0.0.1, 198.51.100.20, 10.0.0.5",
+			wantClient:   "198.51.100.20",
+		},
+		{
+			name:         "several headers",
+			forwardedFor: []string{"198.51.100.20", "192.168.1.1"},
+			wantFor:      "198.51.100.20, 192.168.1.1, 10.0.0.5",
+			wantClient:   "198.51.100.20",
+		},
+		{
+			name:         "every address trusted",
+			forwardedFor: []string{"192.168.1.2, 192.168.1.1"},
+			wantFor:      "192.168.1.2, 192.168.1.1, 10.0.0.5",
+			wantClient:   "192.168.1.2",
+		},
+		{
+			name:       "no header",
+			wantFor:    "10.0.0.5",
+			wantClient: "10.0.0.5",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+			req.RemoteAddr = "10.0.0.5:40000"
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			req.Header.Set("X-Forwarded-Proto", "https")
+
+			got := forwardedHeaders(trusted, req)
+
+			if v := got.Get("X-Forwarded-For"); v != tc.wantFor {
+				t.Errorf("want X-Forwarded-For: %q, got: %q", tc.wantFor, v)
+			}
+			if v := got.Get("X-Real-IP"); v != tc.wantClient {
+				t.Errorf("want X-Real-IP: %q, got: %q", tc.wantClient, v)
+			}
+			if v := got.Get("X-Forwarded-Proto"); v != "https" {
+				t.Errorf("want the trusted proxy's X-Forwarded-Proto, got: %q", v)
+			}
+		})
+	}
+}
+
+func Test_MakeForwardedHeadersHandler_RecordsClientAddress(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	handler := MakeForwardedHeadersHandler(func(w http.ResponseWriter, r *http.Request) {}, trustedNetworks(t, "10.0.0.0/8"))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "gateway")
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil).WithContext(ctx)
+	req.RemoteAddr = "10.0.0.5:40000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.20")
+	handler(httptest.NewRecorder(), req)
+	span.End()
+
+	spans := recorder.Named("gateway")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	got := ""
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == semconv.ClientAddressKey {
+			got = kv.Value.AsString()
+		}
+	}
+	if got != "198.51.100.20" {
+		t.Errorf("want %s: 198.51.100.20, got: %q", semconv.ClientAddressKey, got)
+	}
+}
@@ -17,7 +17,9 @@ import (
 	"time"
 
 	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/types"
 )
 
@@ -33,7 +35,7 @@ func MakeForwardingProxyHandler(proxy *types.HTTPClientReverseProxy,
 		writeRequestURI = exists
 	}
 
-	reverseProxy := makeRewriteProxy(baseURLResolver, urlPathTransformer)
+	reverseProxy := makeRewriteProxy(proxy.Client.Transport, baseURLResolver, urlPathTransformer)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -47,7 +49,7 @@ func MakeForwardingProxyHandler(proxy *types.HTTPClientReverseProxy,
 
 		start := time.Now()
 
-		statusCode, err := forwardRequest(w, r, proxy.Client, baseURL, requestURL, proxy.Timeout, writeRequestURI, serviceAuthInjector, reverseProxy)
+		statusCode, err := forwardRequest(w, r, proxy.Client, proxy.GRPCTransport, baseURL, requestURL, proxy.Timeout, writeRequestURI, serviceAuthInjector, reverseProxy)
 		if err != nil {
 			log.Printf("error with upstream request to: %s, %s\n", requestURL, err.Error())
 		}
@@ -90,6 +92,7 @@ func buildUpstreamRequest(r *http.Request, baseURL string, requestURL string) *h
 func forwardRequest(w http.ResponseWriter,
 	r *http.Request,
 	proxyClient *http.Client,
+	grpcTransport http.RoundTripper,
 	baseURL string,
 	requestURL string,
 	timeout time.Duration,
@@ -111,6 +114,14 @@ func forwardRequest(w http.ResponseWriter,
 		log.Printf("forwardRequest: %s %s\n", upstreamReq.Host, upstreamReq.URL.String())
 	}
 
+	if isWebSocketUpgrade(r) {
+		return proxyWebSocket(w, r, upstreamReq, proxyClient.Transport)
+	}
+
+	if grpcTransport != nil && isGRPC(r) {
+		return proxyGRPC(w, r, upstreamReq, grpcTransport)
+	}
+
 	if strings.HasPrefix(r.Header.Get("Accept"), "text/event-stream") {
 
 		return handleEventStream(w, r, reverseProxy, upstreamReq, timeout)
@@ -121,8 +132,9 @@ func forwardRequest(w http.ResponseWriter,
 
 	res, err := proxyClient.Do(upstreamReq.WithContext(ctx))
 	if err != nil {
+		// the error is logged by the caller, it may name internal addresses
 		badStatus := http.StatusBadGateway
-		w.WriteHeader(badStatus)
+		httperror.Write(w, r, badStatus, "unable to reach the function")
 		return badStatus, err
 	}
 
@@ -135,12 +147,71 @@ func forwardRequest(w http.ResponseWriter,
 	w.WriteHeader(res.StatusCode)
 
 	if res.Body != nil {
-		io.Copy(w, res.Body)
+		if isStream(res) {
+			written, err := copyStream(w, res.Body)
+			tracing.SetStreamedBytes(r.Context(), written)
+			if err != nil {
+				return res.StatusCode, err
+			}
+		} else {
+			io.Copy(w, res.Body)
+		}
 	}
 
 	return res.StatusCode, nil
 }
 
+// isStream reports whether a function's response is sent as it is produced,
+// such as Server-Sent Events, so must be passed on to the caller as it
+// arrives rather than buffered
+func isStream(res *http.Response) bool {
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		return true
+	}
+
+	for _, encoding := range res.TransferEncoding {
+		if encoding == "chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// copyStream flushes each read of body to w, so that the caller sees each
+// event or chunk as soon as the function writes it
+func copyStream(w http.ResponseWriter, body io.Reader) (int64, error) {
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// send the headers before the first chunk arrives
+	flush()
+
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			wrote, writeErr := w.Write(buf[:n])
+			written += int64(wrote)
+			if writeErr != nil {
+				return written, writeErr
+			}
+			flush()
+		}
+
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
 func handleEventStream(w http.ResponseWriter, r *http.Request, reverseProxy *httputil.ReverseProxy, upstreamReq *http.Request, timeout time.Duration) (int, error) {
 	ww := fhttputil.NewHttpWriteInterceptor(w)
 
@@ -203,9 +274,12 @@ var hopHeaders = []string{
 	"Upgrade",
 }
 
-func makeRewriteProxy(baseURLResolver middleware.BaseURLResolver, urlPathTransformer middleware.URLPathTransformer) *httputil.ReverseProxy {
+// makeRewriteProxy streams responses, such as event streams, with transport
+// so that they are sent the same way as other calls to functions
+func makeRewriteProxy(transport http.RoundTripper, baseURLResolver middleware.BaseURLResolver, urlPathTransformer middleware.URLPathTransformer) *httputil.ReverseProxy {
 
 	return &httputil.ReverseProxy{
+		Transport: transport,
 
 		ErrorLog: log.New(io.Discard, "proxy:", 0),
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
@@ -217,7 +291,7 @@ func makeRewriteProxy(baseURLResolver middleware.BaseURLResolver, urlPathTransfo
 
 			requestURL := urlPathTransformer.Transform(r)
 
-			r.URL.Scheme = "http"
+			r.URL.Scheme = baseURLu.Scheme
 			r.URL.Path = requestURL
 			r.URL.Host = baseURLu.Host
 		},
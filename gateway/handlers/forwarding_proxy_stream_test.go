@@ -0,0 +1,122 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/filter"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+func Test_MakeForwardingProxyHandler_StreamsEvents(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	next := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: event-%d\n\n", i)
+			w.(http.Flusher).Flush()
+
+			// the next event is only sent once the caller has seen this one
+			select {
+			case <-next:
+			case <-time.After(5 * time.Second):
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	baseURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Minute, 10, 10)
+	handler := tracing.Middleware(MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil))
+
+	gateway := httptest.NewServer(handler)
+	defer gateway.Close()
+
+	res, err := http.Get(gateway.URL + "/function/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	events := bufio.NewScanner(res.Body)
+	for i := 0; i < 3; i++ {
+		if !events.Scan() {
+			t.Fatalf("want event %d before the stream ended, error: %v", i, events.Err())
+		}
+		if want := fmt.Sprintf("data: event-%d", i); events.Text() != want {
+			t.Fatalf("want: %q, got: %q", want, events.Text())
+		}
+		events.Scan() // blank line between events
+
+		next <- struct{}{}
+	}
+
+	// the span ends once the stream completes
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.Ended()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.StreamedBytesKey {
+			if want := int64(3 * len("data: event-0\n\n")); kv.Value.AsInt64() != want {
+				t.Errorf("want %s: %d, got: %d", tracing.StreamedBytesKey, want, kv.Value.AsInt64())
+			}
+			return
+		}
+	}
+	t.Errorf("want a %s attribute", tracing.StreamedBytesKey)
+}
+
+func Test_MakeForwardingProxyHandler_EventStreamRunsFilters(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	filters := &filter.Chain{}
+	filters.Register("deny", denyFilter{})
+
+	baseURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Minute, 10, 10)
+	proxy.Client = &http.Client{Transport: filters.Transport(proxy.Client.Transport, http.StatusForbidden)}
+	handler := MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/function/stream", nil)
+	r.Header.Set("Accept", "text/event-stream")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("want the filter's status: %d, got: %d", http.StatusForbidden, rr.Code)
+	}
+	if called {
+		t.Errorf("want the stream rejected before it reaches the function")
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/types"
 )
 
 func Test_buildUpstreamRequest_Body_Method_Query(t *testing.T) {
@@ -364,3 +368,29 @@ func Test_deleteHeaders(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_MakeForwardingProxyHandler_UnreachableFunctionHidesAddress(t *testing.T) {
+	baseURL, _ := url.Parse("http://127.0.0.1:1")
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Second, 10, 10)
+	handler := MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: baseURL.String()},
+		middleware.TransparentURLPathTransformer{}, nil)
+
+	websocket := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+	websocket.Header.Set("Connection", "Upgrade")
+	websocket.Header.Set("Upgrade", "websocket")
+
+	for name, r := range map[string]*http.Request{
+		"http":      httptest.NewRequest(http.MethodGet, "/function/echo", nil),
+		"websocket": websocket,
+	} {
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("%s: want status: %d, got: %d", name, http.StatusBadGateway, rr.Code)
+		}
+		if body := rr.Body.String(); strings.Contains(body, "127.0.0.1") || !strings.Contains(body, "unable to reach the function") {
+			t.Errorf("%s: want a message without the function's address, got: %s", name, body)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/openfaas/faas-provider/types"
+)
+
+// DeployResult is the body of a deploy or update which matched the function
+// as it is deployed, so no rollout was started
+type DeployResult struct {
+	Unchanged bool `json:"unchanged"`
+}
+
+// deployedFunction finds the function named by spec as the provider reports
+// it, false when it is not deployed or could not be read
+func deployedFunction(ctx context.Context, provider FunctionProvider, spec types.FunctionDeployment) (types.FunctionStatus, bool) {
+	fn, err := provider.Get(ctx, spec.Service, spec.Namespace)
+	if err != nil {
+		return types.FunctionStatus{}, false
+	}
+	return fn, true
+}
+
+// specChanges lists the fields of spec which differ from the function as it
+// is deployed, any of which needs a rollout. Missing and empty values are the
+// same, and the order of secrets and constraints does not matter. Only the
+// labels and annotations in spec are compared, as providers add their own,
+// so removing one alone does not count as a change.
+func specChanges(current types.FunctionStatus, spec types.FunctionDeployment) []string {
+	var changed []string
+
+	if current.Image != spec.Image {
+		changed = append(changed, "image")
+	}
+	if current.EnvProcess != spec.EnvProcess {
+		changed = append(changed, "envProcess")
+	}
+	if !sameMap(current.EnvVars, spec.EnvVars) {
+		changed = append(changed, "envVars")
+	}
+	if !sameSet(current.Secrets, spec.Secrets) {
+		changed = append(changed, "secrets")
+	}
+	if !sameSet(current.Constraints, spec.Constraints) {
+		changed = append(changed, "constraints")
+	}
+	if !containsMap(derefMap(current.Labels), derefMap(spec.Labels)) {
+		changed = append(changed, "labels")
+	}
+	if !containsMap(derefMap(current.Annotations), derefMap(spec.Annotations)) {
+		changed = append(changed, "annotations")
+	}
+	if derefResources(current.Limits) != derefResources(spec.Limits) {
+		changed = append(changed, "limits")
+	}
+	if derefResources(current.Requests) != derefResources(spec.Requests) {
+		changed = append(changed, "requests")
+	}
+	if current.ReadOnlyRootFilesystem != spec.ReadOnlyRootFilesystem {
+		changed = append(changed, "readOnlyRootFilesystem")
+	}
+
+	return changed
+}
+
+func sameMap(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// containsMap is true when every key in want has the same value in got
+func containsMap(got, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := got[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
+
+func derefMap(m *map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	return *m
+}
+
+func derefResources(r *types.FunctionResources) types.FunctionResources {
+	if r == nil {
+		return types.FunctionResources{}
+	}
+	return *r
+}
@@ -0,0 +1,131 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// FunctionImages caches the image each function was deployed with. An image
+// only changes when its function is deployed again, so it is kept for ttl,
+// or until Invalidate is called, rather than being looked up on each call.
+type FunctionImages struct {
+	functionQuery scaling.FunctionQuery
+	ttl           time.Duration
+
+	lock   sync.RWMutex
+	images map[string]cachedImage
+}
+
+type cachedImage struct {
+	image   string
+	expires time.Time
+}
+
+// NewFunctionImages creates a FunctionImages which looks up images with
+// functionQuery
+func NewFunctionImages(functionQuery scaling.FunctionQuery, ttl time.Duration) *FunctionImages {
+	return &FunctionImages{
+		functionQuery: functionQuery,
+		ttl:           ttl,
+		images:        map[string]cachedImage{},
+	}
+}
+
+// Get returns the image of the function, from the cache when it has not
+// expired. Failed lookups are not cached.
+func (f *FunctionImages) Get(functionName, namespace string) (string, bool) {
+	key := functionName + "." + namespace
+
+	f.lock.RLock()
+	cached, ok := f.images[key]
+	f.lock.RUnlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.image, true
+	}
+
+	res, err := f.functionQuery.Get(functionName, namespace)
+	if err != nil || len(res.Image) == 0 {
+		return "", false
+	}
+
+	f.lock.Lock()
+	f.images[key] = cachedImage{image: res.Image, expires: time.Now().Add(f.ttl)}
+	f.lock.Unlock()
+
+	return res.Image, true
+}
+
+// Invalidate forgets the image of the function, so that it is looked up
+// again on the next call
+func (f *FunctionImages) Invalidate(functionName, namespace string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.images, functionName+"."+namespace)
+}
+
+// MakeFunctionImageHandler records the image of the function being called,
+// and its version, on the span as faas.image and faas.version.
+func MakeFunctionImageHandler(next http.HandlerFunc, images *FunctionImages, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.Path))
+
+		if image, ok := images.Get(functionName, namespace); ok {
+			tracing.SetImage(r.Context(), image, imageVersion(image))
+		}
+
+		next(w, r)
+	}
+}
+
+// imageVersion is the digest of an image, or else its tag, i.e. 0.1.0 for
+// ghcr.io/openfaas/figlet:0.1.0. It is blank when the image has neither.
+func imageVersion(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+
+	// a colon before the last slash separates the registry's port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// InvalidateImagesOnDeploy wraps provider to invalidate the cached image of
+// each function which is deployed, updated or deleted through it.
+func InvalidateImagesOnDeploy(provider FunctionProvider, images *FunctionImages) FunctionProvider {
+	return &invalidatingProvider{FunctionProvider: provider, images: images}
+}
+
+type invalidatingProvider struct {
+	FunctionProvider
+	images *FunctionImages
+}
+
+func (p *invalidatingProvider) Deploy(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	defer p.images.Invalidate(spec.Service, spec.Namespace)
+	return p.FunctionProvider.Deploy(ctx, spec)
+}
+
+func (p *invalidatingProvider) Update(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	defer p.images.Invalidate(spec.Service, spec.Namespace)
+	return p.FunctionProvider.Update(ctx, spec)
+}
+
+func (p *invalidatingProvider) Delete(ctx context.Context, functionName, namespace string) (*ProviderResponse, error) {
+	defer p.images.Invalidate(functionName, namespace)
+	return p.FunctionProvider.Delete(ctx, functionName, namespace)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// imageQuery returns the current image of a function, and counts lookups
+type imageQuery struct {
+	lock    sync.Mutex
+	image   string
+	lookups int
+}
+
+func (q *imageQuery) Get(name, namespace string) (scaling.ServiceQueryResponse, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.lookups++
+	return scaling.ServiceQueryResponse{Image: q.image}, nil
+}
+
+func (q *imageQuery) GetAnnotations(name, namespace string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (q *imageQuery) deploy(image string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.image = image
+}
+
+// spanImage calls the function and returns the faas.image and faas.version
+// recorded on its span
+func spanImage(t *testing.T, recorder *tracetest.Recorder, handler http.HandlerFunc) (string, string) {
+	t.Helper()
+
+	before := len(recorder.Ended())
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != before+1 {
+		t.Fatalf("want 1 new span, got: %d", len(spans)-before)
+	}
+
+	image, version := "", ""
+	for _, attr := range spans[len(spans)-1].Attributes() {
+		switch attr.Key {
+		case tracing.ImageKey:
+			image = attr.Value.AsString()
+		case tracing.VersionKey:
+			version = attr.Value.AsString()
+		}
+	}
+	return image, version
+}
+
+func Test_MakeFunctionImageHandler_RecordsCachedImage(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	query := &imageQuery{image: "ghcr.io/openfaas/figlet:0.1.0"}
+	images := NewFunctionImages(query, time.Minute)
+	handler := tracing.Middleware(MakeFunctionImageHandler(func(w http.ResponseWriter, r *http.Request) {}, images, "openfaas-fn"))
+
+	image, version := spanImage(t, recorder, handler)
+	if image != "ghcr.io/openfaas/figlet:0.1.0" || version != "0.1.0" {
+		t.Errorf("want image: ghcr.io/openfaas/figlet:0.1.0 and version: 0.1.0, got: %q and %q", image, version)
+	}
+
+	// deployed without going through the gateway, so the cached image is kept
+	query.deploy("ghcr.io/openfaas/figlet:0.2.0")
+
+	image, version = spanImage(t, recorder, handler)
+	if image != "ghcr.io/openfaas/figlet:0.1.0" || version != "0.1.0" {
+		t.Errorf("want the cached image: ghcr.io/openfaas/figlet:0.1.0 and version: 0.1.0, got: %q and %q", image, version)
+	}
+	if query.lookups != 1 {
+		t.Errorf("want 1 lookup, got: %d", query.lookups)
+	}
+}
+
+func Test_MakeFunctionImageHandler_InvalidatedOnDeploy(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	query := &imageQuery{image: "ghcr.io/openfaas/figlet:0.1.0"}
+	images := NewFunctionImages(query, time.Minute)
+	handler := tracing.Middleware(MakeFunctionImageHandler(func(w http.ResponseWriter, r *http.Request) {}, images, "openfaas-fn"))
+
+	spanImage(t, recorder, handler)
+
+	provider := InvalidateImagesOnDeploy(newMockFunctionProvider(), images)
+	query.deploy("ghcr.io/openfaas/figlet:0.2.0")
+	if _, err := provider.Deploy(context.Background(), types.FunctionDeployment{Service: "figlet", Namespace: "openfaas-fn", Image: "ghcr.io/openfaas/figlet:0.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	image, version := spanImage(t, recorder, handler)
+	if image != "ghcr.io/openfaas/figlet:0.2.0" || version != "0.2.0" {
+		t.Errorf("want the deployed image: ghcr.io/openfaas/figlet:0.2.0 and version: 0.2.0, got: %q and %q", image, version)
+	}
+	if query.lookups != 2 {
+		t.Errorf("want 2 lookups, got: %d", query.lookups)
+	}
+}
+
+func Test_FunctionImages_ExpiresAfterTTL(t *testing.T) {
+	query := &imageQuery{image: "figlet:0.1.0"}
+	images := NewFunctionImages(query, time.Millisecond)
+
+	images.Get("figlet", "openfaas-fn")
+	query.deploy("figlet:0.2.0")
+	time.Sleep(5 * time.Millisecond)
+
+	if image, _ := images.Get("figlet", "openfaas-fn"); image != "figlet:0.2.0" {
+		t.Errorf("want image looked up again once expired, got: %q", image)
+	}
+}
+
+func Test_FunctionImages_LookupFailed(t *testing.T) {
+	images := NewFunctionImages(fakeFunctionQuery{err: errors.New("function not found")}, time.Minute)
+
+	if image, ok := images.Get("figlet", "openfaas-fn"); ok {
+		t.Errorf("want no image, got: %q", image)
+	}
+}
+
+func Test_imageVersion(t *testing.T) {
+	cases := map[string]string{
+		"ghcr.io/openfaas/figlet:0.1.0":         "0.1.0",
+		"figlet":                                "",
+		"localhost:5000/figlet":                 "",
+		"localhost:5000/figlet:latest":          "latest",
+		"ghcr.io/openfaas/figlet@sha256:abc123": "sha256:abc123",
+	}
+
+	for image, want := range cases {
+		if got := imageVersion(image); got != want {
+			t.Errorf("%s: want version: %q, got: %q", image, want, got)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FunctionProvider deploys, updates, lists and deletes functions on the
+// faas-provider. Each call made for a client returns the provider's
+// response, so that it is passed on as the provider sent it.
+type FunctionProvider interface {
+	Deploy(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error)
+	Update(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error)
+	Delete(ctx context.Context, functionName, namespace string) (*ProviderResponse, error)
+
+	// List decodes the functions in namespace, for the gateway's own use
+	List(ctx context.Context, namespace string) ([]types.FunctionStatus, error)
+
+	// Get decodes the function named functionName in namespace, for the
+	// gateway's own use
+	Get(ctx context.Context, functionName, namespace string) (types.FunctionStatus, error)
+
+	// ListResponse lists the functions in namespace for a client
+	ListResponse(ctx context.Context, namespace string) (*ProviderResponse, error)
+}
+
+// ProviderResponse is the provider's reply to a request which it accepted
+type ProviderResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// writeProviderResponse passes on the provider's status and body, or
+// responds with status when the provider sent no response of its own
+func writeProviderResponse(w http.ResponseWriter, res *ProviderResponse, status int) {
+	if res == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	if len(res.ContentType) > 0 {
+		w.Header().Set("Content-Type", res.ContentType)
+	}
+	w.WriteHeader(res.StatusCode)
+	w.Write(res.Body)
+}
+
+// ProviderError is returned by a FunctionProvider when the provider rejected
+// a request, so that its status can be passed on to the caller
+type ProviderError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// FieldError describes why one field of a function spec is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	functionNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	envNamePattern      = regexp.MustCompile(`^[-._a-zA-Z][-._a-zA-Z0-9]*$`)
+	memoryPattern       = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(Ki|Mi|Gi|Ti|k|K|M|G|T|m)?$`)
+	cpuPattern          = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?m?$`)
+)
+
+// maxFunctionNameLength is the longest DNS label, which functions are named by
+const maxFunctionNameLength = 63
+
+// MakeFunctionsHandler serves /system/functions: POST deploys a function,
+// PUT updates it, GET lists the functions in the namespace query parameter
+// and DELETE removes a function. Specs are validated before they are passed
+// to the provider, whose status and body are passed back to the caller, and
+// each operation is recorded in its own span. A deploy
+// or update which matches the function as it is deployed is not passed on,
+// so that it does not start a rollout, and responds 200 with
+// {"unchanged": true}. The
+// namespace comes from the body, the namespace query parameter, or is
+// defaultNamespace.
+func MakeFunctionsHandler(provider FunctionProvider, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listFunctions(w, r, provider, defaultNamespace)
+		case http.MethodPost:
+			deployFunction(w, r, provider, "deploy", provider.Deploy, defaultNamespace)
+		case http.MethodPut:
+			deployFunction(w, r, provider, "update", provider.Update, defaultNamespace)
+		case http.MethodDelete:
+			deleteFunction(w, r, provider, defaultNamespace)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func listFunctions(w http.ResponseWriter, r *http.Request, provider FunctionProvider, defaultNamespace string) {
+	namespace := requestNamespace(r, "", defaultNamespace)
+
+	ctx, span := startFunctionSpan(r.Context(), "list functions", tracing.NamespaceKey.String(namespace))
+	defer span.End()
+
+	if err := middleware.ValidateNamespace(namespace); err != nil {
+		span.SetStatus(codes.Error, "invalid namespace")
+		writeValidationErrors(w, r, []FieldError{{Field: "namespace", Message: err.Error()}})
+		return
+	}
+
+	res, err := provider.ListResponse(ctx, namespace)
+	if err != nil {
+		writeProviderError(w, r, span, err)
+		return
+	}
+
+	writeProviderResponse(w, res, http.StatusOK)
+}
+
+func deployFunction(w http.ResponseWriter, r *http.Request, provider FunctionProvider, operation string, apply func(context.Context, types.FunctionDeployment) (*ProviderResponse, error), defaultNamespace string) {
+	spec := types.FunctionDeployment{}
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeValidationErrors(w, r, []FieldError{{Message: fmt.Sprintf("unable to parse the function spec: %s", err)}})
+		return
+	}
+
+	spec.Namespace = requestNamespace(r, spec.Namespace, defaultNamespace)
+
+	ctx, span := startFunctionSpan(r.Context(), operation+" "+spec.Service,
+		tracing.FunctionNameKey.String(spec.Service),
+		tracing.NamespaceKey.String(spec.Namespace),
+	)
+	defer span.End()
+
+	if errs := ValidateFunctionDeployment(spec); len(errs) > 0 {
+		span.SetStatus(codes.Error, "invalid function spec")
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if current, ok := deployedFunction(ctx, provider, spec); ok {
+		changed := specChanges(current, spec)
+		if len(changed) == 0 {
+			span.SetAttributes(tracing.RolloutKey.Bool(false))
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(DeployResult{Unchanged: true})
+			return
+		}
+		span.SetAttributes(tracing.ChangedFieldsKey.StringSlice(changed))
+	}
+
+	res, err := apply(ctx, spec)
+	if err != nil {
+		writeProviderError(w, r, span, err)
+		return
+	}
+
+	span.SetAttributes(tracing.RolloutKey.Bool(true))
+	writeProviderResponse(w, res, http.StatusAccepted)
+}
+
+func deleteFunction(w http.ResponseWriter, r *http.Request, provider FunctionProvider, defaultNamespace string) {
+	req := types.DeleteFunctionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeValidationErrors(w, r, []FieldError{{Message: fmt.Sprintf("unable to parse the request: %s", err)}})
+		return
+	}
+
+	req.Namespace = requestNamespace(r, req.Namespace, defaultNamespace)
+
+	ctx, span := startFunctionSpan(r.Context(), "delete "+req.FunctionName,
+		tracing.FunctionNameKey.String(req.FunctionName),
+		tracing.NamespaceKey.String(req.Namespace),
+	)
+	defer span.End()
+
+	var errs []FieldError
+	if len(req.FunctionName) == 0 {
+		errs = append(errs, FieldError{Field: "functionName", Message: "is required"})
+	}
+	if err := middleware.ValidateNamespace(req.Namespace); err != nil {
+		errs = append(errs, FieldError{Field: "namespace", Message: err.Error()})
+	}
+	if len(errs) > 0 {
+		span.SetStatus(codes.Error, "invalid request")
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	res, err := provider.Delete(ctx, req.FunctionName, req.Namespace)
+	if err != nil {
+		writeProviderError(w, r, span, err)
+		return
+	}
+
+	writeProviderResponse(w, res, http.StatusAccepted)
+}
+
+// requestNamespace picks the namespace given in the body, then the query,
+// then the default
+func requestNamespace(r *http.Request, namespace, defaultNamespace string) string {
+	if len(namespace) > 0 {
+		return namespace
+	}
+	if queryNamespace := r.URL.Query().Get(NamespaceQueryParam); len(queryNamespace) > 0 {
+		return queryNamespace
+	}
+	return defaultNamespace
+}
+
+func startFunctionSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracing.TracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// ValidateFunctionDeployment checks the fields of a spec which would be
+// rejected by the provider, or fail at deploy time, so that the caller gets
+// every problem at once
+func ValidateFunctionDeployment(spec types.FunctionDeployment) []FieldError {
+	var errs []FieldError
+
+	switch {
+	case len(spec.Service) == 0:
+		errs = append(errs, FieldError{Field: "service", Message: "is required"})
+	case len(spec.Service) > maxFunctionNameLength:
+		errs = append(errs, FieldError{Field: "service", Message: fmt.Sprintf("must be no more than %d characters", maxFunctionNameLength)})
+	case !functionNamePattern.MatchString(spec.Service):
+		errs = append(errs, FieldError{Field: "service", Message: "must contain only lowercase letters, numbers and '-', and start and end with a letter or number"})
+	}
+
+	if err := middleware.ValidateNamespace(spec.Namespace); err != nil {
+		errs = append(errs, FieldError{Field: "namespace", Message: err.Error()})
+	}
+
+	switch {
+	case len(spec.Image) == 0:
+		errs = append(errs, FieldError{Field: "image", Message: "is required"})
+	case strings.ContainsAny(spec.Image, " \t\r\n"):
+		errs = append(errs, FieldError{Field: "image", Message: "must not contain whitespace"})
+	}
+
+	for _, name := range sortedKeys(spec.EnvVars) {
+		if !envNamePattern.MatchString(name) {
+			errs = append(errs, FieldError{Field: "envVars." + name, Message: "is not a valid environment variable name"})
+		}
+	}
+
+	if spec.Labels != nil {
+		for _, name := range sortedKeys(*spec.Labels) {
+			if len(strings.TrimSpace(name)) == 0 {
+				errs = append(errs, FieldError{Field: "labels", Message: "names must not be empty"})
+			}
+		}
+	}
+
+	// secrets are referenced by name, the provider mounts them into the function
+	for i, name := range spec.Secrets {
+		errs = append(errs, validateSecretName(fmt.Sprintf("secrets[%d]", i), name)...)
+	}
+
+	errs = append(errs, validateResources("requests", spec.Requests)...)
+	errs = append(errs, validateResources("limits", spec.Limits)...)
+
+	return errs
+}
+
+func validateResources(field string, resources *types.FunctionResources) []FieldError {
+	if resources == nil {
+		return nil
+	}
+
+	var errs []FieldError
+	if len(resources.Memory) > 0 && !memoryPattern.MatchString(resources.Memory) {
+		errs = append(errs, FieldError{Field: field + ".memory", Message: "must be a quantity such as 128Mi or 1G"})
+	}
+	if len(resources.CPU) > 0 && !cpuPattern.MatchString(resources.CPU) {
+		errs = append(errs, FieldError{Field: field + ".cpu", Message: "must be a quantity such as 100m or 0.5"})
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeValidationErrors responds with a 400, and the invalid fields as the
+// details of the error
+func writeValidationErrors(w http.ResponseWriter, r *http.Request, errs []FieldError) {
+	httperror.WriteDetail(w, r, http.StatusBadRequest, httperror.Detail{
+		Code:    "invalid_request",
+		Message: "invalid function spec",
+		Details: errs,
+	})
+}
+
+// writeProviderError passes on the status of a request rejected by the
+// provider, any other error means the provider could not be reached
+func writeProviderError(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		httperror.Write(w, r, providerErr.StatusCode, providerErr.Message)
+		return
+	}
+
+	httperror.Write(w, r, http.StatusBadGateway, err.Error())
+}
@@ -0,0 +1,485 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+// mockFunctionProvider keeps deployed functions in memory
+type mockFunctionProvider struct {
+	functions map[string]types.FunctionDeployment
+	err       error
+
+	// added to the labels and annotations of every function
+	added map[string]string
+}
+
+func newMockFunctionProvider() *mockFunctionProvider {
+	return &mockFunctionProvider{functions: map[string]types.FunctionDeployment{}}
+}
+
+func (p *mockFunctionProvider) Deploy(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if _, ok := p.functions[spec.Service]; ok {
+		return nil, &ProviderError{StatusCode: http.StatusConflict, Message: "function already exists"}
+	}
+	p.functions[spec.Service] = spec
+	return nil, nil
+}
+
+func (p *mockFunctionProvider) Update(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	if _, ok := p.functions[spec.Service]; !ok {
+		return nil, &ProviderError{StatusCode: http.StatusNotFound, Message: "function not found"}
+	}
+	p.functions[spec.Service] = spec
+	return nil, nil
+}
+
+func (p *mockFunctionProvider) List(ctx context.Context, namespace string) ([]types.FunctionStatus, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var list []types.FunctionStatus
+	for _, spec := range p.functions {
+		list = append(list, p.status(spec))
+	}
+	return list, nil
+}
+
+func (p *mockFunctionProvider) Get(ctx context.Context, functionName, namespace string) (types.FunctionStatus, error) {
+	if p.err != nil {
+		return types.FunctionStatus{}, p.err
+	}
+
+	spec, ok := p.functions[functionName]
+	if !ok {
+		return types.FunctionStatus{}, &ProviderError{StatusCode: http.StatusNotFound, Message: "function not found"}
+	}
+	return p.status(spec), nil
+}
+
+// status is spec as the provider reports it, with its own labels and
+// annotations added
+func (p *mockFunctionProvider) status(spec types.FunctionDeployment) types.FunctionStatus {
+	labels := derefMap(spec.Labels)
+	annotations := derefMap(spec.Annotations)
+	if len(p.added) > 0 {
+		labels = withKeys(labels, p.added)
+		annotations = withKeys(annotations, p.added)
+	}
+
+	return types.FunctionStatus{
+		Name:        spec.Service,
+		Image:       spec.Image,
+		Namespace:   spec.Namespace,
+		EnvProcess:  spec.EnvProcess,
+		EnvVars:     spec.EnvVars,
+		Secrets:     spec.Secrets,
+		Constraints: spec.Constraints,
+		Labels:      &labels,
+		Annotations: &annotations,
+		Limits:      spec.Limits,
+		Requests:    spec.Requests,
+	}
+}
+
+func withKeys(m, added map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range added {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (p *mockFunctionProvider) ListResponse(ctx context.Context, namespace string) (*ProviderResponse, error) {
+	functions, err := p.List(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if functions == nil {
+		functions = []types.FunctionStatus{}
+	}
+
+	body, _ := json.Marshal(functions)
+	return &ProviderResponse{StatusCode: http.StatusOK, ContentType: "application/json", Body: body}, nil
+}
+
+func (p *mockFunctionProvider) Delete(ctx context.Context, functionName, namespace string) (*ProviderResponse, error) {
+	if _, ok := p.functions[functionName]; !ok {
+		return nil, &ProviderError{StatusCode: http.StatusNotFound, Message: "function not found"}
+	}
+	delete(p.functions, functionName)
+	return nil, nil
+}
+
+func callFunctionsHandler(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/system/functions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_MakeFunctionsHandler_CRUD(t *testing.T) {
+	provider := newMockFunctionProvider()
+	handler := MakeFunctionsHandler(provider, "")
+
+	rr := callFunctionsHandler(handler, http.MethodPost, `{"service":"figlet","image":"ghcr.io/openfaas/figlet:latest"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("deploy: want status: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodPost, `{"service":"figlet","image":"ghcr.io/openfaas/figlet:latest"}`)
+	if rr.Code != http.StatusOK {
+		t.Errorf("deploy again: want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodPost, `{"service":"figlet","image":"ghcr.io/openfaas/figlet:0.1"}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("deploy again with a new image: want status: %d, got: %d", http.StatusConflict, rr.Code)
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodPut, `{"service":"figlet","image":"ghcr.io/openfaas/figlet:0.2"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("update: want status: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodGet, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list: want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+	functions := []types.FunctionStatus{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &functions); err != nil {
+		t.Fatal(err)
+	}
+	if len(functions) != 1 || functions[0].Image != "ghcr.io/openfaas/figlet:0.2" {
+		t.Errorf("list: want the updated figlet function, got: %+v", functions)
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodDelete, `{"functionName":"figlet"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("delete: want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodGet, "")
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("list: want an empty list, got: %s", rr.Body.String())
+	}
+
+	rr = callFunctionsHandler(handler, http.MethodDelete, `{"functionName":"figlet"}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("delete again: want status: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// passthroughProvider responds to every call as the provider would
+type passthroughProvider struct {
+	mockFunctionProvider
+	res *ProviderResponse
+}
+
+func (p *passthroughProvider) Deploy(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	return p.res, nil
+}
+
+func (p *passthroughProvider) ListResponse(ctx context.Context, namespace string) (*ProviderResponse, error) {
+	return p.res, nil
+}
+
+func (p *passthroughProvider) Delete(ctx context.Context, functionName, namespace string) (*ProviderResponse, error) {
+	return p.res, nil
+}
+
+func Test_MakeFunctionsHandler_PassesOnProviderResponse(t *testing.T) {
+	provider := &passthroughProvider{
+		mockFunctionProvider: *newMockFunctionProvider(),
+		res:                  &ProviderResponse{StatusCode: http.StatusCreated, ContentType: "application/json", Body: []byte(`[{"name":"figlet","extra":"kept"}]`)},
+	}
+	handler := MakeFunctionsHandler(provider, "")
+
+	for _, call := range []struct{ method, body string }{
+		{http.MethodPost, `{"service":"figlet","image":"figlet"}`},
+		{http.MethodGet, ""},
+		{http.MethodDelete, `{"functionName":"figlet"}`},
+	} {
+		rr := callFunctionsHandler(handler, call.method, call.body)
+		if rr.Code != http.StatusCreated {
+			t.Errorf("%s: want the provider's status: %d, got: %d", call.method, http.StatusCreated, rr.Code)
+		}
+		if rr.Header().Get("Content-Type") != "application/json" || rr.Body.String() != string(provider.res.Body) {
+			t.Errorf("%s: want the provider's body as it was sent, got: %q %s", call.method, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+	}
+}
+
+func Test_MakeFunctionsHandler_InvalidSpec(t *testing.T) {
+	provider := newMockFunctionProvider()
+	handler := MakeFunctionsHandler(provider, "")
+
+	rr := callFunctionsHandler(handler, http.MethodPost, `{"service":"Figlet_1","envVars":{"1BAD":"x"},"requests":{"memory":"lots"}}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("want status: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+
+	res := struct {
+		Error struct {
+			Code    string       `json:"code"`
+			Details []FieldError `json:"details"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error.Code != "invalid_request" {
+		t.Errorf("want code: invalid_request, got: %q", res.Error.Code)
+	}
+
+	want := []string{"service", "image", "envVars.1BAD", "requests.memory"}
+	if len(res.Error.Details) != len(want) {
+		t.Fatalf("want %d errors, got: %+v", len(want), res.Error.Details)
+	}
+	for i, field := range want {
+		if res.Error.Details[i].Field != field {
+			t.Errorf("error %d: want field: %q, got: %q", i, field, res.Error.Details[i].Field)
+		}
+	}
+
+	if len(provider.functions) != 0 {
+		t.Errorf("want the invalid spec not to reach the provider")
+	}
+}
+
+func Test_MakeFunctionsHandler_MalformedJSON(t *testing.T) {
+	handler := MakeFunctionsHandler(newMockFunctionProvider(), "")
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		rr := callFunctionsHandler(handler, method, `{"service":`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status: %d, got: %d", method, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	rr := callFunctionsHandler(handler, http.MethodDelete, `{}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"functionName"`) {
+		t.Errorf("delete: want a functionName error, got: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func Test_MakeFunctionsHandler_DefaultNamespace(t *testing.T) {
+	provider := newMockFunctionProvider()
+	handler := MakeFunctionsHandler(provider, "tenant-a")
+
+	rr := callFunctionsHandler(handler, http.MethodPost, `{"service":"figlet","image":"figlet"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("want status: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	if got := provider.functions["figlet"].Namespace; got != "tenant-a" {
+		t.Errorf("want the default namespace: tenant-a, got: %q", got)
+	}
+
+	callFunctionsHandler(handler, http.MethodPost, `{"service":"env","image":"env","namespace":"tenant-b"}`)
+	if got := provider.functions["env"].Namespace; got != "tenant-b" {
+		t.Errorf("want the namespace in the spec kept: tenant-b, got: %q", got)
+	}
+}
+
+func Test_MakeFunctionsHandler_ProviderUnavailable(t *testing.T) {
+	provider := newMockFunctionProvider()
+	provider.err = context.DeadlineExceeded
+
+	rr := callFunctionsHandler(MakeFunctionsHandler(provider, ""), http.MethodGet, "")
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("want status: %d, got: %d", http.StatusBadGateway, rr.Code)
+	}
+	if v := rr.Header().Get("Content-Type"); v != "application/json" || !strings.Contains(rr.Body.String(), `"code":"bad_gateway"`) {
+		t.Errorf("want a JSON error, got: %q %s", v, rr.Body.String())
+	}
+}
+
+func Test_MakeFunctionsHandler_TracesFunctionName(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	callFunctionsHandler(MakeFunctionsHandler(newMockFunctionProvider(), ""), http.MethodPost, `{"service":"figlet","image":"figlet"}`)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if spans[0].Name() != "deploy figlet" {
+		t.Errorf("want span name: %q, got: %q", "deploy figlet", spans[0].Name())
+	}
+
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.FunctionNameKey {
+			if kv.Value.AsString() != "figlet" {
+				t.Errorf("want %s: figlet, got: %s", tracing.FunctionNameKey, kv.Value.AsString())
+			}
+			return
+		}
+	}
+	t.Errorf("want a %s attribute", tracing.FunctionNameKey)
+}
+
+func Test_MakeFunctionsHandler_UpdateOnlyRollsOutChanges(t *testing.T) {
+	deployed := `{"service":"figlet","image":"figlet:0.1","envVars":{"mode":"fast"},"secrets":["a","b"],"limits":{"memory":"128Mi"}}`
+
+	cases := []struct {
+		name        string
+		spec        string
+		wantStatus  int
+		wantRollout bool
+		wantChanged []string
+	}{
+		{
+			name:       "identical",
+			spec:       `{"service":"figlet","image":"figlet:0.1","envVars":{"mode":"fast"},"secrets":["b","a"],"limits":{"memory":"128Mi"}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "image changed",
+			spec:        `{"service":"figlet","image":"figlet:0.2","envVars":{"mode":"fast"},"secrets":["a","b"],"limits":{"memory":"128Mi"}}`,
+			wantStatus:  http.StatusAccepted,
+			wantRollout: true,
+			wantChanged: []string{"image"},
+		},
+		{
+			name:        "env changed",
+			spec:        `{"service":"figlet","image":"figlet:0.1","envVars":{"mode":"slow"},"secrets":["a","b"],"limits":{"memory":"128Mi"}}`,
+			wantStatus:  http.StatusAccepted,
+			wantRollout: true,
+			wantChanged: []string{"envVars"},
+		},
+		{
+			name:        "label added",
+			spec:        `{"service":"figlet","image":"figlet:0.1","envVars":{"mode":"fast"},"secrets":["a","b"],"limits":{"memory":"128Mi"},"labels":{"team":"blue"}}`,
+			wantStatus:  http.StatusAccepted,
+			wantRollout: true,
+			wantChanged: []string{"labels"},
+		},
+		{
+			name:        "provider label changed",
+			spec:        `{"service":"figlet","image":"figlet:0.1","envVars":{"mode":"fast"},"secrets":["a","b"],"limits":{"memory":"128Mi"},"annotations":{"faas_function":"other"}}`,
+			wantStatus:  http.StatusAccepted,
+			wantRollout: true,
+			wantChanged: []string{"annotations"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newMockFunctionProvider()
+			// labels and annotations the provider adds are not in the spec
+			provider.added = map[string]string{"faas_function": "figlet"}
+			handler := MakeFunctionsHandler(provider, "")
+			if rr := callFunctionsHandler(handler, http.MethodPost, deployed); rr.Code != http.StatusAccepted {
+				t.Fatalf("deploy: want status: %d, got: %d", http.StatusAccepted, rr.Code)
+			}
+
+			recorder, teardown := tracetest.Install()
+			defer teardown()
+
+			rr := callFunctionsHandler(handler, http.MethodPut, tc.spec)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want status: %d, got: %d, body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if !tc.wantRollout {
+				result := DeployResult{}
+				if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || !result.Unchanged {
+					t.Errorf("want unchanged: true, got: %s", rr.Body.String())
+				}
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got: %d", len(spans))
+			}
+
+			var rollout, found bool
+			var changed []string
+			for _, kv := range spans[0].Attributes() {
+				switch kv.Key {
+				case tracing.RolloutKey:
+					rollout, found = kv.Value.AsBool(), true
+				case tracing.ChangedFieldsKey:
+					changed = kv.Value.AsStringSlice()
+				}
+			}
+			if !found || rollout != tc.wantRollout {
+				t.Errorf("want %s: %t, got: %t (set: %t)", tracing.RolloutKey, tc.wantRollout, rollout, found)
+			}
+			if strings.Join(changed, ",") != strings.Join(tc.wantChanged, ",") {
+				t.Errorf("want %s: %v, got: %v", tracing.ChangedFieldsKey, tc.wantChanged, changed)
+			}
+		})
+	}
+}
+
+// unlistedProvider can only look up functions by name
+type unlistedProvider struct {
+	mockFunctionProvider
+}
+
+func (p *unlistedProvider) List(ctx context.Context, namespace string) ([]types.FunctionStatus, error) {
+	return nil, errors.New("want the function looked up by name")
+}
+
+func Test_MakeFunctionsHandler_UpdateLooksUpFunctionByName(t *testing.T) {
+	provider := &unlistedProvider{mockFunctionProvider: *newMockFunctionProvider()}
+	handler := MakeFunctionsHandler(provider, "")
+
+	spec := `{"service":"figlet","image":"figlet:0.1"}`
+	if rr := callFunctionsHandler(handler, http.MethodPost, spec); rr.Code != http.StatusAccepted {
+		t.Fatalf("deploy: want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+	if rr := callFunctionsHandler(handler, http.MethodPut, spec); rr.Code != http.StatusOK {
+		t.Errorf("update: want status: %d for an unchanged spec, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func Test_ValidateFunctionDeployment(t *testing.T) {
+	valid := types.FunctionDeployment{
+		Service:  "nodeinfo",
+		Image:    "ghcr.io/openfaas/nodeinfo:latest",
+		EnvVars:  map[string]string{"write_debug": "true"},
+		Labels:   &map[string]string{"com.openfaas.scale.min": "1"},
+		Requests: &types.FunctionResources{Memory: "128Mi", CPU: "100m"},
+		Limits:   &types.FunctionResources{Memory: "1G", CPU: "0.5"},
+	}
+	if errs := ValidateFunctionDeployment(valid); len(errs) > 0 {
+		t.Errorf("want no errors, got: %+v", errs)
+	}
+
+	cases := map[string]types.FunctionDeployment{
+		"service":      {Service: strings.Repeat("a", 64), Image: "figlet"},
+		"image":        {Service: "figlet", Image: "figlet latest"},
+		"labels":       {Service: "figlet", Image: "figlet", Labels: &map[string]string{" ": "x"}},
+		"limits.cpu":   {Service: "figlet", Image: "figlet", Limits: &types.FunctionResources{CPU: "two"}},
+		"envVars.A=B":  {Service: "figlet", Image: "figlet", EnvVars: map[string]string{"A=B": "x"}},
+		"requests.cpu": {Service: "figlet", Image: "figlet", Requests: &types.FunctionResources{CPU: "-1"}},
+	}
+	for field, spec := range cases {
+		errs := ValidateFunctionDeployment(spec)
+		if len(errs) != 1 || errs[0].Field != field {
+			t.Errorf("want an error for %s, got: %+v", field, errs)
+		}
+	}
+}
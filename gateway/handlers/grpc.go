@@ -0,0 +1,84 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// isGRPC reports whether the caller is making a gRPC call, which must be
+// sent to the function over HTTP/2
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// proxyGRPC sends a gRPC call to the function with transport, and streams
+// messages in both directions until the call completes. The function's
+// trailers are passed on after the last message, so the caller receives the
+// grpc-status and any trailing metadata it sent.
+func proxyGRPC(w http.ResponseWriter, r *http.Request, upstreamReq *http.Request, transport http.RoundTripper) (int, error) {
+	// TE is hop-by-hop, so was removed from upstreamReq, but gRPC servers
+	// expect to be told that trailers are supported
+	upstreamReq.Header.Set("Te", "trailers")
+
+	res, err := transport.RoundTrip(upstreamReq.WithContext(r.Context()))
+	if err != nil {
+		writeGRPCError(w, codes.Unavailable, "unable to reach the function")
+		// counted as a 502, while the caller is sent the gRPC status
+		return http.StatusBadGateway, err
+	}
+	defer res.Body.Close()
+
+	copyHeaders(w.Header(), &res.Header)
+	w.WriteHeader(res.StatusCode)
+
+	// a trailers-only response, with the grpc-status in its headers, is
+	// sent as it is, since flushing the headers would end the call without
+	// a status
+	if len(res.Header.Get("Grpc-Status")) > 0 {
+		return res.StatusCode, nil
+	}
+
+	if _, err := copyStream(w, res.Body); err != nil {
+		return res.StatusCode, err
+	}
+
+	// res.Trailer is filled in once the body has been read to the end
+	for k, values := range res.Trailer {
+		for _, v := range values {
+			w.Header().Add(http.TrailerPrefix+k, v)
+		}
+	}
+
+	return res.StatusCode, nil
+}
+
+// writeGRPCError responds with a trailers-only gRPC response, so that the
+// caller's client reports code and message rather than a transport error
+func writeGRPCError(w http.ResponseWriter, code codes.Code, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes the bytes of message which are not
+// allowed in the grpc-message header
+func encodeGRPCMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
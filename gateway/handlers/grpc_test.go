@@ -0,0 +1,264 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/filter"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/types"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// echoServer is a gRPC function which sends back each message it receives
+type echoServer struct {
+	traceparents chan string
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "echo.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Fail",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				return nil, status.Error(codes.NotFound, "no such echo")
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chat",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				if md, ok := metadata.FromIncomingContext(stream.Context()); ok {
+					srv.(*echoServer).traceparents <- strings.Join(md.Get("traceparent"), ",")
+				}
+
+				count := 0
+				for {
+					msg := &wrapperspb.StringValue{}
+					err := stream.RecvMsg(msg)
+					if errors.Is(err, io.EOF) {
+						stream.SetTrailer(metadata.Pairs("x-echo-count", strconv.Itoa(count)))
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+
+					count++
+					if err := stream.SendMsg(msg); err != nil {
+						return err
+					}
+				}
+			},
+		},
+	},
+}
+
+// grpcGateway serves the function proxy for an echo function over HTTP/2
+// without TLS, with filters when they are not nil, and returns a client
+// connection to it
+func grpcGateway(t *testing.T, filters *filter.Chain) (*grpc.ClientConn, *echoServer) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo := &echoServer{traceparents: make(chan string, 1)}
+	function := grpc.NewServer()
+	function.RegisterService(&echoServiceDesc, echo)
+	go function.Serve(listener)
+	t.Cleanup(function.Stop)
+
+	functionURL, _ := url.Parse("http://" + listener.Addr().String())
+	proxy := types.NewHTTPClientReverseProxy(functionURL, time.Second*5, 10, 10)
+	proxy.GRPCTransport = tracing.GRPCTransport(proxy.GRPCTransport)
+	if filters != nil {
+		proxy.GRPCTransport = filters.Transport(proxy.GRPCTransport, http.StatusForbidden)
+	}
+
+	handler := tracing.Middleware(MakeForwardingProxyHandler(proxy, nil,
+		middleware.SingleHostBaseURLResolver{BaseURL: functionURL.String()},
+		middleware.FunctionPrefixTrimmingURLPathTransformer{},
+		nil))
+
+	gateway := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	t.Cleanup(gateway.Close)
+
+	conn, err := grpc.Dial(strings.TrimPrefix(gateway.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, echo
+}
+
+func Test_ForwardingProxy_GRPC_BidirectionalStream(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	conn, echo := grpcGateway(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// the gateway's route is the prefix of the gRPC method's path
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/function/echo/echo.Echo/Chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"hello", "from", "openfaas"} {
+		if err := stream.SendMsg(wrapperspb.String(want)); err != nil {
+			t.Fatal(err)
+		}
+
+		got := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(got); err != nil {
+			t.Fatal(err)
+		}
+		if got.GetValue() != want {
+			t.Errorf("want echo: %q, got: %q", want, got.GetValue())
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&wrapperspb.StringValue{}); !errors.Is(err, io.EOF) {
+		t.Fatalf("want the stream to end with an OK status, got: %v", err)
+	}
+
+	if got := stream.Trailer().Get("x-echo-count"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("want the function's trailer x-echo-count: 3, got: %v", got)
+	}
+
+	spans := recorder.Named("echo.Echo/Chat")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 gRPC client span, got: %d", len(spans))
+	}
+	span := spans[0]
+
+	want := map[string]string{
+		string(semconv.RPCSystemKey):  "grpc",
+		string(semconv.RPCServiceKey): "echo.Echo",
+		string(semconv.RPCMethodKey):  "Chat",
+	}
+	for _, kv := range span.Attributes() {
+		if val, ok := want[string(kv.Key)]; ok {
+			if kv.Value.AsString() != val {
+				t.Errorf("want %s: %q, got: %q", kv.Key, val, kv.Value.AsString())
+			}
+			delete(want, string(kv.Key))
+		}
+		if kv.Key == semconv.RPCGRPCStatusCodeKey && kv.Value.AsInt64() != int64(codes.OK) {
+			t.Errorf("want %s: 0, got: %d", kv.Key, kv.Value.AsInt64())
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("want the span to have the attributes: %v", want)
+	}
+
+	traceparent := <-echo.traceparents
+	if !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Errorf("want the client span in the traceparent metadata, got: %q", traceparent)
+	}
+}
+
+func Test_ForwardingProxy_GRPC_PassesOnStatus(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	conn, _ := grpcGateway(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	err := conn.Invoke(ctx, "/function/echo/echo.Echo/Fail", wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Fatalf("want the function's status: %s, got: %s (%v)", codes.NotFound, got, err)
+	}
+	if got := status.Convert(err).Message(); got != "no such echo" {
+		t.Errorf("want the function's message, got: %q", got)
+	}
+
+	spans := recorder.Named("echo.Echo/Fail")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 gRPC client span, got: %d", len(spans))
+	}
+	if desc := spans[0].Status().Description; desc != codes.NotFound.String() {
+		t.Errorf("want an error status of %s, got: %q", codes.NotFound, desc)
+	}
+}
+
+func Test_ForwardingProxy_GRPC_RunsFilters(t *testing.T) {
+	filters := &filter.Chain{}
+	filters.Register("deny", denyFilter{})
+
+	conn, _ := grpcGateway(t, filters)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	err := conn.Invoke(ctx, "/function/echo/echo.Echo/Fail", wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Fatalf("want the filter's rejection as: %s, got: %s (%v)", codes.PermissionDenied, got, err)
+	}
+}
+
+func Test_proxyGRPC_UnreachableFunction(t *testing.T) {
+	upstreamReq, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:1/echo.Echo/Chat", nil)
+	r := httptest.NewRequest(http.MethodPost, "/function/echo/echo.Echo/Chat", nil)
+
+	transport := &http2.Transport{
+		AllowHTTP: true,
+	}
+
+	rr := httptest.NewRecorder()
+	statusCode, err := proxyGRPC(rr, r, upstreamReq, transport)
+	if err == nil || statusCode != http.StatusBadGateway {
+		t.Fatalf("want a 502 and an error, got: %d %v", statusCode, err)
+	}
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Grpc-Status") != strconv.Itoa(int(codes.Unavailable)) {
+		t.Errorf("want a trailers-only response with status %s, got: %d %q", codes.Unavailable, rr.Code, rr.Header().Get("Grpc-Status"))
+	}
+	if got := rr.Header().Get("Grpc-Message"); strings.Contains(got, "127.0.0.1") {
+		t.Errorf("want a message without the function's address, got: %q", got)
+	}
+}
+
+func Test_encodeGRPCMessage(t *testing.T) {
+	got := encodeGRPCMessage("100% down\n")
+	if want := "100%25 down%0A"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
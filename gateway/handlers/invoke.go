@@ -0,0 +1,130 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// Invoker calls functions in-process, for programs which embed the gateway.
+// Each call is served by handler, which should be the same chain that the
+// gateway serves /function/ with, so that calls have the same exec timeout,
+// scaling, circuit breaker, canary routing, spans and metrics as those made
+// over HTTP.
+type Invoker struct {
+	handler http.Handler
+}
+
+// NewInvoker creates an Invoker which serves calls with handler
+func NewInvoker(handler http.Handler) *Invoker {
+	return &Invoker{handler: handler}
+}
+
+// Invoke calls the function fn, i.e. "figlet" or "figlet.openfaas-fn", with
+// req, whose path is the sub-path within the function. The response is
+// returned once its headers are written, and the caller must close its body.
+func (i *Invoker) Invoke(ctx context.Context, fn string, req *http.Request) (*http.Response, error) {
+	if err := middleware.ValidateFunctionName(fn); err != nil {
+		return nil, err
+	}
+
+	subPath := path.Clean("/" + req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") && subPath != "/" {
+		subPath += "/"
+	}
+
+	r := req.Clone(ctx)
+	r.URL.Path = "/function/" + fn + subPath
+	r.URL.RawPath = ""
+	r.RequestURI = r.URL.RequestURI()
+
+	return i.InvokeRequest(ctx, r)
+}
+
+// InvokeRequest calls the function named in the path of req, which is
+// /function/<name> followed by the sub-path, as async.InvokeFunc expects
+func (i *Invoker) InvokeRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	route, fn := middleware.GetFunctionRoute(req.URL.Path)
+	if route != "function" {
+		return nil, fmt.Errorf("not a function path: %q", req.URL.Path)
+	}
+	if err := middleware.ValidateFunctionName(fn); err != nil {
+		return nil, err
+	}
+	if path.Clean(req.URL.Path) != strings.TrimSuffix(req.URL.Path, "/") {
+		return nil, fmt.Errorf("invalid function path: %q", req.URL.Path)
+	}
+
+	r := req.WithContext(ctx)
+	body, pw := io.Pipe()
+	w := &invokeResponseWriter{header: http.Header{}, body: pw, ready: make(chan struct{})}
+
+	go func() {
+		defer func() {
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		i.handler.ServeHTTP(w, r)
+	}()
+
+	<-w.ready
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", w.statusCode, http.StatusText(w.statusCode)),
+		StatusCode: w.statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.written,
+		Body:       body,
+		Request:    r,
+	}, nil
+}
+
+// invokeResponseWriter streams the response written by the handler to the
+// caller of InvokeRequest, through a pipe which is read from the response's
+// body
+type invokeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	once       sync.Once
+	ready      chan struct{}
+	statusCode int
+	written    http.Header
+}
+
+func (w *invokeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *invokeResponseWriter) WriteHeader(statusCode int) {
+	w.once.Do(func() {
+		w.statusCode = statusCode
+		w.written = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *invokeResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush does nothing, each write blocks until the caller has read it
+func (w *invokeResponseWriter) Flush() {}
+
+// Hijack is not supported, as there is no connection to hand over
+func (w *invokeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
@@ -0,0 +1,136 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/types"
+	"go.opentelemetry.io/otel"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_Invoker_Invoke_TracesCallToFunction(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	var gotPath, gotQuery, gotTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotQuery = r.URL.RawQuery
+		gotTraceparent = r.Header.Get("traceparent")
+
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello " + string(body)))
+	}))
+	defer upstream.Close()
+
+	proxy := &types.HTTPClientReverseProxy{
+		Client:  &http.Client{Transport: tracing.Transport(http.DefaultTransport)},
+		Timeout: time.Minute,
+	}
+	functionProxy := MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil)
+	invoker := NewInvoker(tracing.Middleware(functionProxy))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "embedder")
+	req := httptest.NewRequest(http.MethodPost, "/resize?width=100", strings.NewReader("world"))
+
+	res, err := invoker.Invoke(ctx, "figlet", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	parent.End()
+
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("want status %d, got: %d", http.StatusCreated, res.StatusCode)
+	}
+	if string(body) != "hello world" {
+		t.Errorf("want body: hello world, got: %q", string(body))
+	}
+	if gotPath != "/function/figlet/resize" {
+		t.Errorf("want path: /function/figlet/resize, got: %s", gotPath)
+	}
+	if gotQuery != "width=100" {
+		t.Errorf("want query: width=100, got: %s", gotQuery)
+	}
+
+	var server, client tracesdk.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			client = span
+		}
+	}
+	if server == nil || client == nil {
+		t.Fatalf("want the same server and client spans as a call over HTTP")
+	}
+	if server.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("want the function's span to be a child of the caller's span")
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("want the client span to be a child of the function's span")
+	}
+	if !strings.Contains(gotTraceparent, client.SpanContext().TraceID().String()) {
+		t.Errorf("want the trace context sent to the function, got traceparent: %q", gotTraceparent)
+	}
+}
+
+func Test_Invoker_Invoke_ServesThroughHandler(t *testing.T) {
+	var gotPath string
+	invoker := NewInvoker(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("X-Chain", "function-proxy")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	res, err := invoker.Invoke(context.Background(), "figlet.openfaas-fn", httptest.NewRequest(http.MethodGet, "/a/../../system/functions/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusServiceUnavailable || res.Header.Get("X-Chain") != "function-proxy" {
+		t.Errorf("want the handler's response, got: %d %v", res.StatusCode, res.Header)
+	}
+	if gotPath != "/function/figlet.openfaas-fn/system/functions/" {
+		t.Errorf("want the sub-path kept within the function, got: %s", gotPath)
+	}
+}
+
+func Test_Invoker_Invoke_InvalidFunction(t *testing.T) {
+	called := false
+	invoker := NewInvoker(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	for _, fn := range []string{"", "../system", "figlet/secrets", "Figlet"} {
+		if _, err := invoker.Invoke(context.Background(), fn, httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+			t.Errorf("want an error for function name: %q", fn)
+		}
+	}
+
+	for _, target := range []string{"/system/functions", "/function/figlet/../../system/secrets"} {
+		if _, err := invoker.InvokeRequest(context.Background(), httptest.NewRequest(http.MethodGet, target, nil)); err == nil {
+			t.Errorf("want an error for path: %q", target)
+		}
+	}
+
+	if called {
+		t.Errorf("want invalid calls rejected before the handler")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// LogRequest selects the logs of a function to stream
+type LogRequest struct {
+	Name      string
+	Namespace string
+
+	// Since only returns lines logged after it, when set
+	Since *time.Time
+
+	// Tail limits the stream to the most recent lines, 0 for all of them
+	Tail int
+
+	// Follow keeps the stream open for new lines until it is cancelled
+	Follow bool
+}
+
+// LogMessage is a line logged by an instance of a function
+type LogMessage struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Instance  string    `json:"instance"`
+	Timestamp time.Time `json:"timestamp"`
+	Text      string    `json:"text"`
+}
+
+// LogProvider streams the logs of functions
+type LogProvider interface {
+	// Logs sends the lines selected by req on the channel and closes it once
+	// they run out, or for a followed stream once ctx is done
+	Logs(ctx context.Context, req LogRequest) (<-chan LogMessage, error)
+}
+
+// MakeLogStreamHandler streams the logs of the function given with the name
+// query parameter, as server-sent events when the client accepts
+// text/event-stream, or as newline delimited JSON otherwise. The since, tail
+// and follow parameters are passed on to the provider. A followed stream
+// lasts until the client goes away, whatever the server's write timeout,
+// and is traced as one span for its duration.
+func MakeLogStreamHandler(provider LogProvider, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, errs := parseLogRequest(r, defaultNamespace)
+		if len(errs) > 0 {
+			writeValidationErrors(w, r, errs)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			httperror.Write(w, r, http.StatusInternalServerError, "streaming is not supported")
+			return
+		}
+
+		// a followed stream outlasts the server's write timeout
+		if req.Follow {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				log.Printf("[Logs] unable to clear the write deadline for %s.%s: %s", req.Name, req.Namespace, err)
+			}
+		}
+
+		ctx, span := startFunctionSpan(r.Context(), "logs "+req.Name,
+			tracing.FunctionNameKey.String(req.Name),
+			tracing.NamespaceKey.String(req.Namespace),
+			tracing.LogsFollowKey.Bool(req.Follow),
+		)
+		defer span.End()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		messages, err := provider.Logs(ctx, req)
+		if err != nil {
+			writeProviderError(w, r, span, err)
+			return
+		}
+
+		sse := strings.HasPrefix(r.Header.Get("Accept"), "text/event-stream")
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		lines := 0
+		defer func() {
+			span.SetAttributes(tracing.LogLinesKey.Int(lines))
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				line, err := json.Marshal(msg)
+				if err != nil {
+					log.Printf("[Logs] unable to encode a line of %s.%s: %s", req.Name, req.Namespace, err)
+					continue
+				}
+
+				if sse {
+					_, err = fmt.Fprintf(w, "data: %s\n\n", line)
+				} else {
+					_, err = fmt.Fprintf(w, "%s\n", line)
+				}
+				if err != nil {
+					return
+				}
+				flusher.Flush()
+				lines++
+			}
+		}
+	}
+}
+
+// parseLogRequest reads the query parameters of a request for logs
+func parseLogRequest(r *http.Request, defaultNamespace string) (LogRequest, []FieldError) {
+	var errs []FieldError
+	query := r.URL.Query()
+
+	req := LogRequest{
+		Namespace: requestNamespace(r, "", defaultNamespace),
+	}
+	req.Name, req.Namespace = middleware.GetNamespace(req.Namespace, query.Get("name"))
+
+	if len(req.Name) == 0 {
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	}
+	if err := middleware.ValidateNamespace(req.Namespace); err != nil {
+		errs = append(errs, FieldError{Field: "namespace", Message: err.Error()})
+	}
+
+	if since := query.Get("since"); len(since) > 0 {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			req.Since = &t
+		} else if d, err := time.ParseDuration(since); err == nil && d > 0 {
+			t := time.Now().Add(-d)
+			req.Since = &t
+		} else {
+			errs = append(errs, FieldError{Field: "since", Message: "must be an RFC3339 time or a duration such as 5m"})
+		}
+	}
+
+	if tail := query.Get("tail"); len(tail) > 0 {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 0 {
+			errs = append(errs, FieldError{Field: "tail", Message: "must be a number of lines, 0 or more"})
+		}
+		req.Tail = n
+	}
+
+	if follow := query.Get("follow"); len(follow) > 0 {
+		b, err := strconv.ParseBool(follow)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "follow", Message: "must be true or false"})
+		}
+		req.Follow = b
+	}
+
+	return req, errs
+}
@@ -0,0 +1,226 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+// mockLogProvider sends a bounded number of lines, then for a followed
+// stream keeps it open until ctx is done
+type mockLogProvider struct {
+	lines int
+	req   LogRequest
+	done  chan struct{}
+}
+
+func (p *mockLogProvider) Logs(ctx context.Context, req LogRequest) (<-chan LogMessage, error) {
+	p.req = req
+	p.done = make(chan struct{})
+
+	messages := make(chan LogMessage)
+	go func() {
+		defer close(p.done)
+		defer close(messages)
+
+		for i := 0; i < p.lines; i++ {
+			msg := LogMessage{Name: req.Name, Namespace: req.Namespace, Text: fmt.Sprintf("line %d", i)}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if req.Follow {
+			<-ctx.Done()
+		}
+	}()
+	return messages, nil
+}
+
+func Test_MakeLogStreamHandler_BoundedNDJSON(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	provider := &mockLogProvider{lines: 3}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/system/logs?name=figlet&tail=3&since=5m", nil)
+	MakeLogStreamHandler(provider, "openfaas-fn")(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status: %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("want Content-Type: application/x-ndjson, got: %s", got)
+	}
+
+	if provider.req.Namespace != "openfaas-fn" || provider.req.Tail != 3 || provider.req.Follow {
+		t.Errorf("want the query passed to the provider, got: %+v", provider.req)
+	}
+	if provider.req.Since == nil || time.Since(*provider.req.Since) < 5*time.Minute {
+		t.Errorf("want since 5m ago, got: %v", provider.req.Since)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want 3 lines, got: %q", lines)
+	}
+	msg := LogMessage{}
+	if err := json.Unmarshal([]byte(lines[2]), &msg); err != nil || msg.Text != "line 2" {
+		t.Errorf("want the last line as JSON, got: %s", lines[2])
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "logs figlet" {
+		t.Fatalf("want a logs figlet span, got: %d", len(spans))
+	}
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.LogLinesKey && kv.Value.AsInt64() != 3 {
+			t.Errorf("want %s: 3, got: %d", tracing.LogLinesKey, kv.Value.AsInt64())
+		}
+	}
+}
+
+func Test_MakeLogStreamHandler_FollowEndsWhenClientGoes(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	provider := &mockLogProvider{lines: 2}
+	srv := httptest.NewServer(MakeLogStreamHandler(provider, "openfaas-fn"))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/logs?name=figlet.dev&follow=true", nil)
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("want Content-Type: text/event-stream, got: %s", got)
+	}
+
+	reader := bufio.NewReader(res.Body)
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, fmt.Sprintf(`"line %d"`, i)) {
+			t.Errorf("want event %d, got: %q", i, line)
+		}
+		reader.ReadString('\n')
+	}
+
+	cancel()
+
+	select {
+	case <-provider.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the followed stream to end when the client goes away")
+	}
+
+	if provider.req.Namespace != "dev" || !provider.req.Follow {
+		t.Errorf("want a followed stream for figlet.dev, got: %+v", provider.req)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.Ended()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(recorder.Ended()) != 1 {
+		t.Errorf("want the stream's span ended, got: %d spans", len(recorder.Ended()))
+	}
+}
+
+// slowLogProvider sends a line once delay has passed, then keeps the stream
+// open until ctx is done
+type slowLogProvider struct {
+	delay time.Duration
+}
+
+func (p *slowLogProvider) Logs(ctx context.Context, req LogRequest) (<-chan LogMessage, error) {
+	messages := make(chan LogMessage)
+	go func() {
+		defer close(messages)
+
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case messages <- LogMessage{Name: req.Name, Namespace: req.Namespace, Text: "late line"}:
+		case <-ctx.Done():
+			return
+		}
+		<-ctx.Done()
+	}()
+	return messages, nil
+}
+
+func Test_MakeLogStreamHandler_FollowOutlastsWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(MakeLogStreamHandler(&slowLogProvider{delay: 300 * time.Millisecond}, "openfaas-fn"))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/logs?name=figlet&follow=true", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("want a line sent after the write timeout, got: %s", err)
+	}
+	if !strings.Contains(line, "late line") {
+		t.Errorf("want the late line, got: %q", line)
+	}
+}
+
+func Test_MakeLogStreamHandler_InvalidQuery(t *testing.T) {
+	cases := map[string]string{
+		"/system/logs":                          "name",
+		"/system/logs?name=figlet&tail=-1":      "tail",
+		"/system/logs?name=figlet&since=later":  "since",
+		"/system/logs?name=figlet&follow=maybe": "follow",
+		"/system/logs?name=figlet.Dev":          "namespace",
+	}
+
+	for target, field := range cases {
+		rr := httptest.NewRecorder()
+		MakeLogStreamHandler(&mockLogProvider{}, "openfaas-fn")(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status: %d, got: %d", target, http.StatusBadRequest, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), fmt.Sprintf(`"field":%q`, field)) {
+			t.Errorf("%s: want an error for %s, got: %s", target, field, rr.Body.String())
+		}
+	}
+}
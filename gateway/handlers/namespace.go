@@ -0,0 +1,64 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// NamespaceQueryParam selects the namespace of a function when it is not
+// given in the path, i.e. /function/figlet?namespace=staging
+const NamespaceQueryParam = "namespace"
+
+// MakeNamespaceHandler scopes a call to a function to its namespace, given
+// in the path as /function/figlet.staging or with the namespace query
+// parameter, or defaultNamespace when neither is set. A namespace from the
+// query is moved into the path, so that the handlers after it, and the
+// resolver, see a single form. Invalid namespaces are rejected with a 400.
+func MakeNamespaceHandler(next http.HandlerFunc, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceName := middleware.GetServiceName(r.URL.Path)
+		if len(serviceName) == 0 {
+			next(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		queryNamespace := query.Get(NamespaceQueryParam)
+
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, serviceName)
+		if strings.Contains(serviceName, ".") {
+			if len(queryNamespace) > 0 && queryNamespace != namespace {
+				httperror.Write(w, r, http.StatusBadRequest, fmt.Sprintf("namespace %q in the path does not match %q in the query", namespace, queryNamespace))
+				return
+			}
+		} else if len(queryNamespace) > 0 {
+			namespace = queryNamespace
+		}
+
+		if err := middleware.ValidateNamespace(namespace); err != nil {
+			httperror.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if len(queryNamespace) > 0 && !strings.Contains(serviceName, ".") {
+			prefix := "/function/" + serviceName
+			r.URL.Path = "/function/" + functionName + "." + namespace + strings.TrimPrefix(r.URL.Path, prefix)
+			r.URL.RawPath = ""
+
+			query.Del(NamespaceQueryParam)
+			r.URL.RawQuery = query.Encode()
+		}
+
+		tracing.SetNamespace(r.Context(), namespace)
+
+		next(w, r)
+	}
+}
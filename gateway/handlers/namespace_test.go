@@ -0,0 +1,217 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/auth"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+func Test_MakeNamespaceHandler(t *testing.T) {
+	cases := []struct {
+		name          string
+		target        string
+		wantPath      string
+		wantQuery     string
+		wantNamespace string
+	}{
+		{
+			name:          "default namespace",
+			target:        "/function/figlet/path",
+			wantPath:      "/function/figlet/path",
+			wantNamespace: "openfaas-fn",
+		},
+		{
+			name:          "namespace in the path",
+			target:        "/function/figlet.staging/path",
+			wantPath:      "/function/figlet.staging/path",
+			wantNamespace: "staging",
+		},
+		{
+			name:          "namespace in the query",
+			target:        "/function/figlet/path?namespace=staging&q=1",
+			wantPath:      "/function/figlet.staging/path",
+			wantQuery:     "q=1",
+			wantNamespace: "staging",
+		},
+		{
+			name:          "same namespace in both",
+			target:        "/function/figlet.staging?namespace=staging",
+			wantPath:      "/function/figlet.staging",
+			wantQuery:     "namespace=staging",
+			wantNamespace: "staging",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, teardown := tracetest.Install()
+			defer teardown()
+
+			var gotPath, gotQuery string
+			next := func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+			}
+
+			rr := httptest.NewRecorder()
+			tracing.Middleware(MakeNamespaceHandler(next, "openfaas-fn"))(rr, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("want status: %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			if gotPath != tc.wantPath || gotQuery != tc.wantQuery {
+				t.Errorf("want: %s?%s, got: %s?%s", tc.wantPath, tc.wantQuery, gotPath, gotQuery)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got: %d", len(spans))
+			}
+			for _, kv := range spans[0].Attributes() {
+				if kv.Key == tracing.NamespaceKey {
+					if kv.Value.AsString() != tc.wantNamespace {
+						t.Errorf("want %s: %s, got: %s", tracing.NamespaceKey, tc.wantNamespace, kv.Value.AsString())
+					}
+					return
+				}
+			}
+			t.Errorf("want a %s attribute", tracing.NamespaceKey)
+		})
+	}
+}
+
+func Test_MakeNamespaceHandler_RejectsInvalidNamespaces(t *testing.T) {
+	targets := []string{
+		"/function/figlet.Staging",
+		"/function/figlet?namespace=under_score",
+		"/function/figlet.dev?namespace=prod",
+		"/function/figlet?namespace=" + strings.Repeat("a", 64),
+	}
+
+	for _, target := range targets {
+		called := false
+		next := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+		rr := httptest.NewRecorder()
+		MakeNamespaceHandler(next, "openfaas-fn")(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status: %d, got: %d", target, http.StatusBadRequest, rr.Code)
+		}
+		if called {
+			t.Errorf("%s: want the call rejected before the function", target)
+		}
+	}
+}
+
+func Test_MakeNamespaceHandler_WebhookSecretsNeedSignature(t *testing.T) {
+	lookup := func(fn string) ([]byte, error) {
+		if fn == "github-events" || fn == "github-events.openfaas-fn" {
+			return []byte("webhook-secret"), nil
+		}
+		return nil, nil
+	}
+
+	called := false
+	next := auth.VerifyHMAC(lookup)(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	for _, target := range []string{"/function/github-events?namespace=openfaas-fn", "/function/github-events.openfaas-fn"} {
+		rr := httptest.NewRecorder()
+		MakeNamespaceHandler(next, "openfaas-fn")(rr, httptest.NewRequest(http.MethodPost, target, strings.NewReader("{}")))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: want status: %d without a signature, got: %d", target, http.StatusUnauthorized, rr.Code)
+		}
+		if called {
+			t.Errorf("%s: want the unsigned call rejected before the function", target)
+		}
+	}
+}
+
+// namespaceProvider records the namespace each operation was given
+type namespaceProvider struct {
+	mockFunctionProvider
+	namespace string
+}
+
+func (p *namespaceProvider) Deploy(ctx context.Context, spec types.FunctionDeployment) (*ProviderResponse, error) {
+	p.namespace = spec.Namespace
+	return nil, nil
+}
+
+func (p *namespaceProvider) List(ctx context.Context, namespace string) ([]types.FunctionStatus, error) {
+	p.namespace = namespace
+	return nil, nil
+}
+
+func (p *namespaceProvider) ListResponse(ctx context.Context, namespace string) (*ProviderResponse, error) {
+	p.namespace = namespace
+	return nil, nil
+}
+
+func Test_MakeFunctionsHandler_Namespaces(t *testing.T) {
+	cases := []struct {
+		method, target, body, want string
+	}{
+		{http.MethodPost, "/system/functions", `{"service":"figlet","image":"figlet"}`, "openfaas-fn"},
+		{http.MethodPost, "/system/functions?namespace=dev", `{"service":"figlet","image":"figlet"}`, "dev"},
+		{http.MethodPost, "/system/functions?namespace=dev", `{"service":"figlet","image":"figlet","namespace":"prod"}`, "prod"},
+		{http.MethodGet, "/system/functions", "", "openfaas-fn"},
+		{http.MethodGet, "/system/functions?namespace=dev", "", "dev"},
+	}
+
+	for _, tc := range cases {
+		provider := &namespaceProvider{}
+		rr := httptest.NewRecorder()
+		MakeFunctionsHandler(provider, "openfaas-fn")(rr, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+
+		if rr.Code >= http.StatusBadRequest {
+			t.Errorf("%s %s: want success, got: %d %s", tc.method, tc.target, rr.Code, rr.Body.String())
+		}
+		if provider.namespace != tc.want {
+			t.Errorf("%s %s %s: want namespace: %s, got: %s", tc.method, tc.target, tc.body, tc.want, provider.namespace)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	MakeFunctionsHandler(&namespaceProvider{}, "openfaas-fn")(rr, httptest.NewRequest(http.MethodGet, "/system/functions?namespace=Dev", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want status: %d for an invalid namespace, got: %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func Test_MakeScaleHandler_Namespaces(t *testing.T) {
+	cases := map[string]string{
+		"/system/scale/figlet":               "figlet.openfaas-fn",
+		"/system/scale/figlet.dev":           "figlet.dev",
+		"/system/scale/figlet?namespace=dev": "figlet.dev",
+	}
+
+	for target, want := range cases {
+		scaler := &mockScaler{res: scaling.ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 5}}
+
+		name := strings.TrimPrefix(strings.Split(target, "?")[0], "/system/scale/")
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"replicas": 2}`)), map[string]string{"name": name})
+
+		rr := httptest.NewRecorder()
+		MakeScaleHandler(scaler, "openfaas-fn")(rr, req)
+
+		if rr.Code != http.StatusAccepted {
+			t.Errorf("%s: want status: %d, got: %d", target, http.StatusAccepted, rr.Code)
+		}
+		if scaler.service != want {
+			t.Errorf("%s: want: %s, got: %s", target, want, scaler.service)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	"github.com/openfaas/faas/gateway/metrics"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // HTTPNotifier notify about HTTP request/response
@@ -44,7 +47,7 @@ func (p PrometheusFunctionNotifier) Notify(method string, URL string, originalUR
 	}
 
 	code := strconv.Itoa(statusCode)
-	labels := prometheus.Labels{"function_name": serviceName, "code": code}
+	labels := prometheus.Labels{"function_name": metrics.FunctionLabel(serviceName), "code": code}
 
 	if event == "completed" {
 		seconds := duration.Seconds()
@@ -56,9 +59,74 @@ func (p PrometheusFunctionNotifier) Notify(method string, URL string, originalUR
 			With(labels).
 			Inc()
 	} else if event == "started" {
-		p.Metrics.GatewayFunctionInvocationStarted.WithLabelValues(serviceName).Inc()
+		p.Metrics.GatewayFunctionInvocationStarted.WithLabelValues(metrics.FunctionLabel(serviceName)).Inc()
+		if p.Metrics.Invocations != nil {
+			p.Metrics.Invocations.Started(serviceName, time.Now())
+		}
+	}
+
+}
+
+// OTelFunctionNotifier records the same invocation metrics as
+// PrometheusFunctionNotifier through an OpenTelemetry meter, for export with
+// OTLP
+type OTelFunctionNotifier struct {
+	//FunctionNamespace default namespace of the function
+	FunctionNamespace string
+
+	invocations metric.Int64Counter
+	started     metric.Int64Counter
+	duration    metric.Float64Histogram
+}
+
+// NewOTelFunctionNotifier creates the invocation instruments with meter
+func NewOTelFunctionNotifier(meter metric.Meter, functionNamespace string) (*OTelFunctionNotifier, error) {
+	invocations, err := meter.Int64Counter("gateway.function.invocations",
+		metric.WithDescription("Function metrics"))
+	if err != nil {
+		return nil, err
+	}
+
+	started, err := meter.Int64Counter("gateway.function.invocations.started",
+		metric.WithDescription("The total number of function HTTP requests started."))
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram("gateway.functions.duration",
+		metric.WithDescription("Function time taken"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
 	}
 
+	return &OTelFunctionNotifier{
+		FunctionNamespace: functionNamespace,
+		invocations:       invocations,
+		started:           started,
+		duration:          duration,
+	}, nil
+}
+
+// Notify records metrics with the OpenTelemetry meter
+func (p *OTelFunctionNotifier) Notify(method string, URL string, originalURL string, statusCode int, event string, duration time.Duration) {
+	serviceName := middleware.GetServiceName(originalURL)
+	if len(p.FunctionNamespace) > 0 && !strings.Contains(serviceName, ".") {
+		serviceName = fmt.Sprintf("%s.%s", serviceName, p.FunctionNamespace)
+	}
+
+	ctx := context.Background()
+	label := metrics.FunctionLabel(serviceName)
+	if event == "completed" {
+		attrs := metric.WithAttributes(
+			attribute.String("function_name", label),
+			attribute.String("code", strconv.Itoa(statusCode)),
+		)
+		p.duration.Record(ctx, duration.Seconds(), attrs)
+		p.invocations.Add(ctx, 1, attrs)
+	} else if event == "started" {
+		p.started.Add(ctx, 1, metric.WithAttributes(attribute.String("function_name", label)))
+	}
 }
 
 // LoggingNotifier notifies a log about a request
@@ -1,6 +1,16 @@
 package handlers
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
 
 func Test_urlToLabel_normalizeTrailing(t *testing.T) {
 	have := "/system/functions/"
@@ -21,3 +31,64 @@ func Test_urlToLabel_retainRoot(t *testing.T) {
 		t.Errorf("want %s, got %s", want, got)
 	}
 }
+
+func Test_OTelFunctionNotifier_CountsInvocations(t *testing.T) {
+	reader := metricsdk.NewManualReader()
+	provider := metricsdk.NewMeterProvider(metricsdk.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	notifier, err := NewOTelFunctionNotifier(provider.Meter("test"), "openfaas-fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		notifier.Notify("GET", "http://figlet:8080/", "/function/figlet", 200, "started", 0)
+		notifier.Notify("GET", "http://figlet:8080/", "/function/figlet", 200, "completed", time.Millisecond)
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	sums := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if data, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range data.DataPoints {
+					if name, _ := dp.Attributes.Value(attribute.Key("function_name")); name.AsString() == "figlet.openfaas-fn" {
+						sums[m.Name] += dp.Value
+					}
+				}
+			}
+		}
+	}
+
+	if sums["gateway.function.invocations"] != 2 {
+		t.Errorf("want 2 invocations of figlet.openfaas-fn, got: %v", sums)
+	}
+	if sums["gateway.function.invocations.started"] != 2 {
+		t.Errorf("want 2 invocations started, got: %v", sums)
+	}
+}
+
+func Test_PrometheusFunctionNotifier_RecordsInvocations(t *testing.T) {
+	options := metrics.BuildMetricsOptions()
+	notifier := PrometheusFunctionNotifier{Metrics: &options, FunctionNamespace: "openfaas-fn"}
+
+	before := time.Now()
+	notifier.Notify(http.MethodPost, "/function/figlet", "/function/figlet", http.StatusOK, "started", 0)
+	notifier.Notify(http.MethodPost, "/function/figlet", "/function/figlet", http.StatusOK, "completed", time.Millisecond)
+
+	invocation, ok := options.Invocations.Summary()["figlet.openfaas-fn"]
+	if !ok {
+		t.Fatalf("want an invocation of figlet.openfaas-fn")
+	}
+	if invocation.Count != 1 {
+		t.Errorf("want count: 1, got: %f", invocation.Count)
+	}
+	if invocation.Last.Before(before) {
+		t.Errorf("want the last call recorded after %s, got: %s", before, invocation.Last)
+	}
+}
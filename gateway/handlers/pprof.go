@@ -0,0 +1,41 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+	providerauth "github.com/openfaas/faas-provider/auth"
+	gatewayauth "github.com/openfaas/faas/gateway/pkg/auth"
+)
+
+// PprofPath is where the gateway's net/http/pprof profiles are served
+const PprofPath = "/debug/pprof"
+
+// RegisterPprof serves the net/http/pprof profiles under PprofPath on r when
+// enabled. Profiles reveal the gateway's memory and can slow it down while
+// they are taken, so they always need basic auth with credentials, and are
+// not served when basic auth is off. It reports whether they were served.
+func RegisterPprof(r *mux.Router, enabled bool, credentials *providerauth.BasicAuthCredentials) bool {
+	if !enabled {
+		return false
+	}
+	if credentials == nil {
+		log.Printf("warning: pprof is enabled but basic_auth is not, %s will not be served", PprofPath)
+		return false
+	}
+
+	profiles := http.NewServeMux()
+	profiles.HandleFunc(PprofPath+"/", pprof.Index)
+	profiles.HandleFunc(PprofPath+"/cmdline", pprof.Cmdline)
+	profiles.HandleFunc(PprofPath+"/profile", pprof.Profile)
+	profiles.HandleFunc(PprofPath+"/symbol", pprof.Symbol)
+	profiles.HandleFunc(PprofPath+"/trace", pprof.Trace)
+
+	r.PathPrefix(PprofPath + "/").Handler(gatewayauth.BasicAuth(profiles, credentials, []string{PprofPath}))
+	return true
+}
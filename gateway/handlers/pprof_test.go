@@ -0,0 +1,67 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	providerauth "github.com/openfaas/faas-provider/auth"
+)
+
+func pprofStatus(t *testing.T, r *mux.Router, user, password string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, PprofPath+"/", nil)
+	if len(user) > 0 {
+		req.SetBasicAuth(user, password)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func Test_RegisterPprof_RequiresAuth(t *testing.T) {
+	r := mux.NewRouter()
+	credentials := &providerauth.BasicAuthCredentials{User: "admin", Password: "secret"}
+
+	if !RegisterPprof(r, true, credentials) {
+		t.Fatalf("want pprof to be registered")
+	}
+
+	if code := pprofStatus(t, r, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("want status %d without credentials, got: %d", http.StatusUnauthorized, code)
+	}
+	if code := pprofStatus(t, r, "admin", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("want status %d with the wrong password, got: %d", http.StatusUnauthorized, code)
+	}
+	if code := pprofStatus(t, r, "admin", "secret"); code != http.StatusOK {
+		t.Errorf("want status %d with credentials, got: %d", http.StatusOK, code)
+	}
+}
+
+func Test_RegisterPprof_AbsentWhenDisabled(t *testing.T) {
+	r := mux.NewRouter()
+	credentials := &providerauth.BasicAuthCredentials{User: "admin", Password: "secret"}
+
+	if RegisterPprof(r, false, credentials) {
+		t.Fatalf("want pprof not to be registered")
+	}
+	if code := pprofStatus(t, r, "admin", "secret"); code != http.StatusNotFound {
+		t.Errorf("want status %d, got: %d", http.StatusNotFound, code)
+	}
+}
+
+func Test_RegisterPprof_AbsentWithoutBasicAuth(t *testing.T) {
+	r := mux.NewRouter()
+
+	if RegisterPprof(r, true, nil) {
+		t.Fatalf("want pprof not to be registered without basic auth")
+	}
+	if code := pprofStatus(t, r, "", ""); code != http.StatusNotFound {
+		t.Errorf("want status %d, got: %d", http.StatusNotFound, code)
+	}
+}
@@ -4,8 +4,8 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,6 +14,8 @@ import (
 	"github.com/gorilla/mux"
 	ftypes "github.com/openfaas/faas-provider/types"
 	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/async"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
 
 	"github.com/openfaas/faas/gateway/scaling"
@@ -21,43 +23,28 @@ import (
 
 // MakeQueuedProxy accepts work onto a queue
 func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer, pathTransformer middleware.URLPathTransformer, defaultNS string, functionQuery scaling.FunctionQuery) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var body []byte
-		if r.Body != nil {
-			defer r.Body.Close()
-
-			var err error
-			body, err = io.ReadAll(r.Body)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-		}
+	queue := async.NewQueue(queuer, pathTransformer)
 
-		callbackURL, err := getCallbackURLHeader(r.Header)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := getCallbackURLHeader(r.Header); err != nil {
+			httperror.Write(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		vars := mux.Vars(r)
 		name := vars["name"]
 
-		req := &ftypes.QueueRequest{
-			Function:    name,
-			Body:        body,
-			Method:      r.Method,
-			QueryString: r.URL.RawQuery,
-			Path:        pathTransformer.Transform(r),
-			Header:      r.Header,
-			Host:        r.Host,
-			CallbackURL: callbackURL,
-		}
-
-		if err = queuer.Queue(req); err != nil {
-			log.Printf("Error queuing request: %v", err)
-			http.Error(w, fmt.Sprintf("Error queuing request: %s", err.Error()),
-				http.StatusInternalServerError)
+		if err := queue.Enqueue(r.Context(), name, r); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				httperror.Write(w, r, http.StatusRequestEntityTooLarge, err.Error())
+			case errors.Is(err, async.ErrReadBody):
+				httperror.Write(w, r, http.StatusBadRequest, err.Error())
+			default:
+				log.Printf("Error queuing request: %v", err)
+				httperror.Write(w, r, http.StatusInternalServerError, fmt.Sprintf("Error queuing request: %s", err.Error()))
+			}
 			return
 		}
 
@@ -66,19 +53,7 @@ func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer,
 }
 
 func getCallbackURLHeader(header http.Header) (*url.URL, error) {
-	value := header.Get("X-Callback-Url")
-	var callbackURL *url.URL
-
-	if len(value) > 0 {
-		urlVal, err := url.Parse(value)
-		if err != nil {
-			return callbackURL, err
-		}
-
-		callbackURL = urlVal
-	}
-
-	return callbackURL, nil
+	return async.CallbackURL(header)
 }
 
 func getNameParts(name string) (fn, ns string) {
@@ -4,8 +4,17 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/gorilla/mux"
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
 )
 
 func Test_getNameParts(t *testing.T) {
@@ -72,3 +81,64 @@ func Test_getCallbackURLHeader_ParseFails(t *testing.T) {
 		t.Fatal("wanted a parsing error.")
 	}
 }
+
+// failingQueuer fails to queue every request with err
+type failingQueuer struct {
+	err error
+}
+
+func (q failingQueuer) Queue(req *ftypes.QueueRequest) error {
+	return q.err
+}
+
+// errorReader fails every read
+type errorReader struct{}
+
+func (errorReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func Test_MakeQueuedProxy_Errors(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       func(w http.ResponseWriter) io.ReadCloser
+		queueErr   error
+		wantStatus int
+	}{
+		{
+			name: "body over the limit",
+			body: func(w http.ResponseWriter) io.ReadCloser {
+				return http.MaxBytesReader(w, io.NopCloser(strings.NewReader("too long")), 2)
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "body not read",
+			body:       func(w http.ResponseWriter) io.ReadCloser { return io.NopCloser(errorReader{}) },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "queue unavailable",
+			body:       func(w http.ResponseWriter) io.ReadCloser { return io.NopCloser(strings.NewReader("hello")) },
+			queueErr:   errors.New("nats: connection closed"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := MakeQueuedProxy(metrics.MetricOptions{}, failingQueuer{err: tc.queueErr}, middleware.TransparentURLPathTransformer{}, "openfaas-fn", nil)
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+			req.Body = tc.body(rr)
+			req = mux.SetURLVars(req, map[string]string{"name": "figlet"})
+
+			handler(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Errorf("want status: %d, got: %d %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
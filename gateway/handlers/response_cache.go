@@ -0,0 +1,223 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/cache"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// CacheHeader tells the caller whether a response came from the cache
+const CacheHeader = "X-Cache"
+
+// MakeResponseCacheHandler serves GET requests from cache when an earlier
+// response to the same path and query is still fresh, with X-Cache: HIT.
+// Only functions with a com.faas.cache_ttl label are cached, a 200 response
+// is stored for the label's TTL, or for the max-age of its Cache-Control
+// header when that is shorter, and is only served to requests with the same
+// values for the headers named by its Vary header. Requests with
+// credentials, event streams, responses larger than maxBodyBytes, and
+// requests and responses with Cache-Control: no-store, are never cached.
+// Requests with Cache-Control: no-cache are sent to the function and its
+// response replaces the cached one.
+func MakeResponseCacheHandler(next http.HandlerFunc, responses *cache.Cache, functionQuery scaling.FunctionQuery, defaultNamespace string, maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || isWebSocketUpgrade(r) || isEventStream(r) || hasCredentials(r) || hasDirective(r.Header, "no-store") {
+			next(w, r)
+			return
+		}
+
+		// functions without a TTL are passed through, so that their
+		// responses are not held in memory
+		functionName := middleware.GetServiceName(r.URL.Path)
+		name, namespace := middleware.GetNamespace(defaultNamespace, functionName)
+		status, err := functionQuery.Get(name, namespace)
+		if err != nil || status.CacheTTL <= 0 {
+			next(w, r)
+			return
+		}
+
+		key := r.Method + " " + r.URL.RequestURI()
+
+		if res, ok := responses.Get(key); ok && !hasDirective(r.Header, "no-cache") && sameVary(res.Vary, r.Header) {
+			tracing.SetCacheHit(r.Context(), true)
+			metrics.RecordResponseCache(functionName, "hit")
+
+			for k, v := range res.Header {
+				w.Header()[k] = append([]string(nil), v...)
+			}
+			w.Header().Set(CacheHeader, "HIT")
+			w.WriteHeader(res.StatusCode)
+			w.Write(res.Body)
+			return
+		}
+
+		tracing.SetCacheHit(r.Context(), false)
+		metrics.RecordResponseCache(functionName, "miss")
+
+		cw := &cachingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK, maxBodyBytes: maxBodyBytes}
+		next(cw, r)
+
+		if cw.statusCode != http.StatusOK || cw.header == nil || cw.uncacheable {
+			return
+		}
+
+		if ttl := responseTTL(cw.header, status.CacheTTL); ttl > 0 {
+			responses.Set(key, &cache.Response{
+				StatusCode: cw.statusCode,
+				Header:     cw.header,
+				Body:       cw.body.Bytes(),
+				Vary:       varyHeader(cw.header, r.Header),
+			}, ttl)
+		}
+	}
+}
+
+// hasCredentials reports whether the response to r could be for its caller
+// alone, so must not be served to anyone else
+func hasCredentials(r *http.Request) bool {
+	return len(r.Header.Get("Authorization")) > 0 || len(r.Header.Values("Cookie")) > 0
+}
+
+// varyHeader copies the request headers named by the response's Vary header
+func varyHeader(response, request http.Header) http.Header {
+	vary := http.Header{}
+	for _, value := range response.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				vary[http.CanonicalHeaderKey(name)] = request.Values(name)
+			}
+		}
+	}
+	return vary
+}
+
+// sameVary reports whether request has the same values as were stored for
+// each header named by the cached response's Vary header
+func sameVary(vary, request http.Header) bool {
+	for name, values := range vary {
+		if strings.Join(values, ",") != strings.Join(request.Values(name), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// responseTTL is how long a response can be cached for, labelTTL or the
+// response's s-maxage or max-age when that is shorter. It is 0 when the
+// response must not be shared between callers.
+func responseTTL(header http.Header, labelTTL time.Duration) time.Duration {
+	if hasDirective(header, "no-store") || hasDirective(header, "private") || len(header.Values("Set-Cookie")) > 0 || varyAll(header) {
+		return 0
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directiveValue(header, directive); ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0
+			}
+			if maxAge := time.Duration(seconds) * time.Second; maxAge < labelTTL {
+				return maxAge
+			}
+			break
+		}
+	}
+
+	return labelTTL
+}
+
+// varyAll reports whether the response varies on more than request headers,
+// i.e. Vary: *, so can never be matched to another request
+func varyAll(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasDirective reports whether the Cache-Control header includes directive
+func hasDirective(header http.Header, directive string) bool {
+	_, ok := directiveValue(header, directive)
+	return ok
+}
+
+// directiveValue returns the value of a Cache-Control directive i.e. 60
+// for max-age=60, and whether it was present
+func directiveValue(header http.Header, directive string) (string, bool) {
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(name, directive) {
+				return strings.Trim(v, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// cachingResponseWriter passes the response through with X-Cache: MISS,
+// keeping a copy which can be stored once it is complete. An event stream,
+// or a body over maxBodyBytes, is uncacheable and no longer copied.
+type cachingResponseWriter struct {
+	http.ResponseWriter
+
+	statusCode   int
+	header       http.Header
+	body         bytes.Buffer
+	maxBodyBytes int64
+	uncacheable  bool
+}
+
+func (cw *cachingResponseWriter) WriteHeader(statusCode int) {
+	if cw.header != nil {
+		return
+	}
+
+	cw.statusCode = statusCode
+	cw.header = cw.ResponseWriter.Header().Clone()
+	if strings.HasPrefix(cw.header.Get("Content-Type"), "text/event-stream") {
+		cw.uncacheable = true
+	}
+	cw.ResponseWriter.Header().Set(CacheHeader, "MISS")
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *cachingResponseWriter) Write(b []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+
+	if !cw.uncacheable {
+		if int64(cw.body.Len()+len(b)) > cw.maxBodyBytes {
+			cw.uncacheable = true
+			cw.body = bytes.Buffer{}
+		} else {
+			cw.body.Write(b)
+		}
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cachingResponseWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (cw *cachingResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,306 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/cache"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel"
+)
+
+// countingFunction responds with how many times it has been called, and the
+// given Cache-Control header
+func countingFunction(cacheControl string) (http.HandlerFunc, *int) {
+	calls := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if len(cacheControl) > 0 {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "call %d", calls)
+	}, &calls
+}
+
+// cacheTTLQuery is a function labelled to cache its responses for a minute
+var cacheTTLQuery = fakeFunctionQuery{res: scaling.ServiceQueryResponse{CacheTTL: time.Minute}}
+
+func cachedGet(handler http.HandlerFunc, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet?name=openfaas", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_MakeResponseCacheHandler_MissThenHit(t *testing.T) {
+	next, calls := countingFunction("public, max-age=60")
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	miss := cachedGet(handler, nil)
+	if miss.Header().Get(CacheHeader) != "MISS" || miss.Body.String() != "call 1" {
+		t.Fatalf("want the first call to miss, got: %q %q", miss.Header().Get(CacheHeader), miss.Body.String())
+	}
+
+	hit := cachedGet(handler, nil)
+	if hit.Header().Get(CacheHeader) != "HIT" {
+		t.Errorf("want X-Cache: HIT, got: %q", hit.Header().Get(CacheHeader))
+	}
+	if hit.Code != http.StatusOK || hit.Body.String() != "call 1" || hit.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("want the stored response, got: %d %q %q", hit.Code, hit.Body.String(), hit.Header().Get("Content-Type"))
+	}
+	if *calls != 1 {
+		t.Errorf("want 1 call to the function, got: %d", *calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_RecordsHitOnSpan(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	next, _ := countingFunction("max-age=60")
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	for i := 0; i < 2; i++ {
+		ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil).WithContext(ctx))
+		span.End()
+	}
+
+	spans := recorder.Named("request")
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+	for i, want := range []bool{false, true} {
+		found := false
+		for _, kv := range spans[i].Attributes() {
+			if kv.Key == tracing.CacheHitKey {
+				found = true
+				if kv.Value.AsBool() != want {
+					t.Errorf("request %d: want %s: %t, got: %t", i+1, tracing.CacheHitKey, want, kv.Value.AsBool())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("request %d: want a %s attribute", i+1, tracing.CacheHitKey)
+		}
+	}
+}
+
+func Test_MakeResponseCacheHandler_Expires(t *testing.T) {
+	next, calls := countingFunction("")
+	query := fakeFunctionQuery{res: scaling.ServiceQueryResponse{CacheTTL: time.Millisecond * 20}}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), query, "openfaas-fn", 1<<20)
+
+	cachedGet(handler, nil)
+	if hit := cachedGet(handler, nil); hit.Header().Get(CacheHeader) != "HIT" {
+		t.Fatalf("want the label TTL to cache the response, got: %q", hit.Header().Get(CacheHeader))
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	expired := cachedGet(handler, nil)
+	if expired.Header().Get(CacheHeader) != "MISS" || expired.Body.String() != "call 2" {
+		t.Errorf("want the function called again once the TTL has passed, got: %q %q", expired.Header().Get(CacheHeader), expired.Body.String())
+	}
+	if *calls != 2 {
+		t.Errorf("want 2 calls to the function, got: %d", *calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_NoStoreFromFunction(t *testing.T) {
+	next, calls := countingFunction("no-store")
+	query := fakeFunctionQuery{res: scaling.ServiceQueryResponse{CacheTTL: time.Minute}}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), query, "openfaas-fn", 1<<20)
+
+	cachedGet(handler, nil)
+	cachedGet(handler, nil)
+
+	if *calls != 2 {
+		t.Errorf("want a no-store response never cached, even with a label TTL, got: %d calls", *calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_NoStoreFromClient(t *testing.T) {
+	next, calls := countingFunction("max-age=60")
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	noStore := http.Header{"Cache-Control": []string{"no-store"}}
+
+	bypassed := cachedGet(handler, noStore)
+	if len(bypassed.Header().Get(CacheHeader)) > 0 {
+		t.Errorf("want no X-Cache header when the cache is bypassed, got: %q", bypassed.Header().Get(CacheHeader))
+	}
+
+	// the bypassed response was not stored
+	if miss := cachedGet(handler, nil); miss.Header().Get(CacheHeader) != "MISS" {
+		t.Errorf("want a miss after a no-store request, got: %q", miss.Header().Get(CacheHeader))
+	}
+
+	// a stored response is not served to a no-store request
+	if bypassed := cachedGet(handler, noStore); bypassed.Body.String() != "call 3" {
+		t.Errorf("want the function called for a no-store request, got: %q", bypassed.Body.String())
+	}
+	if *calls != 3 {
+		t.Errorf("want 3 calls to the function, got: %d", *calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_PassesThroughWithoutTTL(t *testing.T) {
+	next, calls := countingFunction("max-age=60")
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), fakeFunctionQuery{}, "openfaas-fn", 1<<20)
+
+	cachedGet(handler, nil)
+	rr := cachedGet(handler, nil)
+
+	if *calls != 2 {
+		t.Errorf("want a function without a label TTL not cached, got: %d calls", *calls)
+	}
+	if got := rr.Header().Get(CacheHeader); len(got) > 0 {
+		t.Errorf("want the response passed through without X-Cache, got: %q", got)
+	}
+}
+
+func Test_responseTTL_MaxAgeShortensLabelTTL(t *testing.T) {
+	if got := responseTTL(http.Header{"Cache-Control": []string{"max-age=10"}}, time.Minute); got != 10*time.Second {
+		t.Errorf("want the shorter max-age, got: %s", got)
+	}
+	if got := responseTTL(http.Header{"Cache-Control": []string{"max-age=600"}}, time.Minute); got != time.Minute {
+		t.Errorf("want the label TTL when max-age is longer, got: %s", got)
+	}
+}
+
+func Test_MakeResponseCacheHandler_SkipsEventStreams(t *testing.T) {
+	calls := 0
+	next := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %d\n\n", calls)
+	}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	if rr := cachedGet(handler, http.Header{"Accept": []string{"text/event-stream"}}); len(rr.Header().Get(CacheHeader)) > 0 {
+		t.Errorf("want a request for an event stream passed through, got X-Cache: %q", rr.Header().Get(CacheHeader))
+	}
+
+	cachedGet(handler, nil)
+	if rr := cachedGet(handler, nil); rr.Header().Get(CacheHeader) != "MISS" {
+		t.Errorf("want an event stream response never cached, got X-Cache: %q", rr.Header().Get(CacheHeader))
+	}
+	if calls != 3 {
+		t.Errorf("want 3 calls to the function, got: %d", calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_SkipsBodiesOverLimit(t *testing.T) {
+	calls := 0
+	next := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		for i := 0; i < 4; i++ {
+			w.Write([]byte("0123456789"))
+		}
+	}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 32)
+
+	first := cachedGet(handler, nil)
+	if first.Body.Len() != 40 {
+		t.Errorf("want the whole body passed on, got: %d bytes", first.Body.Len())
+	}
+	if second := cachedGet(handler, nil); second.Header().Get(CacheHeader) != "MISS" {
+		t.Errorf("want a body over the limit not cached, got X-Cache: %q", second.Header().Get(CacheHeader))
+	}
+	if calls != 2 {
+		t.Errorf("want 2 calls to the function, got: %d", calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_SkipsCredentialedRequests(t *testing.T) {
+	for _, header := range []http.Header{
+		{"Authorization": []string{"Bearer alice"}},
+		{"Cookie": []string{"session=alice"}},
+	} {
+		next, calls := countingFunction("public, max-age=60")
+		handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+		cachedGet(handler, header)
+		if other := cachedGet(handler, nil); other.Header().Get(CacheHeader) != "MISS" {
+			t.Errorf("want a credentialed response not served to another caller, got X-Cache: %q", other.Header().Get(CacheHeader))
+		}
+		cachedGet(handler, header)
+
+		if *calls != 3 {
+			t.Errorf("want every call with %v sent to the function, got: %d calls", header, *calls)
+		}
+	}
+}
+
+func Test_MakeResponseCacheHandler_HonoursVary(t *testing.T) {
+	calls := 0
+	next := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "%s %d", r.Header.Get("Accept-Language"), calls)
+	}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	english := http.Header{"Accept-Language": []string{"en"}}
+	german := http.Header{"Accept-Language": []string{"de"}}
+
+	cachedGet(handler, english)
+	if hit := cachedGet(handler, english); hit.Header().Get(CacheHeader) != "HIT" || hit.Body.String() != "en 1" {
+		t.Errorf("want the response served for the same Accept-Language, got: %q %q", hit.Header().Get(CacheHeader), hit.Body.String())
+	}
+	if miss := cachedGet(handler, german); miss.Header().Get(CacheHeader) != "MISS" || miss.Body.String() != "de 2" {
+		t.Errorf("want the function called for another Accept-Language, got: %q %q", miss.Header().Get(CacheHeader), miss.Body.String())
+	}
+}
+
+func Test_MakeResponseCacheHandler_NotCachedWithVaryAll(t *testing.T) {
+	calls := 0
+	next := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "*")
+	}
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	cachedGet(handler, nil)
+	cachedGet(handler, nil)
+
+	if calls != 2 {
+		t.Errorf("want a Vary: * response never cached, got: %d calls", calls)
+	}
+}
+
+func Test_MakeResponseCacheHandler_NoCacheFromClient(t *testing.T) {
+	next, calls := countingFunction("max-age=60")
+	handler := MakeResponseCacheHandler(next, cache.New(10, 1<<20), cacheTTLQuery, "openfaas-fn", 1<<20)
+
+	cachedGet(handler, nil)
+	if fresh := cachedGet(handler, http.Header{"Cache-Control": []string{"no-cache"}}); fresh.Body.String() != "call 2" {
+		t.Errorf("want the function called for a no-cache request, got: %q", fresh.Body.String())
+	}
+
+	// the fresh response replaced the cached one
+	if hit := cachedGet(handler, nil); hit.Header().Get(CacheHeader) != "HIT" || hit.Body.String() != "call 2" {
+		t.Errorf("want the fresh response cached, got: %q %q", hit.Header().Get(CacheHeader), hit.Body.String())
+	}
+	if *calls != 2 {
+		t.Errorf("want 2 calls to the function, got: %d", *calls)
+	}
+}
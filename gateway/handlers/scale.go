@@ -0,0 +1,84 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// scaleRequest is the body of a request to /system/scale/{name}
+type scaleRequest struct {
+	Replicas *uint64 `json:"replicas"`
+}
+
+// MakeScaleHandler sets the replicas of the function named in the path to
+// the count in the body. A count outside the function's min and max
+// replicas, from its scaling labels, is rejected with a 409 rather than
+// being adjusted, so the caller knows it did not get what it asked for.
+func MakeScaleHandler(scaler scaling.Scaler, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, name)
+		if !strings.Contains(name, ".") {
+			namespace = requestNamespace(r, "", defaultNamespace)
+		}
+
+		if err := middleware.ValidateNamespace(namespace); err != nil {
+			httperror.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		req := scaleRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Replicas == nil {
+			httperror.Write(w, r, http.StatusBadRequest, `request body must be {"replicas": n}`)
+			return
+		}
+		replicas := *req.Replicas
+
+		_, span := startFunctionSpan(r.Context(), "scale "+functionName,
+			tracing.FunctionNameKey.String(functionName),
+			tracing.NamespaceKey.String(namespace),
+			tracing.ReplicasToKey.Int64(int64(replicas)),
+		)
+		defer span.End()
+
+		current, err := scaler.GetReplicas(functionName, namespace)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			httperror.Write(w, r, http.StatusNotFound, fmt.Sprintf("unable to find function: %s.%s", functionName, namespace))
+			return
+		}
+		span.SetAttributes(tracing.ReplicasFromKey.Int64(int64(current.Replicas)))
+
+		min, max := scaling.ReplicaBounds(current)
+		if replicas < min || replicas > max {
+			span.SetStatus(codes.Error, "replicas out of range")
+			httperror.Write(w, r, http.StatusConflict, fmt.Sprintf("replicas must be between %d and %d for %s.%s, requested: %d", min, max, functionName, namespace, replicas))
+			return
+		}
+
+		if err := scaler.SetReplicas(functionName, namespace, replicas); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			httperror.Write(w, r, http.StatusBadGateway, fmt.Sprintf("unable to scale function: %s", err))
+			return
+		}
+
+		log.Printf("[Scale] function=%s.%s %d => %d requested", functionName, namespace, current.Replicas, replicas)
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+type mockScaler struct {
+	res scaling.ServiceQueryResponse
+	err error
+
+	set     bool
+	service string
+	count   uint64
+}
+
+func (s *mockScaler) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	return s.res, s.err
+}
+
+func (s *mockScaler) SetReplicas(service, namespace string, count uint64) error {
+	s.set = true
+	s.service = service + "." + namespace
+	s.count = count
+	return nil
+}
+
+func callScaleHandler(scaler scaling.Scaler, name, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/system/scale/"+name, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+
+	rr := httptest.NewRecorder()
+	MakeScaleHandler(scaler, "openfaas-fn")(rr, req)
+	return rr
+}
+
+func Test_MakeScaleHandler_WithinRange(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	scaler := &mockScaler{res: scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10}}
+
+	rr := callScaleHandler(scaler, "figlet.dev", `{"replicas": 4}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("want status: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	if !scaler.set || scaler.service != "figlet.dev" || scaler.count != 4 {
+		t.Errorf("want figlet.dev scaled to 4, got: %s => %d", scaler.service, scaler.count)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	want := map[string]int64{
+		string(tracing.ReplicasFromKey): 1,
+		string(tracing.ReplicasToKey):   4,
+	}
+	for _, kv := range spans[0].Attributes() {
+		if value, ok := want[string(kv.Key)]; ok {
+			if kv.Value.AsInt64() != value {
+				t.Errorf("want %s: %d, got: %d", kv.Key, value, kv.Value.AsInt64())
+			}
+			delete(want, string(kv.Key))
+		}
+	}
+	for key := range want {
+		t.Errorf("want a %s attribute", key)
+	}
+}
+
+func Test_MakeScaleHandler_OutOfRange(t *testing.T) {
+	cases := map[string]string{
+		"below min": `{"replicas": 1}`,
+		"above max": `{"replicas": 6}`,
+	}
+
+	for name, body := range cases {
+		scaler := &mockScaler{res: scaling.ServiceQueryResponse{Replicas: 2, MinReplicas: 2, MaxReplicas: 5}}
+
+		rr := callScaleHandler(scaler, "figlet", body)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("%s: want status: %d, got: %d", name, http.StatusConflict, rr.Code)
+		}
+		if scaler.set {
+			t.Errorf("%s: want the replicas left unchanged", name)
+		}
+	}
+}
+
+func Test_MakeScaleHandler_ClampsBounds(t *testing.T) {
+	// a max below the min is raised to the min
+	scaler := &mockScaler{res: scaling.ServiceQueryResponse{MinReplicas: 3, MaxReplicas: 2}}
+	if rr := callScaleHandler(scaler, "figlet", `{"replicas": 3}`); rr.Code != http.StatusAccepted {
+		t.Errorf("want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+
+	scaler = &mockScaler{res: scaling.ServiceQueryResponse{MinReplicas: 3, MaxReplicas: 2}}
+	if rr := callScaleHandler(scaler, "figlet", `{"replicas": 4}`); rr.Code != http.StatusConflict {
+		t.Errorf("want status: %d, got: %d", http.StatusConflict, rr.Code)
+	}
+
+	// without a max label, the default max applies
+	scaler = &mockScaler{res: scaling.ServiceQueryResponse{MinReplicas: 1}}
+	if rr := callScaleHandler(scaler, "figlet", `{"replicas": 6}`); rr.Code != http.StatusConflict {
+		t.Errorf("want status: %d above the default max, got: %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func Test_MakeScaleHandler_BadRequest(t *testing.T) {
+	for _, body := range []string{``, `{}`, `{"replicas": -1}`, `{"replicas": "two"}`} {
+		scaler := &mockScaler{res: scaling.ServiceQueryResponse{MaxReplicas: 5}}
+
+		if rr := callScaleHandler(scaler, "figlet", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: want status: %d, got: %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func Test_MakeScaleHandler_NotFound(t *testing.T) {
+	scaler := &mockScaler{err: errors.New("server returned non-200 status code (404)")}
+
+	if rr := callScaleHandler(scaler, "figlet", `{"replicas": 1}`); rr.Code != http.StatusNotFound {
+		t.Errorf("want status: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -8,15 +8,18 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
 // MakeScalingHandler creates handler which can scale a function from
 // zero to N replica(s). After scaling the next http.HandlerFunc will
 // be called. If the function is not ready after the configured
-// amount of attempts / queries then next will not be invoked and a status
-// will be returned to the client.
+// amount of attempts / queries, or ScaleTimeout, then next will not be
+// invoked and a 503 will be returned to the client.
 func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, config scaling.ScalingConfig, defaultNamespace string) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -29,8 +32,7 @@ func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, co
 			errStr := fmt.Sprintf("error finding function %s.%s: %s", functionName, namespace, res.Error.Error())
 			log.Printf("Scaling: %s\n", errStr)
 
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(errStr))
+			httperror.Write(w, r, http.StatusNotFound, errStr)
 			return
 		}
 
@@ -38,17 +40,26 @@ func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, co
 			errStr := fmt.Sprintf("error finding function %s.%s: %s", functionName, namespace, res.Error.Error())
 			log.Printf("Scaling: %s\n", errStr)
 
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(errStr))
+			httperror.Write(w, r, http.StatusInternalServerError, errStr)
 			return
 		}
 
 		if res.Available {
+			if res.ColdStart {
+				tracing.AddColdStartEvent(r.Context(), res.Duration)
+				metrics.ObserveColdStart(functionName+"."+namespace, res.Duration)
+			}
+
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		log.Printf("[Scale] function=%s.%s 0=>N timed-out after %.4fs\n",
 			functionName, namespace, res.Duration.Seconds())
+
+		tracing.AddColdStartTimeoutEvent(r.Context(), res.Duration)
+
+		httperror.WriteCode(w, r, http.StatusServiceUnavailable, "function_not_ready",
+			fmt.Sprintf("function %s.%s was not ready after %.4fs", functionName, namespace, res.Duration.Seconds()))
 	}
 }
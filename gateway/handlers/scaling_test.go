@@ -0,0 +1,73 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// scaledToZeroQuery reports a function which never gets a ready replica
+type scaledToZeroQuery struct{}
+
+func (scaledToZeroQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	return scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1}, nil
+}
+
+func (scaledToZeroQuery) SetReplicas(service, namespace string, count uint64) error {
+	return nil
+}
+
+func Test_MakeScalingHandler_TimesOutWith503(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	config := scaling.ScalingConfig{
+		MaxPollCount:         1000,
+		FunctionPollInterval: time.Millisecond,
+		MaxPollInterval:      time.Millisecond * 5,
+		ScaleTimeout:         time.Millisecond * 20,
+		SetScaleRetries:      1,
+		ServiceQuery:         scaledToZeroQuery{},
+	}
+	scaler := scaling.NewFunctionScaler(config, scaling.NewFunctionCache(time.Millisecond))
+
+	called := false
+	next := func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}
+	handler := tracing.Middleware(MakeScalingHandler(next, scaler, config, "openfaas-fn"))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("want status: %d, got: %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if called {
+		t.Errorf("want the request not proxied to a function which is not ready")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	for _, event := range spans[0].Events() {
+		if event.Name != tracing.ColdStartEvent {
+			continue
+		}
+		for _, kv := range event.Attributes {
+			if kv.Key == tracing.ColdStartTimedOutKey && kv.Value.AsBool() {
+				return
+			}
+		}
+	}
+	t.Errorf("want a %s event with %s", tracing.ColdStartEvent, tracing.ColdStartTimedOutKey)
+}
@@ -0,0 +1,166 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// SecretProvider creates, updates, lists and deletes the secrets which are
+// mounted into functions by the faas-provider
+type SecretProvider interface {
+	List(ctx context.Context, namespace string) ([]types.Secret, error)
+	Create(ctx context.Context, secret types.Secret) error
+	Update(ctx context.Context, secret types.Secret) error
+	Delete(ctx context.Context, name, namespace string) error
+}
+
+// redacted replaces a secret's value wherever it appears in an error
+const redacted = "[REDACTED]"
+
+// maxSecretNameLength is the longest DNS subdomain, which secrets are named by
+const maxSecretNameLength = 253
+
+var secretNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// MakeSecretsHandler serves /system/secrets: GET lists the names of the
+// secrets in the namespace query parameter, POST creates a secret, PUT
+// updates its value and DELETE removes it. Values are never logged, traced
+// or returned, and are redacted from any error passed on from the provider.
+func MakeSecretsHandler(provider SecretProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listSecrets(w, r, provider)
+		case http.MethodPost:
+			applySecret(w, r, "create", provider.Create)
+		case http.MethodPut:
+			applySecret(w, r, "update", provider.Update)
+		case http.MethodDelete:
+			deleteSecret(w, r, provider)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func listSecrets(w http.ResponseWriter, r *http.Request, provider SecretProvider) {
+	ctx, span := startFunctionSpan(r.Context(), "list secrets")
+	defer span.End()
+
+	secrets, err := provider.List(ctx, r.URL.Query().Get("namespace"))
+	if err != nil {
+		writeProviderError(w, r, span, err)
+		return
+	}
+
+	names := make([]types.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		names = append(names, types.Secret{Name: secret.Name, Namespace: secret.Namespace})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(names)
+}
+
+func applySecret(w http.ResponseWriter, r *http.Request, operation string, apply func(context.Context, types.Secret) error) {
+	secret := types.Secret{}
+	if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+		// the decoder's error may quote the body, which holds the value
+		writeValidationErrors(w, r, []FieldError{{Message: "unable to parse the secret"}})
+		return
+	}
+
+	ctx, span := startFunctionSpan(r.Context(), operation+" secret "+secret.Name, tracing.SecretNameKey.String(secret.Name))
+	defer span.End()
+
+	errs := validateSecretName("name", secret.Name)
+	if len(secret.Value) == 0 && len(secret.RawValue) == 0 {
+		errs = append(errs, FieldError{Field: "value", Message: "value or rawValue is required"})
+	}
+	if len(errs) > 0 {
+		span.SetStatus(codes.Error, "invalid secret")
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if err := apply(ctx, secret); err != nil {
+		writeProviderError(w, r, span, redactSecret(err, secret))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func deleteSecret(w http.ResponseWriter, r *http.Request, provider SecretProvider) {
+	secret := types.Secret{}
+	if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+		writeValidationErrors(w, r, []FieldError{{Message: "unable to parse the secret"}})
+		return
+	}
+
+	ctx, span := startFunctionSpan(r.Context(), "delete secret "+secret.Name, tracing.SecretNameKey.String(secret.Name))
+	defer span.End()
+
+	if errs := validateSecretName("name", secret.Name); len(errs) > 0 {
+		span.SetStatus(codes.Error, "invalid secret")
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if err := provider.Delete(ctx, secret.Name, secret.Namespace); err != nil {
+		writeProviderError(w, r, span, redactSecret(err, secret))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func validateSecretName(field, name string) []FieldError {
+	switch {
+	case len(name) == 0:
+		return []FieldError{{Field: field, Message: "is required"}}
+	case len(name) > maxSecretNameLength:
+		return []FieldError{{Field: field, Message: fmt.Sprintf("must be no more than %d characters", maxSecretNameLength)}}
+	case !secretNamePattern.MatchString(name):
+		return []FieldError{{Field: field, Message: "must contain only lowercase letters, numbers, '-' and '.', and start and end with a letter or number"}}
+	}
+	return nil
+}
+
+// redactSecret removes the secret's value from err, keeping the status of a
+// ProviderError so the caller still learns why the request failed
+func redactSecret(err error, secret types.Secret) error {
+	values := []string{}
+	if len(secret.Value) > 0 {
+		values = append(values, secret.Value)
+	}
+	if len(secret.RawValue) > 0 {
+		values = append(values, string(secret.RawValue))
+	}
+
+	redact := func(message string) string {
+		for _, value := range values {
+			message = strings.ReplaceAll(message, value, redacted)
+		}
+		return message
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return &ProviderError{StatusCode: providerErr.StatusCode, Message: redact(providerErr.Message)}
+	}
+	return errors.New(redact(err.Error()))
+}
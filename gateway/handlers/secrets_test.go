@@ -0,0 +1,204 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+// mockSecretProvider keeps secrets in memory, err is returned from Create
+// and Update
+type mockSecretProvider struct {
+	secrets map[string]types.Secret
+	err     error
+}
+
+func newMockSecretProvider() *mockSecretProvider {
+	return &mockSecretProvider{secrets: map[string]types.Secret{}}
+}
+
+func (p *mockSecretProvider) List(ctx context.Context, namespace string) ([]types.Secret, error) {
+	var list []types.Secret
+	for _, secret := range p.secrets {
+		list = append(list, secret)
+	}
+	return list, nil
+}
+
+func (p *mockSecretProvider) Create(ctx context.Context, secret types.Secret) error {
+	if p.err != nil {
+		return p.err
+	}
+	if _, ok := p.secrets[secret.Name]; ok {
+		return &ProviderError{StatusCode: http.StatusConflict, Message: "secret already exists"}
+	}
+	p.secrets[secret.Name] = secret
+	return nil
+}
+
+func (p *mockSecretProvider) Update(ctx context.Context, secret types.Secret) error {
+	if p.err != nil {
+		return p.err
+	}
+	if _, ok := p.secrets[secret.Name]; !ok {
+		return &ProviderError{StatusCode: http.StatusNotFound, Message: "secret not found"}
+	}
+	p.secrets[secret.Name] = secret
+	return nil
+}
+
+func (p *mockSecretProvider) Delete(ctx context.Context, name, namespace string) error {
+	if _, ok := p.secrets[name]; !ok {
+		return &ProviderError{StatusCode: http.StatusNotFound, Message: "secret not found"}
+	}
+	delete(p.secrets, name)
+	return nil
+}
+
+func callSecretsHandler(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/system/secrets", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_MakeSecretsHandler_CRUD(t *testing.T) {
+	provider := newMockSecretProvider()
+	handler := MakeSecretsHandler(provider)
+
+	rr := callSecretsHandler(handler, http.MethodPost, `{"name":"db-password","value":"s3cr3t"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("create: want status: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	rr = callSecretsHandler(handler, http.MethodPut, `{"name":"db-password","value":"n3w"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("update: want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+	if provider.secrets["db-password"].Value != "n3w" {
+		t.Errorf("update: want the new value stored")
+	}
+
+	rr = callSecretsHandler(handler, http.MethodGet, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list: want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "n3w") {
+		t.Errorf("list: want values left out, got: %s", rr.Body.String())
+	}
+	secrets := []types.Secret{}
+	json.Unmarshal(rr.Body.Bytes(), &secrets)
+	if len(secrets) != 1 || secrets[0].Name != "db-password" {
+		t.Errorf("list: want db-password, got: %+v", secrets)
+	}
+
+	rr = callSecretsHandler(handler, http.MethodDelete, `{"name":"db-password"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("delete: want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+
+	rr = callSecretsHandler(handler, http.MethodDelete, `{"name":"db-password"}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("delete again: want status: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func Test_MakeSecretsHandler_InvalidSecret(t *testing.T) {
+	provider := newMockSecretProvider()
+	handler := MakeSecretsHandler(provider)
+
+	cases := map[string]string{
+		`{"name":"DB_PASSWORD","value":"x"}`: "name",
+		`{"name":"db-password"}`:             "value",
+		`{"value":"x"}`:                      "name",
+	}
+	for body, field := range cases {
+		rr := callSecretsHandler(handler, http.MethodPost, body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status: %d, got: %d", body, http.StatusBadRequest, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), fmt.Sprintf(`"field":%q`, field)) {
+			t.Errorf("%s: want an error for %s, got: %s", body, field, rr.Body.String())
+		}
+	}
+
+	if len(provider.secrets) != 0 {
+		t.Errorf("want invalid secrets not to reach the provider")
+	}
+}
+
+func Test_MakeSecretsHandler_RedactsErrors(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	cases := map[string]error{
+		"provider error":  &ProviderError{StatusCode: http.StatusUnprocessableEntity, Message: `invalid secret value "s3cr3t"`},
+		"transport error": errors.New(`write: connection reset, sent: {"value":"s3cr3t"}`),
+	}
+
+	for name, err := range cases {
+		provider := newMockSecretProvider()
+		provider.err = err
+
+		rr := callSecretsHandler(MakeSecretsHandler(provider), http.MethodPost, `{"name":"db-password","value":"s3cr3t"}`)
+		if strings.Contains(rr.Body.String(), "s3cr3t") {
+			t.Errorf("%s: want the value redacted, got: %s", name, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), redacted) {
+			t.Errorf("%s: want %s in the error, got: %s", name, redacted, rr.Body.String())
+		}
+	}
+
+	rr := callSecretsHandler(MakeSecretsHandler(&mockSecretProvider{err: cases["provider error"]}), http.MethodPost, `{"name":"db-password","value":"s3cr3t"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("want the provider's status kept, got: %d", rr.Code)
+	}
+
+	// the malformed body's value must not be quoted back either
+	rr = callSecretsHandler(MakeSecretsHandler(newMockSecretProvider()), http.MethodPost, `{"name":"db-password","value":s3cr3t}`)
+	if rr.Code != http.StatusBadRequest || strings.Contains(rr.Body.String(), "s3cr3t") {
+		t.Errorf("want a 400 without the value, got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	for _, span := range recorder.Ended() {
+		for _, kv := range span.Attributes() {
+			if strings.Contains(kv.Value.Emit(), "s3cr3t") {
+				t.Errorf("want the value left out of span %s, got %s: %s", span.Name(), kv.Key, kv.Value.Emit())
+			}
+		}
+		for _, event := range span.Events() {
+			for _, kv := range event.Attributes {
+				if strings.Contains(kv.Value.Emit(), "s3cr3t") {
+					t.Errorf("want the value left out of span %s event %s", span.Name(), event.Name)
+				}
+			}
+		}
+		if strings.Contains(span.Status().Description, "s3cr3t") {
+			t.Errorf("want the value left out of span %s status", span.Name())
+		}
+	}
+}
+
+func Test_ValidateFunctionDeployment_SecretNames(t *testing.T) {
+	spec := types.FunctionDeployment{
+		Service: "figlet",
+		Image:   "figlet",
+		Secrets: []string{"db-password", "API_TOKEN"},
+	}
+
+	errs := ValidateFunctionDeployment(spec)
+	if len(errs) != 1 || errs[0].Field != "secrets[1]" {
+		t.Errorf("want an error for secrets[1], got: %+v", errs)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/scaling"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WarmSpanName is the name of the span for each ping made by a Warmer
+const WarmSpanName = "function.warm"
+
+// warmHealthPath is pinged on each function, as with the load balancer's
+// health checks
+const warmHealthPath = "/_/health"
+
+// FunctionLister lists the functions deployed to a namespace
+type FunctionLister interface {
+	List(ctx context.Context, namespace string) ([]types.FunctionStatus, error)
+}
+
+// Warmer pings the functions labelled with scaling.WarmIntervalLabel on that
+// interval, through the same resolver as invocations, to keep their
+// connections and replicas warm. Functions scaled to zero are not pinged,
+// so that the warmer does not stop them from scaling down.
+type Warmer struct {
+	lister          FunctionLister
+	resolver        middleware.BaseURLResolver
+	client          *http.Client
+	namespace       string
+	directFunctions bool
+	refresh         time.Duration
+
+	loops map[string]warmLoop
+	wg    sync.WaitGroup
+}
+
+// warmLoop is the goroutine pinging one function
+type warmLoop struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+// NewWarmer creates a Warmer for the functions in namespace, which lists
+// them again every refresh to pick up changes to their labels and replicas
+func NewWarmer(lister FunctionLister, resolver middleware.BaseURLResolver, client *http.Client, namespace string, directFunctions bool, refresh time.Duration) *Warmer {
+	return &Warmer{
+		lister:          lister,
+		resolver:        resolver,
+		client:          client,
+		namespace:       namespace,
+		directFunctions: directFunctions,
+		refresh:         refresh,
+		loops:           map[string]warmLoop{},
+	}
+}
+
+// Run warms functions until ctx is done, and only returns once every
+// function's goroutine has stopped
+func (w *Warmer) Run(ctx context.Context) {
+	defer w.wg.Wait()
+
+	ticker := time.NewTicker(w.refresh)
+	defer ticker.Stop()
+
+	w.sync(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			for key, loop := range w.loops {
+				loop.cancel()
+				delete(w.loops, key)
+			}
+			return
+		case <-ticker.C:
+			w.sync(ctx)
+		}
+	}
+}
+
+// sync starts pinging the functions which are labelled and have replicas,
+// and stops pinging those which no longer are, or have a new interval
+func (w *Warmer) sync(ctx context.Context) {
+	functions, err := w.lister.List(ctx, w.namespace)
+	if err != nil {
+		log.Printf("warmer: unable to list functions: %s", err)
+		return
+	}
+
+	wanted := map[string]types.FunctionStatus{}
+	for _, fn := range functions {
+		if fn.AvailableReplicas == 0 || warmInterval(fn.Labels) == 0 {
+			continue
+		}
+		if len(fn.Namespace) == 0 {
+			fn.Namespace = w.namespace
+		}
+		wanted[fn.Name+"."+fn.Namespace] = fn
+	}
+
+	for key, loop := range w.loops {
+		if fn, ok := wanted[key]; !ok || warmInterval(fn.Labels) != loop.interval {
+			loop.cancel()
+			delete(w.loops, key)
+		}
+	}
+
+	for key, fn := range wanted {
+		if _, ok := w.loops[key]; ok {
+			continue
+		}
+
+		interval := warmInterval(fn.Labels)
+		loopCtx, cancel := context.WithCancel(ctx)
+		w.loops[key] = warmLoop{interval: interval, cancel: cancel}
+
+		w.wg.Add(1)
+		go w.warm(loopCtx, fn.Name, fn.Namespace, interval)
+	}
+}
+
+// warm pings a function every interval until ctx is done
+func (w *Warmer) warm(ctx context.Context, name, namespace string, interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.ping(ctx, name, namespace, interval)
+		}
+	}
+}
+
+// ping makes one request to the function's health endpoint, recorded as a
+// WarmSpanName span
+func (w *Warmer) ping(ctx context.Context, name, namespace string, timeout time.Duration) {
+	ctx, span := otel.Tracer(tracing.TracerName).Start(ctx, WarmSpanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			tracing.FunctionNameKey.String(name),
+			tracing.NamespaceKey.String(namespace),
+		),
+	)
+	defer span.End()
+
+	target := w.resolver.BuildURL(name, namespace, warmHealthPath, w.directFunctions)
+	if len(target) == 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("unable to resolve %s.%s", name, namespace))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	res.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+}
+
+// warmInterval reads scaling.WarmIntervalLabel as seconds or a duration, 0
+// when it is unset or invalid
+func warmInterval(labels *map[string]string) time.Duration {
+	if labels == nil {
+		return 0
+	}
+
+	value := (*labels)[scaling.WarmIntervalLabel]
+	if len(value) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if interval, err := time.ParseDuration(value); err == nil && interval > 0 {
+		return interval
+	}
+	return 0
+}
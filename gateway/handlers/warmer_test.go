@@ -0,0 +1,186 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// fakeFunctionLister returns functions, which can be replaced while a
+// Warmer is running
+type fakeFunctionLister struct {
+	mu        sync.Mutex
+	functions []types.FunctionStatus
+}
+
+func (l *fakeFunctionLister) List(ctx context.Context, namespace string) ([]types.FunctionStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]types.FunctionStatus{}, l.functions...), nil
+}
+
+func (l *fakeFunctionLister) set(functions ...types.FunctionStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.functions = functions
+}
+
+func warmFunction(name, interval string, replicas uint64) types.FunctionStatus {
+	labels := map[string]string{}
+	if len(interval) > 0 {
+		labels[scaling.WarmIntervalLabel] = interval
+	}
+	return types.FunctionStatus{Name: name, Labels: &labels, AvailableReplicas: replicas}
+}
+
+// pingCounter counts the requests made to each path
+type pingCounter struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (c *pingCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths[r.URL.Path]++
+}
+
+func (c *pingCounter) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paths[path]
+}
+
+func Test_Warmer_PingsOnIntervalUntilCancelled(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	pings := &pingCounter{paths: map[string]int{}}
+	upstream := httptest.NewServer(pings)
+	defer upstream.Close()
+
+	lister := &fakeFunctionLister{}
+	lister.set(
+		warmFunction("warm", "20ms", 1),
+		warmFunction("cold", "20ms", 0),
+		warmFunction("plain", "", 1),
+	)
+
+	warmer := NewWarmer(lister, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL}, &http.Client{}, "openfaas-fn", false, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		warmer.Run(ctx)
+		close(stopped)
+	}()
+
+	const warmPath = "/function/warm.openfaas-fn/_/health"
+	deadline := time.Now().Add(5 * time.Second)
+	for pings.count(warmPath) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := pings.count(warmPath); got < 3 {
+		t.Fatalf("want at least 3 pings, got: %d", got)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("want Run to return once cancelled")
+	}
+
+	after := pings.count(warmPath)
+	time.Sleep(60 * time.Millisecond)
+	if got := pings.count(warmPath); got != after {
+		t.Errorf("want no pings once stopped, got: %d more", got-after)
+	}
+
+	if got := pings.count("/function/cold.openfaas-fn/_/health"); got != 0 {
+		t.Errorf("want a function without replicas not to be pinged, got: %d", got)
+	}
+	if got := pings.count("/function/plain.openfaas-fn/_/health"); got != 0 {
+		t.Errorf("want a function without the label not to be pinged, got: %d", got)
+	}
+
+	spans := 0
+	for _, span := range recorder.Ended() {
+		if span.Name() != WarmSpanName {
+			continue
+		}
+		spans++
+		for _, attr := range span.Attributes() {
+			if attr.Key == tracing.FunctionNameKey && attr.Value.AsString() != "warm" {
+				t.Errorf("want spans for the warm function only, got: %s", attr.Value.AsString())
+			}
+		}
+	}
+	if spans < 3 {
+		t.Errorf("want a span for each ping, got: %d", spans)
+	}
+}
+
+func Test_Warmer_StopsWhenLabelRemoved(t *testing.T) {
+	lister := &fakeFunctionLister{}
+	lister.set(warmFunction("warm", "1h", 1))
+
+	warmer := NewWarmer(lister, middleware.SingleHostBaseURLResolver{BaseURL: "http://127.0.0.1"}, &http.Client{}, "openfaas-fn", false, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	warmer.sync(ctx)
+	if len(warmer.loops) != 1 {
+		t.Fatalf("want 1 function warmed, got: %d", len(warmer.loops))
+	}
+
+	lister.set(warmFunction("warm", "2h", 1))
+	warmer.sync(ctx)
+	if loop, ok := warmer.loops["warm.openfaas-fn"]; !ok || loop.interval != 2*time.Hour {
+		t.Errorf("want the new interval to be used, got: %v", loop.interval)
+	}
+
+	lister.set(warmFunction("warm", "", 1))
+	warmer.sync(ctx)
+	if len(warmer.loops) != 0 {
+		t.Errorf("want no functions warmed, got: %d", len(warmer.loops))
+	}
+
+	// every goroutine has stopped
+	warmer.wg.Wait()
+}
+
+func Test_warmInterval(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      0,
+		"30":    30 * time.Second,
+		"5m":    5 * time.Minute,
+		"0":     0,
+		"-1s":   0,
+		"often": 0,
+	}
+
+	for value, want := range cases {
+		labels := map[string]string{scaling.WarmIntervalLabel: value}
+		if got := warmInterval(&labels); got != want {
+			t.Errorf("want interval for %q: %s, got: %s", value, want, got)
+		}
+	}
+
+	if got := warmInterval(nil); got != 0 {
+		t.Errorf("want no interval without labels, got: %s", got)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// isWebSocketUpgrade reports whether the caller is asking to switch the
+// connection to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyWebSocket sends the upgrade request to the function with transport,
+// the same as other calls to functions so that filters, tracing and TLS
+// apply to the handshake, and when the function switches protocols, copies
+// frames in both directions until either side closes. It returns once the
+// connection has closed, so the request's span covers the lifetime of the
+// connection.
+func proxyWebSocket(w http.ResponseWriter, r *http.Request, upstreamReq *http.Request, transport http.RoundTripper) (int, error) {
+	// the upgrade headers are hop-by-hop, so were removed from upstreamReq
+	upstreamReq.Header.Set("Connection", "Upgrade")
+	upstreamReq.Header.Set("Upgrade", r.Header.Get("Upgrade"))
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	res, err := transport.RoundTrip(upstreamReq.WithContext(r.Context()))
+	if err != nil {
+		httperror.Write(w, r, http.StatusBadGateway, "unable to reach the function")
+		return http.StatusBadGateway, err
+	}
+
+	// the function refused the upgrade, so pass on its response as it is
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		defer res.Body.Close()
+
+		copyHeaders(w.Header(), &res.Header)
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+		return res.StatusCode, nil
+	}
+
+	// the body of a 101 response is the connection to the function
+	backend, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		res.Body.Close()
+		err := fmt.Errorf("the transport did not return the upgraded connection")
+		httperror.Write(w, r, http.StatusBadGateway, "unable to reach the function")
+		return http.StatusBadGateway, err
+	}
+	defer backend.Close()
+
+	client, clientBuf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		err = fmt.Errorf("unable to hijack the connection for a websocket: %w", err)
+		httperror.Write(w, r, http.StatusInternalServerError, err.Error())
+		return http.StatusInternalServerError, err
+	}
+	defer client.Close()
+
+	// send the function's handshake, without the connection as its body
+	handshake := *res
+	handshake.Body = nil
+	if err := handshake.Write(clientBuf); err != nil {
+		return res.StatusCode, err
+	}
+	if err := clientBuf.Flush(); err != nil {
+		return res.StatusCode, err
+	}
+
+	received := &frameCounter{}
+	sent := &frameCounter{}
+
+	// when either side closes, close the other so that both copies return
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			client.Close()
+			backend.Close()
+		})
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		io.Copy(io.MultiWriter(backend, received), clientBuf)
+	}()
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		io.Copy(io.MultiWriter(client, sent), backend)
+	}()
+
+	select {
+	case <-r.Context().Done():
+		closeBoth()
+	case <-waitFor(&wg):
+	}
+	wg.Wait()
+
+	tracing.SetWebSocketMessages(r.Context(), received.messages, sent.messages)
+
+	return res.StatusCode, nil
+}
+
+// waitFor returns a channel which is closed once wg is done
+func waitFor(wg *sync.WaitGroup) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// frameCounter parses the WebSocket frame headers written to it, counting
+// the data messages which were completed. Control frames such as ping and
+// close are not counted.
+type frameCounter struct {
+	messages int64
+
+	header  []byte
+	payload uint64
+}
+
+func (f *frameCounter) Write(b []byte) (int, error) {
+	n := len(b)
+
+	for len(b) > 0 {
+		// skip the rest of the current frame's payload
+		if f.payload > 0 {
+			skip := uint64(len(b))
+			if skip > f.payload {
+				skip = f.payload
+			}
+			f.payload -= skip
+			b = b[skip:]
+			continue
+		}
+
+		f.header = append(f.header, b[0])
+		b = b[1:]
+
+		size, payload, ok := parseFrameHeader(f.header)
+		if !ok || len(f.header) < size {
+			continue
+		}
+
+		fin := f.header[0]&0x80 != 0
+		opcode := f.header[0] & 0x0f
+		if fin && opcode < 0x8 {
+			f.messages++
+		}
+
+		f.payload = payload
+		f.header = f.header[:0]
+	}
+
+	return n, nil
+}
+
+// parseFrameHeader returns the length of the frame header starting in
+// header and the length of its payload, ok is false until enough of the
+// header has been seen to know its length
+func parseFrameHeader(header []byte) (size int, payload uint64, ok bool) {
+	if len(header) < 2 {
+		return 0, 0, false
+	}
+
+	size = 2
+	payload = uint64(header[1] & 0x7f)
+	switch payload {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+
+	masked := header[1]&0x80 != 0
+	if masked {
+		size += 4
+	}
+
+	if len(header) < size {
+		return size, 0, true
+	}
+
+	switch header[1] & 0x7f {
+	case 126:
+		payload = uint64(header[2])<<8 | uint64(header[3])
+	case 127:
+		payload = 0
+		for _, b := range header[2:10] {
+			payload = payload<<8 | uint64(b)
+		}
+	}
+
+	return size, payload, true
+}
@@ -0,0 +1,283 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/filter"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+)
+
+// echoWebSocket accepts the upgrade, then sends each frame it reads back to
+// the caller until it receives a close frame
+func echoWebSocket(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			http.Error(w, "want a websocket upgrade", http.StatusBadRequest)
+			return
+		}
+
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("unable to hijack: %s", err)
+			return
+		}
+		defer conn.Close()
+
+		accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(accept[:]))
+		buf.Flush()
+
+		for {
+			opcode, payload, err := readFrame(buf.Reader)
+			if err != nil {
+				return
+			}
+			writeFrame(conn, opcode, payload, false)
+			if opcode == opClose {
+				return
+			}
+		}
+	}
+}
+
+func writeFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	frame := []byte{0x80 | opcode}
+
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	default:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+
+	if masked {
+		key := []byte{1, 2, 3, 4}
+		frame = append(frame, key...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := uint64(header[1] & 0x7f)
+	if length == 126 {
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(r, extended); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	}
+
+	var key []byte
+	if header[1]&0x80 != 0 {
+		key = make([]byte, 4)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if key != nil {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+
+	return header[0] & 0x0f, payload, nil
+}
+
+func Test_MakeForwardingProxyHandler_WebSocketEcho(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	upstream := httptest.NewServer(echoWebSocket(t))
+	defer upstream.Close()
+
+	baseURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Minute, 10, 10)
+	handler := tracing.Middleware(MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil))
+
+	gateway := httptest.NewServer(handler)
+	defer gateway.Close()
+
+	conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /function/echo HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		gateway.Listener.Addr().String())
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want status: %d, got: %d", http.StatusSwitchingProtocols, res.StatusCode)
+	}
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; res.Header.Get("Sec-WebSocket-Accept") != want {
+		t.Errorf("want Sec-WebSocket-Accept: %q, got: %q", want, res.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	for _, message := range []string{"hello", "world"} {
+		if err := writeFrame(conn, opText, []byte(message), true); err != nil {
+			t.Fatal(err)
+		}
+
+		opcode, payload, err := readFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opcode != opText || string(payload) != message {
+			t.Fatalf("want text frame: %q, got opcode: %d, payload: %q", message, opcode, payload)
+		}
+	}
+
+	writeFrame(conn, opClose, nil, true)
+	if opcode, _, err := readFrame(reader); err != nil || opcode != opClose {
+		t.Fatalf("want a close frame, got opcode: %d, error: %v", opcode, err)
+	}
+
+	// the span ends once both sides have closed the connection
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.Ended()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	want := map[string]int64{
+		string(tracing.WebSocketReceivedKey): 2,
+		string(tracing.WebSocketSentKey):     2,
+	}
+	for _, kv := range spans[0].Attributes() {
+		if value, ok := want[string(kv.Key)]; ok {
+			if kv.Value.AsInt64() != value {
+				t.Errorf("want %s: %d, got: %d", kv.Key, value, kv.Value.AsInt64())
+			}
+			delete(want, string(kv.Key))
+		}
+	}
+	for key := range want {
+		t.Errorf("want a %s attribute", key)
+	}
+}
+
+// denyFilter rejects every request sent to a function
+type denyFilter struct{}
+
+func (denyFilter) Request(ctx context.Context, r *http.Request) error {
+	return fmt.Errorf("not allowed")
+}
+
+func (denyFilter) Response(ctx context.Context, res *http.Response) error {
+	return nil
+}
+
+func Test_MakeForwardingProxyHandler_WebSocketRunsFilters(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	filters := &filter.Chain{}
+	filters.Register("deny", denyFilter{})
+
+	baseURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(baseURL, time.Minute, 10, 10)
+	proxy.Client = &http.Client{Transport: filters.Transport(proxy.Client.Transport, http.StatusForbidden)}
+	handler := MakeForwardingProxyHandler(proxy, []HTTPNotifier{}, middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL},
+		middleware.TransparentURLPathTransformer{}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("want the filter's status: %d, got: %d", http.StatusForbidden, rr.Code)
+	}
+	if want := "filter deny: not allowed"; rr.Body.String() != want {
+		t.Errorf("want body: %q, got: %q", want, rr.Body.String())
+	}
+	if called {
+		t.Errorf("want the upgrade rejected before it reaches the function")
+	}
+}
+
+func Test_isWebSocketUpgrade(t *testing.T) {
+	cases := []struct {
+		connection, upgrade string
+		want                bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, Upgrade", "WebSocket", true},
+		{"keep-alive", "websocket", false},
+		{"Upgrade", "h2c", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+		r.Header.Set("Connection", c.connection)
+		r.Header.Set("Upgrade", c.upgrade)
+
+		if got := isWebSocketUpgrade(r); got != c.want {
+			t.Errorf("Connection: %q, Upgrade: %q, want: %v, got: %v", c.connection, c.upgrade, c.want, got)
+		}
+	}
+}
@@ -5,32 +5,55 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/openfaas/faas-provider/auth"
 	"github.com/openfaas/faas/gateway/handlers"
 	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/async"
+	gatewayauth "github.com/openfaas/faas/gateway/pkg/auth"
+	"github.com/openfaas/faas/gateway/pkg/cache"
+	"github.com/openfaas/faas/gateway/pkg/circuit"
+	"github.com/openfaas/faas/gateway/pkg/compression"
+	"github.com/openfaas/faas/gateway/pkg/filter"
+	"github.com/openfaas/faas/gateway/pkg/flags"
+	"github.com/openfaas/faas/gateway/pkg/health"
+	"github.com/openfaas/faas/gateway/pkg/logging"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/mtls"
+	"github.com/openfaas/faas/gateway/pkg/ratelimit"
+	"github.com/openfaas/faas/gateway/pkg/resolver"
+	"github.com/openfaas/faas/gateway/pkg/server"
 	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/plugin"
 	"github.com/openfaas/faas/gateway/scaling"
 	"github.com/openfaas/faas/gateway/types"
 	"github.com/openfaas/faas/gateway/version"
 	natsHandler "github.com/openfaas/nats-queue-worker/handler"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // NameExpression for a function / service
 const NameExpression = "-a-zA-Z_0-9."
 
-func main() {
+// functionImageCacheTTL bounds how long the image of a function deployed
+// without going through the gateway is out of date on its spans
+const functionImageCacheTTL = time.Minute
 
-	osEnv := types.OsEnv{}
-	readConfig := types.ReadConfig{}
-	config, configErr := readConfig.Read(osEnv)
+func main() {
+	config, configErr := flags.Read(flag.CommandLine, os.Args[1:], types.OsEnv{})
 
 	if configErr != nil {
 		log.Fatalln(configErr)
@@ -63,25 +86,70 @@ func main() {
 		}
 	}
 
-	shutdown, err := tracing.Provider(context.TODO(), "gateway", version.Version, version.GitCommitMessage)
+	// shutdown is called by the server, once in-flight requests have drained
+	tracingOpts := []tracing.Option{}
+	if len(config.TracesExporter) > 0 {
+		tracingOpts = append(tracingOpts, tracing.WithTracesExporter(config.TracesExporter))
+	}
+	shutdown, err := tracing.Provider(context.TODO(), "gateway", version.Version, version.GitCommitMessage, tracingOpts...)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	defer shutdown(context.TODO())
 
 	var faasHandlers types.HandlerSet
 
 	servicePollInterval := time.Second * 5
 
+	if len(config.ColdStartBuckets) > 0 {
+		metrics.SetColdStartBuckets(config.ColdStartBuckets)
+	}
+
+	functionLabels := &metrics.FunctionLabels{
+		Allowed: config.MetricsFunctionAllowList,
+		Pattern: config.MetricsFunctionPattern,
+	}
+	metrics.SetFunctionLabels(functionLabels)
+
 	metricsOptions := metrics.BuildMetricsOptions()
 	exporter := metrics.NewExporter(metricsOptions, credentials, config.Namespace)
 	exporter.StartServiceWatcher(*config.FunctionsProviderURL, metricsOptions, "func", servicePollInterval)
 	metrics.RegisterExporter(exporter)
 
-	reverseProxy := types.NewHTTPClientReverseProxy(config.FunctionsProviderURL,
+	transportConfig := config.TransportConfig()
+
+	upstreamTLS := mtls.Files{
+		CAFile:     config.UpstreamTLSCAFile,
+		CertFile:   config.UpstreamTLSCertFile,
+		KeyFile:    config.UpstreamTLSKeyFile,
+		ServerName: config.UpstreamTLSServerName,
+	}
+	if upstreamTLS.Enabled() || len(upstreamTLS.ServerName) > 0 {
+		reloader, err := mtls.NewReloader(upstreamTLS)
+		if err != nil {
+			log.Fatalf("unable to load the upstream TLS certificates: %s", err)
+		}
+		go reloader.Watch(context.Background(), config.UpstreamTLSReloadInterval)
+
+		transportConfig.TLSClientConfig = reloader.ClientConfig()
+	}
+
+	reverseProxy := types.NewHTTPClientReverseProxyWithTransport(config.FunctionsProviderURL,
 		config.UpstreamTimeout,
-		config.MaxIdleConns,
-		config.MaxIdleConnsPerHost)
+		transportConfig)
+
+	// retry idempotent calls, within the client span for each call made to a function
+	reverseProxy.Client.Transport = types.NewRetryTransport(reverseProxy.Client.Transport, config.RetryAttempts, config.RetryBackoff)
+	reverseProxy.Client.Transport = tracing.Transport(reverseProxy.Client.Transport)
+	reverseProxy.GRPCTransport = tracing.GRPCTransport(reverseProxy.GRPCTransport)
+
+	// custom filters run only on calls to functions, over HTTP, gRPC,
+	// WebSockets and event streams, outside the client span on the server span
+	functionsProxy := *reverseProxy
+	functionsProxy.Client = &http.Client{
+		Transport:     filter.Transport(reverseProxy.Client.Transport, config.FilterErrorStatus),
+		CheckRedirect: reverseProxy.Client.CheckRedirect,
+	}
+	functionsProxy.GRPCTransport = filter.Transport(reverseProxy.GRPCTransport, config.FilterErrorStatus)
 
 	loggingNotifier := handlers.LoggingNotifier{}
 
@@ -91,6 +159,14 @@ func main() {
 	}
 
 	functionNotifiers := []handlers.HTTPNotifier{loggingNotifier, prometheusNotifier}
+
+	// the same metrics are exported with OTLP when OTEL_METRICS_EXPORTER=otlp,
+	// otherwise the global meter provider does nothing with them
+	otelNotifier, err := handlers.NewOTelFunctionNotifier(otel.Meter(tracing.MeterName), config.Namespace)
+	if err != nil {
+		log.Fatalf("unable to create the function metrics: %s", err)
+	}
+	functionNotifiers = append(functionNotifiers, otelNotifier)
 	forwardingNotifiers := []handlers.HTTPNotifier{loggingNotifier}
 	quietNotifier := []handlers.HTTPNotifier{}
 
@@ -100,9 +176,37 @@ func main() {
 	nilURLTransformer := middleware.TransparentURLPathTransformer{}
 	trimURLTransformer := middleware.FunctionPrefixTrimmingURLPathTransformer{}
 
-	functionURLResolver = urlResolver
 	functionURLTransformer = nilURLTransformer
 
+	// functions are called through the provider, unless they can be called
+	// directly by their service name
+	var functionResolver resolver.Resolver = resolver.ProviderResolver{BaseURL: *config.FunctionsProviderURL}
+	if config.DirectFunctions {
+		functionNamespace := config.Namespace
+		if len(functionNamespace) == 0 {
+			functionNamespace = "openfaas-fn"
+		}
+
+		functionResolver = resolver.KubernetesDNSResolver{Suffix: config.DirectFunctionsSuffix, DefaultNamespace: functionNamespace}
+		functionURLTransformer = trimURLTransformer
+	}
+
+	balancer, balancerErr := resolver.NewLoadBalancer(resolver.Strategy(config.LoadBalancerStrategy))
+	if balancerErr != nil {
+		log.Fatalln(balancerErr)
+	}
+	functionURLResolver = resolver.BaseURLResolver{Resolver: functionResolver, Balancer: balancer, DefaultNamespace: config.Namespace}
+
+	if config.DirectFunctions {
+		go func() {
+			// skip endpoints whose watchdog is not ready
+			healthClient := &http.Client{Timeout: config.LoadBalancerHealthInterval}
+			for range time.Tick(config.LoadBalancerHealthInterval) {
+				balancer.CheckEndpoints(context.Background(), healthClient, "/_/health")
+			}
+		}()
+	}
+
 	var serviceAuthInjector middleware.AuthInjector
 
 	if config.UseBasicAuth {
@@ -116,6 +220,8 @@ func main() {
 		MaxPollCount:         uint(1000),
 		SetScaleRetries:      uint(20),
 		FunctionPollInterval: time.Millisecond * 100,
+		MaxPollInterval:      time.Second,
+		ScaleTimeout:         config.ScaleFromZeroTimeout,
 		CacheExpiry:          time.Millisecond * 250, // freshness of replica values before going stale
 		ServiceQuery:         externalServiceQuery,
 	}
@@ -125,17 +231,27 @@ func main() {
 	cachedFunctionQuery := scaling.NewCachedFunctionQuery(functionAnnotationCache, externalServiceQuery)
 
 	faasHandlers.Proxy = handlers.MakeCallIDMiddleware(
-		handlers.MakeForwardingProxyHandler(reverseProxy, functionNotifiers, functionURLResolver, functionURLTransformer, nil),
+		handlers.MakeForwardingProxyHandler(&functionsProxy, functionNotifiers, functionURLResolver, functionURLTransformer, nil),
 	)
 
-	faasHandlers.ListFunctions = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
-	faasHandlers.DeployFunction = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
-	faasHandlers.DeleteFunction = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
-	faasHandlers.UpdateFunction = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
+	// functionsHandler validates function specs before they reach the provider
+	// images are looked up again once a function is deployed through the gateway
+	functionImages := handlers.NewFunctionImages(cachedFunctionQuery, functionImageCacheTTL)
+	functionProvider := handlers.InvalidateImagesOnDeploy(
+		plugin.NewExternalFunctionProvider(*config.FunctionsProviderURL, reverseProxy.Client, serviceAuthInjector),
+		functionImages,
+	)
+	functionsHandler := handlers.MakeNotifierWrapper(handlers.MakeFunctionsHandler(functionProvider, config.Namespace), forwardingNotifiers)
+
+	faasHandlers.ListFunctions = functionsHandler
+	faasHandlers.DeployFunction = functionsHandler
+	faasHandlers.DeleteFunction = functionsHandler
+	faasHandlers.UpdateFunction = functionsHandler
 	faasHandlers.FunctionStatus = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
 
 	faasHandlers.InfoHandler = handlers.MakeInfoHandler(handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector))
-	faasHandlers.SecretHandler = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
+	secretProvider := plugin.NewExternalSecretProvider(*config.FunctionsProviderURL, reverseProxy.Client, serviceAuthInjector)
+	faasHandlers.SecretHandler = handlers.MakeNotifierWrapper(handlers.MakeSecretsHandler(secretProvider), forwardingNotifiers)
 
 	faasHandlers.NamespaceListerHandler = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
 	faasHandlers.NamespaceMutatorHandler = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
@@ -145,9 +261,20 @@ func main() {
 		quietNotifier,
 	)
 
-	faasHandlers.LogProxyHandler = handlers.NewLogHandlerFunc(*config.LogsProviderURL, config.WriteTimeout)
+	// logs are streamed for as long as the client follows them, so the client
+	// shares the proxy's transport without its timeout
+	logProvider := plugin.NewExternalLogProvider(*config.LogsProviderURL, &http.Client{Transport: reverseProxy.Client.Transport}, serviceAuthInjector)
+	faasHandlers.LogProxyHandler = handlers.MakeLogStreamHandler(logProvider, config.Namespace)
+
+	// spans are bucketed in the same way as the metrics, once restricted
+	functionTracingOpts := []tracing.Option{}
+	if functionLabels.Restricted() {
+		functionTracingOpts = append(functionTracingOpts, tracing.WithFunctionNameFunc(metrics.FunctionLabel))
+	}
 
 	functionProxy := faasHandlers.Proxy
+	functionProxy = handlers.MakeExecTimeoutHandler(functionProxy, cachedFunctionQuery, config.ExecTimeout, config.Namespace)
+	functionProxy = handlers.MakeFunctionImageHandler(functionProxy, functionImages, config.Namespace)
 
 	if config.ScaleFromZero {
 		scalingFunctionCache := scaling.NewFunctionCache(scalingConfig.CacheExpiry)
@@ -155,7 +282,84 @@ func main() {
 		functionProxy = handlers.MakeScalingHandler(functionProxy, scaler, scalingConfig, config.Namespace)
 	}
 
-	functionProxy = tracing.Middleware(functionProxy)
+	if config.CircuitBreakerFailureRatio > 0 {
+		breaker := handlers.NewCircuitBreaker(circuit.Config{
+			FailureRatio: config.CircuitBreakerFailureRatio,
+			MinRequests:  config.CircuitBreakerMinRequests,
+			Window:       config.CircuitBreakerWindow,
+			Cooldown:     config.CircuitBreakerCooldown,
+		})
+		functionProxy = handlers.MakeCircuitBreakerHandler(functionProxy, breaker, int(config.CircuitBreakerCooldown.Seconds()), config.Namespace)
+	}
+
+	// the canary has its own scaling, timeout and circuit breaker
+	functionProxy = handlers.MakeCanaryHandler(functionProxy, cachedFunctionQuery, config.CanarySessionHeader, config.Namespace)
+	// before the canary, so that the function chosen can have one of its own
+	functionProxy = handlers.MakeContentRouteHandler(functionProxy, cachedFunctionQuery, config.Namespace)
+	if config.StickySessions {
+		// outside the canary, so the cookie is scoped to the function called
+		functionProxy = resolver.Sticky(functionProxy, config.BasePath)
+	}
+
+	functionProxy = handlers.MakeConcurrencyLimitHandler(functionProxy, cachedFunctionQuery, config.Namespace)
+	// callers which share a call are not counted against max_inflight
+	functionProxy = handlers.MakeCoalescingHandler(functionProxy, cachedFunctionQuery, config.Namespace)
+	if config.ResponseCacheSize > 0 {
+		functionProxy = handlers.MakeResponseCacheHandler(functionProxy, cache.New(config.ResponseCacheSize, config.ResponseCacheMaxBytes), cachedFunctionQuery, config.Namespace, config.ResponseCacheMaxBodyBytes)
+	}
+	if len(config.WebhookSecretPath) > 0 {
+		functionProxy = gatewayauth.VerifyHMAC(gatewayauth.SecretsFromDir(config.WebhookSecretPath, config.Namespace))(functionProxy)
+	}
+	functionProxy = handlers.MakeBodyLimitHandler(functionProxy, cachedFunctionQuery, config.MaxBodyBytes, config.LimitResponseBody, config.Namespace)
+
+	if config.RateLimitRPS > 0 {
+		rateLimitKey := ratelimit.ByClientIP
+		if config.RateLimitKey == "api_key" {
+			rateLimitKey = ratelimit.ByAPIKey
+		}
+
+		limiter := ratelimit.NewLimiter(ratelimit.Config{
+			Rate:    config.RateLimitRPS,
+			Burst:   config.RateLimitBurst,
+			MaxKeys: config.RateLimitMaxKeys,
+		})
+		functionProxy = ratelimit.Middleware(functionProxy, limiter, rateLimitKey)
+	}
+
+	functionProxy = metrics.Middleware(functionProxy)
+
+	// a namespace in the query is moved into the path, before the function
+	// name is read for metrics, limits and routing
+	functionProxy = handlers.MakeNamespaceHandler(functionProxy, config.Namespace)
+	functionProxy = handlers.MakeForwardedHeadersHandler(functionProxy, config.TrustedProxies)
+
+	if config.CompressResponses {
+		functionProxy = compression.Middleware(functionProxy, config.CompressMinBytes)
+	}
+
+	// inside the access log, so that a recovered panic is logged as a 500
+	functionProxy = tracing.Recover(functionProxy)
+
+	if len(config.AccessLogFormat) > 0 {
+		accessLog := os.Stdout
+		if len(config.AccessLogPath) > 0 {
+			var openErr error
+			accessLog, openErr = os.OpenFile(config.AccessLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if openErr != nil {
+				log.Fatalf("unable to open access log: %s", openErr)
+			}
+			defer accessLog.Close()
+		}
+
+		// logged inside the tracing middleware so the trace ID is available
+		functionProxy = logging.Middleware(functionProxy,
+			logging.WithFormat(logging.Format(config.AccessLogFormat)),
+			logging.WithOutput(accessLog))
+	}
+
+	// outside the access log, so that the request ID is logged
+	functionProxy = tracing.RequestID(functionProxy)
+	functionProxy = tracing.Middleware(functionProxy, functionTracingOpts...)
 
 	if config.UseNATS() {
 		log.Println("Async enabled: Using NATS Streaming")
@@ -171,49 +375,47 @@ func main() {
 			log.Fatalln(queueErr)
 		}
 
+		if len(config.NATSMonitorURL) > 0 {
+			depthWatcher := async.NewDepthWatcher(&async.NATSMonitor{
+				URL:        config.NATSMonitorURL,
+				Channel:    *config.NATSChannel,
+				QueueGroup: config.NATSQueueGroup,
+				Client:     &http.Client{Timeout: servicePollInterval},
+			}, metricsOptions.AsyncQueueDepth.WithLabelValues(*config.NATSChannel))
+			go depthWatcher.Watch(context.Background(), servicePollInterval)
+		}
+
 		faasHandlers.QueuedProxy = handlers.MakeNotifierWrapper(
 			handlers.MakeCallIDMiddleware(handlers.MakeQueuedProxy(metricsOptions, natsQueue, trimURLTransformer, config.Namespace, cachedFunctionQuery)),
 			forwardingNotifiers,
 		)
+
+		// the trace context is queued with the request, so the invocation
+		// made by the queue-worker joins the caller's trace
+		faasHandlers.QueuedProxy = handlers.MakeForwardedHeadersHandler(faasHandlers.QueuedProxy, config.TrustedProxies)
+		faasHandlers.QueuedProxy = tracing.Middleware(tracing.RequestID(tracing.Recover(faasHandlers.QueuedProxy)), functionTracingOpts...)
 	}
 
 	prometheusQuery := metrics.NewPrometheusQuery(config.PrometheusHost, config.PrometheusPort, &http.Client{})
-	faasHandlers.ListFunctions = metrics.AddMetricsHandler(faasHandlers.ListFunctions, prometheusQuery)
+	faasHandlers.ListFunctions = metrics.AddMetricsHandler(faasHandlers.ListFunctions, prometheusQuery, metricsOptions.Invocations)
 	faasHandlers.ScaleFunction = scaling.MakeHorizontalScalingHandler(handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector))
+	faasHandlers.SetReplicas = handlers.MakeNotifierWrapper(handlers.MakeScaleHandler(externalServiceQuery, config.Namespace), forwardingNotifiers)
 
-	if credentials != nil {
-		faasHandlers.Alert =
-			auth.DecorateWithBasicAuth(faasHandlers.Alert, credentials)
-		faasHandlers.UpdateFunction =
-			auth.DecorateWithBasicAuth(faasHandlers.UpdateFunction, credentials)
-		faasHandlers.DeleteFunction =
-			auth.DecorateWithBasicAuth(faasHandlers.DeleteFunction, credentials)
-		faasHandlers.DeployFunction =
-			auth.DecorateWithBasicAuth(faasHandlers.DeployFunction, credentials)
-		faasHandlers.ListFunctions =
-			auth.DecorateWithBasicAuth(faasHandlers.ListFunctions, credentials)
-		faasHandlers.ScaleFunction =
-			auth.DecorateWithBasicAuth(faasHandlers.ScaleFunction, credentials)
-		faasHandlers.FunctionStatus =
-			auth.DecorateWithBasicAuth(faasHandlers.FunctionStatus, credentials)
-		faasHandlers.InfoHandler =
-			auth.DecorateWithBasicAuth(faasHandlers.InfoHandler, credentials)
-		faasHandlers.SecretHandler =
-			auth.DecorateWithBasicAuth(faasHandlers.SecretHandler, credentials)
-		faasHandlers.LogProxyHandler =
-			auth.DecorateWithBasicAuth(faasHandlers.LogProxyHandler, credentials)
-		faasHandlers.NamespaceListerHandler =
-			auth.DecorateWithBasicAuth(faasHandlers.NamespaceListerHandler, credentials)
-		faasHandlers.NamespaceMutatorHandler =
-			auth.DecorateWithBasicAuth(faasHandlers.NamespaceMutatorHandler, credentials)
+	r := mux.NewRouter()
+
+	// with an admin address, functions are invoked through a router and
+	// handler chain of their own, served on the listen address, and the
+	// admin API and UI through r on the admin address
+	functionRouter := r
+	if len(config.AdminListenAddress) > 0 {
+		functionRouter = mux.NewRouter()
 	}
 
-	r := mux.NewRouter()
 	// max wait time to start a function = maxPollCount * functionPollInterval
 
-	r.HandleFunc("/function/{name:["+NameExpression+"]+}", functionProxy)
-	r.HandleFunc("/function/{name:["+NameExpression+"]+}/", functionProxy)
-	r.HandleFunc("/function/{name:["+NameExpression+"]+}/{params:.*}", functionProxy)
+	functionRouter.HandleFunc("/function/{name:["+NameExpression+"]+}", functionProxy)
+	functionRouter.HandleFunc("/function/{name:["+NameExpression+"]+}/", functionProxy)
+	functionRouter.HandleFunc("/function/{name:["+NameExpression+"]+}/{params:.*}", functionProxy)
 
 	r.HandleFunc("/system/info", faasHandlers.InfoHandler).Methods(http.MethodGet)
 	r.HandleFunc("/system/alert", faasHandlers.Alert).Methods(http.MethodPost)
@@ -224,6 +426,7 @@ func main() {
 	r.HandleFunc("/system/functions", faasHandlers.DeleteFunction).Methods(http.MethodDelete)
 	r.HandleFunc("/system/functions", faasHandlers.UpdateFunction).Methods(http.MethodPut)
 	r.HandleFunc("/system/scale-function/{name:["+NameExpression+"]+}", faasHandlers.ScaleFunction).Methods(http.MethodPost)
+	r.HandleFunc("/system/scale/{name:["+NameExpression+"]+}", faasHandlers.SetReplicas).Methods(http.MethodPost)
 
 	r.HandleFunc("/system/secrets", faasHandlers.SecretHandler).Methods(http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
 	r.HandleFunc("/system/logs", faasHandlers.LogProxyHandler).Methods(http.MethodGet)
@@ -233,9 +436,9 @@ func main() {
 		Methods(http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodGet)
 
 	if faasHandlers.QueuedProxy != nil {
-		r.HandleFunc("/async-function/{name:["+NameExpression+"]+}/", faasHandlers.QueuedProxy).Methods(http.MethodPost)
-		r.HandleFunc("/async-function/{name:["+NameExpression+"]+}", faasHandlers.QueuedProxy).Methods(http.MethodPost)
-		r.HandleFunc("/async-function/{name:["+NameExpression+"]+}/{params:.*}", faasHandlers.QueuedProxy).Methods(http.MethodPost)
+		functionRouter.HandleFunc("/async-function/{name:["+NameExpression+"]+}/", faasHandlers.QueuedProxy).Methods(http.MethodPost)
+		functionRouter.HandleFunc("/async-function/{name:["+NameExpression+"]+}", faasHandlers.QueuedProxy).Methods(http.MethodPost)
+		functionRouter.HandleFunc("/async-function/{name:["+NameExpression+"]+}/{params:.*}", faasHandlers.QueuedProxy).Methods(http.MethodPost)
 	}
 
 	fs := http.FileServer(http.Dir("./assets/"))
@@ -245,40 +448,118 @@ func main() {
 	fsCORS := handlers.DecorateWithCORS(fs, allowedCORSHost)
 
 	uiHandler := http.StripPrefix("/ui", fsCORS)
-	if credentials != nil {
-		r.PathPrefix("/ui/").Handler(
-			auth.DecorateWithBasicAuth(uiHandler.ServeHTTP, credentials)).
-			Methods(http.MethodGet)
-	} else {
-		r.PathPrefix("/ui/").Handler(uiHandler).
-			Methods(http.MethodGet)
-	}
+	r.PathPrefix("/ui/").Handler(uiHandler).Methods(http.MethodGet)
 
 	//Start metrics server in a goroutine
 	go runMetricsServer()
 
-	r.HandleFunc("/healthz",
-		handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)).Methods(http.MethodGet)
+	// readiness checks the gateway can export traces and reach the provider
+	healthChecks := health.NewRegistry(5 * time.Second)
+	healthChecks.Register(
+		health.NewChecker("tracing", tracing.Check),
+		health.NewChecker("provider", makeProviderCheck(reverseProxy.Client, config.FunctionsProviderURL, serviceAuthInjector)),
+	)
+
+	r.HandleFunc("/healthz", health.LivenessHandler).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", healthChecks.ReadinessHandler).Methods(http.MethodGet)
+	if functionRouter != r {
+		// so that the listen address can be probed too
+		functionRouter.HandleFunc("/healthz", health.LivenessHandler).Methods(http.MethodGet)
+		functionRouter.HandleFunc("/readyz", healthChecks.ReadinessHandler).Methods(http.MethodGet)
+	}
+
+	if handlers.RegisterPprof(r, config.EnablePprof, credentials) {
+		log.Printf("Serving pprof profiles on %s/", handlers.PprofPath)
+	}
 
-	r.Handle("/", http.RedirectHandler("/ui/", http.StatusMovedPermanently)).Methods(http.MethodGet)
+	r.Handle("/", http.RedirectHandler(config.BasePath+"/ui/", http.StatusMovedPermanently)).Methods(http.MethodGet)
 
-	tcpPort := 8080
+	// the admin API and UI need basic auth when it is enabled, function
+	// invocations stay open unless their paths are listed too
+	var handler http.Handler = r
+	if credentials != nil {
+		handler = gatewayauth.BasicAuth(r, credentials, config.AuthProtectedPaths)
+	}
 
-	s := &http.Server{
-		Addr:           fmt.Sprintf(":%d", tcpPort),
-		ReadTimeout:    config.ReadTimeout,
-		WriteTimeout:   config.WriteTimeout,
-		MaxHeaderBytes: http.DefaultMaxHeaderBytes, // 1MB - can be overridden by setting Server.MaxHeaderBytes.
-		Handler:        r,
+	if len(config.BasePath) > 0 {
+		log.Printf("Serving routes under %s", config.BasePath)
+	}
+	if len(config.FunctionHeader) > 0 {
+		log.Printf("Selecting functions by the %s header", config.FunctionHeader)
 	}
 
-	log.Fatal(s.ListenAndServe())
+	// the base path is removed, and a function named by header moved into the
+	// path, before routing, auth and tracing, so that all of them see the same
+	// paths as when the gateway is at the root and called by path
+	newServer := func(addr string, handler http.Handler) *http.Server {
+		handler = middleware.BasePath(handler, config.BasePath)
+		return &http.Server{
+			Addr:           addr,
+			ReadTimeout:    config.ReadTimeout,
+			WriteTimeout:   config.WriteTimeout,
+			MaxHeaderBytes: http.DefaultMaxHeaderBytes, // 1MB - can be overridden by setting Server.MaxHeaderBytes.
+			// gRPC callers use HTTP/2 without TLS
+			Handler: h2c.NewHandler(handler, &http2.Server{}),
+		}
+	}
+
+	// with an admin address, function invocations are served on the listen
+	// address and everything else on the admin address, both drain together
+	var gatewayServer *server.Server
+	if functionRouter != r {
+		// the function chain only checks basic auth for function paths which
+		// were listed as protected
+		var functionHandler http.Handler = functionRouter
+		if credentials != nil {
+			functionPaths := []string{"/function", "/async-function"}
+			if protected := gatewayauth.ProtectedWithin(config.AuthProtectedPaths, functionPaths); len(protected) > 0 {
+				functionHandler = gatewayauth.BasicAuth(functionHandler, credentials, protected)
+			}
+		}
+
+		gatewayServer = server.New(newServer(config.ListenAddress, middleware.FunctionHeader(functionHandler, config.FunctionHeader)), config.DrainTimeout, shutdown)
+		gatewayServer.Add(newServer(config.AdminListenAddress, handler))
+		log.Printf("Serving functions on %s and the admin API on %s", config.ListenAddress, config.AdminListenAddress)
+	} else {
+		gatewayServer = server.New(newServer(config.ListenAddress, middleware.FunctionHeader(handler, config.FunctionHeader)), config.DrainTimeout, shutdown)
+	}
+
+	// stop receiving traffic as soon as draining starts
+	healthChecks.Register(health.NewChecker("shutdown", gatewayServer.Check))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// stopped along with the server, on SIGTERM, and waited for before the
+	// gateway exits. Pings are not retried or traced as calls to functions.
+	background := sync.WaitGroup{}
+	if config.WarmerRefreshInterval > 0 {
+		warmerClient := &http.Client{
+			Transport: types.NewTransport(transportConfig),
+			Timeout:   config.UpstreamTimeout,
+		}
+		warmer := handlers.NewWarmer(functionProvider, functionURLResolver, warmerClient, config.Namespace, config.DirectFunctions, config.WarmerRefreshInterval)
+
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			warmer.Run(ctx)
+		}()
+	}
+
+	err = gatewayServer.ListenAndServe(ctx)
+	stop()
+	background.Wait()
+
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 // runMetricsServer Listen on a separate HTTP port for Prometheus metrics to keep this accessible from
 // the internal network only.
 func runMetricsServer() {
-	metricsHandler := metrics.PrometheusHandler()
+	metricsHandler := metrics.Handler()
 	router := mux.NewRouter()
 	router.Handle("/metrics", metricsHandler)
 	router.HandleFunc("/healthz", handlers.HealthzHandler)
@@ -297,3 +578,31 @@ func runMetricsServer() {
 
 	log.Fatal(s.ListenAndServe())
 }
+
+// makeProviderCheck checks that the functions provider answers its /healthz
+// endpoint with a 200
+func makeProviderCheck(client *http.Client, providerURL *url.URL, authInjector middleware.AuthInjector) func(ctx context.Context) error {
+	healthzURL := providerURL.ResolveReference(&url.URL{Path: "healthz"}).String()
+
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthzURL, nil)
+		if err != nil {
+			return err
+		}
+
+		if authInjector != nil {
+			authInjector.Inject(req)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status from %s: %d", healthzURL, res.StatusCode)
+		}
+		return nil
+	}
+}
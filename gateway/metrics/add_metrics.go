@@ -9,12 +9,30 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	types "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
 )
 
-// AddMetricsHandler wraps a http.HandlerFunc with Prometheus metrics
-func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQueryFetcher) http.HandlerFunc {
+// FunctionListing is a function returned by GET /system/functions, the
+// provider's status with when the function was last invoked
+type FunctionListing struct {
+	types.FunctionStatus
+
+	// LastInvoked is left out until the function has been called through
+	// this gateway
+	LastInvoked *time.Time `json:"lastInvoked,omitempty"`
+}
+
+// AddMetricsHandler wraps a http.HandlerFunc with Prometheus metrics. The
+// replicas come from the provider, and the invocation counts are queried
+// from Prometheus, so that they cover every replica of the gateway. The last
+// call comes from invocations, which is held by this replica only, as are
+// the counts it falls back to when Prometheus is not set or can not be
+// queried.
+func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQueryFetcher, invocations *Invocations) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -34,7 +52,15 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 			log.Printf("List functions responded with code %d, body: %s",
 				recorder.Code,
 				string(upstreamBody))
-			http.Error(w, string(upstreamBody), recorder.Code)
+			// an error which is already JSON is passed on as it is
+			if strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+				w.Header().Set("Content-Type", recorder.Header().Get("Content-Type"))
+				w.WriteHeader(recorder.Code)
+				w.Write(upstreamBody)
+				return
+			}
+
+			httperror.Write(w, r, recorder.Code, strings.TrimSpace(string(upstreamBody)))
 			return
 		}
 
@@ -44,7 +70,7 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 		if err != nil {
 			log.Printf("Metrics upstream error: %s, value: %s", err, string(upstreamBody))
 
-			http.Error(w, "Unable to parse list of functions from provider", http.StatusInternalServerError)
+			httperror.Write(w, r, http.StatusInternalServerError, "Unable to parse list of functions from provider")
 			return
 		}
 
@@ -53,24 +79,39 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 			functions[i].InvocationCount = 0
 		}
 
-		if len(functions) > 0 {
+		listing := make([]FunctionListing, len(functions))
+		for i := range functions {
+			listing[i].FunctionStatus = functions[i]
+		}
 
+		// the counts are summed across every replica of the gateway by
+		// Prometheus, this replica's own counts are only used without it
+		counted := false
+		if prometheusQuery != nil && len(listing) > 0 {
 			ns := functions[0].Namespace
 			q := fmt.Sprintf(`sum(gateway_function_invocation_total{function_name=~".*.%s"}) by (function_name)`, ns)
 			// Restrict query results to only function names matching namespace suffix.
 
 			results, err := prometheusQuery.Fetch(url.QueryEscape(q))
 			if err != nil {
-				// log the error but continue, the mixIn will correctly handle the empty results.
 				log.Printf("Error querying Prometheus: %s\n", err.Error())
+			} else {
+				mixIn(&functions, results)
+				for i := range functions {
+					listing[i].InvocationCount = functions[i].InvocationCount
+				}
+				counted = true
 			}
-			mixIn(&functions, results)
 		}
 
-		bytesOut, err := json.Marshal(functions)
+		if invocations != nil {
+			mixInInvocations(listing, invocations.Summary(), !counted)
+		}
+
+		bytesOut, err := json.Marshal(listing)
 		if err != nil {
 			log.Printf("Error serializing functions: %s", err)
-			http.Error(w, "Error writing response after adding metrics", http.StatusInternalServerError)
+			httperror.Write(w, r, http.StatusInternalServerError, "Error writing response after adding metrics")
 			return
 		}
 
@@ -80,6 +121,26 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 	}
 }
 
+// mixInInvocations sets the last call of each function, and its count when
+// counts is true, by its name.namespace as labelled by the
+// PrometheusFunctionNotifier
+func mixInInvocations(listing []FunctionListing, summary map[string]Invocation, counts bool) {
+	for i := range listing {
+		invocation, ok := summary[listing[i].Name+"."+listing[i].Namespace]
+		if !ok {
+			continue
+		}
+
+		if counts {
+			listing[i].InvocationCount = invocation.Count
+		}
+		if !invocation.Last.IsZero() {
+			last := invocation.Last.UTC()
+			listing[i].LastInvoked = &last
+		}
+	}
+}
+
 func mixIn(functions *[]types.FunctionStatus, metrics *VectorQueryResponse) {
 
 	if functions == nil {
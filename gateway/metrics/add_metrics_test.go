@@ -2,11 +2,13 @@ package metrics
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	types "github.com/openfaas/faas-provider/types"
 )
@@ -29,7 +31,7 @@ func Test_PrometheusMetrics_MixedInto_Services(t *testing.T) {
 	functionsHandler := makeFunctionsHandler()
 	fakeQuery := makeFakePrometheusQueryFetcher()
 
-	handler := AddMetricsHandler(functionsHandler, fakeQuery)
+	handler := AddMetricsHandler(functionsHandler, fakeQuery, nil)
 
 	rr := httptest.NewRecorder()
 	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
@@ -64,7 +66,7 @@ func Test_MetricHandler_ForwardsErrors(t *testing.T) {
 	// explicitly set the query fetcher to nil because it should
 	// not be called when a non-200 response is returned from the
 	// functions handler, if it is called then the test will panic
-	handler := AddMetricsHandler(functionsHandler, nil)
+	handler := AddMetricsHandler(functionsHandler, nil, nil)
 
 	rr := httptest.NewRecorder()
 	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
@@ -74,14 +76,128 @@ func Test_MetricHandler_ForwardsErrors(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
 	}
 
-	if rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
-		t.Errorf("Want 'text/plain; charset=utf-8' content-type, got: %s", rr.Header().Get("Content-Type"))
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Want 'application/json' content-type, got: %s", rr.Header().Get("Content-Type"))
 	}
 	body := strings.TrimSpace(rr.Body.String())
-	if body != "test error case" {
-		t.Errorf("Want 'test error case', got: %q", body)
+	if body != `{"error":{"code":"conflict","message":"test error case"}}` {
+		t.Errorf("Want the error as JSON, got: %q", body)
+	}
+
+}
+
+func Test_MetricHandler_PassesOnJSONErrors(t *testing.T) {
+	upstream := `{"error":{"code":"not_found","message":"namespace not found"}}`
+	functionsHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(upstream))
+	}
+	handler := AddMetricsHandler(functionsHandler, nil, nil)
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
+	handler.ServeHTTP(rr, request)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	if body := rr.Body.String(); body != upstream {
+		t.Errorf("Want the error passed on, got: %q", body)
 	}
+}
+
+func Test_MetricHandler_ListsRecordedInvocations(t *testing.T) {
+	options := BuildMetricsOptions()
+	counter := options.GatewayFunctionInvocation
+
+	counter.WithLabelValues("func_echoit.openfaas-fn", "200").Add(3)
+	counter.WithLabelValues("func_echoit.openfaas-fn", "500").Inc()
+	counter.WithLabelValues("func_echoit.staging", "200").Inc()
+	last := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	options.Invocations.Started("func_echoit.openfaas-fn", last.Add(-time.Minute))
+	options.Invocations.Started("func_echoit.openfaas-fn", last)
+
+	// the fetcher is nil as Prometheus should not be queried
+	handler := AddMetricsHandler(makeFunctionsHandler(), nil, options.Invocations)
 
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
+	handler.ServeHTTP(rr, request)
+
+	results := []FunctionListing{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Want 1 function, got: %d", len(results))
+	}
+
+	got := results[0]
+	if got.InvocationCount != 4 {
+		t.Errorf("InvocationCount want: 4, got: %f", got.InvocationCount)
+	}
+	if got.LastInvoked == nil || !got.LastInvoked.Equal(last) {
+		t.Errorf("LastInvoked want: %s, got: %v", last, got.LastInvoked)
+	}
+	if !strings.Contains(rr.Body.String(), `"lastInvoked":"2024-03-01T12:00:00Z"`) {
+		t.Errorf("Want lastInvoked in the listing, got: %s", rr.Body.String())
+	}
+}
+
+// failingPrometheusQueryFetcher can not reach Prometheus
+type failingPrometheusQueryFetcher struct{}
+
+func (failingPrometheusQueryFetcher) Fetch(query string) (*VectorQueryResponse, error) {
+	return nil, errors.New("connection refused")
+}
+
+func Test_MetricHandler_CountsFromPrometheus(t *testing.T) {
+	options := BuildMetricsOptions()
+	options.GatewayFunctionInvocation.WithLabelValues("func_echoit.openfaas-fn", "200").Add(3)
+	last := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	options.Invocations.Started("func_echoit.openfaas-fn", last)
+
+	for name, fetcher := range map[string]struct {
+		query PrometheusQueryFetcher
+		want  float64
+	}{
+		"every replica from Prometheus": {makeFakePrometheusQueryFetcher(), 1},
+		"this replica without it":       {failingPrometheusQueryFetcher{}, 3},
+	} {
+		handler := AddMetricsHandler(makeFunctionsHandler(), fetcher.query, options.Invocations)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
+		handler.ServeHTTP(rr, request)
+
+		results := []FunctionListing{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: want 1 function, got: %d", name, len(results))
+		}
+		if results[0].InvocationCount != fetcher.want {
+			t.Errorf("%s: InvocationCount want: %f, got: %f", name, fetcher.want, results[0].InvocationCount)
+		}
+		if results[0].LastInvoked == nil || !results[0].LastInvoked.Equal(last) {
+			t.Errorf("%s: LastInvoked want: %s, got: %v", name, last, results[0].LastInvoked)
+		}
+	}
+}
+
+func Test_MetricHandler_NotInvoked(t *testing.T) {
+	options := BuildMetricsOptions()
+	handler := AddMetricsHandler(makeFunctionsHandler(), nil, options.Invocations)
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
+	handler.ServeHTTP(rr, request)
+
+	if strings.Contains(rr.Body.String(), "lastInvoked") {
+		t.Errorf("Want no lastInvoked before a call, got: %s", rr.Body.String())
+	}
 }
 
 func Test_FunctionsHandler_ReturnsJSONAndOneFunction(t *testing.T) {
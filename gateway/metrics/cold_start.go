@@ -0,0 +1,40 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultColdStartBuckets span functions which already had a warm Pod, in
+// tens of milliseconds, up to images which had to be pulled first.
+var DefaultColdStartBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60}
+
+// coldStartBuckets are used when the cold start histogram is registered
+var coldStartBuckets = DefaultColdStartBuckets
+
+// SetColdStartBuckets replaces DefaultColdStartBuckets. It must be called
+// before Handler, Middleware or ObserveColdStart.
+func SetColdStartBuckets(buckets []float64) {
+	coldStartBuckets = buckets
+}
+
+func buildColdStartHistogram(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gateway",
+		Subsystem: "function",
+		Name:      "cold_start_seconds",
+		Help:      "Time requests waited for a function to scale up from zero replicas.",
+		Buckets:   buckets,
+	}, []string{"function_name"})
+}
+
+// ObserveColdStart records the time a request waited for a function to scale
+// up from zero, functionName should include the namespace.
+func ObserveColdStart(functionName string, waited time.Duration) {
+	register()
+	requestMetrics.ColdStart.WithLabelValues(FunctionLabel(functionName)).Observe(waited.Seconds())
+}
@@ -0,0 +1,57 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func Test_ObserveColdStart_IsScraped(t *testing.T) {
+	ObserveColdStart("cs-figlet.openfaas-fn", 80*time.Millisecond)
+	ObserveColdStart("cs-figlet.openfaas-fn", 700*time.Millisecond)
+	ObserveColdStart("cs-figlet.openfaas-fn", 12*time.Second)
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rr.Body)
+
+	for _, want := range []string{
+		`gateway_function_cold_start_seconds_count{function_name="cs-figlet.openfaas-fn"} 3`,
+		`gateway_function_cold_start_seconds_bucket{function_name="cs-figlet.openfaas-fn",le="0.1"} 1`,
+		`gateway_function_cold_start_seconds_bucket{function_name="cs-figlet.openfaas-fn",le="1"} 2`,
+		`gateway_function_cold_start_seconds_bucket{function_name="cs-figlet.openfaas-fn",le="20"} 3`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("want metrics to contain %s, got:\n%s", want, string(body))
+		}
+	}
+}
+
+func Test_buildColdStartHistogram_CustomBuckets(t *testing.T) {
+	histogram := buildColdStartHistogram([]float64{1, 30})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(histogram)
+
+	histogram.WithLabelValues("figlet.openfaas-fn").Observe(5)
+
+	rr := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rr.Body)
+
+	want := `gateway_function_cold_start_seconds_bucket{function_name="figlet.openfaas-fn",le="30"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("want metrics to contain %s, got:\n%s", want, string(body))
+	}
+	if strings.Contains(string(body), `le="0.05"`) {
+		t.Errorf("want only the custom buckets, got:\n%s", string(body))
+	}
+}
@@ -46,6 +46,7 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.metricOptions.GatewayFunctionsHistogram.Describe(ch)
 	e.metricOptions.ServiceReplicasGauge.Describe(ch)
 	e.metricOptions.GatewayFunctionInvocationStarted.Describe(ch)
+	e.metricOptions.AsyncQueueDepth.Describe(ch)
 }
 
 // Collect collects data to be consumed by prometheus
@@ -65,13 +66,15 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 			serviceName = service.Name
 		}
 
-		// Set current replica count
+		// Add the current replica count, functions bucketed into
+		// OtherFunctionLabel share one series
 		e.metricOptions.ServiceReplicasGauge.
-			WithLabelValues(serviceName).
-			Set(float64(service.Replicas))
+			WithLabelValues(FunctionLabel(serviceName)).
+			Add(float64(service.Replicas))
 	}
 
 	e.metricOptions.ServiceReplicasGauge.Collect(ch)
+	e.metricOptions.AsyncQueueDepth.Collect(ch)
 }
 
 // StartServiceWatcher starts a ticker and collects service replica counts to expose to prometheus
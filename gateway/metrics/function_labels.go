@@ -0,0 +1,68 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"regexp"
+	"strings"
+)
+
+// OtherFunctionLabel is the function_name given to every function which is
+// not eligible for its own label
+const OtherFunctionLabel = "__other__"
+
+// FunctionLabels limits the functions which get their own function_name
+// label, so that dynamically named functions cannot grow the number of
+// series without bound. A function is eligible when it is in Allowed, or
+// Pattern matches its name. Both are checked against the name with and
+// without its namespace, so "echo" covers "echo.openfaas-fn".
+type FunctionLabels struct {
+	Allowed []string
+	Pattern *regexp.Regexp
+}
+
+// Restricted reports whether any functions are bucketed, a nil or empty
+// FunctionLabels labels every function by its name
+func (f *FunctionLabels) Restricted() bool {
+	return f != nil && (len(f.Allowed) > 0 || f.Pattern != nil)
+}
+
+// Label is name when the function is eligible for its own label, and
+// OtherFunctionLabel when it is not
+func (f *FunctionLabels) Label(name string) string {
+	if !f.Restricted() || f.eligible(name) {
+		return name
+	}
+
+	if i := strings.Index(name, "."); i > 0 && f.eligible(name[:i]) {
+		return name
+	}
+
+	return OtherFunctionLabel
+}
+
+func (f *FunctionLabels) eligible(name string) bool {
+	for _, allowed := range f.Allowed {
+		if name == allowed {
+			return true
+		}
+	}
+	return f.Pattern != nil && f.Pattern.MatchString(name)
+}
+
+// functionLabels are applied by FunctionLabel, nil leaves every function
+// with its own label
+var functionLabels *FunctionLabels
+
+// SetFunctionLabels restricts the functions labelled by name in the
+// gateway's metrics. It must be called before any metrics are recorded.
+func SetFunctionLabels(labels *FunctionLabels) {
+	functionLabels = labels
+}
+
+// FunctionLabel is the function_name label for name, as restricted by
+// SetFunctionLabels
+func FunctionLabel(name string) string {
+	return functionLabels.Label(name)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func Test_FunctionLabels_Label(t *testing.T) {
+	cases := []struct {
+		name   string
+		labels *FunctionLabels
+		fn     string
+		want   string
+	}{
+		{name: "unrestricted when nil", labels: nil, fn: "dyn-3f2a.openfaas-fn", want: "dyn-3f2a.openfaas-fn"},
+		{name: "unrestricted when empty", labels: &FunctionLabels{}, fn: "dyn-3f2a", want: "dyn-3f2a"},
+		{name: "allowed by name", labels: &FunctionLabels{Allowed: []string{"echo"}}, fn: "echo", want: "echo"},
+		{name: "allowed without namespace", labels: &FunctionLabels{Allowed: []string{"echo"}}, fn: "echo.openfaas-fn", want: "echo.openfaas-fn"},
+		{name: "allowed in namespace only", labels: &FunctionLabels{Allowed: []string{"echo.openfaas-fn"}}, fn: "echo.staging", want: OtherFunctionLabel},
+		{name: "bucketed when not allowed", labels: &FunctionLabels{Allowed: []string{"echo"}}, fn: "dyn-3f2a", want: OtherFunctionLabel},
+		{name: "allowed by pattern", labels: &FunctionLabels{Pattern: regexp.MustCompile(`^team-a-`)}, fn: "team-a-resize.openfaas-fn", want: "team-a-resize.openfaas-fn"},
+		{name: "bucketed by pattern", labels: &FunctionLabels{Pattern: regexp.MustCompile(`^team-a-[a-z]+$`)}, fn: "team-a-3f2a", want: OtherFunctionLabel},
+		{name: "either list or pattern", labels: &FunctionLabels{Allowed: []string{"echo"}, Pattern: regexp.MustCompile(`^team-a-`)}, fn: "team-a-resize", want: "team-a-resize"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.labels.Label(tc.fn); got != tc.want {
+				t.Errorf("want label: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_Middleware_BucketsFunctionsNotAllowed(t *testing.T) {
+	SetFunctionLabels(&FunctionLabels{Allowed: []string{"fl-echo"}})
+	defer SetFunctionLabels(nil)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/fl-echo", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/fl-dyn-1", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/fl-dyn-2", nil))
+
+	for label, want := range map[string]float64{"fl-echo": 1, OtherFunctionLabel: 2, "fl-dyn-1": 0} {
+		m := &dto.Metric{}
+		requestMetrics.Requests.WithLabelValues(label, "200").Write(m)
+		if got := m.GetCounter().GetValue(); got != want {
+			t.Errorf("want %.0f requests for %s, got: %.0f", want, label, got)
+		}
+	}
+}
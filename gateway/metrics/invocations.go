@@ -0,0 +1,85 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Invocation is what the gateway knows about the calls to a function
+type Invocation struct {
+	// Count of completed calls, with any status code
+	Count float64
+
+	// Last call to start, zero before the function has been called
+	Last time.Time
+}
+
+// Invocations reads the invocation counts from the
+// gateway_function_invocation_total counter, which is incremented by the
+// PrometheusFunctionNotifier, and records when each function was last
+// called. Both are held in memory by each replica of the gateway, so only
+// count the calls which this replica served since it started.
+type Invocations struct {
+	counter *prometheus.CounterVec
+
+	lock sync.RWMutex
+	last map[string]time.Time
+}
+
+// NewInvocations reads the counts from counter, which is labelled by
+// function_name and code
+func NewInvocations(counter *prometheus.CounterVec) *Invocations {
+	return &Invocations{counter: counter, last: map[string]time.Time{}}
+}
+
+// Started records a call to functionName, as name.namespace
+func (i *Invocations) Started(functionName string, at time.Time) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if at.After(i.last[functionName]) {
+		i.last[functionName] = at
+	}
+}
+
+// Summary of the calls to each function, by name.namespace
+func (i *Invocations) Summary() map[string]Invocation {
+	summary := map[string]Invocation{}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		i.counter.Collect(ch)
+		close(ch)
+	}()
+
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			continue
+		}
+
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "function_name" {
+				invocation := summary[label.GetValue()]
+				invocation.Count += m.GetCounter().GetValue()
+				summary[label.GetValue()] = invocation
+			}
+		}
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	for name, last := range i.last {
+		invocation := summary[name]
+		invocation.Last = last
+		summary[name] = invocation
+	}
+
+	return summary
+}
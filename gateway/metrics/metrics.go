@@ -18,6 +18,12 @@ type MetricOptions struct {
 	GatewayFunctionInvocationStarted *prometheus.CounterVec
 
 	ServiceReplicasGauge *prometheus.GaugeVec
+
+	// AsyncQueueDepth is the number of queued requests waiting to be run
+	AsyncQueueDepth *prometheus.GaugeVec
+
+	// Invocations reads GatewayFunctionInvocation for the function listing
+	Invocations *Invocations
 }
 
 // ServiceMetricOptions provides RED metrics
@@ -77,11 +83,23 @@ func BuildMetricsOptions() MetricOptions {
 		[]string{"function_name"},
 	)
 
+	asyncQueueDepth := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "queue_depth",
+			Help:      "Queued requests waiting to be run by the queue-worker.",
+		},
+		[]string{"queue"},
+	)
+
 	metricsOptions := MetricOptions{
 		GatewayFunctionsHistogram:        gatewayFunctionsHistogram,
 		GatewayFunctionInvocation:        gatewayFunctionInvocation,
 		ServiceReplicasGauge:             serviceReplicas,
 		GatewayFunctionInvocationStarted: gatewayFunctionInvocationStarted,
+		AsyncQueueDepth:                  asyncQueueDepth,
+		Invocations:                      NewInvocations(gatewayFunctionInvocation),
 	}
 
 	return metricsOptions
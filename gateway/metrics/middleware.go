@@ -0,0 +1,127 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RequestMetrics provides RED metrics for requests proxied to functions
+type RequestMetrics struct {
+	Requests *prometheus.CounterVec
+	InFlight *prometheus.GaugeVec
+	Duration *prometheus.HistogramVec
+
+	// CircuitState is 0 for closed, 1 for open and 2 for half-open
+	CircuitState *prometheus.GaugeVec
+
+	// ResponseCache counts lookups in the response cache by result, hit or
+	// miss
+	ResponseCache *prometheus.CounterVec
+
+	// ColdStart is built when the metrics are registered, so that its
+	// buckets can be set from the gateway's config
+	ColdStart *prometheus.HistogramVec
+}
+
+// requestMetrics are recorded by Middleware and served by Handler
+var requestMetrics = buildRequestMetrics()
+
+// Synchronize to make sure the request metrics are only registered once
+var registerRequestMetrics = sync.Once{}
+
+func buildRequestMetrics() RequestMetrics {
+	return RequestMetrics{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "The total number of HTTP requests to functions.",
+		}, []string{"function_name", "code"}),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "The number of HTTP requests to functions currently being served.",
+		}, []string{"function_name"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests to functions.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"function_name", "code"}),
+		CircuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "circuit_breaker",
+			Name:      "state",
+			Help:      "State of the circuit breaker for a function: 0 closed, 1 open, 2 half-open.",
+		}, []string{"function_name"}),
+		ResponseCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "response_cache",
+			Name:      "requests_total",
+			Help:      "Requests to functions looked up in the response cache, by result.",
+		}, []string{"function_name", "result"}),
+	}
+}
+
+func register() {
+	registerRequestMetrics.Do(func() {
+		requestMetrics.ColdStart = buildColdStartHistogram(coldStartBuckets)
+
+		prometheus.MustRegister(requestMetrics.Requests, requestMetrics.InFlight, requestMetrics.Duration, requestMetrics.CircuitState, requestMetrics.ResponseCache, requestMetrics.ColdStart)
+	})
+}
+
+// Handler serves the metrics recorded by Middleware along with any other
+// metrics in the default Prometheus registry
+func Handler() http.Handler {
+	register()
+	return promhttp.Handler()
+}
+
+// Middleware records the count, in-flight requests and latency of requests
+// to functions, labelled by the function name from the URL path and the
+// status code of the response.
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	register()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionName := FunctionLabel(middleware.GetServiceName(r.URL.Path))
+
+		inFlight := requestMetrics.InFlight.WithLabelValues(functionName)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		next(ww, r)
+
+		labels := prometheus.Labels{"function_name": functionName, "code": strconv.Itoa(ww.Status())}
+		requestMetrics.Requests.With(labels).Inc()
+		requestMetrics.Duration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetCircuitState records the state of the circuit breaker for a function
+func SetCircuitState(functionName string, state float64) {
+	register()
+	requestMetrics.CircuitState.WithLabelValues(FunctionLabel(functionName)).Set(state)
+}
+
+// RecordResponseCache counts a lookup in the response cache for a function,
+// result is hit or miss
+func RecordResponseCache(functionName, result string) {
+	register()
+	requestMetrics.ResponseCache.WithLabelValues(FunctionLabel(functionName), result).Inc()
+}
@@ -0,0 +1,90 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func Test_Middleware_RecordsRequestMetrics(t *testing.T) {
+	var inFlight float64
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		m := &dto.Metric{}
+		requestMetrics.InFlight.WithLabelValues("mw-figlet").Write(m)
+		inFlight = m.GetGauge().GetValue()
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/function/mw-figlet/sub/path", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/function/mw-figlet", nil))
+
+	if inFlight != 1 {
+		t.Errorf("want 1 request in-flight during the call, got: %f", inFlight)
+	}
+
+	m := &dto.Metric{}
+	requestMetrics.InFlight.WithLabelValues("mw-figlet").Write(m)
+	if got := m.GetGauge().GetValue(); got != 0 {
+		t.Errorf("want 0 requests in-flight after the call, got: %f", got)
+	}
+
+	m = &dto.Metric{}
+	requestMetrics.Requests.WithLabelValues("mw-figlet", "201").Write(m)
+	if got := m.GetCounter().GetValue(); got != 2 {
+		t.Errorf("want 2 requests counted, got: %f", got)
+	}
+
+	m = &dto.Metric{}
+	requestMetrics.Duration.WithLabelValues("mw-figlet", "201").(prometheus.Histogram).Write(m)
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("want 2 durations observed, got: %d", got)
+	}
+}
+
+func Test_Handler_ServesRequestMetrics(t *testing.T) {
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/mw-scraped", nil))
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, _ := io.ReadAll(rr.Body)
+	want := `gateway_http_requests_total{code="200",function_name="mw-scraped"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("want metrics to contain %s, got:\n%s", want, string(body))
+	}
+}
+
+func Test_RecordResponseCache(t *testing.T) {
+	RecordResponseCache("rc-figlet", "hit")
+	RecordResponseCache("rc-figlet", "hit")
+
+	m := &dto.Metric{}
+	requestMetrics.ResponseCache.WithLabelValues("rc-figlet", "hit").Write(m)
+
+	if got := m.GetCounter().GetValue(); got != 2 {
+		t.Errorf("want 2 hits, got: %f", got)
+	}
+}
+
+func Test_SetCircuitState(t *testing.T) {
+	SetCircuitState("cb-figlet.openfaas-fn", 1)
+
+	m := &dto.Metric{}
+	requestMetrics.CircuitState.WithLabelValues("cb-figlet.openfaas-fn").Write(m)
+
+	if got := m.GetGauge().GetValue(); got != 1 {
+		t.Errorf("want circuit state: 1, got: %f", got)
+	}
+}
@@ -0,0 +1,228 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InvokeFunc runs a queued request through the synchronous invoke path. The
+// request's path is /function/<name> followed by the queued sub-path.
+type InvokeFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+// Subscriber delivers each queued message to handler until ctx is done,
+// along with which attempt at delivering it this is, starting at 1. A
+// message should only be acknowledged when handler returns nil.
+type Subscriber interface {
+	Subscribe(ctx context.Context, handler func(data []byte, attempt int) error) error
+}
+
+// Consumer drains a queue, invoking each function and posting the result to
+// the request's callback URL when one was given.
+type Consumer struct {
+	subscriber Subscriber
+	client     *http.Client
+
+	deadLetter        Publisher
+	deadLetterSubject string
+	maxAttempts       int
+
+	depth     *DepthWatcher
+	queueWait prometheus.Observer
+
+	now func() time.Time
+}
+
+// NewConsumer creates a Consumer, client is used for callbacks.
+func NewConsumer(subscriber Subscriber, client *http.Client, opts ...ConsumerOption) *Consumer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	c := &Consumer{
+		subscriber: subscriber,
+		client:     client,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.queueWait == nil {
+		c.queueWait = defaultQueueWait()
+	}
+	return c
+}
+
+// Consume blocks, calling invoke for each queued request until ctx is done.
+func (c *Consumer) Consume(ctx context.Context, invoke InvokeFunc) error {
+	return c.subscriber.Subscribe(ctx, func(data []byte, attempt int) error {
+		req := ftypes.QueueRequest{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			// a message which cannot be decoded will never succeed, so drop it
+			log.Printf("async: unable to decode queued request: %s", err)
+			return nil
+		}
+
+		return c.handle(ctx, &req, data, attempt, invoke)
+	})
+}
+
+func (c *Consumer) handle(ctx context.Context, req *ftypes.QueueRequest, data []byte, attempt int, invoke InvokeFunc) error {
+	header := req.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	// continue the trace from the producer span stored when the request was
+	// queued, or from the headers for requests queued without one
+	var carrier propagation.TextMapCarrier = propagation.HeaderCarrier(header)
+	if hasTraceContext(req.Annotations) {
+		carrier = annotationCarrier(req.Annotations)
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	ctx, span := otel.Tracer(tracing.TracerName).Start(ctx, "async "+req.Function,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(tracing.FunctionNameKey.String(req.Function)),
+	)
+	defer span.End()
+
+	if wait, ok := queueWait(req.Function, req.Annotations, c.now()); ok {
+		tracing.SetQueueWait(ctx, wait)
+		c.queueWait.Observe(wait.Seconds())
+	}
+
+	if c.depth != nil {
+		if depth, ok := c.depth.Depth(); ok {
+			tracing.SetQueueDepth(ctx, depth)
+		}
+	}
+
+	target := "/function/" + req.Function + req.Path
+	if len(req.QueryString) > 0 {
+		target += "?" + req.QueryString
+	}
+
+	upstream, err := http.NewRequestWithContext(ctx, req.Method, target, bytes.NewReader(req.Body))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil
+	}
+	upstream.Header = header.Clone()
+	upstream.Host = req.Host
+
+	start := time.Now()
+	res, err := invoke(ctx, upstream)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return c.retryOrDeadLetter(ctx, req.Function, data, attempt, err)
+	}
+	defer res.Body.Close()
+	duration := time.Since(start)
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+
+	if req.CallbackURL == nil {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	if err := c.callback(ctx, req, res, duration); err != nil {
+		// the function has already run, so retrying would invoke it twice
+		log.Printf("async: callback to %s failed: %s", req.CallbackURL.String(), err)
+		span.RecordError(err)
+	}
+
+	return nil
+}
+
+// retryOrDeadLetter returns err so the message is redelivered, until the
+// final attempt when it is published to the dead-letter queue instead.
+func (c *Consumer) retryOrDeadLetter(ctx context.Context, function string, data []byte, attempt int, err error) error {
+	if c.deadLetter == nil || attempt < c.maxAttempts {
+		return err
+	}
+
+	if publishErr := c.publishDeadLetter(function, data, attempt, err); publishErr != nil {
+		log.Printf("async: unable to publish %s to %s: %s", function, c.deadLetterSubject, publishErr)
+		return err
+	}
+
+	log.Printf("async: %s failed after %d attempts, published to %s: %s", function, attempt, c.deadLetterSubject, err)
+	tracing.AddDeadLetteredEvent(ctx, attempt, c.deadLetterSubject)
+	return nil
+}
+
+// callback posts the function's response to the caller's callback URL, with
+// the same headers as used by the nats-queue-worker. The delivery is a client
+// span in the invocation's trace, which is propagated to the receiver.
+func (c *Consumer) callback(ctx context.Context, req *ftypes.QueueRequest, res *http.Response, duration time.Duration) (err error) {
+	ctx, span := otel.Tracer(tracing.TracerName).Start(ctx, "callback "+req.Function,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			tracing.FunctionNameKey.String(req.Function),
+			semconv.URLFull(req.CallbackURL.Redacted()),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	callbackReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.CallbackURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if contentType := res.Header.Get("Content-Type"); len(contentType) > 0 {
+		callbackReq.Header.Set("Content-Type", contentType)
+	}
+	if callID := req.Header.Get("X-Call-Id"); len(callID) > 0 {
+		callbackReq.Header.Set("X-Call-Id", callID)
+	}
+	callbackReq.Header.Set("X-Function-Name", req.Function)
+	callbackReq.Header.Set("X-Function-Status", strconv.Itoa(res.StatusCode))
+	callbackReq.Header.Set("X-Duration-Seconds", fmt.Sprintf("%f", duration.Seconds()))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(callbackReq.Header))
+
+	callbackRes, err := c.client.Do(callbackReq)
+	if err != nil {
+		return err
+	}
+	defer callbackRes.Body.Close()
+	io.Copy(io.Discard, callbackRes.Body)
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(callbackRes.StatusCode))
+
+	if callbackRes.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code: %d", callbackRes.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeSubscriber delivers each message once, or up to redeliveries more
+// times while the handler fails, recording the handler's final result
+type fakeSubscriber struct {
+	messages     [][]byte
+	redeliveries int
+	results      []error
+}
+
+func (f *fakeSubscriber) Subscribe(ctx context.Context, handler func(data []byte, attempt int) error) error {
+	for _, msg := range f.messages {
+		err := handler(msg, 1)
+		for attempt := 2; err != nil && attempt <= f.redeliveries+1; attempt++ {
+			err = handler(msg, attempt)
+		}
+		f.results = append(f.results, err)
+	}
+	return nil
+}
+
+func queued(t *testing.T, req *ftypes.QueueRequest) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func okInvoke(body string) InvokeFunc {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+func Test_Consume_InvokesFunction(t *testing.T) {
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, &ftypes.QueueRequest{
+		Function:    "figlet",
+		Method:      http.MethodPost,
+		Path:        "/employees",
+		QueryString: "format=json",
+		Body:        []byte("hello"),
+		Header:      http.Header{"X-Call-Id": []string{"call-1"}},
+	})}}
+
+	var got *http.Request
+	var gotBody string
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		got = req
+		body, _ := io.ReadAll(req.Body)
+		gotBody = string(body)
+		return okInvoke("")(ctx, req)
+	}
+
+	if err := NewConsumer(subscriber, nil).Consume(context.Background(), invoke); err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil {
+		t.Fatal("want the function to be invoked")
+	}
+	if got.URL.String() != "/function/figlet/employees?format=json" {
+		t.Errorf("want URL: /function/figlet/employees?format=json, got: %s", got.URL.String())
+	}
+	if got.Method != http.MethodPost {
+		t.Errorf("want method: %s, got: %s", http.MethodPost, got.Method)
+	}
+	if gotBody != "hello" {
+		t.Errorf("want body: hello, got: %s", gotBody)
+	}
+	if got.Header.Get("X-Call-Id") != "call-1" {
+		t.Errorf("want X-Call-Id: call-1, got: %s", got.Header.Get("X-Call-Id"))
+	}
+	if subscriber.results[0] != nil {
+		t.Errorf("want the message to be acknowledged, got: %s", subscriber.results[0])
+	}
+}
+
+func Test_Consume_InvokeErrorIsRedelivered(t *testing.T) {
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost})}}
+
+	want := errors.New("connection refused")
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return nil, want
+	}
+
+	NewConsumer(subscriber, nil).Consume(context.Background(), invoke)
+
+	if !errors.Is(subscriber.results[0], want) {
+		t.Errorf("want the invoke error so the message is redelivered, got: %v", subscriber.results[0])
+	}
+}
+
+func Test_Consume_DropsUndecodableMessage(t *testing.T) {
+	subscriber := &fakeSubscriber{messages: [][]byte{[]byte("not json")}}
+
+	invoked := false
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		invoked = true
+		return okInvoke("")(ctx, req)
+	}
+
+	NewConsumer(subscriber, nil).Consume(context.Background(), invoke)
+
+	if invoked {
+		t.Error("want no invocation for an undecodable message")
+	}
+	if subscriber.results[0] != nil {
+		t.Errorf("want the message to be acknowledged and dropped, got: %s", subscriber.results[0])
+	}
+}
+
+func Test_Consume_PostsResultToCallbackURL(t *testing.T) {
+	var callback *http.Request
+	var callbackBody string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callback = r
+		body, _ := io.ReadAll(r.Body)
+		callbackBody = string(body)
+	}))
+	defer receiver.Close()
+
+	callbackURL, _ := url.Parse(receiver.URL + "/result")
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, &ftypes.QueueRequest{
+		Function:    "figlet",
+		Method:      http.MethodPost,
+		Header:      http.Header{"X-Call-Id": []string{"call-1"}},
+		CallbackURL: callbackURL,
+	})}}
+
+	NewConsumer(subscriber, receiver.Client()).Consume(context.Background(), okInvoke("_____"))
+
+	if callback == nil {
+		t.Fatal("want the result to be posted to the callback URL")
+	}
+	if callback.URL.Path != "/result" {
+		t.Errorf("want callback path: /result, got: %s", callback.URL.Path)
+	}
+	if callbackBody != "_____" {
+		t.Errorf("want callback body: _____, got: %s", callbackBody)
+	}
+
+	want := map[string]string{
+		"X-Function-Status": "200",
+		"X-Function-Name":   "figlet",
+		"X-Call-Id":         "call-1",
+		"Content-Type":      "text/plain",
+	}
+	for key, val := range want {
+		if got := callback.Header.Get(key); got != val {
+			t.Errorf("want %s: %s, got: %s", key, val, got)
+		}
+	}
+	if len(callback.Header.Get("X-Duration-Seconds")) == 0 {
+		t.Error("want X-Duration-Seconds to be set")
+	}
+}
+
+func Test_EnqueueConsume_LinksToOriginatingTrace(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	ctx, caller := otel.Tracer("test").Start(context.Background(), "caller")
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+	if err := queue.Enqueue(ctx, "figlet", req); err != nil {
+		t.Fatal(err)
+	}
+	caller.End()
+
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, queuer.requests[0])}}
+
+	var invokeSpan trace.SpanContext
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		invokeSpan = trace.SpanContextFromContext(req.Context())
+		return okInvoke("")(ctx, req)
+	}
+	NewConsumer(subscriber, nil).Consume(context.Background(), invoke)
+
+	spans := recorder.Named("async figlet")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 consumer span, got: %d", len(spans))
+	}
+	consumer := spans[0]
+
+	if consumer.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("want span kind: %s, got: %s", trace.SpanKindConsumer, consumer.SpanKind())
+	}
+
+	producers := recorder.Named("enqueue figlet")
+	if len(producers) != 1 {
+		t.Fatalf("want 1 producer span, got: %d", len(producers))
+	}
+	if producers[0].Parent().SpanID() != caller.SpanContext().SpanID() {
+		t.Errorf("want the producer span within the caller's, got parent: %s", producers[0].Parent().SpanID())
+	}
+	if consumer.Parent().SpanID() != producers[0].SpanContext().SpanID() {
+		t.Errorf("want parent span: %s, got: %s", producers[0].SpanContext().SpanID(), consumer.Parent().SpanID())
+	}
+	if invokeSpan.SpanID() != consumer.SpanContext().SpanID() {
+		t.Errorf("want the invocation to run in the consumer span, got: %s", invokeSpan.SpanID())
+	}
+}
+
+func Test_EnqueueConsume_CallbackSharesTrace(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	var traceparent string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer receiver.Close()
+
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+	req.Header.Set(CallbackURLHeader, receiver.URL)
+	if err := queue.Enqueue(context.Background(), "figlet", req); err != nil {
+		t.Fatal(err)
+	}
+
+	// a worker may pass on the headers it needs to invoke the function only
+	message := queuer.requests[0]
+	message.Header.Del("traceparent")
+	if len(message.Annotations["trace.traceparent"]) == 0 {
+		t.Fatalf("want the trace context stored with the message, got: %v", message.Annotations)
+	}
+
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, message)}}
+	NewConsumer(subscriber, receiver.Client()).Consume(context.Background(), okInvoke("done"))
+
+	enqueue := recorder.Named("enqueue figlet")
+	callbacks := recorder.Named("callback figlet")
+	if len(enqueue) != 1 || len(callbacks) != 1 {
+		t.Fatalf("want an enqueue and a callback span, got: %d and %d", len(enqueue), len(callbacks))
+	}
+
+	traceID := enqueue[0].SpanContext().TraceID()
+	if callbacks[0].SpanContext().TraceID() != traceID {
+		t.Errorf("want the callback in trace %s, got: %s", traceID, callbacks[0].SpanContext().TraceID())
+	}
+	if callbacks[0].SpanKind() != trace.SpanKindClient {
+		t.Errorf("want span kind: %s, got: %s", trace.SpanKindClient, callbacks[0].SpanKind())
+	}
+	if !strings.Contains(traceparent, traceID.String()) || !strings.Contains(traceparent, callbacks[0].SpanContext().SpanID().String()) {
+		t.Errorf("want the callback span propagated to the receiver, got traceparent: %q", traceparent)
+	}
+}
@@ -0,0 +1,89 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+)
+
+// Publisher sends data to a subject, stan.Conn is a Publisher.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// DeadLetter is published to the dead-letter queue for a queued request
+// which failed on every attempt. Message is the original message, exactly
+// as it was read from the queue, so it can be published again as it is.
+type DeadLetter struct {
+	Message  json.RawMessage `json:"message"`
+	Function string          `json:"function"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// Request decodes the original queued request.
+func (d *DeadLetter) Request() (*ftypes.QueueRequest, error) {
+	req := ftypes.QueueRequest{}
+	if err := json.Unmarshal(d.Message, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ConsumerOption configures a Consumer.
+type ConsumerOption func(*Consumer)
+
+// WithDeadLetter publishes requests which fail maxAttempts times to subject,
+// and acknowledges them, rather than leaving them to be redelivered forever.
+func WithDeadLetter(publisher Publisher, subject string, maxAttempts int) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetter = publisher
+		c.deadLetterSubject = subject
+		c.maxAttempts = maxAttempts
+	}
+}
+
+// WithDepthWatcher records the queue depth last read by watcher on each
+// consumer span, watcher must be started with Watch.
+func WithDepthWatcher(watcher *DepthWatcher) ConsumerOption {
+	return func(c *Consumer) {
+		c.depth = watcher
+	}
+}
+
+// publishDeadLetter publishes data with the error from its final attempt.
+func (c *Consumer) publishDeadLetter(function string, data []byte, attempts int, invokeErr error) error {
+	deadLetter := DeadLetter{
+		Message:  json.RawMessage(data),
+		Function: function,
+		Error:    invokeErr.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+	}
+
+	payload, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+
+	return c.deadLetter.Publish(c.deadLetterSubject, payload)
+}
+
+// InspectDeadLetters reads the dead-letter queue from subscriber, calling
+// handler with each DeadLetter until ctx is done. Messages are acknowledged
+// when handler returns nil, ones which cannot be decoded are skipped.
+func InspectDeadLetters(ctx context.Context, subscriber Subscriber, handler func(DeadLetter) error) error {
+	return subscriber.Subscribe(ctx, func(data []byte, attempt int) error {
+		deadLetter := DeadLetter{}
+		if err := json.Unmarshal(data, &deadLetter); err != nil {
+			log.Printf("async: unable to decode dead-letter: %s", err)
+			return nil
+		}
+
+		return handler(deadLetter)
+	})
+}
@@ -0,0 +1,130 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+// fakePublisher records what is published, or fails with err
+type fakePublisher struct {
+	subject   string
+	published [][]byte
+	err       error
+}
+
+func (p *fakePublisher) Publish(subject string, data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.subject = subject
+	p.published = append(p.published, data)
+	return nil
+}
+
+func Test_Consume_DeadLettersAfterMaxAttempts(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	data := queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost, Body: []byte("hello")})
+	subscriber := &fakeSubscriber{messages: [][]byte{data}, redeliveries: 10}
+	publisher := &fakePublisher{}
+
+	invocations := 0
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		invocations++
+		return nil, errors.New("connection refused")
+	}
+
+	NewConsumer(subscriber, nil, WithDeadLetter(publisher, "faas-request-dlq", 3)).Consume(context.Background(), invoke)
+
+	if invocations != 3 {
+		t.Errorf("want 3 attempts, got: %d", invocations)
+	}
+	if subscriber.results[0] != nil {
+		t.Errorf("want the message acknowledged once dead-lettered, got: %v", subscriber.results[0])
+	}
+	if publisher.subject != "faas-request-dlq" || len(publisher.published) != 1 {
+		t.Fatalf("want 1 message published to faas-request-dlq, got: %d to %q", len(publisher.published), publisher.subject)
+	}
+
+	deadLetter := DeadLetter{}
+	if err := json.Unmarshal(publisher.published[0], &deadLetter); err != nil {
+		t.Fatal(err)
+	}
+	if deadLetter.Attempts != 3 || deadLetter.Error != "connection refused" || deadLetter.Function != "figlet" {
+		t.Errorf("want the final error and attempts, got: %+v", deadLetter)
+	}
+	if deadLetter.FailedAt.IsZero() {
+		t.Error("want the time of the final attempt")
+	}
+
+	req, err := deadLetter.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Function != "figlet" || string(req.Body) != "hello" {
+		t.Errorf("want the original request, got: %+v", req)
+	}
+
+	spans := recorder.Named("async figlet")
+	if len(spans) != 3 {
+		t.Fatalf("want a span for each attempt, got: %d", len(spans))
+	}
+	for i, span := range spans {
+		dead := false
+		for _, event := range span.Events() {
+			if event.Name == tracing.DeadLetteredEvent {
+				dead = true
+			}
+		}
+		if want := i == len(spans)-1; dead != want {
+			t.Errorf("want %s on the final attempt only, attempt %d had it: %t", tracing.DeadLetteredEvent, i+1, dead)
+		}
+	}
+}
+
+func Test_Consume_RedeliveredWhenDeadLetterFails(t *testing.T) {
+	data := queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost})
+	subscriber := &fakeSubscriber{messages: [][]byte{data}}
+	publisher := &fakePublisher{err: errors.New("nats: connection closed")}
+
+	want := errors.New("connection refused")
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return nil, want
+	}
+
+	NewConsumer(subscriber, nil, WithDeadLetter(publisher, "faas-request-dlq", 1)).Consume(context.Background(), invoke)
+
+	if !errors.Is(subscriber.results[0], want) {
+		t.Errorf("want the invoke error so the message is redelivered, got: %v", subscriber.results[0])
+	}
+}
+
+func Test_InspectDeadLetters(t *testing.T) {
+	data := queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost})
+	payload, _ := json.Marshal(DeadLetter{Message: data, Function: "figlet", Error: "timeout", Attempts: 5})
+	subscriber := &fakeSubscriber{messages: [][]byte{[]byte("not json"), payload}}
+
+	var got []DeadLetter
+	err := InspectDeadLetters(context.Background(), subscriber, func(deadLetter DeadLetter) error {
+		got = append(got, deadLetter)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Attempts != 5 || got[0].Error != "timeout" {
+		t.Fatalf("want the decoded dead-letter, got: %+v", got)
+	}
+	if req, err := got[0].Request(); err != nil || req.Function != "figlet" {
+		t.Errorf("want the original request, got: %+v, %v", req, err)
+	}
+}
@@ -0,0 +1,166 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DepthReader reports how many queued requests are waiting to be run
+type DepthReader interface {
+	Depth(ctx context.Context) (int64, error)
+}
+
+// NATSMonitor reads the depth of a NATS Streaming channel from the server's
+// monitoring endpoint, usually on port 8222. The depth is the consumer lag
+// of the queue group as reported by the server: the messages not yet sent
+// to the group, and those sent but not yet acknowledged.
+type NATSMonitor struct {
+	// URL of the monitoring endpoint, i.e. http://nats:8222
+	URL string
+
+	Channel    string
+	QueueGroup string
+
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// channelz is the part of a channel read from /streaming/channelsz
+type channelz struct {
+	Msgs          int64           `json:"msgs"`
+	LastSeq       int64           `json:"last_seq"`
+	Subscriptions []subscriptionz `json:"subscriptions"`
+}
+
+type subscriptionz struct {
+	QueueName    string `json:"queue_name"`
+	LastSent     int64  `json:"last_sent"`
+	PendingCount int64  `json:"pending_count"`
+}
+
+// Depth reads the channel and its subscriptions from the monitoring
+// endpoint
+func (m *NATSMonitor) Depth(ctx context.Context) (int64, error) {
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	query := url.Values{}
+	query.Set("channel", m.Channel)
+	query.Set("subs", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(m.URL, "/")+"/streaming/channelsz?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code from NATS monitoring: %d", res.StatusCode)
+	}
+
+	channel := channelz{}
+	if err := json.NewDecoder(res.Body).Decode(&channel); err != nil {
+		return 0, fmt.Errorf("unable to decode channel %s: %w", m.Channel, err)
+	}
+
+	return channel.lag(m.QueueGroup), nil
+}
+
+// lag of group, the members of a queue group share its last sent sequence.
+// Until the group has subscribed every message in the channel is waiting.
+func (c channelz) lag(group string) int64 {
+	found := false
+	lastSent, pending := int64(0), int64(0)
+
+	for _, sub := range c.Subscriptions {
+		// a durable queue subscription is named durable:group
+		if sub.QueueName != group && !strings.HasSuffix(sub.QueueName, ":"+group) {
+			continue
+		}
+
+		found = true
+		if sub.LastSent > lastSent {
+			lastSent = sub.LastSent
+		}
+		pending += sub.PendingCount
+	}
+
+	if !found {
+		return c.Msgs
+	}
+
+	lag := c.LastSeq - lastSent
+	if lag < 0 {
+		lag = 0
+	}
+	return lag + pending
+}
+
+// DepthWatcher reads the depth of a queue every interval, and exports it
+// as a Prometheus gauge. A Consumer given WithDepthWatcher records the last
+// depth read on each of its spans.
+type DepthWatcher struct {
+	reader DepthReader
+	gauge  prometheus.Gauge
+	depth  atomic.Int64
+	read   atomic.Bool
+}
+
+// NewDepthWatcher creates a DepthWatcher, gauge can be nil
+func NewDepthWatcher(reader DepthReader, gauge prometheus.Gauge) *DepthWatcher {
+	return &DepthWatcher{reader: reader, gauge: gauge}
+}
+
+// Watch reads the depth straight away, then every interval until ctx is
+// done. The last depth is kept when it can not be read.
+func (w *DepthWatcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.update(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("async: unable to read the queue depth: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *DepthWatcher) update(ctx context.Context) error {
+	depth, err := w.reader.Depth(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.depth.Store(depth)
+	w.read.Store(true)
+	if w.gauge != nil {
+		w.gauge.Set(float64(depth))
+	}
+	return nil
+}
+
+// Depth is the last depth read, and false until it has been read
+func (w *DepthWatcher) Depth() (int64, bool) {
+	return w.depth.Load(), w.read.Load()
+}
@@ -0,0 +1,172 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// natsMonitoring serves a channelsz response for faas-request, as the NATS
+// Streaming monitoring endpoint does
+func natsMonitoring(t *testing.T, body *atomic.Value) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/streaming/channelsz" || r.URL.Query().Get("channel") != "faas-request" || r.URL.Query().Get("subs") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body.Load().(string)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := gauge.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func Test_DepthWatcher_ExportsConsumerLag(t *testing.T) {
+	body := &atomic.Value{}
+	body.Store(`{"name":"faas-request","msgs":120,"first_seq":1,"last_seq":120,"subscriptions":[
+		{"queue_name":"faas:faas","is_durable":true,"last_sent":100,"pending_count":1},
+		{"queue_name":"faas:faas","is_durable":true,"last_sent":98,"pending_count":1},
+		{"queue_name":"other:other","last_sent":10,"pending_count":0}
+	]}`)
+	server := natsMonitoring(t, body)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_depth"})
+	watcher := NewDepthWatcher(&NATSMonitor{URL: server.URL, Channel: "faas-request", QueueGroup: "faas"}, gauge)
+
+	if _, ok := watcher.Depth(); ok {
+		t.Fatalf("want no depth before the first read")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Watch(ctx, time.Millisecond*10)
+
+	// 20 messages not yet sent to the group, and 2 sent but not acknowledged
+	waitForGauge(t, gauge, 22)
+	if depth, ok := watcher.Depth(); !ok || depth != 22 {
+		t.Errorf("want depth: 22, got: %d %t", depth, ok)
+	}
+
+	body.Store(`{"name":"faas-request","msgs":130,"last_seq":130,"subscriptions":[
+		{"queue_name":"faas:faas","last_sent":130,"pending_count":0}
+	]}`)
+	waitForGauge(t, gauge, 0)
+}
+
+func waitForGauge(t *testing.T, gauge prometheus.Gauge, want float64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for gaugeValue(t, gauge) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("want gauge: %f, got: %f", want, gaugeValue(t, gauge))
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func Test_NATSMonitor_WithoutQueueGroup(t *testing.T) {
+	body := &atomic.Value{}
+	body.Store(`{"name":"faas-request","msgs":7,"first_seq":1,"last_seq":7,"subscriptions":[]}`)
+	server := natsMonitoring(t, body)
+
+	monitor := &NATSMonitor{URL: server.URL + "/", Channel: "faas-request", QueueGroup: "faas"}
+	depth, err := monitor.Depth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 7 {
+		t.Errorf("want every message in the channel waiting, got: %d", depth)
+	}
+}
+
+func Test_NATSMonitor_UnknownChannel(t *testing.T) {
+	body := &atomic.Value{}
+	server := natsMonitoring(t, body)
+
+	monitor := &NATSMonitor{URL: server.URL, Channel: "missing", QueueGroup: "faas"}
+	if _, err := monitor.Depth(context.Background()); err == nil {
+		t.Errorf("want an error for a channel which is not found")
+	}
+}
+
+// staticDepth reports depth, or err
+type staticDepth struct {
+	depth int64
+	err   error
+}
+
+func (s staticDepth) Depth(ctx context.Context) (int64, error) {
+	return s.depth, s.err
+}
+
+func Test_DepthWatcher_KeepsLastDepthOnError(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_depth"})
+	watcher := NewDepthWatcher(staticDepth{depth: 5}, gauge)
+	if err := watcher.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher.reader = staticDepth{err: errors.New("connection refused")}
+	if err := watcher.update(context.Background()); err == nil {
+		t.Fatalf("want the error returned")
+	}
+
+	if depth, _ := watcher.Depth(); depth != 5 || gaugeValue(t, gauge) != 5 {
+		t.Errorf("want the last depth kept, got: %d %f", depth, gaugeValue(t, gauge))
+	}
+}
+
+func Test_Consume_RecordsQueueDepth(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	watcher := NewDepthWatcher(staticDepth{depth: 42}, nil)
+	if err := watcher.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost})}}
+	NewConsumer(subscriber, nil, WithDepthWatcher(watcher)).Consume(context.Background(), okInvoke(""))
+
+	spans := recorder.Named("async figlet")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 consumer span, got: %d", len(spans))
+	}
+
+	found := false
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.QueueDepthKey {
+			found = true
+			if kv.Value.AsInt64() != 42 {
+				t.Errorf("want %s: 42, got: %d", tracing.QueueDepthKey, kv.Value.AsInt64())
+			}
+		}
+	}
+	if !found {
+		t.Errorf("want the consumer span to have %s", tracing.QueueDepthKey)
+	}
+}
@@ -0,0 +1,66 @@
+package async
+
+import (
+	"context"
+	"log"
+	"time"
+
+	stan "github.com/nats-io/stan.go"
+)
+
+// NATSSubscriber reads queued requests from a NATS Streaming channel. Workers
+// which share the queue group share the work between them.
+type NATSSubscriber struct {
+	conn       stan.Conn
+	channel    string
+	queueGroup string
+	ackWait    time.Duration
+
+	// ack is replaced in tests, a stan.Msg can only be acknowledged
+	// through a live subscription
+	ack func(msg *stan.Msg) error
+}
+
+// NewNATSSubscriber creates a Subscriber for channel, ackWait is how long a
+// message can be processed for before it is redelivered.
+func NewNATSSubscriber(conn stan.Conn, channel, queueGroup string, ackWait time.Duration) *NATSSubscriber {
+	return &NATSSubscriber{
+		conn:       conn,
+		channel:    channel,
+		queueGroup: queueGroup,
+		ackWait:    ackWait,
+		ack:        (*stan.Msg).Ack,
+	}
+}
+
+// Subscribe acknowledges each message once handler succeeds, and blocks until
+// ctx is done. The attempt is counted from the message's redeliveries.
+func (s *NATSSubscriber) Subscribe(ctx context.Context, handler func(data []byte, attempt int) error) error {
+	sub, err := s.conn.QueueSubscribe(s.channel, s.queueGroup, func(msg *stan.Msg) {
+		if err := handler(msg.Data, int(msg.RedeliveryCount)+1); err != nil {
+			log.Printf("async: message %d will be redelivered: %s", msg.Sequence, err)
+			return
+		}
+
+		if err := s.ack(msg); err != nil {
+			log.Printf("async: unable to acknowledge message %d: %s", msg.Sequence, err)
+		}
+	},
+		stan.DurableName(s.queueGroup),
+		stan.SetManualAckMode(),
+		stan.AckWait(s.ackWait),
+		stan.MaxInflight(1),
+	)
+	if err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+
+	// Close rather than Unsubscribe, so the durable subscription is kept
+	if err := sub.Close(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
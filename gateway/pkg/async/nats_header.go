@@ -0,0 +1,71 @@
+package async
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// NATSHeaderCarrier reads and writes the trace context and baggage in the
+// headers of a NATS message, for publishers and subscribers using NATS core
+// or JetStream. NATS Streaming messages have no headers, so queued requests
+// carry their trace context in their Header and Annotations instead.
+type NATSHeaderCarrier nats.Header
+
+var _ propagation.TextMapCarrier = NATSHeaderCarrier{}
+
+// Get returns the first value for key. NATS headers are case-sensitive, so
+// a key set by another client in a different case, i.e. Traceparent, is
+// matched when there is no exact match.
+func (c NATSHeaderCarrier) Get(key string) string {
+	if value := nats.Header(c).Get(key); len(value) > 0 {
+		return value
+	}
+
+	for k, values := range c {
+		if strings.EqualFold(k, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// Set replaces any values for key
+func (c NATSHeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+// Keys lists the keys of the headers
+func (c NATSHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// InjectNATS writes the trace context and baggage of ctx into header with
+// the global propagator, creating the header when it is nil. Nothing is
+// written when there is no header to write to.
+func InjectNATS(ctx context.Context, header *nats.Header) {
+	if header == nil {
+		return
+	}
+	if *header == nil {
+		*header = nats.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, NATSHeaderCarrier(*header))
+}
+
+// ExtractNATS returns ctx with the trace context and baggage read from
+// header with the global propagator, a span started from it continues the
+// publisher's trace.
+func ExtractNATS(ctx context.Context, header *nats.Header) context.Context {
+	if header == nil || *header == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, NATSHeaderCarrier(*header))
+}
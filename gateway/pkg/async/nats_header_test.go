@@ -0,0 +1,94 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_NATSHeader_RoundTripsTraceContext(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	member, err := baggage.NewMember("tenant", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bag, err := baggage.New(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := otel.Tracer("test").Start(baggage.ContextWithBaggage(context.Background(), bag), "publish")
+	defer span.End()
+
+	var header nats.Header
+	InjectNATS(ctx, &header)
+
+	if len(header.Get("traceparent")) == 0 {
+		t.Fatalf("want traceparent in the headers, got: %v", header)
+	}
+
+	got := ExtractNATS(context.Background(), &header)
+
+	remote := trace.SpanContextFromContext(got)
+	if !remote.IsRemote() {
+		t.Errorf("want a remote span context")
+	}
+	if remote.TraceID() != span.SpanContext().TraceID() || remote.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("want span context: %s/%s, got: %s/%s",
+			span.SpanContext().TraceID(), span.SpanContext().SpanID(), remote.TraceID(), remote.SpanID())
+	}
+
+	if tenant := baggage.FromContext(got).Member("tenant").Value(); tenant != "acme" {
+		t.Errorf("want baggage tenant: acme, got: %q", tenant)
+	}
+}
+
+func Test_NATSHeader_ExtractMatchesKeyInAnyCase(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	var header nats.Header
+	InjectNATS(ctx, &header)
+
+	canonical := nats.Header{"Traceparent": header.Values("traceparent")}
+	got := trace.SpanContextFromContext(ExtractNATS(context.Background(), &canonical))
+	if got.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("want trace ID: %s, got: %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+}
+
+func Test_NATSHeader_ExtractWithoutHeaders(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	ctx := context.Background()
+	if got := ExtractNATS(ctx, nil); got != ctx {
+		t.Errorf("want ctx returned as it is for nil headers")
+	}
+
+	var header nats.Header
+	if got := trace.SpanContextFromContext(ExtractNATS(ctx, &header)); got.IsValid() {
+		t.Errorf("want no span context from empty headers, got: %v", got)
+	}
+}
+
+func Test_NATSHeader_InjectWithoutHeaders(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	// must not panic
+	InjectNATS(ctx, nil)
+}
@@ -0,0 +1,200 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	stan "github.com/nats-io/stan.go"
+	"github.com/nats-io/stan.go/pb"
+	ftypes "github.com/openfaas/faas-provider/types"
+)
+
+// fakeConn records the queue subscription made by NATSSubscriber, the
+// embedded interface panics if any other method is called.
+type fakeConn struct {
+	stan.Conn
+
+	channel    string
+	queueGroup string
+	opts       stan.SubscriptionOptions
+	handler    chan stan.MsgHandler
+	sub        *fakeSubscription
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		handler: make(chan stan.MsgHandler, 1),
+		sub:     &fakeSubscription{},
+	}
+}
+
+func (c *fakeConn) QueueSubscribe(subject, qgroup string, cb stan.MsgHandler, opts ...stan.SubscriptionOption) (stan.Subscription, error) {
+	c.channel = subject
+	c.queueGroup = qgroup
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	c.handler <- cb
+	return c.sub, nil
+}
+
+type fakeSubscription struct {
+	stan.Subscription
+	closed bool
+}
+
+func (s *fakeSubscription) Close() error {
+	s.closed = true
+	return nil
+}
+
+func message(sequence uint64, data []byte) *stan.Msg {
+	return &stan.Msg{MsgProto: pb.MsgProto{Sequence: sequence, Data: data}}
+}
+
+// subscribe starts s.Subscribe and returns the handler registered with the
+// fake connection, along with a func to stop the subscription.
+func subscribe(t *testing.T, conn *fakeConn, s *NATSSubscriber, handler func([]byte, int) error) (stan.MsgHandler, func() error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Subscribe(ctx, handler)
+	}()
+
+	select {
+	case cb := <-conn.handler:
+		return cb, func() error {
+			cancel()
+			return <-result
+		}
+	case <-time.After(time.Second):
+		cancel()
+		t.Fatal("want Subscribe to create a queue subscription")
+		return nil, nil
+	}
+}
+
+func Test_NATSSubscriber_SubscriptionOptions(t *testing.T) {
+	conn := newFakeConn()
+	s := NewNATSSubscriber(conn, "faas-request", "faas", time.Second*30)
+
+	_, stop := subscribe(t, conn, s, func([]byte, int) error { return nil })
+
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("want %s when ctx is done, got: %v", context.Canceled, err)
+	}
+
+	if conn.channel != "faas-request" || conn.queueGroup != "faas" {
+		t.Errorf("want channel faas-request and group faas, got: %s and %s", conn.channel, conn.queueGroup)
+	}
+	if !conn.opts.ManualAcks {
+		t.Error("want manual acknowledgements")
+	}
+	if conn.opts.DurableName != "faas" {
+		t.Errorf("want durable name: faas, got: %s", conn.opts.DurableName)
+	}
+	if conn.opts.AckWait != time.Second*30 {
+		t.Errorf("want ack wait: %s, got: %s", time.Second*30, conn.opts.AckWait)
+	}
+	if !conn.sub.closed {
+		t.Error("want the subscription to be closed when ctx is done")
+	}
+}
+
+func Test_NATSSubscriber_AcksOnlyWhenHandlerSucceeds(t *testing.T) {
+	conn := newFakeConn()
+	s := NewNATSSubscriber(conn, "faas-request", "faas", time.Second)
+
+	acked := []uint64{}
+	s.ack = func(msg *stan.Msg) error {
+		acked = append(acked, msg.Sequence)
+		return nil
+	}
+
+	cb, stop := subscribe(t, conn, s, func(data []byte, attempt int) error {
+		if string(data) == "fail" {
+			return errors.New("invoke failed")
+		}
+		return nil
+	})
+	defer stop()
+
+	cb(message(1, []byte("ok")))
+	cb(message(2, []byte("fail")))
+	cb(message(3, []byte("ok")))
+
+	if len(acked) != 2 || acked[0] != 1 || acked[1] != 3 {
+		t.Errorf("want messages 1 and 3 acknowledged, got: %v", acked)
+	}
+}
+
+func Test_NATSSubscriber_CountsRedeliveries(t *testing.T) {
+	conn := newFakeConn()
+	s := NewNATSSubscriber(conn, "faas-request", "faas", time.Second)
+	s.ack = func(msg *stan.Msg) error { return nil }
+
+	attempts := []int{}
+	cb, stop := subscribe(t, conn, s, func(data []byte, attempt int) error {
+		attempts = append(attempts, attempt)
+		return nil
+	})
+	defer stop()
+
+	msg := message(1, []byte("ok"))
+	cb(msg)
+	msg.Redelivered = true
+	msg.RedeliveryCount = 2
+	cb(msg)
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 3 {
+		t.Errorf("want attempts 1 and 3, got: %v", attempts)
+	}
+}
+
+func Test_Consumer_WithNATSSubscriber(t *testing.T) {
+	conn := newFakeConn()
+	s := NewNATSSubscriber(conn, "faas-request", "faas", time.Second)
+
+	acked := []uint64{}
+	s.ack = func(msg *stan.Msg) error {
+		acked = append(acked, msg.Sequence)
+		return nil
+	}
+
+	invoke := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.URL.Path, "/function/offline") {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewConsumer(s, nil).Consume(ctx, invoke)
+	}()
+
+	var cb stan.MsgHandler
+	select {
+	case cb = <-conn.handler:
+	case <-time.After(time.Second):
+		t.Fatal("want Consume to subscribe")
+	}
+
+	cb(message(1, queued(t, &ftypes.QueueRequest{Function: "figlet", Method: http.MethodPost})))
+	cb(message(2, queued(t, &ftypes.QueueRequest{Function: "offline", Method: http.MethodPost})))
+
+	cancel()
+	<-done
+
+	if len(acked) != 1 || acked[0] != 1 {
+		t.Errorf("want only the successful invocation acknowledged, got: %v", acked)
+	}
+}
@@ -0,0 +1,159 @@
+// Package async queues function invocations over NATS Streaming, so that
+// callers receive a 202 straight away and the result is delivered to an
+// optional callback URL once the function has run.
+//
+// Enqueue is a method on Queue rather than a package-level func, so that the
+// gateway can be given its queue and path transformer without any global
+// state. The gateway only publishes: queued requests are normally run by the
+// separate nats-queue-worker, and Consumer with NATSSubscriber is for a
+// worker built on this package. A Consumer given WithDeadLetter publishes
+// requests which keep failing to a dead-letter queue, which can be read
+// back with InspectDeadLetters. A DepthWatcher exports how many requests are
+// waiting, read from the broker's consumer lag by NATSMonitor, and each
+// request's wait in the queue is recorded by the Consumer. InjectNATS and
+// ExtractNATS carry the trace context in the headers of NATS core and
+// JetStream messages.
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrReadBody is wrapped by the error from Enqueue when the request's body
+// could not be read, as opposed to the request failing to be queued
+var ErrReadBody = errors.New("unable to read the request body")
+
+// CallbackURLHeader is set by the caller to receive the function's response
+const CallbackURLHeader = "X-Callback-Url"
+
+// Queue publishes invocations to a RequestQueuer such as the NATS Streaming
+// queue from nats-queue-worker.
+type Queue struct {
+	queuer          ftypes.RequestQueuer
+	pathTransformer middleware.URLPathTransformer
+}
+
+// NewQueue creates a Queue, pathTransformer decides which part of the
+// incoming path is passed on to the function.
+func NewQueue(queuer ftypes.RequestQueuer, pathTransformer middleware.URLPathTransformer) *Queue {
+	return &Queue{
+		queuer:          queuer,
+		pathTransformer: pathTransformer,
+	}
+}
+
+// Enqueue serializes req and publishes it for functionName, within a
+// producer span. The span's context is stored in the message's annotations,
+// and written into the queued headers for workers which only read those, so
+// that the eventual invocation and its callback are part of the same trace
+// as the original request. The time it was published is stored in the
+// EnqueuedAtAnnotation, for the consumer to record how long it waited.
+func (q *Queue) Enqueue(ctx context.Context, functionName string, req *http.Request) (err error) {
+	ctx, span := otel.Tracer(tracing.TracerName).Start(ctx, "enqueue "+functionName,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.FunctionNameKey.String(functionName)),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var body []byte
+	if req.Body != nil {
+		defer req.Body.Close()
+
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReadBody, err)
+		}
+	}
+
+	callbackURL, err := CallbackURL(req.Header)
+	if err != nil {
+		return err
+	}
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+
+	annotations := map[string]string{
+		EnqueuedAtAnnotation: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, annotationCarrier(annotations))
+
+	return q.queuer.Queue(&ftypes.QueueRequest{
+		Function:    functionName,
+		Body:        body,
+		Method:      req.Method,
+		QueryString: req.URL.RawQuery,
+		Path:        q.pathTransformer.Transform(req),
+		Header:      header,
+		Host:        req.Host,
+		CallbackURL: callbackURL,
+		Annotations: annotations,
+	})
+}
+
+// traceAnnotationPrefix namespaces the trace context fields stored in the
+// annotations of a queued request, i.e. trace.traceparent
+const traceAnnotationPrefix = "trace."
+
+// annotationCarrier reads and writes the trace context in the annotations
+// of a queued request
+type annotationCarrier map[string]string
+
+func (c annotationCarrier) Get(key string) string {
+	return c[traceAnnotationPrefix+key]
+}
+
+func (c annotationCarrier) Set(key, value string) {
+	c[traceAnnotationPrefix+key] = value
+}
+
+func (c annotationCarrier) Keys() []string {
+	keys := []string{}
+	for key := range c {
+		if strings.HasPrefix(key, traceAnnotationPrefix) {
+			keys = append(keys, strings.TrimPrefix(key, traceAnnotationPrefix))
+		}
+	}
+	return keys
+}
+
+// hasTraceContext reports whether trace context was stored in annotations
+func hasTraceContext(annotations map[string]string) bool {
+	return len(annotationCarrier(annotations).Keys()) > 0
+}
+
+// CallbackURL parses the optional X-Callback-Url header, returning nil when
+// it is not set.
+func CallbackURL(header http.Header) (*url.URL, error) {
+	value := header.Get(CallbackURLHeader)
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	return url.Parse(value)
+}
@@ -0,0 +1,115 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+)
+
+type fakeQueuer struct {
+	requests []*ftypes.QueueRequest
+	err      error
+}
+
+func (f *fakeQueuer) Queue(req *ftypes.QueueRequest) error {
+	f.requests = append(f.requests, req)
+	return f.err
+}
+
+func Test_Enqueue_SerializesRequest(t *testing.T) {
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet/employees?format=json", strings.NewReader("hello"))
+	req.Header.Set(CallbackURLHeader, "http://receiver:8080/result")
+	req.Header.Set("X-Call-Id", "call-1")
+
+	if err := queue.Enqueue(context.Background(), "figlet", req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queuer.requests) != 1 {
+		t.Fatalf("want 1 queued request, got: %d", len(queuer.requests))
+	}
+	got := queuer.requests[0]
+
+	if got.Function != "figlet" {
+		t.Errorf("want function: figlet, got: %s", got.Function)
+	}
+	if string(got.Body) != "hello" {
+		t.Errorf("want body: hello, got: %s", string(got.Body))
+	}
+	if got.Method != http.MethodPost {
+		t.Errorf("want method: %s, got: %s", http.MethodPost, got.Method)
+	}
+	if got.Path != "/employees" {
+		t.Errorf("want path: /employees, got: %s", got.Path)
+	}
+	if got.QueryString != "format=json" {
+		t.Errorf("want query: format=json, got: %s", got.QueryString)
+	}
+	if got.CallbackURL == nil || got.CallbackURL.String() != "http://receiver:8080/result" {
+		t.Errorf("want callback URL: http://receiver:8080/result, got: %v", got.CallbackURL)
+	}
+	if got.Header.Get("X-Call-Id") != "call-1" {
+		t.Errorf("want X-Call-Id header: call-1, got: %s", got.Header.Get("X-Call-Id"))
+	}
+}
+
+func Test_Enqueue_PropagatesTraceContext(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "caller")
+	defer span.End()
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+	if err := queue.Enqueue(ctx, "figlet", req); err != nil {
+		t.Fatal(err)
+	}
+
+	traceparent := queuer.requests[0].Header.Get("traceparent")
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("want traceparent with trace ID %s, got: %q", span.SpanContext().TraceID(), traceparent)
+	}
+
+	if v := req.Header.Get("traceparent"); len(v) > 0 {
+		t.Errorf("want the caller's headers to be left alone, got traceparent: %s", v)
+	}
+}
+
+func Test_Enqueue_InvalidCallbackURL(t *testing.T) {
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+	req.Header.Set(CallbackURLHeader, "ht tp://foo.com")
+
+	if err := queue.Enqueue(context.Background(), "figlet", req); err == nil {
+		t.Fatal("want an error for an invalid callback URL")
+	}
+	if len(queuer.requests) != 0 {
+		t.Errorf("want nothing queued, got: %d", len(queuer.requests))
+	}
+}
+
+func Test_Enqueue_ReturnsQueueError(t *testing.T) {
+	want := errors.New("nats: connection closed")
+	queue := NewQueue(&fakeQueuer{err: want}, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)
+	if err := queue.Enqueue(context.Background(), "figlet", req); !errors.Is(err, want) {
+		t.Errorf("want error: %s, got: %v", want, err)
+	}
+}
@@ -0,0 +1,68 @@
+package async
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EnqueuedAtAnnotation is the annotation of a queued request with the time
+// it was published, in RFC3339 with nanoseconds and UTC
+const EnqueuedAtAnnotation = "queue.enqueued_at"
+
+// queueWaitSeconds observes the wait of every consumer which is not given
+// WithQueueWaitHistogram
+var queueWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "gateway",
+	Subsystem: "async",
+	Name:      "queue_wait_seconds",
+	Help:      "Seconds each request waited in the queue before it was consumed.",
+	Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+})
+
+var registerQueueWait sync.Once
+
+// WithQueueWaitHistogram observes the seconds each request waited in the
+// queue before it was consumed, instead of gateway_async_queue_wait_seconds
+// in the default Prometheus registry. Requests queued without a timestamp
+// are not observed.
+func WithQueueWaitHistogram(histogram prometheus.Observer) ConsumerOption {
+	return func(c *Consumer) {
+		c.queueWait = histogram
+	}
+}
+
+// defaultQueueWait is gateway_async_queue_wait_seconds in the default
+// Prometheus registry
+func defaultQueueWait() prometheus.Observer {
+	registerQueueWait.Do(func() {
+		prometheus.MustRegister(queueWaitSeconds)
+	})
+	return queueWaitSeconds
+}
+
+// queueWait is how long the request queued at the time in annotations
+// waited until now, and false when there is no valid timestamp. The
+// publisher's clock may be ahead of the consumer's, so a negative wait is
+// logged and clamped to zero.
+func queueWait(function string, annotations map[string]string, now time.Time) (time.Duration, bool) {
+	value, ok := annotations[EnqueuedAtAnnotation]
+	if !ok {
+		return 0, false
+	}
+
+	enqueuedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.Printf("async: invalid %s for %s: %q", EnqueuedAtAnnotation, function, value)
+		return 0, false
+	}
+
+	wait := now.Sub(enqueuedAt)
+	if wait < 0 {
+		log.Printf("async: %s for %s is %s in the future, the clocks of the publisher and consumer may be skewed", EnqueuedAtAnnotation, function, -wait)
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,149 @@
+package async
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func queueWaitAttribute(t *testing.T, span sdktrace.ReadOnlySpan) (attribute.Value, bool) {
+	t.Helper()
+
+	for _, kv := range span.Attributes() {
+		if kv.Key == tracing.QueueWaitKey {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func consumeEnqueuedAt(t *testing.T, enqueuedAt string, now time.Time, histogram prometheus.Histogram) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	annotations := map[string]string{}
+	if len(enqueuedAt) > 0 {
+		annotations[EnqueuedAtAnnotation] = enqueuedAt
+	}
+	subscriber := &fakeSubscriber{messages: [][]byte{queued(t, &ftypes.QueueRequest{
+		Function:    "figlet",
+		Method:      http.MethodPost,
+		Annotations: annotations,
+	})}}
+
+	consumer := NewConsumer(subscriber, nil, WithQueueWaitHistogram(histogram))
+	consumer.now = func() time.Time { return now }
+	if err := consumer.Consume(context.Background(), okInvoke("")); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Named("async figlet")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 consumer span, got: %d", len(spans))
+	}
+	return spans[0]
+}
+
+func histogramSample(t *testing.T, histogram prometheus.Histogram) (uint64, float64) {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := histogram.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func Test_Consume_RecordsQueueWait(t *testing.T) {
+	enqueuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait_seconds"})
+
+	span := consumeEnqueuedAt(t, enqueuedAt.Format(time.RFC3339Nano), enqueuedAt.Add(1500*time.Millisecond), histogram)
+
+	value, ok := queueWaitAttribute(t, span)
+	if !ok {
+		t.Fatalf("want the consumer span to have %s", tracing.QueueWaitKey)
+	}
+	if value.AsInt64() != 1500 {
+		t.Errorf("want %s: 1500, got: %d", tracing.QueueWaitKey, value.AsInt64())
+	}
+
+	if count, sum := histogramSample(t, histogram); count != 1 || sum != 1.5 {
+		t.Errorf("want 1 observation of 1.5s, got: %d totalling %f", count, sum)
+	}
+}
+
+func Test_Consume_ObservesDefaultQueueWaitHistogram(t *testing.T) {
+	enqueuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	before, _ := histogramSample(t, queueWaitSeconds)
+
+	consumeEnqueuedAt(t, enqueuedAt.Format(time.RFC3339Nano), enqueuedAt.Add(time.Second), nil)
+
+	if after, _ := histogramSample(t, queueWaitSeconds); after != before+1 {
+		t.Errorf("want 1 observation on gateway_async_queue_wait_seconds, got: %d", after-before)
+	}
+}
+
+func Test_Consume_ClampsQueueWaitFromSkewedClock(t *testing.T) {
+	enqueuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait_seconds"})
+
+	span := consumeEnqueuedAt(t, enqueuedAt.Format(time.RFC3339Nano), enqueuedAt.Add(-2*time.Second), histogram)
+
+	value, ok := queueWaitAttribute(t, span)
+	if !ok || value.AsInt64() != 0 {
+		t.Errorf("want %s: 0 when the publisher's clock is ahead, got: %v", tracing.QueueWaitKey, value.Emit())
+	}
+	if count, sum := histogramSample(t, histogram); count != 1 || sum != 0 {
+		t.Errorf("want 1 observation of 0s, got: %d totalling %f", count, sum)
+	}
+}
+
+func Test_Consume_SkipsQueueWaitWithoutTimestamp(t *testing.T) {
+	for name, enqueuedAt := range map[string]string{"missing": "", "invalid": "yesterday"} {
+		t.Run(name, func(t *testing.T) {
+			histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait_seconds"})
+
+			span := consumeEnqueuedAt(t, enqueuedAt, time.Now(), histogram)
+
+			if _, ok := queueWaitAttribute(t, span); ok {
+				t.Errorf("want no %s", tracing.QueueWaitKey)
+			}
+			if count, _ := histogramSample(t, histogram); count != 0 {
+				t.Errorf("want no observations, got: %d", count)
+			}
+		})
+	}
+}
+
+func Test_Enqueue_StoresEnqueuedAt(t *testing.T) {
+	queuer := &fakeQueuer{}
+	queue := NewQueue(queuer, middleware.FunctionPrefixTrimmingURLPathTransformer{})
+
+	before := time.Now()
+	if err := queue.Enqueue(context.Background(), "figlet", httptest.NewRequest(http.MethodPost, "/async-function/figlet", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	value := queuer.requests[0].Annotations[EnqueuedAtAnnotation]
+	enqueuedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t.Fatalf("want %s in RFC3339, got: %q", EnqueuedAtAnnotation, value)
+	}
+	if enqueuedAt.Before(before) || enqueuedAt.After(time.Now()) {
+		t.Errorf("want %s set when the request was queued, got: %s", EnqueuedAtAnnotation, enqueuedAt)
+	}
+}
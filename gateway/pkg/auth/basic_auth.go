@@ -0,0 +1,79 @@
+// Package auth authenticates requests to the gateway before they are routed.
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	providerauth "github.com/openfaas/faas-provider/auth"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+)
+
+// DefaultProtectedPaths covers the admin API and the UI, function invocations
+// under /function/ are left open unless they are added explicitly.
+var DefaultProtectedPaths = []string{"/system", "/ui"}
+
+// BasicAuth requires HTTP Basic Auth matching credentials for any request
+// with a path under one of the protected prefixes, other requests are passed
+// to next untouched. Credentials are usually read from the secret mount with
+// ReadBasicAuthFromDisk from faas-provider.
+func BasicAuth(next http.Handler, credentials *providerauth.BasicAuthCredentials, protected []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPrefix(r.URL.Path, protected) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if !ok || !matches(credentials, user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			httperror.Write(w, r, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProtectedWithin returns the protected paths which cover any route under
+// prefixes, so that a listener serving only those routes, such as function
+// invocations, checks basic auth for nothing else.
+func ProtectedWithin(protected []string, prefixes []string) []string {
+	within := []string{}
+	for _, path := range protected {
+		for _, prefix := range prefixes {
+			if hasPrefix(prefix, []string{path}) || hasPrefix(path, []string{prefix}) {
+				within = append(within, path)
+				break
+			}
+		}
+	}
+	return within
+}
+
+// matches compares both the user and password in constant time, so that
+// neither can be guessed one byte at a time from response latency.
+func matches(credentials *providerauth.BasicAuthCredentials, user, password string) bool {
+	userMatch := subtle.ConstantTimeCompare([]byte(credentials.User), []byte(user))
+	passwordMatch := subtle.ConstantTimeCompare([]byte(credentials.Password), []byte(password))
+
+	return userMatch&passwordMatch == 1
+}
+
+// hasPrefix matches whole path segments, so "/system" protects
+// "/system/functions" but not "/systems".
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" {
+			return true
+		}
+
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
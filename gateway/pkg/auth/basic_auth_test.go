@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	providerauth "github.com/openfaas/faas-provider/auth"
+)
+
+func basicAuthHandler() http.Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	credentials := &providerauth.BasicAuthCredentials{User: "admin", Password: "secret"}
+	return BasicAuth(next, credentials, DefaultProtectedPaths)
+}
+
+func Test_BasicAuth_CorrectCredentials(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/system/functions", nil)
+	req.SetBasicAuth("admin", "secret")
+
+	rr := httptest.NewRecorder()
+	basicAuthHandler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+}
+
+func Test_BasicAuth_WrongCredentials(t *testing.T) {
+	cases := map[string][2]string{
+		"wrong password": {"admin", "guess"},
+		"wrong user":     {"root", "secret"},
+		"empty":          {"", ""},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/system/functions", nil)
+			req.SetBasicAuth(c[0], c[1])
+
+			rr := httptest.NewRecorder()
+			basicAuthHandler().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("want status: %d, got: %d", http.StatusUnauthorized, rr.Code)
+			}
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("want a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func Test_BasicAuth_MissingHeader(t *testing.T) {
+	rr := httptest.NewRecorder()
+	basicAuthHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/system/functions", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("want status: %d, got: %d", http.StatusUnauthorized, rr.Code)
+	}
+	if got := rr.Header().Get("WWW-Authenticate"); got != `Basic realm="Restricted"` {
+		t.Errorf("want WWW-Authenticate: Basic realm=\"Restricted\", got: %s", got)
+	}
+}
+
+func Test_BasicAuth_LeavesUnprotectedPathsOpen(t *testing.T) {
+	for _, path := range []string{"/function/figlet", "/healthz", "/systems"} {
+		rr := httptest.NewRecorder()
+		basicAuthHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: want status: %d, got: %d", path, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+func Test_ProtectedWithin(t *testing.T) {
+	protected := []string{"/system", "/ui", "/function/figlet", "/async-function", "/functions"}
+
+	got := ProtectedWithin(protected, []string{"/function", "/async-function"})
+	want := []string{"/function/figlet", "/async-function"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	if got := ProtectedWithin([]string{"/"}, []string{"/function"}); !reflect.DeepEqual(got, []string{"/"}) {
+		t.Errorf("want the root to cover functions, got: %v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body as sent by
+// GitHub and other webhook senders, i.e. "sha256=<hex>"
+const SignatureHeader = "X-Hub-Signature-256"
+
+const signaturePrefix = "sha256="
+
+// VerifyHMAC returns a middleware which checks the SignatureHeader of each
+// request against its body, using the secret returned by secretLookup for
+// the function being invoked, i.e. fn or fn.namespace as it appears in the
+// path. When secretLookup returns a nil secret the function does not need
+// signed requests and they are passed through.
+//
+// The body is read to compute the signature, then restored so that next
+// still sees it.
+func VerifyHMAC(secretLookup func(fn string) ([]byte, error)) func(next http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fn := middleware.GetServiceName(r.URL.Path)
+
+			secret, err := secretLookup(fn)
+			if err != nil {
+				log.Printf("unable to look up the webhook secret for %s: %s", fn, err)
+				httperror.Write(w, r, http.StatusInternalServerError, "unable to verify signature")
+				return
+			}
+			if secret == nil {
+				next(w, r)
+				return
+			}
+
+			signature := r.Header.Get(SignatureHeader)
+			if len(signature) == 0 {
+				tracing.SetSignatureOutcome(r.Context(), "missing")
+				httperror.Write(w, r, http.StatusUnauthorized, fmt.Sprintf("missing %s header", SignatureHeader))
+				return
+			}
+
+			body, err := tracing.ReadRequestBody(r)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					httperror.Write(w, r, http.StatusRequestEntityTooLarge, err.Error())
+					return
+				}
+
+				httperror.Write(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if !validSignature(secret, body, signature) {
+				tracing.SetSignatureOutcome(r.Context(), "invalid")
+				httperror.Write(w, r, http.StatusUnauthorized, "invalid signature")
+				return
+			}
+
+			tracing.SetSignatureOutcome(r.Context(), "valid")
+			next(w, r)
+		}
+	}
+}
+
+// validSignature compares the signature to the HMAC of body in constant time
+func validSignature(secret, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hmac.Equal(sent, mac.Sum(nil))
+}
+
+// SecretsFromDir looks up webhook secrets from files in dir named after each
+// function and its namespace, i.e. github-events.openfaas-fn, as mounted from
+// a Kubernetes secret. fn is resolved with defaultNamespace, so that fn and
+// fn.<default> look up the same secret. Functions in defaultNamespace, or in
+// any namespace when it is empty, may also use a file named after the
+// function alone. Functions without a file do not need signed requests.
+func SecretsFromDir(dir, defaultNamespace string) func(fn string) ([]byte, error) {
+	return func(fullName string) ([]byte, error) {
+		fn, namespace := middleware.GetNamespace(defaultNamespace, fullName)
+
+		names := []string{fn}
+		if len(namespace) > 0 {
+			names = []string{fn + "." + namespace}
+			if len(defaultNamespace) == 0 || namespace == defaultNamespace {
+				names = append(names, fn)
+			}
+		}
+
+		for _, name := range names {
+			if fn == "" || name == "." || name == ".." || name != filepath.Base(name) {
+				return nil, fmt.Errorf("invalid function name: %q", name)
+			}
+
+			secret, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+
+			return bytes.TrimSpace(secret), nil
+		}
+
+		return nil, nil
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+var webhookSecret = []byte("webhook-secret")
+
+func sign(secret []byte, body string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func lookupSecret(fn string) ([]byte, error) {
+	if fn == "github-events" || fn == "github-events.openfaas-fn" {
+		return webhookSecret, nil
+	}
+	return nil, nil
+}
+
+// invokeSigned calls the handler wrapped by VerifyHMAC, returning the status
+// and the body seen by the handler
+func invokeSigned(t *testing.T, path, body, signature string) (int, string) {
+	t.Helper()
+
+	var seen string
+	handler := VerifyHMAC(lookupSecret)(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seen = string(b)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if len(signature) > 0 {
+		req.Header.Set(SignatureHeader, signature)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr.Code, seen
+}
+
+func Test_VerifyHMAC_ValidSignature_ReplaysBody(t *testing.T) {
+	body := `{"action":"opened"}`
+
+	code, seen := invokeSigned(t, "/function/github-events", body, sign(webhookSecret, body))
+
+	if code != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, code)
+	}
+	if seen != body {
+		t.Errorf("want the function to see body: %q, got: %q", body, seen)
+	}
+}
+
+func Test_VerifyHMAC_InvalidSignature(t *testing.T) {
+	cases := map[string]string{
+		"other secret":   sign([]byte("guess"), `{"action":"opened"}`),
+		"other body":     sign(webhookSecret, `{"action":"closed"}`),
+		"no prefix":      strings.TrimPrefix(sign(webhookSecret, `{"action":"opened"}`), "sha256="),
+		"not hex":        "sha256=zz",
+		"sha1 signature": "sha1=0123",
+	}
+
+	for name, signature := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, seen := invokeSigned(t, "/function/github-events", `{"action":"opened"}`, signature)
+
+			if code != http.StatusUnauthorized {
+				t.Errorf("want status: %d, got: %d", http.StatusUnauthorized, code)
+			}
+			if seen != "" {
+				t.Error("want the function not to be invoked")
+			}
+		})
+	}
+}
+
+func Test_VerifyHMAC_MissingSignature(t *testing.T) {
+	code, _ := invokeSigned(t, "/function/github-events", `{}`, "")
+
+	if code != http.StatusUnauthorized {
+		t.Errorf("want status: %d, got: %d", http.StatusUnauthorized, code)
+	}
+}
+
+func Test_VerifyHMAC_SkipsFunctionsWithoutSecret(t *testing.T) {
+	code, seen := invokeSigned(t, "/function/figlet", "hi", "")
+
+	if code != http.StatusOK || seen != "hi" {
+		t.Errorf("want the request passed through, got status: %d, body: %q", code, seen)
+	}
+}
+
+func Test_VerifyHMAC_RecordsOutcomeOnSpan(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	handler := tracing.Middleware(VerifyHMAC(lookupSecret)(func(w http.ResponseWriter, r *http.Request) {}))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/function/github-events", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.SignatureKey {
+			if kv.Value.AsString() != "missing" {
+				t.Errorf("want %s: missing, got: %s", tracing.SignatureKey, kv.Value.AsString())
+			}
+			return
+		}
+	}
+	t.Errorf("want a %s attribute", tracing.SignatureKey)
+}
+
+func Test_VerifyHMAC_NamespacedPathsNeedSignature(t *testing.T) {
+	for _, path := range []string{"/function/github-events.openfaas-fn", "/function/github-events.openfaas-fn/push"} {
+		t.Run(path, func(t *testing.T) {
+			code, seen := invokeSigned(t, path, `{}`, "")
+
+			if code != http.StatusUnauthorized || seen != "" {
+				t.Errorf("want status: %d without a signature, got: %d", http.StatusUnauthorized, code)
+			}
+
+			code, _ = invokeSigned(t, path, `{}`, sign(webhookSecret, `{}`))
+			if code != http.StatusOK {
+				t.Errorf("want status: %d with a signature, got: %d", http.StatusOK, code)
+			}
+		})
+	}
+}
+
+func Test_SecretsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "github-events"), []byte("webhook-secret\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "stripe-events.payments"), []byte("stripe-secret\n"), 0600)
+
+	lookup := SecretsFromDir(dir, "openfaas-fn")
+
+	if secret, err := lookup("github-events"); err != nil || string(secret) != "webhook-secret" {
+		t.Errorf("want secret: webhook-secret, got: %q, error: %v", secret, err)
+	}
+	if secret, err := lookup("github-events.openfaas-fn"); err != nil || string(secret) != "webhook-secret" {
+		t.Errorf("want secret: webhook-secret in the default namespace, got: %q, error: %v", secret, err)
+	}
+	if secret, err := lookup("stripe-events.payments"); err != nil || string(secret) != "stripe-secret" {
+		t.Errorf("want secret: stripe-secret, got: %q, error: %v", secret, err)
+	}
+	if secret, err := lookup("github-events.other"); err != nil || secret != nil {
+		t.Errorf("want no secret for github-events in another namespace, got: %q, error: %v", secret, err)
+	}
+	if secret, err := lookup("figlet"); err != nil || secret != nil {
+		t.Errorf("want no secret for figlet, got: %q, error: %v", secret, err)
+	}
+	if _, err := lookup(".."); err == nil {
+		t.Error("want an error for a name outside of the directory")
+	}
+}
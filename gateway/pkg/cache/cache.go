@@ -0,0 +1,148 @@
+// Package cache keeps function responses in memory, so that repeated calls
+// for cacheable content can be answered by the gateway until they expire.
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a cached response from a function
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Vary holds the request headers named by the response's Vary header,
+	// it is only served to requests with the same values
+	Vary http.Header
+}
+
+type entry struct {
+	key      string
+	response *Response
+	expires  time.Time
+	size     int64
+}
+
+// size is roughly the memory held by a response stored for key, its key
+// and body along with the names and values of its headers
+func size(key string, res *Response) int64 {
+	n := len(key) + len(res.Body)
+	for _, header := range []http.Header{res.Header, res.Vary} {
+		for name, values := range header {
+			n += len(name)
+			for _, value := range values {
+				n += len(value)
+			}
+		}
+	}
+	return int64(n)
+}
+
+// Cache is a least recently used cache of responses, each with its own
+// expiry. It is safe for concurrent use.
+type Cache struct {
+	maxEntries int
+	maxBytes   int64
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// New creates a Cache which holds up to maxEntries responses and maxBytes
+// of keys, headers and bodies, the least recently used are evicted when it
+// is full. A response larger than maxBytes is not stored.
+func New(maxEntries int, maxBytes int64) *Cache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get returns the response stored for key, if it has not expired
+func (c *Cache) Get(key string) (*Response, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return e.response, true
+}
+
+// Set stores res for key until ttl has passed
+func (c *Cache) Set(key string, res *Response, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	n := size(key, res)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// a response replaced by one which is too large is not kept either
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	if n > c.maxBytes {
+		return
+	}
+
+	for c.lru.Len() >= c.maxEntries || c.bytes+n > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, response: res, expires: c.now().Add(ttl), size: n})
+	c.bytes += n
+}
+
+// remove must be called with the lock held
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
+
+// Len returns the number of responses held, including any which have
+// expired but not yet been looked up
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lru.Len()
+}
+
+// Bytes returns the size of the responses held, as counted against
+// maxBytes
+func (c *Cache) Bytes() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.bytes
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is advanced by tests instead of sleeping
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestCache(maxEntries int) (*Cache, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c := New(maxEntries, 1<<20)
+	c.now = clock.now
+	return c, clock
+}
+
+func Test_Cache_GetUntilExpired(t *testing.T) {
+	c, clock := newTestCache(10)
+
+	c.Set("GET /function/figlet", &Response{StatusCode: 200, Body: []byte("hello")}, time.Minute)
+
+	res, ok := c.Get("GET /function/figlet")
+	if !ok || string(res.Body) != "hello" {
+		t.Fatalf("want the stored response, got: %v %v", res, ok)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if _, ok := c.Get("GET /function/figlet"); ok {
+		t.Error("want the response to have expired")
+	}
+	if got := c.Len(); got != 0 {
+		t.Errorf("want the expired response removed, got: %d", got)
+	}
+}
+
+func Test_Cache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(2)
+
+	c.Set("a", &Response{}, time.Minute)
+	c.Set("b", &Response{}, time.Minute)
+	c.Get("a")
+	c.Set("c", &Response{}, time.Minute)
+
+	if got := c.Len(); got != 2 {
+		t.Fatalf("want 2 entries, got: %d", got)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("want b evicted, a was used after it")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("want a kept")
+	}
+}
+
+func Test_Cache_EvictsToStayUnderMaxBytes(t *testing.T) {
+	c := New(10, 100)
+
+	c.Set("a", &Response{Body: make([]byte, 40)}, time.Minute)
+	c.Set("b", &Response{Body: make([]byte, 40)}, time.Minute)
+	c.Set("c", &Response{Body: make([]byte, 40)}, time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("want a evicted to make room for c")
+	}
+	if got := c.Bytes(); got != 82 {
+		t.Errorf("want 82 bytes held for b and c, got: %d", got)
+	}
+
+	c.Set("b", &Response{Body: make([]byte, 200)}, time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Error("want a response larger than the cache not stored")
+	}
+	if got := c.Bytes(); got != 41 {
+		t.Errorf("want the replaced response freed, got: %d bytes", got)
+	}
+}
+
+func Test_Cache_SetWithoutTTL(t *testing.T) {
+	c, _ := newTestCache(10)
+
+	c.Set("a", &Response{}, 0)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("want a response without a TTL not to be stored")
+	}
+}
@@ -0,0 +1,236 @@
+// Package circuit stops requests to a function which keeps failing, so that
+// the gateway does not spend connections and time on requests which are
+// likely to fail, and gives the function time to recover.
+package circuit
+
+import (
+	"sync"
+	"time"
+)
+
+// State of the circuit for a function
+type State int
+
+const (
+	// Closed lets every request through, recording failures
+	Closed State = iota
+
+	// Open rejects every request until the cooldown has passed
+	Open
+
+	// HalfOpen lets a single probe request through, its result decides
+	// whether the circuit closes again or re-opens
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Config for a Breaker
+type Config struct {
+	// FailureRatio of failed requests within Window which opens the circuit,
+	// between 0 and 1.
+	FailureRatio float64
+
+	// MinRequests within Window before the circuit can open, so that a
+	// single failure does not open it.
+	MinRequests int
+
+	// Window over which failures are counted.
+	Window time.Duration
+
+	// Cooldown is how long the circuit stays open before a probe request is
+	// allowed through.
+	Cooldown time.Duration
+
+	// OnStateChange is called whenever the circuit for a function changes
+	// state. It is called with the Breaker's lock held, so must not call the
+	// Breaker.
+	OnStateChange func(name string, from, to State)
+}
+
+type circuit struct {
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+
+	// generation changes with each transition, so that the outcome of a
+	// request allowed before the transition is not counted after it
+	generation uint64
+}
+
+// Token is returned by Allow for a request which was let through, its
+// outcome is recorded with Done, or its slot given back with Cancel
+type Token struct {
+	breaker    *Breaker
+	name       string
+	generation uint64
+	probe      bool
+}
+
+// Breaker keeps a circuit for each function name, it is safe for concurrent
+// use.
+type Breaker struct {
+	config   Config
+	lock     sync.Mutex
+	circuits map[string]*circuit
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewBreaker creates a Breaker with every circuit closed
+func NewBreaker(config Config) *Breaker {
+	return &Breaker{
+		config:   config,
+		circuits: map[string]*circuit{},
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a request to name can be made, along with the state
+// of its circuit. A request which is allowed must be followed by a call to
+// Done with its outcome, or to Cancel when it has no outcome, on the Token.
+func (b *Breaker) Allow(name string) (Token, State, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := b.get(name)
+	now := b.now()
+
+	switch c.state {
+	case Open:
+		if now.Sub(c.openedAt) < b.config.Cooldown {
+			return Token{}, Open, false
+		}
+		b.transition(name, c, HalfOpen)
+		c.probing = true
+		return b.token(name, c, true), HalfOpen, true
+
+	case HalfOpen:
+		// only one probe at a time
+		if c.probing {
+			return Token{}, HalfOpen, false
+		}
+		c.probing = true
+		return b.token(name, c, true), HalfOpen, true
+	}
+
+	return b.token(name, c, false), Closed, true
+}
+
+func (b *Breaker) token(name string, c *circuit, probe bool) Token {
+	return Token{breaker: b, name: name, generation: c.generation, probe: probe}
+}
+
+// Done records the outcome of the request. Only the probe's outcome closes
+// or re-opens a half-open circuit, and the outcome of a request allowed
+// before the circuit last changed state is ignored.
+func (t Token) Done(success bool) {
+	if t.breaker == nil {
+		return
+	}
+	b := t.breaker
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := b.get(t.name)
+	if c.generation != t.generation {
+		return
+	}
+	now := b.now()
+
+	switch c.state {
+	case HalfOpen:
+		if !t.probe {
+			return
+		}
+		c.probing = false
+		if success {
+			b.transition(t.name, c, Closed)
+			c.reset(now)
+		} else {
+			b.transition(t.name, c, Open)
+			c.openedAt = now
+		}
+
+	case Closed:
+		if now.Sub(c.windowStart) >= b.config.Window {
+			c.reset(now)
+		}
+
+		c.requests++
+		if !success {
+			c.failures++
+		}
+
+		if c.requests >= b.config.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= b.config.FailureRatio {
+			b.transition(t.name, c, Open)
+			c.openedAt = now
+		}
+	}
+}
+
+// Cancel gives back the slot of a request which has no outcome, such as
+// one whose caller went away, so that a half-open circuit can let another
+// probe through
+func (t Token) Cancel() {
+	if t.breaker == nil || !t.probe {
+		return
+	}
+	b := t.breaker
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := b.get(t.name)
+	if c.generation == t.generation && c.state == HalfOpen {
+		c.probing = false
+	}
+}
+
+// State returns the current state of the circuit for name
+func (b *Breaker) State(name string) State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.get(name).state
+}
+
+func (b *Breaker) get(name string) *circuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{windowStart: b.now()}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+func (b *Breaker) transition(name string, c *circuit, to State) {
+	from := c.state
+	c.state = to
+	c.generation++
+
+	if b.config.OnStateChange != nil && from != to {
+		b.config.OnStateChange(name, from, to)
+	}
+}
+
+func (c *circuit) reset(now time.Time) {
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+}
@@ -0,0 +1,219 @@
+package circuit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type transition struct {
+	from, to State
+}
+
+func newTestBreaker(clock *time.Time) (*Breaker, *[]transition) {
+	transitions := &[]transition{}
+	b := NewBreaker(Config{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Second * 10,
+		Cooldown:     time.Second * 30,
+		OnStateChange: func(name string, from, to State) {
+			*transitions = append(*transitions, transition{from, to})
+		},
+	})
+	b.now = func() time.Time { return *clock }
+	return b, transitions
+}
+
+func request(t *testing.T, b *Breaker, success bool) {
+	t.Helper()
+
+	token, _, ok := b.Allow("figlet")
+	if !ok {
+		t.Fatalf("want the request to be allowed, circuit is %s", b.State("figlet"))
+	}
+	token.Done(success)
+}
+
+func Test_Breaker_Transitions(t *testing.T) {
+	clock := time.Now()
+	b, transitions := newTestBreaker(&clock)
+
+	// closed: failures under MinRequests do not open the circuit
+	request(t, b, false)
+	request(t, b, false)
+	request(t, b, true)
+	if got := b.State("figlet"); got != Closed {
+		t.Fatalf("want %s before MinRequests, got: %s", Closed, got)
+	}
+
+	// closed => open: 3 of 4 failed
+	request(t, b, false)
+	if got := b.State("figlet"); got != Open {
+		t.Fatalf("want %s after 3 of 4 failures, got: %s", Open, got)
+	}
+
+	if _, state, ok := b.Allow("figlet"); ok || state != Open {
+		t.Fatalf("want requests rejected while %s, got: %s, allowed: %v", Open, state, ok)
+	}
+
+	// open => half-open: a single probe after the cooldown
+	clock = clock.Add(time.Second * 30)
+	probe, state, ok := b.Allow("figlet")
+	if !ok || state != HalfOpen {
+		t.Fatalf("want a probe allowed as %s, got: %s, allowed: %v", HalfOpen, state, ok)
+	}
+	if _, _, ok := b.Allow("figlet"); ok {
+		t.Fatal("want only one probe while half-open")
+	}
+
+	// half-open => open: the probe failed
+	probe.Done(false)
+	if got := b.State("figlet"); got != Open {
+		t.Fatalf("want %s after a failed probe, got: %s", Open, got)
+	}
+
+	// open => half-open => closed: the next probe succeeds
+	clock = clock.Add(time.Second * 30)
+	request(t, b, true)
+	if got := b.State("figlet"); got != Closed {
+		t.Fatalf("want %s after a successful probe, got: %s", Closed, got)
+	}
+
+	// the failure counts were reset when the circuit closed
+	request(t, b, false)
+	if got := b.State("figlet"); got != Closed {
+		t.Fatalf("want %s after one failure, got: %s", Closed, got)
+	}
+
+	want := []transition{
+		{Closed, Open},
+		{Open, HalfOpen},
+		{HalfOpen, Open},
+		{Open, HalfOpen},
+		{HalfOpen, Closed},
+	}
+	if len(*transitions) != len(want) {
+		t.Fatalf("want transitions: %v, got: %v", want, *transitions)
+	}
+	for i := range want {
+		if (*transitions)[i] != want[i] {
+			t.Errorf("transition %d: want %s=>%s, got: %s=>%s", i, want[i].from, want[i].to, (*transitions)[i].from, (*transitions)[i].to)
+		}
+	}
+}
+
+func Test_Breaker_WindowResetsCounts(t *testing.T) {
+	clock := time.Now()
+	b, _ := newTestBreaker(&clock)
+
+	request(t, b, false)
+	request(t, b, false)
+	request(t, b, false)
+
+	// the earlier failures fall out of the window
+	clock = clock.Add(time.Second * 10)
+	request(t, b, false)
+
+	if got := b.State("figlet"); got != Closed {
+		t.Errorf("want %s when failures are spread over windows, got: %s", Closed, got)
+	}
+}
+
+func Test_Breaker_FunctionsAreIndependent(t *testing.T) {
+	clock := time.Now()
+	b, _ := newTestBreaker(&clock)
+
+	for i := 0; i < 4; i++ {
+		request(t, b, false)
+	}
+
+	if _, _, ok := b.Allow("nodeinfo"); !ok {
+		t.Error("want another function's circuit to stay closed")
+	}
+}
+
+func Test_Breaker_ConcurrentUse(t *testing.T) {
+	b := NewBreaker(Config{FailureRatio: 0.5, MinRequests: 10, Window: time.Second, Cooldown: time.Millisecond})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if token, _, ok := b.Allow("figlet"); ok {
+					token.Done((i+j)%3 == 0)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_Breaker_OnlyTheProbeDecidesHalfOpen(t *testing.T) {
+	clock := time.Now()
+	b, _ := newTestBreaker(&clock)
+
+	// a slow request allowed while the circuit was closed
+	slow, _, _ := b.Allow("figlet")
+	for i := 0; i < 4; i++ {
+		request(t, b, false)
+	}
+
+	clock = clock.Add(time.Second * 30)
+	probe, state, ok := b.Allow("figlet")
+	if !ok || state != HalfOpen {
+		t.Fatalf("want a probe allowed as %s, got: %s, allowed: %v", HalfOpen, state, ok)
+	}
+
+	slow.Done(true)
+	if got := b.State("figlet"); got != HalfOpen {
+		t.Fatalf("want %s until the probe is done, got: %s", HalfOpen, got)
+	}
+	if _, _, ok := b.Allow("figlet"); ok {
+		t.Fatal("want the probe's slot still held")
+	}
+
+	probe.Done(true)
+	if got := b.State("figlet"); got != Closed {
+		t.Fatalf("want %s after a successful probe, got: %s", Closed, got)
+	}
+
+	// a probe's outcome once the circuit has moved on is ignored
+	probe.Done(false)
+	if got := b.State("figlet"); got != Closed {
+		t.Errorf("want %s after a stale outcome, got: %s", Closed, got)
+	}
+}
+
+func Test_Breaker_CancelledProbeFreesSlot(t *testing.T) {
+	clock := time.Now()
+	b, _ := newTestBreaker(&clock)
+
+	for i := 0; i < 4; i++ {
+		request(t, b, false)
+	}
+
+	clock = clock.Add(time.Second * 30)
+	probe, _, ok := b.Allow("figlet")
+	if !ok {
+		t.Fatal("want a probe allowed")
+	}
+	probe.Cancel()
+
+	if got := b.State("figlet"); got != HalfOpen {
+		t.Fatalf("want %s after a cancelled probe, got: %s", HalfOpen, got)
+	}
+	if _, state, ok := b.Allow("figlet"); !ok || state != HalfOpen {
+		t.Errorf("want another probe allowed, got: %s, allowed: %v", state, ok)
+	}
+}
+
+func Test_State_String(t *testing.T) {
+	for state, want := range map[State]string{Closed: "closed", Open: "open", HalfOpen: "half-open"} {
+		if got := state.String(); got != want {
+			t.Errorf("want: %s, got: %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package compression compresses function responses with gzip or deflate
+// for clients which accept them.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+const (
+	gzipEncoding    = "gzip"
+	deflateEncoding = "deflate"
+)
+
+// DefaultMinBytes is the smallest response which is compressed, below this
+// the framing overhead outweighs the saving.
+const DefaultMinBytes = 1024
+
+// skippedTypes are already compressed, or are streamed and must not be
+// buffered. image/svg+xml is text and is still compressed.
+var skippedTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/zstd",
+	"text/event-stream",
+}
+
+// Middleware compresses responses of at least minBytes with gzip or deflate,
+// whichever the client prefers in its Accept-Encoding header. Responses which
+// already have a Content-Encoding, or have a content type in the skip list
+// such as images and video, are passed through. The compression ratio is
+// recorded on the request's span.
+func Middleware(next http.HandlerFunc, minBytes int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiate(r.Header.Get("Accept-Encoding"))
+		if len(encoding) == 0 || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			r:              r,
+			encoding:       encoding,
+			minBytes:       minBytes,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+
+		next(cw, r)
+	}
+}
+
+// negotiate picks gzip or deflate from an Accept-Encoding header, using the
+// highest q-value and preferring gzip when they are equal. An empty string
+// means neither is accepted.
+func negotiate(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if name == "*" {
+			name = gzipEncoding
+		}
+
+		if name != gzipEncoding && name != deflateEncoding || q <= 0 {
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && name == gzipEncoding) {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+func skipped(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "image/svg+xml") {
+		return false
+	}
+
+	for _, prefix := range skippedTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it has minBytes, or
+// the handler returns, then decides whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	encoding string
+	minBytes int
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         bytes.Buffer
+
+	compressor io.WriteCloser
+	written    countingWriter
+	original   int64
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+
+	// responses without a body, or which cannot be compressed, are not held back
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || !cw.compressible() {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.compressor == nil {
+			return cw.ResponseWriter.Write(b)
+		}
+		cw.original += int64(len(b))
+		return cw.compressor.Write(b)
+	}
+
+	cw.buf.Write(b)
+	if cw.buf.Len() >= cw.minBytes {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been buffered so far, so that streamed responses are
+// not held back
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(cw.buf.Len() >= cw.minBytes)
+	}
+
+	if flusher, ok := cw.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible checks the headers set by the function
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if len(header.Get("Content-Encoding")) > 0 {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+	return !skipped(contentType)
+}
+
+// start writes the status and any buffered bytes, compressing them when
+// compress is set and the headers allow it
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+
+	if compress && cw.compressible() {
+		header := cw.Header()
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		cw.written.w = cw.ResponseWriter
+		if cw.encoding == gzipEncoding {
+			cw.compressor = gzip.NewWriter(&cw.written)
+		} else {
+			cw.compressor = zlib.NewWriter(&cw.written)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	defer cw.buf.Reset()
+	if cw.compressor == nil {
+		_, err := cw.ResponseWriter.Write(cw.buf.Bytes())
+		return err
+	}
+
+	cw.original += int64(cw.buf.Len())
+	_, err := cw.compressor.Write(cw.buf.Bytes())
+	return err
+}
+
+// close sends a response which stayed under minBytes as it is, or finishes
+// the compressed stream
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.wroteHeader && cw.buf.Len() == 0 {
+			return
+		}
+		cw.start(false)
+		return
+	}
+
+	if cw.compressor == nil {
+		return
+	}
+
+	cw.compressor.Close()
+	if cw.original > 0 {
+		tracing.SetCompression(cw.r.Context(), cw.encoding, float64(cw.written.n)/float64(cw.original))
+	}
+}
+
+// countingWriter counts the compressed bytes sent to the client
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
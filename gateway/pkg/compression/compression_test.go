@@ -0,0 +1,175 @@
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+var jsonBody = strings.Repeat(`{"name":"figlet","replicas":1},`, 100)
+
+func respondWith(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	}
+}
+
+func invoke(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	if len(acceptEncoding) > 0 {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_negotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"gzip":                     "gzip",
+		"deflate":                  "deflate",
+		"deflate, gzip":            "gzip",
+		"gzip;q=0.5, deflate":      "deflate",
+		"gzip;q=0, deflate;q=0":    "",
+		"br":                       "",
+		"*":                        "gzip",
+		"identity, GZIP;q=0.8":     "gzip",
+		"gzip;q=invalid, deflate":  "deflate",
+		"br;q=1.0, deflate;q=0.9 ": "deflate",
+	}
+
+	for header, want := range cases {
+		if got := negotiate(header); got != want {
+			t.Errorf("%q: want: %q, got: %q", header, want, got)
+		}
+	}
+}
+
+func Test_Middleware_Gzip(t *testing.T) {
+	rr := invoke(Middleware(respondWith("application/json", jsonBody), DefaultMinBytes), "gzip, deflate")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("want Content-Encoding: gzip, got: %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("want Vary: Accept-Encoding, got: %q", got)
+	}
+
+	reader, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(reader); string(body) != jsonBody {
+		t.Errorf("want the decompressed body to match the function's response")
+	}
+}
+
+func Test_Middleware_Deflate(t *testing.T) {
+	rr := invoke(Middleware(respondWith("text/html", jsonBody), DefaultMinBytes), "deflate")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "deflate" {
+		t.Fatalf("want Content-Encoding: deflate, got: %q", got)
+	}
+
+	reader, err := zlib.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(reader); string(body) != jsonBody {
+		t.Errorf("want the decompressed body to match the function's response")
+	}
+}
+
+func Test_Middleware_ClientWithoutSupport(t *testing.T) {
+	rr := invoke(Middleware(respondWith("application/json", jsonBody), DefaultMinBytes), "")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("want no Content-Encoding, got: %q", got)
+	}
+	if rr.Body.String() != jsonBody {
+		t.Errorf("want the body passed through")
+	}
+	if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("want Vary: Accept-Encoding, got: %q", got)
+	}
+}
+
+func Test_Middleware_BelowThreshold(t *testing.T) {
+	rr := invoke(Middleware(respondWith("application/json", `{"ok":true}`), DefaultMinBytes), "gzip")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("want no Content-Encoding below the threshold, got: %q", got)
+	}
+	if rr.Body.String() != `{"ok":true}` {
+		t.Errorf("want body: {\"ok\":true}, got: %q", rr.Body.String())
+	}
+}
+
+func Test_Middleware_SkipsCompressedTypes(t *testing.T) {
+	for _, contentType := range []string{"image/png", "video/mp4", "application/gzip"} {
+		rr := invoke(Middleware(respondWith(contentType, jsonBody), DefaultMinBytes), "gzip")
+
+		if got := rr.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: want no Content-Encoding, got: %q", contentType, got)
+		}
+		if rr.Body.String() != jsonBody {
+			t.Errorf("%s: want the body passed through", contentType)
+		}
+	}
+
+	rr := invoke(Middleware(respondWith("image/svg+xml", jsonBody), DefaultMinBytes), "gzip")
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("image/svg+xml: want Content-Encoding: gzip, got: %q", got)
+	}
+}
+
+func Test_Middleware_KeepsFunctionEncoding(t *testing.T) {
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, jsonBody)
+	}, DefaultMinBytes)
+
+	rr := invoke(handler, "gzip")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "br" {
+		t.Errorf("want Content-Encoding: br, got: %q", got)
+	}
+	if rr.Body.String() != jsonBody {
+		t.Errorf("want the body passed through")
+	}
+}
+
+func Test_Middleware_RecordsRatio(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	handler := tracing.Middleware(Middleware(respondWith("application/json", jsonBody), DefaultMinBytes))
+	rr := invoke(handler, "gzip")
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	want := float64(rr.Body.Len()) / float64(len(jsonBody))
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == tracing.CompressionRatioKey {
+			if got := kv.Value.AsFloat64(); got != want {
+				t.Errorf("want %s: %f, got: %f", tracing.CompressionRatioKey, want, got)
+			}
+			return
+		}
+	}
+	t.Errorf("want a %s attribute", tracing.CompressionRatioKey)
+}
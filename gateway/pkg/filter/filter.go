@@ -0,0 +1,176 @@
+// Package filter lets custom logic, such as rewriting headers or adding
+// details about the caller, change the requests the gateway sends to
+// functions and the responses they return, without forking the gateway.
+//
+// Filters run on calls to functions only, not on the gateway's calls to the
+// provider. For a WebSocket upgrade, the body of the function's 101 response
+// is the connection, so a Response filter must not replace it.
+//
+// Filters are registered by name, usually from the init func of a package
+// imported by the gateway's main package, in the same way as database/sql
+// drivers:
+//
+//	func init() {
+//		filter.Register("tenant", tenantFilter{})
+//	}
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// Filter changes the requests sent to functions, and their responses
+type Filter interface {
+	// Request is called before the request is sent to the function, an
+	// error stops the request from being sent
+	Request(ctx context.Context, r *http.Request) error
+
+	// Response is called with the function's response before it is
+	// returned to the caller, an error replaces the response
+	Response(ctx context.Context, res *http.Response) error
+}
+
+type namedFilter struct {
+	name   string
+	filter Filter
+}
+
+// Chain runs filters in the order they were registered
+type Chain struct {
+	lock    sync.RWMutex
+	filters []namedFilter
+}
+
+// Register adds filter to the end of the chain
+func (c *Chain) Register(name string, filter Filter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.filters = append(c.filters, namedFilter{name: name, filter: filter})
+}
+
+// Len is the number of filters registered
+func (c *Chain) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.filters)
+}
+
+func (c *Chain) snapshot() []namedFilter {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return append([]namedFilter{}, c.filters...)
+}
+
+// Transport wraps base so that every request goes through the chain's
+// filters. Request filters run in the order they were registered, and
+// Response filters in the reverse order, so the first filter registered
+// sees the request first and the response last.
+//
+// When a filter returns an error the filters after it and the function are
+// not called, and the caller is sent errorStatus with the error's message.
+// Each filter which runs is recorded as a span event on the span in the
+// request's context.
+//
+// Chain Transport outside tracing.Transport, so that a rejected request is
+// not recorded as a call to the function.
+func (c *Chain) Transport(base http.RoundTripper, errorStatus int) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base: base, chain: c, errorStatus: errorStatus}
+}
+
+type transport struct {
+	base        http.RoundTripper
+	chain       *Chain
+	errorStatus int
+}
+
+// The phases of a filter, recorded on its span event
+const (
+	requestPhase  = "request"
+	responsePhase = "response"
+)
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	filters := t.chain.snapshot()
+	if len(filters) == 0 {
+		return t.base.RoundTrip(r)
+	}
+
+	ctx := r.Context()
+
+	// A RoundTripper must not modify the request it was given.
+	r = r.Clone(ctx)
+
+	for _, f := range filters {
+		err := f.filter.Request(ctx, r)
+		tracing.AddFilterEvent(ctx, f.name, requestPhase, err)
+		if err != nil {
+			return t.errorResponse(r, f.name, err), nil
+		}
+	}
+
+	res, err := t.base.RoundTrip(r)
+	if err != nil {
+		return res, err
+	}
+
+	for i := len(filters) - 1; i >= 0; i-- {
+		f := filters[i]
+		err := f.filter.Response(ctx, res)
+		tracing.AddFilterEvent(ctx, f.name, responsePhase, err)
+		if err != nil {
+			res.Body.Close()
+			return t.errorResponse(r, f.name, err), nil
+		}
+	}
+
+	return res, nil
+}
+
+// errorResponse is sent to the caller in place of the function's response
+func (t *transport) errorResponse(r *http.Request, name string, err error) *http.Response {
+	body := fmt.Sprintf("filter %s: %s", name, err)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", t.errorStatus, http.StatusText(t.errorStatus)),
+		StatusCode:    t.errorStatus,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// registered holds the filters added with Register
+var registered = &Chain{}
+
+// Register adds filter to the gateway's filters, which run in the order
+// they were registered
+func Register(name string, filter Filter) {
+	registered.Register(name, filter)
+}
+
+// Transport wraps base with the filters added with Register, when there
+// are none base is returned
+func Transport(base http.RoundTripper, errorStatus int) http.RoundTripper {
+	if registered.Len() == 0 {
+		return base
+	}
+	return registered.Transport(base, errorStatus)
+}
@@ -0,0 +1,197 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+)
+
+// recordingFilter appends its name to calls for each phase it runs, and
+// returns the errors it was given
+type recordingFilter struct {
+	name        string
+	calls       *[]string
+	requestErr  error
+	responseErr error
+}
+
+func (f recordingFilter) Request(ctx context.Context, r *http.Request) error {
+	*f.calls = append(*f.calls, f.name+".request")
+	r.Header.Add("X-Filters", f.name)
+	return f.requestErr
+}
+
+func (f recordingFilter) Response(ctx context.Context, res *http.Response) error {
+	*f.calls = append(*f.calls, f.name+".response")
+	res.Header.Add("X-Filters", f.name)
+	return f.responseErr
+}
+
+// function records the X-Filters header of the requests it receives
+func function(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	received := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = append(*received, r.Header.Values("X-Filters")...)
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, received
+}
+
+func Test_Chain_RunsFiltersInOrder(t *testing.T) {
+	server, received := function(t)
+
+	calls := []string{}
+	chain := &Chain{}
+	chain.Register("auth", recordingFilter{name: "auth", calls: &calls})
+	chain.Register("tenant", recordingFilter{name: "tenant", calls: &calls})
+
+	client := &http.Client{Transport: chain.Transport(http.DefaultTransport, http.StatusInternalServerError)}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	want := []string{"auth.request", "tenant.request", "tenant.response", "auth.response"}
+	if len(calls) != len(want) {
+		t.Fatalf("want calls: %v, got: %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("want calls: %v, got: %v", want, calls)
+			break
+		}
+	}
+
+	if got := *received; len(got) != 2 || got[0] != "auth" || got[1] != "tenant" {
+		t.Errorf("want the function to receive the headers set by each filter, got: %v", got)
+	}
+	if got := res.Header.Values("X-Filters"); len(got) != 2 || got[0] != "tenant" || got[1] != "auth" {
+		t.Errorf("want the response headers set by each filter, got: %v", got)
+	}
+}
+
+func Test_Chain_RequestErrorShortCircuits(t *testing.T) {
+	server, received := function(t)
+
+	calls := []string{}
+	chain := &Chain{}
+	chain.Register("auth", recordingFilter{name: "auth", calls: &calls, requestErr: errors.New("no tenant for caller")})
+	chain.Register("tenant", recordingFilter{name: "tenant", calls: &calls})
+
+	client := &http.Client{Transport: chain.Transport(http.DefaultTransport, http.StatusForbidden)}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("want status: %d, got: %d", http.StatusForbidden, res.StatusCode)
+	}
+	if want := "filter auth: no tenant for caller"; string(body) != want {
+		t.Errorf("want body: %q, got: %q", want, string(body))
+	}
+	if len(calls) != 1 || calls[0] != "auth.request" {
+		t.Errorf("want only the failing filter called, got: %v", calls)
+	}
+	if len(*received) > 0 {
+		t.Errorf("want the function not to be called, got: %v", *received)
+	}
+}
+
+func Test_Chain_ResponseErrorReplacesResponse(t *testing.T) {
+	server, _ := function(t)
+
+	calls := []string{}
+	chain := &Chain{}
+	chain.Register("auth", recordingFilter{name: "auth", calls: &calls})
+	chain.Register("redact", recordingFilter{name: "redact", calls: &calls, responseErr: errors.New("unable to redact")})
+
+	client := &http.Client{Transport: chain.Transport(http.DefaultTransport, http.StatusBadGateway)}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("want status: %d, got: %d", http.StatusBadGateway, res.StatusCode)
+	}
+	if string(body) == "hello" {
+		t.Errorf("want the function's response replaced")
+	}
+
+	// auth's Response is not called once redact has failed
+	want := []string{"auth.request", "redact.request", "redact.response"}
+	if len(calls) != len(want) || calls[2] != want[2] {
+		t.Errorf("want calls: %v, got: %v", want, calls)
+	}
+}
+
+func Test_Chain_RecordsSpanEvents(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	server, _ := function(t)
+
+	calls := []string{}
+	chain := &Chain{}
+	chain.Register("auth", recordingFilter{name: "auth", calls: &calls})
+	chain.Register("tenant", recordingFilter{name: "tenant", calls: &calls, requestErr: errors.New("unknown tenant")})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "gateway")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	res, err := chain.Transport(http.DefaultTransport, http.StatusForbidden).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	span.End()
+
+	spans := recorder.Named("gateway")
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	events := spans[0].Events()
+	if len(events) != 2 {
+		t.Fatalf("want an event for each filter which ran, got: %d", len(events))
+	}
+
+	for i, want := range []struct{ name, err string }{{"auth", ""}, {"tenant", "unknown tenant"}} {
+		got := map[string]string{}
+		for _, kv := range events[i].Attributes {
+			got[string(kv.Key)] = kv.Value.AsString()
+		}
+
+		if events[i].Name != tracing.FilterEvent || got[string(tracing.FilterNameKey)] != want.name {
+			t.Errorf("want a %s event for %s, got: %s %v", tracing.FilterEvent, want.name, events[i].Name, got)
+		}
+		if got[string(tracing.FilterPhaseKey)] != "request" {
+			t.Errorf("want the request phase, got: %v", got)
+		}
+		if got[string(tracing.FilterErrorKey)] != want.err {
+			t.Errorf("want %s: %q, got: %q", tracing.FilterErrorKey, want.err, got[string(tracing.FilterErrorKey)])
+		}
+	}
+}
+
+func Test_Transport_WithoutFilters(t *testing.T) {
+	if got := Transport(http.DefaultTransport, http.StatusInternalServerError); got != http.DefaultTransport {
+		t.Errorf("want base returned when no filters are registered")
+	}
+}
@@ -0,0 +1,96 @@
+// Package flags reads the gateway's command-line flags into the same
+// GatewayConfig as its environment variables, for local runs where setting
+// the environment is awkward.
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/openfaas/faas/gateway/types"
+)
+
+// Flags are the gateway's command-line flags. Each one which is given
+// overrides the environment variable for the same setting, those which are
+// not leave it as it is.
+type Flags struct {
+	ListenAddress    string
+	ExecTimeout      time.Duration
+	MaxBodyBytes     int64
+	TracesExporter   string
+	DefaultNamespace string
+
+	fs *flag.FlagSet
+}
+
+// Register adds the gateway's flags to fs
+func Register(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+
+	fs.StringVar(&f.ListenAddress, "listen-address", types.DefaultListenAddress, "host and port to serve on, overrides FAAS_LISTEN_ADDRESS")
+	fs.DurationVar(&f.ExecTimeout, "exec-timeout", 0, "longest a function may take to respond, 0 for no limit, overrides FAAS_EXEC_TIMEOUT")
+	fs.Int64Var(&f.MaxBodyBytes, "max-body-bytes", 0, "largest request body sent to a function, 0 for no limit, overrides FAAS_MAX_BODY_BYTES")
+	fs.StringVar(&f.TracesExporter, "traces-exporter", "", "comma-separated span exporters: otlp, console or none, overrides OTEL_TRACES_EXPORTER")
+	fs.StringVar(&f.DefaultNamespace, "default-namespace", "", "namespace for functions named without one, overrides FAAS_DEFAULT_NAMESPACE")
+
+	return f
+}
+
+// Apply overrides cfg with the flags which were given when fs was parsed
+func (f *Flags) Apply(cfg *types.GatewayConfig) error {
+	var err error
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch fl.Name {
+		case "listen-address":
+			if len(f.ListenAddress) == 0 {
+				err = fmt.Errorf("invalid value for -listen-address: %q", f.ListenAddress)
+				return
+			}
+			cfg.ListenAddress = f.ListenAddress
+		case "exec-timeout":
+			if f.ExecTimeout < 0 {
+				err = fmt.Errorf("invalid value for -exec-timeout: %s", f.ExecTimeout)
+				return
+			}
+			cfg.ExecTimeout = f.ExecTimeout
+		case "max-body-bytes":
+			if f.MaxBodyBytes < 0 {
+				err = fmt.Errorf("invalid value for -max-body-bytes: %d", f.MaxBodyBytes)
+				return
+			}
+			cfg.MaxBodyBytes = f.MaxBodyBytes
+		case "traces-exporter":
+			cfg.TracesExporter = f.TracesExporter
+		case "default-namespace":
+			err = cfg.SetDefaultNamespace(f.DefaultNamespace)
+		}
+	})
+
+	return err
+}
+
+// Read parses args with the gateway's flags registered on fs, reads the
+// environment, then applies the flags which were given over it.
+func Read(fs *flag.FlagSet, args []string, env types.HasEnv) (*types.GatewayConfig, error) {
+	f := Register(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg, err := types.ReadConfig{}.Read(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Apply(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
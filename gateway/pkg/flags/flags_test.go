@@ -0,0 +1,144 @@
+package flags
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/types"
+)
+
+type env map[string]string
+
+func (e env) Getenv(key string) string {
+	return e[key]
+}
+
+func read(t *testing.T, args []string, e env) (*types.GatewayConfig, error) {
+	t.Helper()
+
+	fs := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Read(fs, args, e)
+}
+
+func Test_Read_Defaults(t *testing.T) {
+	cfg, err := read(t, nil, env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddress != ":8080" {
+		t.Errorf("want listen address: :8080, got: %s", cfg.ListenAddress)
+	}
+	if cfg.ExecTimeout != 0 {
+		t.Errorf("want no exec timeout, got: %s", cfg.ExecTimeout)
+	}
+	if cfg.MaxBodyBytes != 0 {
+		t.Errorf("want no body limit, got: %d", cfg.MaxBodyBytes)
+	}
+	if cfg.TracesExporter != "" {
+		t.Errorf("want OTEL_TRACES_EXPORTER to be left to the tracing package, got: %s", cfg.TracesExporter)
+	}
+	if cfg.Namespace != "" {
+		t.Errorf("want the provider's default namespace, got: %s", cfg.Namespace)
+	}
+}
+
+func Test_Read_EnvWithoutFlags(t *testing.T) {
+	cfg, err := read(t, nil, env{
+		"FAAS_LISTEN_ADDRESS":    "127.0.0.1:9000",
+		"FAAS_EXEC_TIMEOUT":      "30s",
+		"FAAS_MAX_BODY_BYTES":    "1024",
+		"FAAS_DEFAULT_NAMESPACE": "tenant-a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddress != "127.0.0.1:9000" {
+		t.Errorf("want listen address: 127.0.0.1:9000, got: %s", cfg.ListenAddress)
+	}
+	if cfg.ExecTimeout != time.Second*30 {
+		t.Errorf("want exec timeout: 30s, got: %s", cfg.ExecTimeout)
+	}
+	if cfg.MaxBodyBytes != 1024 {
+		t.Errorf("want body limit: 1024, got: %d", cfg.MaxBodyBytes)
+	}
+	if cfg.Namespace != "tenant-a" {
+		t.Errorf("want namespace: tenant-a, got: %s", cfg.Namespace)
+	}
+}
+
+func Test_Read_FlagsOverrideEnv(t *testing.T) {
+	cfg, err := read(t, []string{
+		"-listen-address=:3000",
+		"-exec-timeout=5s",
+		"-max-body-bytes=0",
+		"-traces-exporter=none",
+		"-default-namespace=tenant-b",
+	}, env{
+		"FAAS_LISTEN_ADDRESS":    "127.0.0.1:9000",
+		"FAAS_EXEC_TIMEOUT":      "30s",
+		"FAAS_MAX_BODY_BYTES":    "1024",
+		"FAAS_DEFAULT_NAMESPACE": "tenant-a",
+		"OTEL_TRACES_EXPORTER":   "otlp",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddress != ":3000" {
+		t.Errorf("want listen address: :3000, got: %s", cfg.ListenAddress)
+	}
+	if cfg.ExecTimeout != time.Second*5 {
+		t.Errorf("want exec timeout: 5s, got: %s", cfg.ExecTimeout)
+	}
+	if cfg.MaxBodyBytes != 0 {
+		t.Errorf("want an explicit 0 to remove the body limit, got: %d", cfg.MaxBodyBytes)
+	}
+	if cfg.TracesExporter != "none" {
+		t.Errorf("want traces exporter: none, got: %s", cfg.TracesExporter)
+	}
+	if cfg.Namespace != "tenant-b" {
+		t.Errorf("want namespace: tenant-b, got: %s", cfg.Namespace)
+	}
+}
+
+func Test_Read_FlagsOnlyOverrideWhatIsGiven(t *testing.T) {
+	cfg, err := read(t, []string{"-exec-timeout=5s"}, env{
+		"FAAS_LISTEN_ADDRESS": "127.0.0.1:9000",
+		"FAAS_MAX_BODY_BYTES": "1024",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddress != "127.0.0.1:9000" {
+		t.Errorf("want listen address from the env: 127.0.0.1:9000, got: %s", cfg.ListenAddress)
+	}
+	if cfg.MaxBodyBytes != 1024 {
+		t.Errorf("want body limit from the env: 1024, got: %d", cfg.MaxBodyBytes)
+	}
+	if cfg.ExecTimeout != time.Second*5 {
+		t.Errorf("want exec timeout: 5s, got: %s", cfg.ExecTimeout)
+	}
+}
+
+func Test_Read_InvalidFlags(t *testing.T) {
+	cases := [][]string{
+		{"-max-body-bytes=-1"},
+		{"-exec-timeout=-1s"},
+		{"-exec-timeout=soon"},
+		{"-listen-address="},
+		{"-default-namespace=Tenant_B"},
+		{"-unknown"},
+	}
+
+	for _, args := range cases {
+		if _, err := read(t, args, env{}); err == nil {
+			t.Errorf("want an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package health serves the gateway's liveness and readiness endpoints.
+// Liveness only shows that the process can serve HTTP, readiness runs the
+// registered Checkers, such as whether the functions provider can be reached.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker verifies that a dependency of the gateway is available
+type Checker interface {
+	// Name identifies the check in the readiness response
+	Name() string
+
+	// Check returns an error when the dependency is unavailable
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string { return c.name }
+
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// NewChecker creates a Checker from a func
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, check: check}
+}
+
+// Response is the JSON body written by the readiness handler
+type Response struct {
+	Status string            `json:"status"`
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Registry holds the Checkers run for readiness, it is safe to register
+// checks while the handlers are serving.
+type Registry struct {
+	lock     sync.RWMutex
+	checkers []Checker
+	timeout  time.Duration
+}
+
+// NewRegistry creates a Registry, each check is given up to timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds checkers to the readiness checks
+func (reg *Registry) Register(checkers ...Checker) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	reg.checkers = append(reg.checkers, checkers...)
+}
+
+// LivenessHandler always returns 200, it does not run any checks so that a
+// failing dependency does not cause the gateway to be restarted.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// ReadinessHandler runs every check concurrently, returning 200 when they
+// all pass, or 503 with the failed checks and their errors.
+func (reg *Registry) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	failed := reg.run(r.Context())
+
+	res := Response{Status: "ok"}
+	status := http.StatusOK
+	if len(failed) > 0 {
+		res = Response{Status: "unavailable", Failed: failed}
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// run returns the name and error of each failing check, a check which has
+// not returned within the timeout is reported as failed
+func (reg *Registry) run(ctx context.Context) map[string]string {
+	reg.lock.RLock()
+	checkers := append([]Checker{}, reg.checkers...)
+	reg.lock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+
+	var lock sync.Mutex
+	failed := map[string]string{}
+
+	wg := sync.WaitGroup{}
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+
+			done := make(chan error, 1)
+			go func() { done <- runCheck(ctx, checker) }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			if err != nil {
+				lock.Lock()
+				failed[checker.Name()] = err.Error()
+				lock.Unlock()
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	return failed
+}
+
+// runCheck treats a panic in a check as a failure
+func runCheck(ctx context.Context, checker Checker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+
+	return checker.Check(ctx)
+}
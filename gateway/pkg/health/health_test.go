@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readiness(t *testing.T, reg *Registry) (int, Response) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	reg.ReadinessHandler(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("want Content-Type: application/json, got: %s", got)
+	}
+
+	var res Response
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("want a JSON body, got error: %s", err)
+	}
+	return rr.Code, res
+}
+
+func passing(name string) Checker {
+	return NewChecker(name, func(ctx context.Context) error { return nil })
+}
+
+func Test_Readiness_AllChecksPass(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Register(passing("tracing"), passing("provider"))
+
+	code, res := readiness(t, reg)
+
+	if code != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, code)
+	}
+	if res.Status != "ok" || len(res.Failed) != 0 {
+		t.Errorf("want status ok with no failed checks, got: %+v", res)
+	}
+}
+
+func Test_Readiness_OneCheckFails(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Register(passing("tracing"), NewChecker("provider", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+
+	code, res := readiness(t, reg)
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("want status: %d, got: %d", http.StatusServiceUnavailable, code)
+	}
+	if len(res.Failed) != 1 || res.Failed["provider"] != "connection refused" {
+		t.Errorf("want only the provider check to fail, got: %+v", res.Failed)
+	}
+}
+
+func Test_Readiness_PanickingCheckFails(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Register(passing("tracing"), NewChecker("provider", func(ctx context.Context) error {
+		panic("nil resolver")
+	}))
+
+	code, res := readiness(t, reg)
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("want status: %d, got: %d", http.StatusServiceUnavailable, code)
+	}
+	if !strings.Contains(res.Failed["provider"], "nil resolver") {
+		t.Errorf("want the panic reported for the provider check, got: %+v", res.Failed)
+	}
+}
+
+func Test_Readiness_SlowCheckTimesOut(t *testing.T) {
+	reg := NewRegistry(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+
+	reg.Register(NewChecker("provider", func(ctx context.Context) error {
+		<-block
+		return nil
+	}))
+
+	code, res := readiness(t, reg)
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("want status: %d, got: %d", http.StatusServiceUnavailable, code)
+	}
+	if res.Failed["provider"] != context.DeadlineExceeded.Error() {
+		t.Errorf("want the provider check to time out, got: %+v", res.Failed)
+	}
+}
+
+func Test_Liveness_AlwaysOK(t *testing.T) {
+	rr := httptest.NewRecorder()
+	LivenessHandler(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, rr.Code)
+	}
+}
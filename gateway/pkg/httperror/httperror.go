@@ -0,0 +1,86 @@
+// Package httperror writes the gateway's error responses in one JSON shape,
+// so that clients can handle them in the same way whichever handler failed:
+//
+//	{"error": {"code": "not_found", "message": "...", "trace_id": "..."}}
+//
+// The trace ID is that of the span in the request's context, so that the
+// failed request can be found in the tracing backend.
+package httperror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CodeHeader is set to the Code of each error response, so that a proxy in
+// front of the gateway can tell the gateway's errors from a function's
+// responses with the same status
+const CodeHeader = "X-OpenFaaS-Error"
+
+// Response is the body of an error response
+type Response struct {
+	Error Detail `json:"error"`
+}
+
+// Detail describes the error
+type Detail struct {
+	// Code is a stable, machine-readable name for the error, by default
+	// the status text in snake case, i.e. "service_unavailable"
+	Code string `json:"code"`
+
+	// Message is for people, and can change between releases
+	Message string `json:"message"`
+
+	// TraceID is set when the request was traced
+	TraceID string `json:"trace_id,omitempty"`
+
+	// Details is anything else the client needs, such as which fields of
+	// a request were invalid
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Write responds with status, the Code for status, and message
+func Write(w http.ResponseWriter, r *http.Request, status int, message string) {
+	WriteCode(w, r, status, Code(status), message)
+}
+
+// WriteCode responds with status, code and message, for errors which need
+// telling apart from others with the same status
+func WriteCode(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteDetail(w, r, status, Detail{Code: code, Message: message})
+}
+
+// WriteDetail responds with status and detail, the TraceID is set from r
+func WriteDetail(w http.ResponseWriter, r *http.Request, status int, detail Detail) {
+	if len(detail.Code) == 0 {
+		detail.Code = Code(status)
+	}
+	if r != nil {
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			detail.TraceID = sc.TraceID().String()
+		}
+	}
+
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set(CodeHeader, detail.Code)
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(Response{Error: detail})
+}
+
+// Code is the default code for status, i.e. "not_found" for a 404
+func Code(status int) string {
+	text := http.StatusText(status)
+	if len(text) == 0 {
+		return "error"
+	}
+
+	text = strings.ToLower(strings.ReplaceAll(text, "'", ""))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(text)
+}
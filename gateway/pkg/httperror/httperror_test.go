@@ -0,0 +1,114 @@
+package httperror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+)
+
+func decode(t *testing.T, rr *httptest.ResponseRecorder) map[string]map[string]interface{} {
+	t.Helper()
+
+	body := map[string]map[string]interface{}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("want a JSON body, got: %q, %s", rr.Body.String(), err)
+	}
+	return body
+}
+
+func Test_Write_Shape(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Length", "5")
+	req := httptest.NewRequest(http.MethodGet, "/system/functions", nil)
+
+	Write(rr, req, http.StatusNotFound, "unable to find function: figlet")
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("want status: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+	if v := rr.Header().Get("Content-Type"); v != "application/json" {
+		t.Errorf("want Content-Type: application/json, got: %q", v)
+	}
+	if v := rr.Header().Get(CodeHeader); v != "not_found" {
+		t.Errorf("want %s: not_found, got: %q", CodeHeader, v)
+	}
+	if v := rr.Header().Get("Content-Length"); v != "" {
+		t.Errorf("want Content-Length removed, got: %q", v)
+	}
+
+	body := decode(t, rr)
+	if len(body) != 1 {
+		t.Errorf("want only an error member, got: %v", body)
+	}
+
+	got := body["error"]
+	if got["code"] != "not_found" {
+		t.Errorf("want code: not_found, got: %v", got["code"])
+	}
+	if got["message"] != "unable to find function: figlet" {
+		t.Errorf("want the message, got: %v", got["message"])
+	}
+	if _, ok := got["trace_id"]; ok {
+		t.Errorf("want no trace_id without a span, got: %v", got["trace_id"])
+	}
+}
+
+func Test_WriteCode_TraceID(t *testing.T) {
+	_, teardown := tracetest.Install()
+	defer teardown()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "gateway")
+	defer span.End()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil).WithContext(ctx)
+
+	WriteCode(rr, req, http.StatusServiceUnavailable, "circuit_open", "function figlet.openfaas-fn is unavailable")
+
+	got := decode(t, rr)["error"]
+	if got["code"] != "circuit_open" {
+		t.Errorf("want code: circuit_open, got: %v", got["code"])
+	}
+	if want := span.SpanContext().TraceID().String(); got["trace_id"] != want {
+		t.Errorf("want trace_id: %s, got: %v", want, got["trace_id"])
+	}
+}
+
+func Test_WriteDetail_Details(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	WriteDetail(rr, nil, http.StatusBadRequest, Detail{
+		Message: "invalid function spec",
+		Details: []string{"service"},
+	})
+
+	got := decode(t, rr)["error"]
+	if got["code"] != "bad_request" {
+		t.Errorf("want the default code: bad_request, got: %v", got["code"])
+	}
+	if details, ok := got["details"].([]interface{}); !ok || len(details) != 1 || details[0] != "service" {
+		t.Errorf("want the details, got: %v", got["details"])
+	}
+}
+
+func Test_Code(t *testing.T) {
+	cases := map[int]string{
+		http.StatusBadRequest:            "bad_request",
+		http.StatusTooManyRequests:       "too_many_requests",
+		http.StatusRequestEntityTooLarge: "request_entity_too_large",
+		http.StatusTeapot:                "im_a_teapot",
+		http.StatusNonAuthoritativeInfo:  "non_authoritative_information",
+		599:                              "error",
+	}
+
+	for status, want := range cases {
+		if got := Code(status); got != want {
+			t.Errorf("%d: want code: %q, got: %q", status, want, got)
+		}
+	}
+}
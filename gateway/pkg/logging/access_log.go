@@ -0,0 +1,98 @@
+// Package logging writes an access log line for each request, with the trace
+// and span IDs of the request's span so that logs can be joined to traces.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Format is the encoding of each access log line
+type Format string
+
+const (
+	// TextFormat writes key=value pairs
+	TextFormat Format = "text"
+
+	// JSONFormat writes one JSON object per line
+	JSONFormat Format = "json"
+)
+
+type config struct {
+	format Format
+	output io.Writer
+}
+
+// Option configures the access log
+type Option func(*config)
+
+// WithFormat sets the encoding of the access log, the default is TextFormat.
+func WithFormat(format Format) Option {
+	return func(c *config) {
+		c.format = format
+	}
+}
+
+// WithOutput sets where the access log is written, the default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// Middleware logs the method, path, status, duration and size of each
+// response. Chain it inside tracing.Middleware, so that the request's span
+// has been started and its trace_id and span_id can be logged, and inside
+// tracing.RequestID to log the request_id.
+func Middleware(next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	cfg := &config{
+		format: TextFormat,
+		output: os.Stdout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var handler slog.Handler
+	if cfg.format == JSONFormat {
+		handler = slog.NewJSONHandler(cfg.output, nil)
+	} else {
+		handler = slog.NewTextHandler(cfg.output, nil)
+	}
+	logger := slog.New(handler)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		next(ww, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", ww.Status()),
+			slog.Float64("duration_seconds", time.Since(start).Seconds()),
+			slog.Int64("bytes", ww.BytesWritten()),
+		}
+
+		if id := tracing.RequestIDFromContext(r.Context()); len(id) > 0 {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			attrs = append(attrs,
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "access", attrs...)
+	}
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+func Test_Middleware_JSON_IncludesTraceID(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	var out bytes.Buffer
+	handler := tracing.Middleware(Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}, WithFormat(JSONFormat), WithOutput(&out)))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/function/figlet", nil))
+
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("want a JSON line, got: %q, error: %s", out.String(), err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	sc := spans[0].SpanContext()
+
+	want := map[string]interface{}{
+		"method":   http.MethodPost,
+		"path":     "/function/figlet",
+		"status":   float64(http.StatusCreated),
+		"bytes":    float64(5),
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+	for key, val := range want {
+		if line[key] != val {
+			t.Errorf("want %s: %v, got: %v", key, val, line[key])
+		}
+	}
+
+	if _, ok := line["duration_seconds"].(float64); !ok {
+		t.Errorf("want duration_seconds, got: %v", line["duration_seconds"])
+	}
+}
+
+func Test_Middleware_Text_WithoutTracing(t *testing.T) {
+	var out bytes.Buffer
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, WithOutput(&out))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/missing", nil))
+
+	line := out.String()
+	for _, want := range []string{"method=GET", "path=/function/missing", "status=404", "bytes=0"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("want %q in the access log, got: %q", want, line)
+		}
+	}
+
+	if strings.Contains(line, "trace_id") {
+		t.Errorf("want no trace_id when there is no span, got: %q", line)
+	}
+}
+
+func Test_Middleware_IncludesRequestID(t *testing.T) {
+	var out bytes.Buffer
+	handler := tracing.RequestID(Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithFormat(JSONFormat), WithOutput(&out)))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set(tracing.RequestIDHeader, "req-1234")
+	handler(httptest.NewRecorder(), req)
+
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("want a JSON line, got: %q, error: %s", out.String(), err)
+	}
+	if line["request_id"] != "req-1234" {
+		t.Errorf("want request_id: req-1234, got: %v", line["request_id"])
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NormalizeBasePath returns basePath with a leading "/" and without a
+// trailing one, or "" for the root.
+func NormalizeBasePath(basePath string) (string, error) {
+	basePath = strings.TrimSpace(basePath)
+	if strings.ContainsAny(basePath, "?#") {
+		return "", fmt.Errorf("invalid base path: %q, want a path such as /openfaas", basePath)
+	}
+
+	basePath = strings.TrimRight(basePath, "/")
+	if len(basePath) > 0 && !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	return basePath, nil
+}
+
+// BasePath serves next under basePath, as though it were the root, for a
+// gateway mounted on a sub-path. The base path is removed from the request
+// before next, so that routes, function names and the span name are parsed
+// without it. Requests outside of basePath respond with 404 Not Found. An
+// empty basePath returns next.
+func BasePath(next http.Handler, basePath string) http.Handler {
+	if len(basePath) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := trimBasePath(r.URL.Path, basePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		rawPath := ""
+		if len(r.URL.RawPath) > 0 {
+			if rawPath, ok = trimBasePath(r.URL.RawPath, basePath); !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = rawPath
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// trimBasePath matches whole path segments, so "/openfaas" is removed from
+// "/openfaas/function/figlet" but does not match "/openfaas-dev".
+func trimBasePath(path, basePath string) (string, bool) {
+	if path == basePath {
+		return "/", true
+	}
+
+	if rest, ok := strings.CutPrefix(path, basePath); ok && strings.HasPrefix(rest, "/") {
+		return rest, true
+	}
+	return "", false
+}
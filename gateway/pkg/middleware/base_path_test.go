@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_BasePath(t *testing.T) {
+	cases := []struct {
+		basePath   string
+		path       string
+		wantStatus int
+		wantPath   string
+	}{
+		{basePath: "", path: "/function/figlet", wantStatus: http.StatusOK, wantPath: "/function/figlet"},
+		{basePath: "/openfaas", path: "/openfaas/function/figlet", wantStatus: http.StatusOK, wantPath: "/function/figlet"},
+		{basePath: "/openfaas", path: "/openfaas/system/functions", wantStatus: http.StatusOK, wantPath: "/system/functions"},
+		{basePath: "/openfaas", path: "/openfaas", wantStatus: http.StatusOK, wantPath: "/"},
+		{basePath: "/openfaas", path: "/function/figlet", wantStatus: http.StatusNotFound},
+		{basePath: "/openfaas", path: "/openfaas-dev/function/figlet", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.basePath+tc.path, func(t *testing.T) {
+			gotPath := ""
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+			})
+
+			rec := httptest.NewRecorder()
+			BasePath(next, tc.basePath).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("want status: %d, got: %d", tc.wantStatus, rec.Code)
+			}
+			if gotPath != tc.wantPath {
+				t.Errorf("want path: %q, got: %q", tc.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func Test_BasePath_TrimsRawPath(t *testing.T) {
+	var got *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/openfaas/function/figlet/a%2Fb", nil)
+	BasePath(next, "/openfaas").ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.URL.RawPath != "/function/figlet/a%2Fb" {
+		t.Errorf("want raw path without the base path, got: %q", got.URL.RawPath)
+	}
+	if req.URL.Path != "/openfaas/function/figlet/a/b" {
+		t.Errorf("want the caller's request left alone, got: %q", req.URL.Path)
+	}
+}
+
+func Test_NormalizeBasePath(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"/":          "",
+		"/openfaas":  "/openfaas",
+		"/openfaas/": "/openfaas",
+		"openfaas":   "/openfaas",
+		"/a/b/":      "/a/b",
+	}
+
+	for value, want := range cases {
+		got, err := NormalizeBasePath(value)
+		if err != nil {
+			t.Errorf("%q: %s", value, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: want: %q, got: %q", value, want, got)
+		}
+	}
+
+	if _, err := NormalizeBasePath("/openfaas?x=1"); err == nil {
+		t.Errorf("want an error for a base path with a query")
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+)
+
+// FunctionHeader invokes the function named by header, i.e. X-Function, for
+// gateways which route on a header rather than the URL path. The request's
+// path becomes the function's sub-path by rewriting it to
+// /function/<name><path>, so that routing, the resolver and the span see the
+// same request as one made by path, and the header is removed so that the
+// function does not receive it. Requests without the header, and those for
+// the gateway's own health checks and /system API, are passed on as they
+// are. An invalid function name responds with 400 Bad Request. An empty
+// header returns next.
+func FunctionHeader(next http.Handler, header string) http.Handler {
+	if len(header) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		functionName := r.Header.Get(header)
+		if len(functionName) == 0 || gatewayPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := ValidateFunctionName(functionName); err != nil {
+			httperror.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		r2 := r.Clone(context.WithValue(r.Context(), functionHeaderKey{}, true))
+		r2.URL.Path = "/function/" + functionName + r.URL.Path
+		if len(r.URL.RawPath) > 0 {
+			r2.URL.RawPath = "/function/" + functionName + r.URL.RawPath
+		}
+		r2.RequestURI = r2.URL.RequestURI()
+		r2.Header.Del(header)
+		next.ServeHTTP(w, r2)
+	})
+}
+
+type functionHeaderKey struct{}
+
+// SelectedByHeader reports whether the function called was named by the
+// FunctionHeader, so that the path the client called does not include it
+func SelectedByHeader(ctx context.Context) bool {
+	selected, _ := ctx.Value(functionHeaderKey{}).(bool)
+	return selected
+}
+
+// gatewayPath is true for the paths the gateway serves itself, which a
+// function named by header does not replace
+func gatewayPath(path string) bool {
+	return path == "/healthz" || path == "/readyz" ||
+		path == "/system" || strings.HasPrefix(path, "/system/")
+}
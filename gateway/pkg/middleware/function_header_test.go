@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_FunctionHeader(t *testing.T) {
+	cases := []struct {
+		name       string
+		header     string
+		path       string
+		wantStatus int
+		wantPath   string
+	}{
+		{name: "header present", header: "figlet", path: "/employees/100", wantStatus: http.StatusOK, wantPath: "/function/figlet/employees/100"},
+		{name: "header present at root", header: "figlet", path: "/", wantStatus: http.StatusOK, wantPath: "/function/figlet/"},
+		{name: "header with namespace", header: "figlet.openfaas-fn", path: "/", wantStatus: http.StatusOK, wantPath: "/function/figlet.openfaas-fn/"},
+		{name: "header absent", path: "/function/figlet/employees", wantStatus: http.StatusOK, wantPath: "/function/figlet/employees"},
+		{name: "header absent on admin path", path: "/system/functions", wantStatus: http.StatusOK, wantPath: "/system/functions"},
+		{name: "header on admin path", header: "figlet", path: "/system/functions", wantStatus: http.StatusOK, wantPath: "/system/functions"},
+		{name: "header on liveness", header: "figlet", path: "/healthz", wantStatus: http.StatusOK, wantPath: "/healthz"},
+		{name: "header on readiness", header: "figlet", path: "/readyz", wantStatus: http.StatusOK, wantPath: "/readyz"},
+		{name: "header on path like admin", header: "figlet", path: "/systems", wantStatus: http.StatusOK, wantPath: "/function/figlet/systems"},
+		{name: "invalid name", header: "../system", path: "/functions", wantStatus: http.StatusBadRequest},
+		{name: "uppercase name", header: "Figlet", path: "/", wantStatus: http.StatusBadRequest},
+		{name: "invalid namespace", header: "figlet.kube_system", path: "/", wantStatus: http.StatusBadRequest},
+		{name: "empty namespace", header: "figlet.", path: "/", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotPath := ""
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if len(tc.header) > 0 {
+				req.Header.Set("X-Function", tc.header)
+			}
+
+			rec := httptest.NewRecorder()
+			FunctionHeader(next, "X-Function").ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("want status: %d, got: %d", tc.wantStatus, rec.Code)
+			}
+			if gotPath != tc.wantPath {
+				t.Errorf("want path: %q, got: %q", tc.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func Test_FunctionHeader_KeepsQueryAndRawPath(t *testing.T) {
+	var got *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a%2Fb?format=json", nil)
+	req.Header.Set("X-Function", "figlet")
+	FunctionHeader(next, "X-Function").ServeHTTP(httptest.NewRecorder(), req)
+
+	if !SelectedByHeader(got.Context()) || SelectedByHeader(req.Context()) {
+		t.Errorf("want only the rewritten request marked as selected by header")
+	}
+	if got.Header.Get("X-Function") != "" {
+		t.Errorf("want the header removed before the function, got: %q", got.Header.Get("X-Function"))
+	}
+	if req.Header.Get("X-Function") != "figlet" {
+		t.Errorf("want the caller's header left alone, got: %q", req.Header.Get("X-Function"))
+	}
+	if got.URL.RawPath != "/function/figlet/files/a%2Fb" {
+		t.Errorf("want raw path under the function, got: %q", got.URL.RawPath)
+	}
+	if got.RequestURI != "/function/figlet/files/a%2Fb?format=json" {
+		t.Errorf("want request URI under the function with its query, got: %q", got.RequestURI)
+	}
+	if req.URL.Path != "/files/a/b" {
+		t.Errorf("want the caller's request left alone, got: %q", req.URL.Path)
+	}
+}
+
+func Test_FunctionHeader_Disabled(t *testing.T) {
+	gotPath := ""
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/employees", nil)
+	req.Header.Set("X-Function", "figlet")
+	FunctionHeader(next, "").ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotPath != "/employees" {
+		t.Errorf("want the header ignored when no header is configured, got path: %q", gotPath)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package middleware
+
+import "testing"
+
+func Test_GetFunctionRoute(t *testing.T) {
+	cases := []struct {
+		path      string
+		wantRoute string
+		wantName  string
+	}{
+		{path: "/function/figlet", wantRoute: "function", wantName: "figlet"},
+		{path: "/function/figlet/", wantRoute: "function", wantName: "figlet"},
+		{path: "/function/figlet/employees/100", wantRoute: "function", wantName: "figlet"},
+		{path: "/function/figlet.fn", wantRoute: "function", wantName: "figlet.fn"},
+		{path: "/async-function/figlet/employees", wantRoute: "async-function", wantName: "figlet"},
+		{path: "/function/", wantRoute: "", wantName: ""},
+		{path: "/", wantRoute: "", wantName: ""},
+		{path: "", wantRoute: "", wantName: ""},
+		{path: "/system/functions", wantRoute: "", wantName: ""},
+		{path: "/ui/function/figlet", wantRoute: "", wantName: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			route, name := GetFunctionRoute(tc.path)
+			if route != tc.wantRoute {
+				t.Errorf("route want: %q, got: %q", tc.wantRoute, route)
+			}
+			if name != tc.wantName {
+				t.Errorf("name want: %q, got: %q", tc.wantName, name)
+			}
+		})
+	}
+}
@@ -154,3 +154,21 @@ func GetServiceName(urlValue string) string {
 	}
 	return strings.Trim(serviceName, "/")
 }
+
+// GetFunctionRoute returns the route, "function" or "async-function", and the
+// function name for a URL path such as /async-function/xyz/rest/of/path. Both
+// values are empty when the path does not invoke a function.
+func GetFunctionRoute(urlPath string) (route string, functionName string) {
+	matcher := functionMatcher.Copy()
+	matches := matcher.FindStringSubmatch(urlPath)
+	if len(matches) != hasPathCount || !strings.HasPrefix(urlPath, "/") {
+		return "", ""
+	}
+
+	route = "function"
+	if strings.HasPrefix(urlPath, "/async-function/") {
+		route = "async-function"
+	}
+
+	return route, matches[nameIndex]
+}
@@ -1,6 +1,16 @@
 package middleware
 
-import "strings"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxNamespaceLength is the longest DNS label, which namespaces and functions
+// are named by
+const maxNamespaceLength = 63
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
 
 func GetNamespace(defaultNamespace, fullName string) (string, string) {
 	if index := strings.LastIndex(fullName, "."); index > -1 {
@@ -8,3 +18,30 @@ func GetNamespace(defaultNamespace, fullName string) (string, string) {
 	}
 	return fullName, defaultNamespace
 }
+
+// ValidateNamespace returns an error when namespace could not be the name of
+// a namespace, so that it is rejected before it reaches the provider. An
+// empty namespace is valid and means the provider's default.
+func ValidateNamespace(namespace string) error {
+	if len(namespace) == 0 {
+		return nil
+	}
+
+	if len(namespace) > maxNamespaceLength || !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace: %q, use lowercase letters, numbers and '-', up to %d characters", namespace, maxNamespaceLength)
+	}
+	return nil
+}
+
+// ValidateFunctionName returns an error when fullName, a function's name
+// optionally followed by "." and its namespace, could not name a function.
+func ValidateFunctionName(fullName string) error {
+	name, namespace := GetNamespace("", fullName)
+	if len(name) > maxNamespaceLength || !namespacePattern.MatchString(name) {
+		return fmt.Errorf("invalid function name: %q, use lowercase letters, numbers and '-', up to %d characters", name, maxNamespaceLength)
+	}
+	if strings.HasSuffix(fullName, ".") {
+		return fmt.Errorf("invalid function name: %q, the namespace after '.' is empty", fullName)
+	}
+	return ValidateNamespace(namespace)
+}
@@ -0,0 +1,205 @@
+// Package mtls loads the CA bundle and client certificate which the gateway
+// uses to call functions over mutual TLS, and reloads them when the files
+// are replaced, such as when a mounted secret is rotated.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files names the PEM files read by a Reloader
+type Files struct {
+	// CAFile is the bundle used to verify functions' certificates, when
+	// it is empty the system's roots are used
+	CAFile string
+
+	// CertFile and KeyFile are the client certificate presented to
+	// functions which require one
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the name sent with SNI, and verified against
+	// the function's certificate, which is otherwise the function's host.
+	// It must be set when functions are called by an IP address.
+	ServerName string
+}
+
+// Enabled is true when any of the files are set
+func (f Files) Enabled() bool {
+	return len(f.CAFile) > 0 || len(f.CertFile) > 0 || len(f.KeyFile) > 0
+}
+
+func (f Files) paths() []string {
+	paths := []string{}
+	for _, path := range []string{f.CAFile, f.CertFile, f.KeyFile} {
+		if len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Reloader holds the certificates read from Files, for the tls.Config
+// returned by ClientConfig
+type Reloader struct {
+	files Files
+
+	lock        sync.RWMutex
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+	modTimes    map[string]time.Time
+}
+
+// NewReloader reads files, returning an error when they can not be read or
+// parsed
+func NewReloader(files Files) (*Reloader, error) {
+	if (len(files.CertFile) == 0) != (len(files.KeyFile) == 0) {
+		return nil, fmt.Errorf("a client certificate needs both a cert file and a key file")
+	}
+
+	r := &Reloader{files: files}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again when any of them has been modified since
+// they were last read, and reports whether they were. The certificates in
+// use are kept when the new files can not be parsed, for instance when a
+// certificate has been replaced but its key has not been yet.
+func (r *Reloader) Reload() (bool, error) {
+	modTimes := map[string]time.Time{}
+	for _, path := range r.files.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	r.lock.RLock()
+	changed := r.modTimes == nil
+	for path, modTime := range modTimes {
+		if !r.modTimes[path].Equal(modTime) {
+			changed = true
+		}
+	}
+	r.lock.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	var roots *x509.CertPool
+	if len(r.files.CAFile) > 0 {
+		bundle, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return false, err
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(bundle) {
+			return false, fmt.Errorf("no certificates found in %s", r.files.CAFile)
+		}
+	}
+
+	var certificate *tls.Certificate
+	if len(r.files.CertFile) > 0 {
+		pair, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("unable to load the client certificate: %w", err)
+		}
+		certificate = &pair
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.roots = roots
+	r.certificate = certificate
+	r.modTimes = modTimes
+
+	return true, nil
+}
+
+// Watch calls Reload every interval until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				log.Printf("Unable to reload the upstream TLS certificates: %s", err)
+			} else if reloaded {
+				log.Printf("Reloaded the upstream TLS certificates")
+			}
+		}
+	}
+}
+
+// ClientConfig returns a tls.Config which always uses the certificates
+// last read by Reload, so connections opened after a reload use the new
+// certificates without the transport being replaced.
+func (r *Reloader) ClientConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:           r.files.ServerName,
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: r.getClientCertificate,
+
+		// RootCAs is fixed for the life of a tls.Config, so verification is
+		// done in VerifyConnection instead, with the roots last read
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyConnection,
+	}
+}
+
+func (r *Reloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.certificate == nil {
+		// no certificate is sent
+		return &tls.Certificate{}, nil
+	}
+	return r.certificate, nil
+}
+
+func (r *Reloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("the function sent no certificate")
+	}
+
+	// SNI is not sent for an IP address, which leaves no name to verify
+	if len(cs.ServerName) == 0 {
+		return fmt.Errorf("unable to verify the function's certificate without a server name, call it by its hostname or set a server name")
+	}
+
+	r.lock.RLock()
+	roots := r.roots
+	r.lock.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,313 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// authority issues the certificates for a test
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &authority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM certificate and key for name, for a server when
+// server is true, otherwise for a client
+func (a *authority) issue(t *testing.T, name string, server bool) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.DNSNames = []string{name}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data to path with a modification time after the last
+// write, so that a reload sees the change however quickly it follows
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// function serves name over TLS with a certificate from serverCA, and
+// requires a client certificate from one of clientCAs
+func function(t *testing.T, serverCA *authority, name string, clientCAs ...*authority) *httptest.Server {
+	t.Helper()
+
+	certPEM, keyPEM := serverCA.issue(t, name, true)
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range clientCAs {
+		pool.AddCert(ca.cert)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func call(client *http.Client, url string) (string, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body := make([]byte, 64)
+	n, _ := res.Body.Read(body)
+	return string(body[:n]), nil
+}
+
+func Test_Reloader_SendsClientCertificate(t *testing.T) {
+	ca := newAuthority(t, "openfaas")
+	server := function(t, ca, "figlet.openfaas-fn", ca)
+
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "gateway", false)
+	files := Files{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   filepath.Join(dir, "tls.crt"),
+		KeyFile:    filepath.Join(dir, "tls.key"),
+		ServerName: "figlet.openfaas-fn",
+	}
+	writeFile(t, files.CAFile, ca.pem, time.Now())
+	writeFile(t, files.CertFile, certPEM, time.Now())
+	writeFile(t, files.KeyFile, keyPEM, time.Now())
+
+	reloader, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig()}}
+	got, err := call(client, server.URL)
+	if err != nil {
+		t.Fatalf("want the call to succeed with the client certificate, got: %s", err)
+	}
+	if got != "gateway" {
+		t.Errorf("want the function to see the client certificate for: gateway, got: %q", got)
+	}
+
+	// without a certificate the function rejects the handshake
+	withoutCert, err := NewReloader(Files{CAFile: files.CAFile, ServerName: files.ServerName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: withoutCert.ClientConfig()}}
+	if _, err := call(client, server.URL); err == nil {
+		t.Errorf("want the call to fail without a client certificate")
+	}
+}
+
+func Test_Reloader_VerifiesFunctionCertificate(t *testing.T) {
+	ca := newAuthority(t, "openfaas")
+	other := newAuthority(t, "other")
+	server := function(t, other, "figlet.openfaas-fn", ca)
+
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "gateway", false)
+	files := Files{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   filepath.Join(dir, "tls.crt"),
+		KeyFile:    filepath.Join(dir, "tls.key"),
+		ServerName: "figlet.openfaas-fn",
+	}
+	writeFile(t, files.CAFile, ca.pem, time.Now())
+	writeFile(t, files.CertFile, certPEM, time.Now())
+	writeFile(t, files.KeyFile, keyPEM, time.Now())
+
+	reloader, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig()}}
+
+	_, err = call(client, server.URL)
+	if err == nil || !strings.Contains(err.Error(), "unknown authority") {
+		t.Fatalf("want a certificate from another authority rejected, got: %v", err)
+	}
+
+	// the CA bundle is reloaded without replacing the client's transport
+	writeFile(t, files.CAFile, append(ca.pem, other.pem...), time.Now().Add(time.Minute))
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := call(client, server.URL); err != nil {
+		t.Errorf("want the call to succeed once the authority is trusted, got: %s", err)
+	}
+
+	// the name must match the function's certificate
+	files.ServerName = "env.openfaas-fn"
+	misnamed, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: misnamed.ClientConfig()}}
+	if _, err := call(client, server.URL); err == nil {
+		t.Errorf("want a certificate for another name rejected")
+	}
+
+	// an IP address is not sent with SNI, so there is no name to verify
+	files.ServerName = ""
+	unnamed, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: unnamed.ClientConfig()}}
+	if _, err := call(client, server.URL); err == nil || !strings.Contains(err.Error(), "without a server name") {
+		t.Errorf("want a function called by IP address rejected without a server name, got: %v", err)
+	}
+}
+
+func Test_Reloader_ReloadsChangedFiles(t *testing.T) {
+	oldCA := newAuthority(t, "old")
+	newCA := newAuthority(t, "new")
+
+	// the function has moved to accept client certificates from newCA only
+	server := function(t, oldCA, "figlet.openfaas-fn", newCA)
+
+	dir := t.TempDir()
+	certPEM, keyPEM := oldCA.issue(t, "gateway-old", false)
+	files := Files{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   filepath.Join(dir, "tls.crt"),
+		KeyFile:    filepath.Join(dir, "tls.key"),
+		ServerName: "figlet.openfaas-fn",
+	}
+	modTime := time.Now()
+	writeFile(t, files.CAFile, oldCA.pem, modTime)
+	writeFile(t, files.CertFile, certPEM, modTime)
+	writeFile(t, files.KeyFile, keyPEM, modTime)
+
+	reloader, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig()}}
+
+	if _, err := call(client, server.URL); err == nil {
+		t.Fatalf("want the old client certificate rejected")
+	}
+
+	if reloaded, err := reloader.Reload(); err != nil || reloaded {
+		t.Fatalf("want no reload when the files are unchanged, got: %t %v", reloaded, err)
+	}
+
+	// a certificate whose key has not been replaced yet is not used
+	certPEM, keyPEM = newCA.issue(t, "gateway-new", false)
+	modTime = modTime.Add(time.Minute)
+	writeFile(t, files.CertFile, certPEM, modTime)
+	if _, err := reloader.Reload(); err == nil {
+		t.Fatalf("want an error for a certificate which does not match its key")
+	}
+
+	writeFile(t, files.KeyFile, keyPEM, modTime)
+	if reloaded, err := reloader.Reload(); err != nil || !reloaded {
+		t.Fatalf("want the files reloaded, got: %t %v", reloaded, err)
+	}
+
+	got, err := call(client, server.URL)
+	if err != nil {
+		t.Fatalf("want the call to succeed with the new client certificate, got: %s", err)
+	}
+	if got != "gateway-new" {
+		t.Errorf("want the function to see the new client certificate, got: %q", got)
+	}
+}
+
+func Test_NewReloader_NeedsCertAndKey(t *testing.T) {
+	if _, err := NewReloader(Files{CertFile: "tls.crt"}); err == nil {
+		t.Errorf("want an error for a cert file without a key file")
+	}
+	if _, err := NewReloader(Files{CAFile: filepath.Join(t.TempDir(), "missing.crt")}); err == nil {
+		t.Errorf("want an error for a missing CA file")
+	}
+}
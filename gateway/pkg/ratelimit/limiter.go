@@ -0,0 +1,110 @@
+// Package ratelimit limits the rate of requests from each caller of the
+// gateway with a token bucket, keyed by API key or client IP.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Config for a Limiter
+type Config struct {
+	// Rate is the number of requests per second allowed for each key
+	Rate float64
+
+	// Burst is the number of requests a key can make at once, after being
+	// idle. It is at least 1.
+	Burst int
+
+	// MaxKeys bounds the number of keys tracked, the least recently seen key
+	// is evicted when it is reached, and starts again with a full bucket.
+	MaxKeys int
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket for each key, it is safe for concurrent use.
+type Limiter struct {
+	config Config
+
+	lock    sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewLimiter creates a Limiter, MaxKeys defaults to 10000
+func NewLimiter(config Config) *Limiter {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if config.MaxKeys < 1 {
+		config.MaxKeys = 10000
+	}
+
+	return &Limiter{
+		config:  config,
+		buckets: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for key. When the bucket is empty it
+// returns false and how long until the next token is added.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	b := l.get(key, now)
+
+	b.tokens = math.Min(float64(l.config.Burst), b.tokens+now.Sub(b.last).Seconds()*l.config.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if l.config.Rate <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+	wait := time.Duration((1 - b.tokens) / l.config.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Len returns the number of keys being tracked
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lru.Len()
+}
+
+// get returns the bucket for key, marking it as most recently used, and
+// evicts the least recently used bucket when a new one would go over MaxKeys
+func (l *Limiter) get(key string, now time.Time) *bucket {
+	if el, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*bucket)
+	}
+
+	if l.lru.Len() >= l.config.MaxKeys {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+
+	b := &bucket{key: key, tokens: float64(l.config.Burst), last: now}
+	l.buckets[key] = l.lru.PushFront(b)
+	return b
+}
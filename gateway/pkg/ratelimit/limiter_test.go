@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is advanced by tests instead of sleeping
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestLimiter(config Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	limiter := NewLimiter(config)
+	limiter.now = clock.now
+	return limiter, clock
+}
+
+func Test_Limiter_SteadyRatePasses(t *testing.T) {
+	limiter, clock := newTestLimiter(Config{Rate: 10, Burst: 1})
+
+	for i := 0; i < 50; i++ {
+		if ok, _ := limiter.Allow("ip:10.0.0.1"); !ok {
+			t.Fatalf("want request %d at the configured rate to pass", i)
+		}
+		clock.t = clock.t.Add(100 * time.Millisecond)
+	}
+}
+
+func Test_Limiter_Burst(t *testing.T) {
+	limiter, clock := newTestLimiter(Config{Rate: 1, Burst: 5})
+
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.Allow("ip:10.0.0.1"); !ok {
+			t.Fatalf("want request %d within the burst to pass", i)
+		}
+	}
+
+	ok, wait := limiter.Allow("ip:10.0.0.1")
+	if ok {
+		t.Fatal("want the request after the burst to be limited")
+	}
+	if wait != time.Second {
+		t.Errorf("want to wait 1s for the next token, got: %s", wait)
+	}
+
+	// other keys have their own bucket
+	if ok, _ := limiter.Allow("ip:10.0.0.2"); !ok {
+		t.Error("want a different key to pass")
+	}
+
+	clock.t = clock.t.Add(time.Second)
+	if ok, _ := limiter.Allow("ip:10.0.0.1"); !ok {
+		t.Error("want a request to pass once a token has been added")
+	}
+}
+
+func Test_Limiter_EvictsLeastRecentlyUsed(t *testing.T) {
+	limiter, _ := newTestLimiter(Config{Rate: 1, Burst: 1, MaxKeys: 2})
+
+	limiter.Allow("key:a")
+	limiter.Allow("key:b")
+	limiter.Allow("key:a")
+	limiter.Allow("key:c")
+
+	if got := limiter.Len(); got != 2 {
+		t.Fatalf("want 2 keys tracked, got: %d", got)
+	}
+
+	// a was used after b, so b was evicted and starts with a full bucket
+	if ok, _ := limiter.Allow("key:b"); !ok {
+		t.Error("want the evicted key to start again with a full bucket")
+	}
+	if ok, _ := limiter.Allow("key:c"); ok {
+		t.Error("want the most recent key to still be limited")
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// APIKeyHeader identifies the caller when keying by API key
+const APIKeyHeader = "X-API-Key"
+
+// KeyFunc returns the key that a request is limited by
+type KeyFunc func(r *http.Request) string
+
+// ByClientIP limits each client IP address
+func ByClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByAPIKey limits each value of the X-API-Key header, requests without one
+// are limited by client IP
+func ByAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); len(key) > 0 {
+		return "key:" + key
+	}
+	return ByClientIP(r)
+}
+
+// Middleware rejects requests over the limiter's rate for their key with a
+// 429 and a Retry-After header, in whole seconds.
+func Middleware(next http.HandlerFunc, limiter *Limiter, keyFunc KeyFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := limiter.Allow(keyFunc(r))
+		if ok {
+			next(w, r)
+			return
+		}
+
+		tracing.SetRateLimited(r.Context(), limiter.config.Rate)
+
+		retryAfter := int64(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		httperror.WriteCode(w, r, http.StatusTooManyRequests, "rate_limited", fmt.Sprintf("rate limit of %g requests per second exceeded", limiter.config.Rate))
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+func Test_Middleware_RejectsOverLimit(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	limiter, _ := newTestLimiter(Config{Rate: 0.5, Burst: 1})
+	handler := tracing.Middleware(Middleware(func(w http.ResponseWriter, r *http.Request) {}, limiter, ByAPIKey))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set(APIKeyHeader, "tenant-a")
+
+	first := httptest.NewRecorder()
+	handler(first, req)
+	second := httptest.NewRecorder()
+	handler(second, req)
+
+	if first.Code != http.StatusOK {
+		t.Errorf("want the first request to pass, got: %d", first.Code)
+	}
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("want status: %d, got: %d", http.StatusTooManyRequests, second.Code)
+	}
+	if got := second.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("want Retry-After: 2, got: %q", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+	found := false
+	for _, kv := range spans[1].Attributes() {
+		found = found || kv.Key == tracing.RateLimitKey
+	}
+	if !found {
+		t.Errorf("want a %s attribute on the limited request", tracing.RateLimitKey)
+	}
+}
+
+func Test_KeyFuncs(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.RemoteAddr = "10.0.0.1:51234"
+
+	if got := ByClientIP(req); got != "ip:10.0.0.1" {
+		t.Errorf("want key: ip:10.0.0.1, got: %s", got)
+	}
+	if got := ByAPIKey(req); got != "ip:10.0.0.1" {
+		t.Errorf("want the client IP without an API key, got: %s", got)
+	}
+
+	req.Header.Set(APIKeyHeader, "tenant-a")
+	if got := ByAPIKey(req); got != "key:tenant-a" {
+		t.Errorf("want key: key:tenant-a, got: %s", got)
+	}
+}
@@ -0,0 +1,175 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Strategy picks which of a function's endpoints is called
+type Strategy string
+
+const (
+	// RoundRobin calls each endpoint in turn
+	RoundRobin Strategy = "round_robin"
+
+	// Random calls an endpoint picked at random
+	Random Strategy = "random"
+)
+
+// endpointExpiry is how long an endpoint which has not been picked is
+// remembered for health checks, and a function which has not been called,
+// such as one which was deleted, is remembered for round robin
+const endpointExpiry = time.Minute * 10
+
+// LoadBalancer spreads calls to a function across its endpoints, skipping
+// endpoints which have failed their last health check. It is safe for
+// concurrent use.
+type LoadBalancer struct {
+	strategy Strategy
+
+	lock      sync.Mutex
+	next      map[string]uint64
+	picked    map[string]time.Time
+	seen      map[string]time.Time
+	unhealthy map[string]bool
+	swept     time.Time
+	rand      *rand.Rand
+
+	now func() time.Time
+}
+
+// NewLoadBalancer creates a LoadBalancer, an unknown strategy is an error
+func NewLoadBalancer(strategy Strategy) (*LoadBalancer, error) {
+	switch strategy {
+	case RoundRobin, Random:
+	default:
+		return nil, fmt.Errorf("unknown load balancer strategy: %q, use %s or %s", strategy, RoundRobin, Random)
+	}
+
+	return &LoadBalancer{
+		strategy:  strategy,
+		next:      map[string]uint64{},
+		picked:    map[string]time.Time{},
+		seen:      map[string]time.Time{},
+		unhealthy: map[string]bool{},
+		swept:     time.Now(),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:       time.Now,
+	}, nil
+}
+
+// Pick chooses one of the endpoints of functionName. When every endpoint is
+// unhealthy, one is still picked from all of them, since the health checks
+// may be out of date.
+func (lb *LoadBalancer) Pick(functionName string, endpoints []url.URL) url.URL {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	now := lb.now()
+	if now.Sub(lb.swept) > endpointExpiry {
+		lb.forget(now)
+	}
+
+	lb.picked[functionName] = now
+	healthy := make([]url.URL, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		lb.seen[endpoint.String()] = now
+		if !lb.unhealthy[endpoint.String()] {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = endpoints
+	}
+
+	if lb.strategy == Random {
+		return healthy[lb.rand.Intn(len(healthy))]
+	}
+
+	i := lb.next[functionName]
+	lb.next[functionName] = i + 1
+	return healthy[i%uint64(len(healthy))]
+}
+
+// Healthy reports whether endpoint passed its last health check, endpoints
+// which have not been checked are healthy. As with Pick, the endpoint is
+// kept in the health checks.
+func (lb *LoadBalancer) Healthy(endpoint url.URL) bool {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	lb.seen[endpoint.String()] = lb.now()
+	return !lb.unhealthy[endpoint.String()]
+}
+
+// SetHealthy records the result of a health check for an endpoint
+func (lb *LoadBalancer) SetHealthy(endpoint url.URL, healthy bool) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	if healthy {
+		delete(lb.unhealthy, endpoint.String())
+	} else {
+		lb.unhealthy[endpoint.String()] = true
+	}
+}
+
+// CheckEndpoints calls healthPath on each endpoint picked recently, marking
+// those which do not return a 2xx as unhealthy. Endpoints which have not
+// been picked for a while are forgotten.
+func (lb *LoadBalancer) CheckEndpoints(ctx context.Context, client *http.Client, healthPath string) {
+	lb.lock.Lock()
+	lb.forget(lb.now())
+	endpoints := make([]string, 0, len(lb.seen))
+	for endpoint := range lb.seen {
+		endpoints = append(endpoints, endpoint)
+	}
+	lb.lock.Unlock()
+
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			continue
+		}
+
+		lb.SetHealthy(*u, checkEndpoint(ctx, client, u.JoinPath(healthPath).String()))
+	}
+}
+
+// forget removes the functions and endpoints which have not been picked
+// within endpointExpiry, it must be called with the lock held
+func (lb *LoadBalancer) forget(now time.Time) {
+	for functionName, picked := range lb.picked {
+		if now.Sub(picked) > endpointExpiry {
+			delete(lb.picked, functionName)
+			delete(lb.next, functionName)
+		}
+	}
+	for endpoint, seen := range lb.seen {
+		if now.Sub(seen) > endpointExpiry {
+			delete(lb.seen, endpoint)
+			delete(lb.unhealthy, endpoint)
+		}
+	}
+	lb.swept = now
+}
+
+func checkEndpoint(ctx context.Context, client *http.Client, healthURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return false
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+
+	return res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices
+}
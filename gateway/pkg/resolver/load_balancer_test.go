@@ -0,0 +1,147 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func threeEndpoints(t *testing.T) []url.URL {
+	return []url.URL{
+		mustParse(t, "http://10.0.0.1:8080"),
+		mustParse(t, "http://10.0.0.2:8080"),
+		mustParse(t, "http://10.0.0.3:8080"),
+	}
+}
+
+func Test_LoadBalancer_RoundRobinIsEven(t *testing.T) {
+	lb, err := NewLoadBalancer(RoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := threeEndpoints(t)
+
+	const requests = 3000
+
+	var lock sync.Mutex
+	counts := map[string]int{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			picked := lb.Pick("figlet", endpoints)
+
+			lock.Lock()
+			counts[picked.Host]++
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, endpoint := range endpoints {
+		if got := counts[endpoint.Host]; got != requests/len(endpoints) {
+			t.Errorf("want %s picked %d times, got: %d", endpoint.Host, requests/len(endpoints), got)
+		}
+	}
+}
+
+func Test_LoadBalancer_Random(t *testing.T) {
+	lb, _ := NewLoadBalancer(Random)
+	endpoints := threeEndpoints(t)
+
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		counts[lb.Pick("figlet", endpoints).Host]++
+	}
+
+	for _, endpoint := range endpoints {
+		if counts[endpoint.Host] == 0 {
+			t.Errorf("want %s to be picked at least once", endpoint.Host)
+		}
+	}
+}
+
+func Test_LoadBalancer_SkipsUnhealthy(t *testing.T) {
+	lb, _ := NewLoadBalancer(RoundRobin)
+	endpoints := threeEndpoints(t)
+	lb.SetHealthy(endpoints[1], false)
+
+	for i := 0; i < 10; i++ {
+		if picked := lb.Pick("figlet", endpoints); picked.Host == endpoints[1].Host {
+			t.Fatalf("want the unhealthy endpoint %s to be skipped", picked.Host)
+		}
+	}
+
+	// with no healthy endpoints, the proxy still gets one to try
+	lb.SetHealthy(endpoints[0], false)
+	lb.SetHealthy(endpoints[2], false)
+	if picked := lb.Pick("figlet", endpoints); len(picked.Host) == 0 {
+		t.Error("want an endpoint when all are unhealthy")
+	}
+
+	lb.SetHealthy(endpoints[1], true)
+	if picked := lb.Pick("figlet", endpoints); picked.Host != endpoints[1].Host {
+		t.Errorf("want the only healthy endpoint %s, got: %s", endpoints[1].Host, picked.Host)
+	}
+}
+
+func Test_LoadBalancer_CheckEndpoints(t *testing.T) {
+	ready := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_/health" {
+			t.Errorf("want health check path: /_/health, got: %s", r.URL.Path)
+		}
+	}))
+	defer ready.Close()
+
+	notReady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer notReady.Close()
+
+	endpoints := []url.URL{mustParse(t, ready.URL), mustParse(t, notReady.URL)}
+
+	lb, _ := NewLoadBalancer(RoundRobin)
+	lb.Pick("figlet", endpoints)
+	lb.CheckEndpoints(context.Background(), http.DefaultClient, "/_/health")
+
+	for i := 0; i < 4; i++ {
+		if picked := lb.Pick("figlet", endpoints); picked.Host != endpoints[0].Host {
+			t.Fatalf("want only the ready endpoint %s, got: %s", endpoints[0].Host, picked.Host)
+		}
+	}
+}
+
+func Test_LoadBalancer_ForgetsFunctionsNoLongerCalled(t *testing.T) {
+	lb, _ := NewLoadBalancer(RoundRobin)
+	now := time.Now()
+	lb.now = func() time.Time { return now }
+
+	deleted := []url.URL{mustParse(t, "http://10.0.0.9:8080")}
+	lb.Pick("deleted", deleted)
+	lb.SetHealthy(deleted[0], false)
+
+	now = now.Add(endpointExpiry + time.Second)
+	lb.Pick("figlet", threeEndpoints(t))
+
+	if _, ok := lb.next["deleted"]; ok {
+		t.Errorf("want a function which has not been called for %s forgotten", endpointExpiry)
+	}
+	if _, ok := lb.seen[deleted[0].String()]; ok || lb.unhealthy[deleted[0].String()] {
+		t.Errorf("want its endpoint forgotten too")
+	}
+	if _, ok := lb.next["figlet"]; !ok {
+		t.Errorf("want the function just called remembered")
+	}
+}
+
+func Test_NewLoadBalancer_UnknownStrategy(t *testing.T) {
+	if _, err := NewLoadBalancer("least_connections"); err == nil {
+		t.Error("want an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,169 @@
+// Package resolver maps the name of a function to the addresses that calls
+// to it can be sent to. The proxy depends on the Resolver interface, so that
+// functions can be reached through the provider, directly by their service
+// DNS name, or from a static list in tests and development.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// ErrNotFound is returned when a Resolver has no addresses for a function
+var ErrNotFound = errors.New("function not found")
+
+// Resolver returns the base URLs of a function, functionName may include
+// the namespace i.e. "figlet.openfaas-fn"
+type Resolver interface {
+	Resolve(ctx context.Context, functionName string) ([]url.URL, error)
+}
+
+// ProviderResolver sends every call to the functions provider, which proxies
+// it on to the function.
+type ProviderResolver struct {
+	BaseURL url.URL
+}
+
+// Resolve returns the provider's URL for any function
+func (p ProviderResolver) Resolve(ctx context.Context, functionName string) ([]url.URL, error) {
+	return []url.URL{p.BaseURL}, nil
+}
+
+// KubernetesDNSResolver calls functions directly, by the DNS name of their
+// Kubernetes service: http://<name>.<namespace>.<suffix>:<port>
+type KubernetesDNSResolver struct {
+	// Suffix follows the namespace, i.e. "svc.cluster.local", it can be
+	// left blank when the search domains of the gateway's Pod are enough
+	Suffix string
+
+	// DefaultNamespace is used when the function name has no namespace
+	DefaultNamespace string
+
+	// Port of the function's service, the watchdog listens on 8080
+	Port int
+}
+
+// Resolve builds the service URL for a function, without a DNS lookup
+func (k KubernetesDNSResolver) Resolve(ctx context.Context, functionName string) ([]url.URL, error) {
+	name, namespace := middleware.GetNamespace(k.DefaultNamespace, functionName)
+	if len(name) == 0 || len(namespace) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, functionName)
+	}
+
+	host := name + "." + namespace
+	if suffix := strings.Trim(k.Suffix, "."); len(suffix) > 0 {
+		host += "." + suffix
+	}
+
+	port := k.Port
+	if port == 0 {
+		port = 8080
+	}
+
+	return []url.URL{{Scheme: "http", Host: fmt.Sprintf("%s:%d", host, port)}}, nil
+}
+
+// StaticResolver looks functions up in a fixed map, for tests and local
+// development
+type StaticResolver map[string][]url.URL
+
+// Resolve returns the addresses listed for functionName
+func (s StaticResolver) Resolve(ctx context.Context, functionName string) ([]url.URL, error) {
+	endpoints, ok := s[functionName]
+	if !ok || len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, functionName)
+	}
+
+	return append([]url.URL{}, endpoints...), nil
+}
+
+// BaseURLResolver adapts a Resolver to the middleware.BaseURLResolver used
+// by the forwarding proxy. The time taken to resolve each call is recorded as
+// a span event, and the endpoint which was picked as a span attribute. Calls
+// made within Sticky keep to the client's endpoint.
+type BaseURLResolver struct {
+	Resolver Resolver
+
+	// Balancer picks between the endpoints of a function, when nil the
+	// first endpoint is used
+	Balancer *LoadBalancer
+
+	// DefaultNamespace scopes calls to functions without a namespace, so
+	// that the Resolver always gets "name.namespace"
+	DefaultNamespace string
+}
+
+// Resolve returns an address for the function being called, or an empty
+// string when it cannot be resolved, which the proxy turns into a 502
+func (b BaseURLResolver) Resolve(r *http.Request) string {
+	endpoint, err := b.resolve(r.Context(), b.scope(middleware.GetServiceName(r.URL.Path)))
+	if err != nil {
+		log.Printf("unable to resolve %s: %s", r.URL.Path, err)
+		return ""
+	}
+
+	tracing.SetUpstreamAddr(r.Context(), endpoint.Host)
+
+	return strings.TrimSuffix(endpoint.String(), "/")
+}
+
+// BuildURL returns the URL of healthPath on the function
+func (b BaseURLResolver) BuildURL(function, namespace, healthPath string, directFunctions bool) string {
+	endpoint, err := b.resolve(context.Background(), function+"."+namespace)
+	if err != nil {
+		return ""
+	}
+
+	endpoint.Path = path.Join("/", endpoint.Path, healthPath)
+	return endpoint.String()
+}
+
+// scope adds the default namespace to a function name without one
+func (b BaseURLResolver) scope(functionName string) string {
+	name, namespace := middleware.GetNamespace(b.DefaultNamespace, functionName)
+	if len(name) == 0 || len(namespace) == 0 {
+		return functionName
+	}
+	return name + "." + namespace
+}
+
+func (b BaseURLResolver) resolve(ctx context.Context, functionName string) (url.URL, error) {
+	start := time.Now()
+	endpoints, err := b.Resolver.Resolve(ctx, functionName)
+	tracing.AddResolveEvent(ctx, time.Since(start), len(endpoints))
+
+	if err != nil {
+		return url.URL{}, err
+	}
+	if len(endpoints) == 0 {
+		return url.URL{}, fmt.Errorf("%w: %q", ErrNotFound, functionName)
+	}
+
+	a, sticky := ctx.Value(affinityContextKey{}).(*affinity)
+	if sticky {
+		if endpoint, ok := b.stickyEndpoint(a, endpoints); ok {
+			a.picked = a.cookie
+			return endpoint, nil
+		}
+	}
+
+	endpoint := endpoints[0]
+	if b.Balancer != nil {
+		endpoint = b.Balancer.Pick(functionName, endpoints)
+	}
+
+	if sticky {
+		a.picked = endpointID(endpoint)
+	}
+	return endpoint, nil
+}
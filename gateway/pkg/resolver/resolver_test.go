@@ -0,0 +1,186 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func mustParse(t *testing.T, raw string) url.URL {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *u
+}
+
+// mockResolver returns the same endpoints for any function
+type mockResolver struct {
+	endpoints []url.URL
+	err       error
+}
+
+func (m mockResolver) Resolve(ctx context.Context, functionName string) ([]url.URL, error) {
+	return m.endpoints, m.err
+}
+
+func Test_StaticResolver(t *testing.T) {
+	static := StaticResolver{
+		"figlet.openfaas-fn": {mustParse(t, "http://127.0.0.1:8081"), mustParse(t, "http://127.0.0.1:8082")},
+	}
+
+	endpoints, err := static.Resolve(context.Background(), "figlet.openfaas-fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 || endpoints[1].Host != "127.0.0.1:8082" {
+		t.Errorf("want both endpoints for figlet, got: %v", endpoints)
+	}
+
+	// callers can not change the map through the result
+	endpoints[0].Host = "changed"
+	if again, _ := static.Resolve(context.Background(), "figlet.openfaas-fn"); again[0].Host != "127.0.0.1:8081" {
+		t.Errorf("want the static endpoints unchanged, got: %v", again)
+	}
+
+	if _, err := static.Resolve(context.Background(), "env.openfaas-fn"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound for an unknown function, got: %v", err)
+	}
+}
+
+func Test_KubernetesDNSResolver(t *testing.T) {
+	cases := []struct {
+		resolver KubernetesDNSResolver
+		name     string
+		want     string
+	}{
+		{KubernetesDNSResolver{DefaultNamespace: "openfaas-fn"}, "figlet", "http://figlet.openfaas-fn:8080"},
+		{KubernetesDNSResolver{DefaultNamespace: "openfaas-fn"}, "figlet.staging", "http://figlet.staging:8080"},
+		{KubernetesDNSResolver{Suffix: "svc.cluster.local", DefaultNamespace: "openfaas-fn", Port: 8000}, "figlet", "http://figlet.openfaas-fn.svc.cluster.local:8000"},
+	}
+
+	for _, c := range cases {
+		endpoints, err := c.resolver.Resolve(context.Background(), c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(endpoints) != 1 || endpoints[0].String() != c.want {
+			t.Errorf("%s: want: %s, got: %v", c.name, c.want, endpoints)
+		}
+	}
+
+	if _, err := (KubernetesDNSResolver{}).Resolve(context.Background(), "figlet"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound without a namespace, got: %v", err)
+	}
+}
+
+func Test_BaseURLResolver_UsesFirstOfMultipleEndpoints(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	b := BaseURLResolver{Resolver: mockResolver{endpoints: []url.URL{
+		mustParse(t, "http://10.0.0.1:8080/"),
+		mustParse(t, "http://10.0.0.2:8080/"),
+	}}}
+
+	var got string
+	handler := tracing.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		got = b.Resolve(r)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if got != "http://10.0.0.1:8080" {
+		t.Errorf("want base URL: http://10.0.0.1:8080, got: %s", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if v, ok := spanAttribute(spans[0].Attributes(), tracing.UpstreamAddrKey); !ok || v != "10.0.0.1:8080" {
+		t.Errorf("want %s: 10.0.0.1:8080, got: %s", tracing.UpstreamAddrKey, v)
+	}
+
+	events := spans[0].Events()
+	if len(events) != 1 || events[0].Name != tracing.ResolveEvent {
+		t.Fatalf("want a %s event, got: %v", tracing.ResolveEvent, events)
+	}
+	for _, kv := range events[0].Attributes {
+		if kv.Key == tracing.ResolveEndpointsKey && kv.Value.AsInt64() != 2 {
+			t.Errorf("want %s: 2, got: %d", tracing.ResolveEndpointsKey, kv.Value.AsInt64())
+		}
+	}
+}
+
+func Test_BaseURLResolver_Balances(t *testing.T) {
+	lb, _ := NewLoadBalancer(RoundRobin)
+	b := BaseURLResolver{
+		Resolver: mockResolver{endpoints: threeEndpoints(t)},
+		Balancer: lb,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	first, second := b.Resolve(req), b.Resolve(req)
+
+	if first == second {
+		t.Errorf("want calls spread across endpoints, got %s twice", first)
+	}
+}
+
+func spanAttribute(attrs []attribute.KeyValue, key attribute.Key) (string, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.AsString(), true
+		}
+	}
+	return "", false
+}
+
+func Test_BaseURLResolver_Errors(t *testing.T) {
+	for _, m := range []mockResolver{{err: errors.New("no route to host")}, {}} {
+		b := BaseURLResolver{Resolver: m}
+
+		if got := b.Resolve(httptest.NewRequest(http.MethodGet, "/function/figlet", nil)); got != "" {
+			t.Errorf("want no base URL when resolving fails, got: %s", got)
+		}
+	}
+}
+
+func Test_BaseURLResolver_ScopesToNamespace(t *testing.T) {
+	b := BaseURLResolver{
+		Resolver: StaticResolver{
+			"figlet.openfaas-fn": {mustParse(t, "http://10.0.0.1:8080")},
+			"figlet.staging":     {mustParse(t, "http://10.0.0.2:8080")},
+		},
+		DefaultNamespace: "openfaas-fn",
+	}
+
+	cases := map[string]string{
+		"/function/figlet":         "http://10.0.0.1:8080",
+		"/function/figlet.staging": "http://10.0.0.2:8080",
+		"/function/figlet.dev":     "",
+	}
+	for path, want := range cases {
+		if got := b.Resolve(httptest.NewRequest(http.MethodGet, path, nil)); got != want {
+			t.Errorf("%s: want: %q, got: %q", path, want, got)
+		}
+	}
+}
+
+func Test_BaseURLResolver_BuildURL(t *testing.T) {
+	b := BaseURLResolver{Resolver: KubernetesDNSResolver{DefaultNamespace: "openfaas-fn"}}
+
+	if got := b.BuildURL("figlet", "staging", "_/health", true); got != "http://figlet.staging:8080/_/health" {
+		t.Errorf("want: http://figlet.staging:8080/_/health, got: %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// AffinityCookie routes a client back to the endpoint of a function which
+// served its first call
+const AffinityCookie = "faas_affinity"
+
+type affinityContextKey struct{}
+
+// affinity holds the endpoint named by the client's cookie, and the one
+// picked by BaseURLResolver for the call
+type affinity struct {
+	cookie string
+	picked string
+}
+
+// Sticky sends each client of a function to the same endpoint, for
+// functions which keep state in memory between calls. The endpoint picked
+// by the Balancer for the client's first call is set in the AffinityCookie,
+// scoped to the function's path under basePath, and used for its calls
+// after that for as long as it is healthy. Otherwise a new endpoint is
+// picked and the cookie is replaced. When the function was named by the
+// FunctionHeader, the client's path does not name it, so the cookie is
+// scoped to basePath.
+//
+// Chain Sticky outside the forwarding proxy, whose BaseURLResolver reads
+// the cookie from the request's context.
+func Sticky(next http.HandlerFunc, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceName := middleware.GetServiceName(r.URL.Path)
+		if len(serviceName) == 0 {
+			next(w, r)
+			return
+		}
+
+		a := &affinity{}
+		if cookie, err := r.Cookie(AffinityCookie); err == nil {
+			a.cookie = cookie.Value
+		}
+
+		// the path the client called, before the base path was removed
+		path := basePath + "/function/" + serviceName
+		if middleware.SelectedByHeader(r.Context()) {
+			path = basePath + "/"
+		}
+
+		sw := &stickyResponseWriter{
+			ResponseWriter: w,
+			affinity:       a,
+			path:           path,
+		}
+		next(sw, r.WithContext(context.WithValue(r.Context(), affinityContextKey{}, a)))
+	}
+}
+
+// endpointID names an endpoint in the cookie without giving its address
+// away to the client
+func endpointID(endpoint url.URL) string {
+	h := fnv.New64a()
+	h.Write([]byte(endpoint.String()))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// stickyEndpoint returns the endpoint named by the client's cookie, when it
+// is still one of the function's endpoints and is healthy
+func (b BaseURLResolver) stickyEndpoint(a *affinity, endpoints []url.URL) (url.URL, bool) {
+	if len(a.cookie) == 0 {
+		return url.URL{}, false
+	}
+
+	for _, endpoint := range endpoints {
+		if endpointID(endpoint) != a.cookie {
+			continue
+		}
+		if b.Balancer != nil && !b.Balancer.Healthy(endpoint) {
+			return url.URL{}, false
+		}
+		return endpoint, true
+	}
+	return url.URL{}, false
+}
+
+// stickyResponseWriter sets the AffinityCookie before the response's
+// headers are written, when the call was sent to a new endpoint
+type stickyResponseWriter struct {
+	http.ResponseWriter
+
+	affinity    *affinity
+	path        string
+	wroteHeader bool
+}
+
+func (sw *stickyResponseWriter) WriteHeader(statusCode int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+
+		if picked := sw.affinity.picked; len(picked) > 0 && picked != sw.affinity.cookie {
+			http.SetCookie(sw.ResponseWriter, &http.Cookie{
+				Name:     AffinityCookie,
+				Value:    picked,
+				Path:     sw.path,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *stickyResponseWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *stickyResponseWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection to WebSocket upgrades, for handlers which
+// assert http.Hijacker rather than using http.ResponseController
+func (sw *stickyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(sw.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection
+func (sw *stickyResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
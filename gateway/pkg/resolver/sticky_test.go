@@ -0,0 +1,195 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+// stickyProxy resolves each call within Sticky, returning the base URL and
+// the affinity cookie set on the response, if any
+func stickyProxy(t *testing.T, b BaseURLResolver) func(cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+
+	var got string
+	handler := tracing.Middleware(Sticky(func(w http.ResponseWriter, r *http.Request) {
+		got = b.Resolve(r)
+		w.WriteHeader(http.StatusOK)
+	}, ""))
+
+	return func(cookie *http.Cookie) (string, *http.Cookie) {
+		req := httptest.NewRequest(http.MethodGet, "/function/counter", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		for _, set := range rr.Result().Cookies() {
+			if set.Name == AffinityCookie {
+				return got, set
+			}
+		}
+		return got, nil
+	}
+}
+
+func stickyResolver(t *testing.T) (BaseURLResolver, []url.URL) {
+	endpoints := []url.URL{
+		mustParse(t, "http://10.0.0.1:8080"),
+		mustParse(t, "http://10.0.0.2:8080"),
+		mustParse(t, "http://10.0.0.3:8080"),
+	}
+
+	balancer, err := NewLoadBalancer(RoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return BaseURLResolver{Resolver: mockResolver{endpoints: endpoints}, Balancer: balancer}, endpoints
+}
+
+func Test_Sticky_AssignsEndpointOnFirstCall(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	b, _ := stickyResolver(t)
+	call := stickyProxy(t, b)
+
+	got, cookie := call(nil)
+	if got != "http://10.0.0.1:8080" {
+		t.Errorf("want the balancer's pick, got: %s", got)
+	}
+	if cookie == nil || len(cookie.Value) == 0 {
+		t.Fatalf("want a %s cookie", AffinityCookie)
+	}
+	if cookie.Path != "/function/counter" || !cookie.HttpOnly {
+		t.Errorf("want an HttpOnly cookie for /function/counter, got: %+v", cookie)
+	}
+	if cookie.Value == "10.0.0.1:8080" || cookie.Value == got {
+		t.Errorf("want the endpoint's address kept out of the cookie, got: %q", cookie.Value)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if v, ok := spanAttribute(spans[0].Attributes(), tracing.UpstreamAddrKey); !ok || v != "10.0.0.1:8080" {
+		t.Errorf("want %s: 10.0.0.1:8080, got: %s", tracing.UpstreamAddrKey, v)
+	}
+}
+
+func Test_Sticky_ScopesCookieToClientPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		basePath string
+		target   string
+		header   string
+		want     string
+	}{
+		{name: "at the root", target: "/function/counter/add", want: "/function/counter"},
+		{name: "under a base path", basePath: "/openfaas", target: "/openfaas/function/counter/add", want: "/openfaas/function/counter"},
+		{name: "named by header", target: "/add", header: "counter", want: "/"},
+		{name: "named by header under a base path", basePath: "/openfaas", target: "/openfaas/add", header: "counter", want: "/openfaas/"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, _ := stickyResolver(t)
+			var handler http.Handler = Sticky(func(w http.ResponseWriter, r *http.Request) {
+				b.Resolve(r)
+				w.WriteHeader(http.StatusOK)
+			}, tc.basePath)
+			handler = middleware.FunctionHeader(handler, "X-Function")
+			handler = middleware.BasePath(handler, tc.basePath)
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if len(tc.header) > 0 {
+				req.Header.Set("X-Function", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Path != tc.want {
+				t.Errorf("want a cookie for %s, got: %+v", tc.want, cookies)
+			}
+		})
+	}
+}
+
+func Test_Sticky_KeepsEndpointOnRepeatCalls(t *testing.T) {
+	b, _ := stickyResolver(t)
+	call := stickyProxy(t, b)
+
+	// the second client's first call moves the round robin on
+	_, first := call(nil)
+	call(nil)
+
+	for i := 0; i < 5; i++ {
+		got, cookie := call(first)
+		if got != "http://10.0.0.1:8080" {
+			t.Fatalf("call %d: want the client's endpoint: http://10.0.0.1:8080, got: %s", i+1, got)
+		}
+		if cookie != nil {
+			t.Errorf("call %d: want the cookie left as it is, got: %+v", i+1, cookie)
+		}
+	}
+}
+
+func Test_Sticky_FailsOverFromUnhealthyEndpoint(t *testing.T) {
+	b, endpoints := stickyResolver(t)
+	call := stickyProxy(t, b)
+
+	_, first := call(nil)
+	b.Balancer.SetHealthy(endpoints[0], false)
+
+	got, cookie := call(first)
+	if got == "http://10.0.0.1:8080" || len(got) == 0 {
+		t.Errorf("want a healthy endpoint, got: %q", got)
+	}
+	if cookie == nil || cookie.Value == first.Value {
+		t.Fatalf("want the cookie moved to the new endpoint, got: %+v", cookie)
+	}
+
+	// the client keeps to its new endpoint
+	if again, _ := call(cookie); again != got {
+		t.Errorf("want the new endpoint: %s, got: %s", got, again)
+	}
+}
+
+func Test_Sticky_HijacksWebSocketUpgrades(t *testing.T) {
+	server := httptest.NewServer(Sticky(func(w http.ResponseWriter, r *http.Request) {
+		// the circuit breaker's write interceptor asserts http.Hijacker
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		buf.Flush()
+	}, ""))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/function/counter", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("want status: %d, got: %d", http.StatusSwitchingProtocols, res.StatusCode)
+	}
+}
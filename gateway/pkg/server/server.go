@@ -0,0 +1,151 @@
+// Package server runs the gateway's http.Servers, draining in-flight requests
+// before the process exits.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// ErrDraining is returned by Check once the server has started to shut down
+var ErrDraining = errors.New("the gateway is shutting down")
+
+// Server wraps one or more http.Servers so that on shutdown it reports
+// not-ready, stops accepting connections, waits up to DrainTimeout for
+// in-flight requests to complete on all of them, then flushes the tracer.
+type Server struct {
+	servers      []*http.Server
+	drainTimeout time.Duration
+	shutdown     tracing.Shutdown
+
+	draining atomic.Bool
+}
+
+// New wraps server. shutdown is called once requests have drained, so that
+// their spans are exported, it may be nil.
+func New(server *http.Server, drainTimeout time.Duration, shutdown tracing.Shutdown) *Server {
+	return &Server{
+		servers:      []*http.Server{server},
+		drainTimeout: drainTimeout,
+		shutdown:     shutdown,
+	}
+}
+
+// Add serves another http.Server, such as the admin API on an address of
+// its own, which is drained along with the first. It must be called before
+// ListenAndServe or Serve.
+func (s *Server) Add(server *http.Server) {
+	s.servers = append(s.servers, server)
+}
+
+// ListenAndServe listens on the Addr of each server and serves until ctx is
+// done, then drains. It returns nil after a graceful shutdown, or an error
+// when requests were still in-flight at the drain timeout.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listeners := make([]net.Listener, 0, len(s.servers))
+	for _, server := range s.servers {
+		addr := server.Addr
+		if len(addr) == 0 {
+			addr = ":http"
+		}
+
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+	return s.Serve(ctx, listeners...)
+}
+
+// Serve serves connections from one listener for each server, in the order
+// they were added, until ctx is done, then drains. When any server fails,
+// the others are closed and its error is returned.
+func (s *Server) Serve(ctx context.Context, listeners ...net.Listener) error {
+	if len(listeners) != len(s.servers) {
+		return fmt.Errorf("want %d listeners, got: %d", len(s.servers), len(listeners))
+	}
+
+	serveErr := make(chan error, len(s.servers))
+	for i, server := range s.servers {
+		go func(server *http.Server, l net.Listener) {
+			serveErr <- server.Serve(l)
+		}(server, listeners[i])
+	}
+
+	select {
+	case err := <-serveErr:
+		for _, server := range s.servers {
+			server.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	return s.drain()
+}
+
+// Draining reports whether the server has started to shut down
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Check fails once the server is draining, register it as a readiness check
+// so that load balancers stop sending traffic before the listener closes
+func (s *Server) Check(_ context.Context) error {
+	if s.Draining() {
+		return ErrDraining
+	}
+	return nil
+}
+
+func (s *Server) drain() error {
+	s.draining.Store(true)
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", s.drainTimeout)
+
+	ctx := context.Background()
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+
+	// every server is drained at once, so the slowest sets the time taken
+	errs := make([]error, len(s.servers))
+	var wg sync.WaitGroup
+	for i, server := range s.servers {
+		wg.Add(1)
+		go func(i int, server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("Requests to %s did not complete within %s: %s", server.Addr, s.drainTimeout, err)
+				server.Close()
+				errs[i] = err
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	if s.shutdown != nil {
+		s.shutdown(context.Background())
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
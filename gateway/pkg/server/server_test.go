@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_Server_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	flushed := make(chan struct{})
+	srv := New(&http.Server{Handler: handler}, 5*time.Second, func(ctx context.Context) {
+		close(flushed)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, l)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	res := make(chan result, 1)
+	go func() {
+		r, err := http.Get("http://" + l.Addr().String() + "/function/slow")
+		if err != nil {
+			res <- result{err: err}
+			return
+		}
+		defer r.Body.Close()
+		body, err := io.ReadAll(r.Body)
+		res <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// readiness fails as soon as draining starts
+	deadline := time.Now().Add(5 * time.Second)
+	for !srv.Draining() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := srv.Check(context.Background()); err != ErrDraining {
+		t.Errorf("want readiness to fail while draining, got: %v", err)
+	}
+
+	select {
+	case <-flushed:
+		t.Fatal("want the tracer flushed after requests have drained")
+	default:
+	}
+
+	close(release)
+
+	got := <-res
+	if got.err != nil || got.body != "done" {
+		t.Errorf("want the in-flight request to complete, got: %q, error: %v", got.body, got.err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("want a graceful shutdown, got: %s", err)
+	}
+
+	select {
+	case <-flushed:
+	default:
+		t.Errorf("want the tracer flushed on shutdown")
+	}
+}
+
+func Test_Server_DrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	srv := New(&http.Server{Handler: handler}, 50*time.Millisecond, nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, l)
+	}()
+
+	go http.Get("http://" + l.Addr().String() + "/function/stuck")
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err != context.DeadlineExceeded {
+			t.Errorf("want %s, got: %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want Serve to return after the drain timeout")
+	}
+}
+
+func Test_Server_ServesEachServerOnItsListener(t *testing.T) {
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+
+	srv := New(&http.Server{Handler: respond("functions")}, 5*time.Second, nil)
+	srv.Add(&http.Server{Handler: respond("admin")})
+
+	data, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, data, admin)
+	}()
+
+	// a connection left open would hold up the drain
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	for l, want := range map[net.Listener]string{data: "functions", admin: "admin"} {
+		res, err := client.Get("http://" + l.Addr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if string(body) != want {
+			t.Errorf("%s: want: %q, got: %q", l.Addr(), want, string(body))
+		}
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("want both servers shut down gracefully, got: %s", err)
+	}
+}
+
+func Test_Server_ServeWantsListenerForEachServer(t *testing.T) {
+	srv := New(&http.Server{}, time.Second, nil)
+	srv.Add(&http.Server{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := srv.Serve(context.Background(), l); err == nil {
+		t.Errorf("want an error for one listener and two servers")
+	}
+}
@@ -0,0 +1,323 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BodyLimitExceededKey is set when a body was larger than the gateway allows,
+// its value is "request" or "response" for the body which was rejected.
+const BodyLimitExceededKey = attribute.Key("faas.body_limit.exceeded")
+
+// BodyLimitKey is the attribute for the body size limit in bytes.
+const BodyLimitKey = attribute.Key("faas.body_limit.bytes")
+
+// SetBodyLimitExceeded records that the request or response body was over
+// limit on the active span in ctx. It is safe to call when the span is not
+// recording.
+func SetBodyLimitExceeded(ctx context.Context, body string, limit int64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		BodyLimitExceededKey.String(body),
+		BodyLimitKey.Int64(limit),
+	)
+}
+
+// CircuitStateKey is the attribute for the state of the function's circuit
+// breaker when the request was made: closed, open or half-open.
+const CircuitStateKey = attribute.Key("circuit.state")
+
+// SetCircuitState records the circuit breaker state on the active span in
+// ctx. It is safe to call when the span is not recording.
+func SetCircuitState(ctx context.Context, state string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(CircuitStateKey.String(state))
+}
+
+// SignatureKey is the attribute for the outcome of verifying a webhook's
+// HMAC signature: valid, invalid or missing.
+const SignatureKey = attribute.Key("faas.hmac.signature")
+
+// SetSignatureOutcome records the outcome of verifying the request's HMAC
+// signature on the active span in ctx. It is safe to call when the span is
+// not recording.
+func SetSignatureOutcome(ctx context.Context, outcome string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(SignatureKey.String(outcome))
+}
+
+// Attributes for a compressed response: the Content-Encoding used, and the
+// compressed size divided by the original size.
+const (
+	CompressionEncodingKey = attribute.Key("faas.compression.encoding")
+	CompressionRatioKey    = attribute.Key("faas.compression.ratio")
+)
+
+// SetCompression records how the response was compressed on the active span
+// in ctx. It is safe to call when the span is not recording.
+func SetCompression(ctx context.Context, encoding string, ratio float64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		CompressionEncodingKey.String(encoding),
+		CompressionRatioKey.Float64(ratio),
+	)
+}
+
+// Attributes for the deadline of a call to a function: the time it must
+// respond by, and the timeout it was given, in seconds, which is the least
+// of the gateway's exec timeout and the caller's budget.
+const (
+	DeadlineKey        = attribute.Key("faas.deadline")
+	DeadlineTimeoutKey = attribute.Key("faas.deadline.timeout_seconds")
+)
+
+// SetDeadline records the deadline of the call to the function on the
+// active span in ctx. It is safe to call when the span is not recording.
+func SetDeadline(ctx context.Context, deadline time.Time, timeout time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		DeadlineKey.String(deadline.UTC().Format(time.RFC3339Nano)),
+		DeadlineTimeoutKey.Float64(timeout.Seconds()),
+	)
+}
+
+// UpstreamAddrKey is the attribute for the host and port of the function
+// endpoint which was picked to serve the request.
+const UpstreamAddrKey = attribute.Key("upstream.addr")
+
+// SetUpstreamAddr records the endpoint picked for the request on the active
+// span in ctx. It is safe to call when the span is not recording.
+func SetUpstreamAddr(ctx context.Context, addr string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(UpstreamAddrKey.String(addr))
+}
+
+// RateLimitKey is set when a request was rejected by the rate limiter, its
+// value is the rate in requests per second which was exceeded.
+const RateLimitKey = attribute.Key("faas.rate_limit.exceeded")
+
+// SetRateLimited records that the caller went over rate on the active span
+// in ctx. It is safe to call when the span is not recording.
+func SetRateLimited(ctx context.Context, rate float64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(RateLimitKey.Float64(rate))
+}
+
+// StreamedBytesKey is the attribute for the number of bytes of a streamed
+// response, such as Server-Sent Events, which were passed to the caller.
+const StreamedBytesKey = attribute.Key("faas.response.streamed_bytes")
+
+// SetStreamedBytes records the size of a streamed response on the active
+// span in ctx, once the stream has completed. It is safe to call when the
+// span is not recording.
+func SetStreamedBytes(ctx context.Context, written int64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(StreamedBytesKey.Int64(written))
+}
+
+// Attributes for a proxied WebSocket connection: the number of data messages
+// received from the caller and sent to it by the function.
+const (
+	WebSocketReceivedKey = attribute.Key("websocket.messages.received")
+	WebSocketSentKey     = attribute.Key("websocket.messages.sent")
+)
+
+// SetWebSocketMessages records the messages passed in each direction of a
+// WebSocket connection on the active span in ctx, once the connection has
+// closed. It is safe to call when the span is not recording.
+func SetWebSocketMessages(ctx context.Context, received, sent int64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		WebSocketReceivedKey.Int64(received),
+		WebSocketSentKey.Int64(sent),
+	)
+}
+
+// Attributes for a request to scale a function: the replicas it had before,
+// and the replicas which were requested.
+const (
+	ReplicasFromKey = attribute.Key("faas.scale.replicas.from")
+	ReplicasToKey   = attribute.Key("faas.scale.replicas.to")
+)
+
+// Attributes for a deploy or update of a function: whether it started a
+// rollout, and which fields of the spec differed from the deployed function.
+const (
+	RolloutKey       = attribute.Key("faas.deploy.rollout")
+	ChangedFieldsKey = attribute.Key("faas.deploy.changed")
+)
+
+// SecretNameKey is the attribute for the name of a secret being managed, its
+// value is never recorded.
+const SecretNameKey = attribute.Key("faas.secret")
+
+// SetNamespace records the namespace of the function on the active span in
+// ctx. It is safe to call when the span is not recording.
+func SetNamespace(ctx context.Context, namespace string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(NamespaceKey.String(namespace))
+}
+
+// Attributes for a stream of a function's logs: whether it followed new
+// lines, and how many lines were sent before it ended.
+const (
+	LogsFollowKey = attribute.Key("faas.logs.follow")
+	LogLinesKey   = attribute.Key("faas.logs.lines")
+)
+
+// CacheHitKey is set on requests to functions with a cacheable response,
+// true when the response was served from the gateway's cache.
+const CacheHitKey = attribute.Key("faas.cache.hit")
+
+// SetCacheHit records whether the response cache was hit on the active span
+// in ctx. It is safe to call when the span is not recording.
+func SetCacheHit(ctx context.Context, hit bool) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(CacheHitKey.Bool(hit))
+}
+
+// VariantKey is the attribute for the version of a function which served
+// the request, stable or canary when the function has a canary, or the
+// function chosen by its content routes.
+const VariantKey = attribute.Key("faas.variant")
+
+// SetVariant records the version of the function which served the request
+// on the active span in ctx. It is safe to call when the span is not
+// recording.
+func SetVariant(ctx context.Context, variant string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(VariantKey.String(variant))
+}
+
+// ContentRouteKey is the attribute for the media type of the content route
+// which matched the request, when the function has content routes.
+const ContentRouteKey = attribute.Key("faas.content_route")
+
+// SetContentRoute records the media type of the content route which matched
+// the request on the active span in ctx. It is safe to call when the span is
+// not recording.
+func SetContentRoute(ctx context.Context, mediaType string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(ContentRouteKey.String(mediaType))
+}
+
+// Attributes for the image the function was deployed with, and its tag or
+// digest, so that traces can be matched to a deployment.
+const (
+	ImageKey   = attribute.Key("faas.image")
+	VersionKey = attribute.Key("faas.version")
+)
+
+// SetImage records the function's image, and its version when the image has
+// a tag or digest, on the active span in ctx. It is safe to call when the
+// span is not recording.
+func SetImage(ctx context.Context, image, version string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(ImageKey.String(image))
+	if len(version) > 0 {
+		span.SetAttributes(VersionKey.String(version))
+	}
+}
+
+// QueueDepthKey is the attribute for the number of queued requests waiting
+// to be run, as last read from the broker when the request was consumed.
+const QueueDepthKey = attribute.Key("messaging.queue.depth")
+
+// SetQueueDepth records the depth of the queue on the active span in ctx.
+// It is safe to call when the span is not recording.
+func SetQueueDepth(ctx context.Context, depth int64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(QueueDepthKey.Int64(depth))
+}
+
+// QueueWaitKey is the attribute for the milliseconds a queued request waited
+// between being published and being consumed.
+const QueueWaitKey = attribute.Key("faas.queue_wait_ms")
+
+// SetQueueWait records how long the request waited in the queue on the
+// active span in ctx. It is safe to call when the span is not recording.
+func SetQueueWait(ctx context.Context, wait time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(QueueWaitKey.Int64(wait.Milliseconds()))
+}
+
+// SetClientAddress records the address of the client which made the
+// request, which is not the address of the connection when the gateway is
+// behind a proxy, on the active span in ctx. It is safe to call when the
+// span is not recording.
+func SetClientAddress(ctx context.Context, address string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(semconv.ClientAddress(address))
+}
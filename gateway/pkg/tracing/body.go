@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BodyReadSpanName is the name of the span for reading a request body
+const BodyReadSpanName = "request.body.read"
+
+// ReadRequestBody reads the whole of r's body in a request.body.read child
+// span of the span in r's context, so that the time spent waiting on a slow
+// client is not put down to whatever runs next. The bytes read are recorded
+// as http.request.body.size. r.Body is then replaced with a reader over what
+// was read, so that handlers further on still see the full body.
+//
+// Spans are started with the global TracerProvider registered by Provider.
+func ReadRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	_, span := otel.Tracer(TracerName).Start(r.Context(), BodyReadSpanName,
+		trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	span.SetAttributes(semconv.HTTPRequestBodySize(len(body)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return body, err
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func Test_ReadRequestBody_RecordsSpanAndRestoresBody(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "/function/figlet")
+	req := httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader("hello world"))
+	req = req.WithContext(ctx)
+
+	body, err := ReadRequestBody(req)
+	parent.End()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if string(body) != "hello world" {
+		t.Errorf("want body: %q, got: %q", "hello world", string(body))
+	}
+
+	restored, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("want no error reading restored body, got: %s", err)
+	}
+	if string(restored) != "hello world" {
+		t.Errorf("want restored body: %q, got: %q", "hello world", string(restored))
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != BodyReadSpanName {
+		t.Fatalf("want span: %s, got: %s", BodyReadSpanName, span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("want %s to be a child of the request span", BodyReadSpanName)
+	}
+
+	found := false
+	for _, attr := range span.Attributes() {
+		if attr.Key == semconv.HTTPRequestBodySizeKey {
+			found = true
+			if got := attr.Value.AsInt64(); got != int64(len("hello world")) {
+				t.Errorf("want %s: %d, got: %d", attr.Key, len("hello world"), got)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("want a %s attribute, got: %v", semconv.HTTPRequestBodySizeKey, span.Attributes())
+	}
+}
+
+func Test_ReadRequestBody_NoSpanWithoutBody(t *testing.T) {
+	recorder := recordSpans(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+
+	body, err := ReadRequestBody(req)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if len(body) != 0 {
+		t.Errorf("want empty body, got: %q", string(body))
+	}
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("want no spans, got: %d", len(spans))
+	}
+}
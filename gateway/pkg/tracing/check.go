@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// globalTracesExporter is the WithTracesExporter value given to Provider,
+// which Check consults instead of OTEL_TRACES_EXPORTER
+var globalTracesExporter atomic.Pointer[string]
+
+// Check is a readiness check which fails when OTEL_TRACES_EXPORTER, or
+// WithTracesExporter, asks for spans to be exported, but Provider has not
+// registered a TracerProvider. With WithReadinessGate it also fails until the
+// first spans are exported.
+func Check(ctx context.Context) error {
+	if err := globalGate.Load().Ready(ctx); err != nil {
+		return err
+	}
+
+	value := os.Getenv(otelEnvTraceSExporter)
+	if override := globalTracesExporter.Load(); override != nil {
+		value = *override
+	}
+
+	exporter := Exporter(value)
+	if exporter != OTELExporter && exporter != StdoutExporter {
+		return nil
+	}
+
+	if !enabled() {
+		return fmt.Errorf("%s is %q but no tracer provider is registered", otelEnvTraceSExporter, exporter)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Check_PassesWhenTracingDisabled(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, "")
+
+	if err := Check(context.Background()); err != nil {
+		t.Errorf("want no error when tracing is disabled, got: %s", err)
+	}
+}
+
+func Test_Check_FailsWithoutProvider(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(StdoutExporter))
+
+	if err := Check(context.Background()); err == nil {
+		t.Error("want an error when no tracer provider is registered")
+	}
+}
+
+func Test_Check_PassesWithProvider(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(StdoutExporter))
+	recordSpans(t)
+
+	if err := Check(context.Background()); err != nil {
+		t.Errorf("want no error with a registered provider, got: %s", err)
+	}
+}
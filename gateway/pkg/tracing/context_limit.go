@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	envTraceMaxHeaderBytes = "FAAS_TRACE_MAX_HEADER_BYTES"
+
+	// defaultMaxTraceHeaderBytes is the largest trace context header, in
+	// bytes, which Middleware extracts. It is the limit the W3C sets for
+	// baggage, the largest of the headers.
+	defaultMaxTraceHeaderBytes = 8192
+
+	// droppedLogInterval is the least time between logs of dropped trace
+	// context, so that a caller which always sends an oversized header does
+	// not flood the log
+	droppedLogInterval = time.Minute
+)
+
+// TraceContextDroppedKey is set when the caller's trace context was dropped
+// for being too large, its value is the name of the header over the limit.
+const TraceContextDroppedKey = attribute.Key("faas.trace_context.dropped")
+
+// WithMaxTraceHeaderBytes limits the size of each trace context header, such
+// as traceparent, tracestate and baggage, which Middleware extracts, instead
+// of FAAS_TRACE_MAX_HEADER_BYTES or the default of 8192. When any header is
+// larger, all of them are removed from the request and a new trace is
+// started. A limit of 0 or less extracts headers of any size.
+func WithMaxTraceHeaderBytes(n int) Option {
+	return func(c *config) {
+		c.maxTraceHeaderBytes = &n
+	}
+}
+
+// traceHeaderLimit is the configured limit for trace context headers
+func (c *config) traceHeaderLimit() int {
+	if c.maxTraceHeaderBytes != nil {
+		return *c.maxTraceHeaderBytes
+	}
+
+	val, ok := os.LookupEnv(envTraceMaxHeaderBytes)
+	if !ok {
+		return defaultMaxTraceHeaderBytes
+	}
+
+	limit, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("invalid %s value: %q, using %d", envTraceMaxHeaderBytes, val, defaultMaxTraceHeaderBytes)
+		return defaultMaxTraceHeaderBytes
+	}
+	return limit
+}
+
+// droppedLog logs dropped trace context at most once per interval, with a
+// count of the drops which were not logged since the last time
+type droppedLog struct {
+	interval time.Duration
+
+	lock       sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+func (l *droppedLog) log(now time.Time, field string, limit int, path string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.last.IsZero() && now.Sub(l.last) < l.interval {
+		l.suppressed++
+		return
+	}
+
+	if l.suppressed > 0 {
+		log.Printf("tracing: %s header over %d bytes on request to %s, starting a new trace (%d more since %s)", field, limit, path, l.suppressed, l.last.Format(time.RFC3339))
+	} else {
+		log.Printf("tracing: %s header over %d bytes on request to %s, starting a new trace", field, limit, path)
+	}
+	l.last = now
+	l.suppressed = 0
+}
+
+// dropOversizedTraceContext removes every one of fields, the headers read by
+// the propagator, from header when any of them is over limit bytes, counting
+// all of its values. It returns the name of the first header over the limit.
+func dropOversizedTraceContext(header http.Header, fields []string, limit int) (string, bool) {
+	if limit <= 0 {
+		return "", false
+	}
+
+	oversized := ""
+	for _, field := range fields {
+		size := 0
+		for _, value := range header.Values(field) {
+			size += len(value)
+		}
+		if size > limit {
+			oversized = field
+			break
+		}
+	}
+	if len(oversized) == 0 {
+		return "", false
+	}
+
+	for _, field := range fields {
+		header.Del(field)
+	}
+	return oversized, true
+}
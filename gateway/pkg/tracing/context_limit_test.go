@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const parentTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// traceContextRequest calls Middleware with the given trace context headers,
+// and returns the headers seen by the handler it wraps
+func traceContextRequest(t *testing.T, header http.Header, opts ...Option) http.Header {
+	t.Helper()
+
+	opts = append([]Option{WithPropagators(propagation.TraceContext{}, propagation.Baggage{})}, opts...)
+
+	var seen http.Header
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+	}, opts...)
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	handler(httptest.NewRecorder(), req)
+
+	return seen
+}
+
+func Test_Middleware_ExtractsTraceContextWithinLimit(t *testing.T) {
+	recorder := recordSpans(t)
+
+	seen := traceContextRequest(t, http.Header{
+		"Traceparent": []string{parentTraceparent},
+		"Tracestate":  []string{"vendor=value"},
+		"Baggage":     []string{"tenant=a"},
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("want the caller's trace to be continued, got trace ID: %s", got)
+	}
+	if _, ok := spanAttribute(spans[0], TraceContextDroppedKey); ok {
+		t.Errorf("want no %s attribute", TraceContextDroppedKey)
+	}
+	if got := seen.Get("Baggage"); got != "tenant=a" {
+		t.Errorf("want baggage passed on, got: %q", got)
+	}
+}
+
+func Test_Middleware_DropsOversizedTraceContext(t *testing.T) {
+	recorder := recordSpans(t)
+
+	seen := traceContextRequest(t, http.Header{
+		"Traceparent": []string{parentTraceparent},
+		"Tracestate":  []string{"vendor=" + strings.Repeat("a", 200)},
+		"Baggage":     []string{"tenant=a"},
+	}, WithMaxTraceHeaderBytes(128))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("want a new trace, got the caller's trace ID: %s", got)
+	}
+	if spans[0].Parent().IsValid() {
+		t.Errorf("want a root span, got parent: %s", spans[0].Parent().SpanID())
+	}
+
+	got, ok := spanAttribute(spans[0], TraceContextDroppedKey)
+	if !ok || got.AsString() != "tracestate" {
+		t.Errorf("want %s: tracestate, got: %q", TraceContextDroppedKey, got.AsString())
+	}
+
+	if len(seen.Get("Tracestate")) > 0 || len(seen.Get("Baggage")) > 0 {
+		t.Errorf("want the caller's trace context removed, got tracestate: %q, baggage: %q", seen.Get("Tracestate"), seen.Get("Baggage"))
+	}
+	if !strings.Contains(seen.Get("Traceparent"), spans[0].SpanContext().TraceID().String()) {
+		t.Errorf("want the new trace passed on, got traceparent: %q", seen.Get("Traceparent"))
+	}
+}
+
+func Test_Middleware_CountsEveryValueOfTraceHeader(t *testing.T) {
+	recorder := recordSpans(t)
+
+	traceContextRequest(t, http.Header{
+		"Traceparent": []string{parentTraceparent},
+		"Baggage":     []string{strings.Repeat("a=b,", 20), strings.Repeat("c=d,", 20)},
+	}, WithMaxTraceHeaderBytes(128))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if got, ok := spanAttribute(spans[0], TraceContextDroppedKey); !ok || got.AsString() != "baggage" {
+		t.Errorf("want %s: baggage, got: %q", TraceContextDroppedKey, got.AsString())
+	}
+}
+
+func Test_Middleware_NoTraceHeaderLimit(t *testing.T) {
+	recorder := recordSpans(t)
+
+	traceContextRequest(t, http.Header{
+		"Traceparent": []string{parentTraceparent},
+		"Tracestate":  []string{"vendor=" + strings.Repeat("a", 200)},
+	}, WithMaxTraceHeaderBytes(0))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("want the caller's trace to be continued, got trace ID: %s", got)
+	}
+}
+
+func Test_config_traceHeaderLimit(t *testing.T) {
+	cases := []struct {
+		name  string
+		value *string
+		opts  []Option
+		want  int
+	}{
+		{name: "unset", want: defaultMaxTraceHeaderBytes},
+		{name: "env", value: strPtr("512"), want: 512},
+		{name: "invalid env", value: strPtr("lots"), want: defaultMaxTraceHeaderBytes},
+		{name: "option over env", value: strPtr("512"), opts: []Option{WithMaxTraceHeaderBytes(256)}, want: 256},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unsetEnv(t, envTraceMaxHeaderBytes)
+			if tc.value != nil {
+				t.Setenv(envTraceMaxHeaderBytes, *tc.value)
+			}
+
+			if got := newConfig(tc.opts).traceHeaderLimit(); got != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_droppedLog_LogsOncePerInterval(t *testing.T) {
+	var logged bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logged)
+	defer log.SetOutput(previous)
+
+	l := &droppedLog{interval: time.Minute}
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	l.log(start, "baggage", 128, "/function/figlet")
+	l.log(start.Add(time.Second), "baggage", 128, "/function/figlet")
+	l.log(start.Add(2*time.Second), "tracestate", 128, "/function/figlet")
+
+	if lines := strings.Count(logged.String(), "\n"); lines != 1 {
+		t.Fatalf("want 1 line within the interval, got: %d\n%s", lines, logged.String())
+	}
+
+	logged.Reset()
+	l.log(start.Add(time.Minute), "baggage", 128, "/function/env")
+
+	if !strings.Contains(logged.String(), "/function/env") || !strings.Contains(logged.String(), "2 more") {
+		t.Errorf("want the next drop logged with a count of those not logged, got: %q", logged.String())
+	}
+}
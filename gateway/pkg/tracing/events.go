@@ -0,0 +1,250 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ColdStartEvent is the name of the span event recorded when a request had
+// to wait for a function to scale up from zero replicas.
+const ColdStartEvent = "function.cold_start"
+
+// ColdStartWaitKey is the attribute for the time waited for a function to
+// become ready, in seconds.
+const ColdStartWaitKey = attribute.Key("function.cold_start.wait_seconds")
+
+// AddColdStartEvent records a ColdStartEvent with the time waited on the
+// active span in ctx. It is safe to call when the span is not recording.
+func AddColdStartEvent(ctx context.Context, waited time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(ColdStartEvent, trace.WithAttributes(
+		ColdStartWaitKey.Float64(waited.Seconds()),
+	))
+}
+
+// ColdStartTimedOutKey is set on a ColdStartEvent when the function did not
+// become ready in time, so the request was not sent to it.
+const ColdStartTimedOutKey = attribute.Key("function.cold_start.timed_out")
+
+// AddColdStartTimeoutEvent records a ColdStartEvent for a request which gave
+// up waiting for the function on the active span in ctx. It is safe to call
+// when the span is not recording.
+func AddColdStartTimeoutEvent(ctx context.Context, waited time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(ColdStartEvent, trace.WithAttributes(
+		ColdStartWaitKey.Float64(waited.Seconds()),
+		ColdStartTimedOutKey.Bool(true),
+	))
+}
+
+// ThrottledEvent is the name of the span event recorded when a request is
+// rejected because the function already has its maximum in-flight requests.
+const ThrottledEvent = "throttled"
+
+// ThrottledLimitKey is the attribute for the in-flight limit that was reached.
+const ThrottledLimitKey = attribute.Key("function.max_inflight")
+
+// AddThrottledEvent records a ThrottledEvent with the limit on the active
+// span in ctx. It is safe to call when the span is not recording.
+func AddThrottledEvent(ctx context.Context, limit uint64) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(ThrottledEvent, trace.WithAttributes(
+		ThrottledLimitKey.Int64(int64(limit)),
+	))
+}
+
+// RetryEvent is the name of the span event recorded before a failed request
+// to a function is tried again.
+const RetryEvent = "retry"
+
+// Attributes for a RetryEvent: the attempt which failed, the delay before the
+// next attempt in seconds and why the attempt failed.
+const (
+	RetryAttemptKey = attribute.Key("retry.attempt")
+	RetryDelayKey   = attribute.Key("retry.delay_seconds")
+	RetryReasonKey  = attribute.Key("retry.reason")
+)
+
+// AddRetryEvent records a RetryEvent on the active span in ctx. It is safe
+// to call when the span is not recording.
+func AddRetryEvent(ctx context.Context, attempt int, delay time.Duration, reason string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(RetryEvent, trace.WithAttributes(
+		RetryAttemptKey.Int(attempt),
+		RetryDelayKey.Float64(delay.Seconds()),
+		RetryReasonKey.String(reason),
+	))
+}
+
+// RetryMaxKey is the span attribute for the most attempts a request may
+// take, along with RetryAttemptKey for the attempt which gave its response.
+const RetryMaxKey = attribute.Key("retry.max")
+
+// RetrySucceededEvent is the name of the span event recorded when a request
+// is not tried again after one or more RetryEvents, since the attempt got a
+// response which can be passed on.
+const RetrySucceededEvent = "retry.succeeded"
+
+// SetRetryAttempt records the attempt which gave the response to a request
+// which may be retried, out of max attempts, on the active span in ctx.
+func SetRetryAttempt(ctx context.Context, attempt, max int) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		RetryAttemptKey.Int(attempt),
+		RetryMaxKey.Int(max),
+	)
+}
+
+// AddRetrySucceededEvent records a RetrySucceededEvent for attempt on the
+// active span in ctx. It is safe to call when the span is not recording.
+func AddRetrySucceededEvent(ctx context.Context, attempt int) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(RetrySucceededEvent, trace.WithAttributes(
+		RetryAttemptKey.Int(attempt),
+	))
+}
+
+// TimeoutEvent is the name of the span event recorded when a function did
+// not respond within its execution timeout.
+const TimeoutEvent = "timeout"
+
+// TimeoutKey is the attribute for the execution timeout, in seconds.
+const TimeoutKey = attribute.Key("function.exec_timeout_seconds")
+
+// AddTimeoutEvent records a TimeoutEvent with the configured timeout on the
+// active span in ctx. It is safe to call when the span is not recording.
+func AddTimeoutEvent(ctx context.Context, timeout time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(TimeoutEvent, trace.WithAttributes(
+		TimeoutKey.Float64(timeout.Seconds()),
+	))
+}
+
+// ResolveEvent is the name of the span event recorded when the addresses of
+// a function have been resolved.
+const ResolveEvent = "function.resolve"
+
+// Attributes for a ResolveEvent: the time taken in seconds, and how many
+// addresses were found.
+const (
+	ResolveDurationKey  = attribute.Key("function.resolve.duration_seconds")
+	ResolveEndpointsKey = attribute.Key("function.resolve.endpoints")
+)
+
+// AddResolveEvent records a ResolveEvent on the active span in ctx. It is
+// safe to call when the span is not recording.
+func AddResolveEvent(ctx context.Context, took time.Duration, endpoints int) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(ResolveEvent, trace.WithAttributes(
+		ResolveDurationKey.Float64(took.Seconds()),
+		ResolveEndpointsKey.Int(endpoints),
+	))
+}
+
+// DeadLetteredEvent is the name of the span event recorded when a queued
+// request has failed on every attempt and is published to a dead-letter
+// queue instead of being redelivered.
+const DeadLetteredEvent = "dead_lettered"
+
+// Attributes for a DeadLetteredEvent: how many times the request was tried
+// and the subject it was published to.
+const (
+	DeadLetterAttemptsKey = attribute.Key("messaging.dead_letter.attempts")
+	DeadLetterSubjectKey  = attribute.Key("messaging.dead_letter.subject")
+)
+
+// AddDeadLetteredEvent records a DeadLetteredEvent on the active span in
+// ctx. It is safe to call when the span is not recording.
+func AddDeadLetteredEvent(ctx context.Context, attempts int, subject string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(DeadLetteredEvent, trace.WithAttributes(
+		DeadLetterAttemptsKey.Int(attempts),
+		DeadLetterSubjectKey.String(subject),
+	))
+}
+
+// CoalescedEvent is the name of the span event recorded when a request was
+// answered with the response to an identical request already in progress,
+// rather than making its own call to the function.
+const CoalescedEvent = "coalesced"
+
+// AddCoalescedEvent records a CoalescedEvent on the active span in ctx. It
+// is safe to call when the span is not recording.
+func AddCoalescedEvent(ctx context.Context) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent(CoalescedEvent)
+}
+
+// FilterEvent is the name of the span event recorded for each filter run on
+// a request to a function, or on its response.
+const FilterEvent = "filter"
+
+// Attributes for a FilterEvent: the filter's name, whether it ran on the
+// "request" or the "response", and the error it returned.
+const (
+	FilterNameKey  = attribute.Key("faas.filter.name")
+	FilterPhaseKey = attribute.Key("faas.filter.phase")
+	FilterErrorKey = attribute.Key("faas.filter.error")
+)
+
+// AddFilterEvent records a FilterEvent on the active span in ctx, with err
+// when the filter failed. It is safe to call when the span is not recording.
+func AddFilterEvent(ctx context.Context, name, phase string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		FilterNameKey.String(name),
+		FilterPhaseKey.String(phase),
+	}
+	if err != nil {
+		attrs = append(attrs, FilterErrorKey.String(err.Error()))
+	}
+
+	span.AddEvent(FilterEvent, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func Test_AddColdStartEvent_RecordsEventOnActiveSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "/function/figlet")
+	AddColdStartEvent(ctx, time.Millisecond*1500)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	events := spans[0].Events()
+	if len(events) != 1 {
+		t.Fatalf("want 1 event, got: %d", len(events))
+	}
+
+	if events[0].Name != ColdStartEvent {
+		t.Errorf("want event: %s, got: %s", ColdStartEvent, events[0].Name)
+	}
+
+	if len(events[0].Attributes) != 1 || events[0].Attributes[0].Key != ColdStartWaitKey {
+		t.Fatalf("want a %s attribute, got: %v", ColdStartWaitKey, events[0].Attributes)
+	}
+	if got := events[0].Attributes[0].Value.AsFloat64(); got != 1.5 {
+		t.Errorf("want %s: 1.5, got: %f", ColdStartWaitKey, got)
+	}
+}
+
+func Test_AddColdStartEvent_NoopWithoutSpan(t *testing.T) {
+	AddColdStartEvent(context.Background(), time.Second)
+}
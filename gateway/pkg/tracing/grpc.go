@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+)
+
+// grpcTransport starts a client span for each gRPC call made to a function
+type grpcTransport struct {
+	base       http.RoundTripper
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	limit      int
+}
+
+// GRPCTransport wraps base so that each gRPC call is recorded as a client
+// span, named after the call's service and method, with the gRPC semantic
+// conventions in place of the HTTP ones used by Transport. The span ends
+// once the response has been read to the end, or closed, so that it covers
+// a streaming call, and records the grpc-status sent by the function.
+//
+// The span context is injected into the call's metadata, its HTTP/2
+// headers, with the same propagator as Transport. When tracing is disabled
+// base is returned unchanged.
+func GRPCTransport(base http.RoundTripper, opts ...Option) http.RoundTripper {
+	cfg := newConfig(opts)
+	if !cfg.tracingEnabled() {
+		return base
+	}
+
+	return &grpcTransport{
+		base:       base,
+		tracer:     cfg.tracer(),
+		propagator: cfg.propagator(),
+		limit:      cfg.attributeLimit(),
+	}
+}
+
+// RoundTrip starts the call's span, which is ended by the response body
+func (t *grpcTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	service, method := grpcMethod(r.URL.Path)
+
+	ctx, span := t.tracer.Start(r.Context(), truncate(strings.TrimPrefix(r.URL.Path, "/"), t.limit),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			truncatedString(semconv.RPCServiceKey, service, t.limit),
+			truncatedString(semconv.RPCMethodKey, method, t.limit),
+			truncatedString(semconv.ServerAddressKey, r.URL.Hostname(), t.limit),
+			semconv.ServerPort(serverPort(r.URL)),
+		),
+	)
+
+	// A RoundTripper must not modify the request it was given.
+	r = r.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	res, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return res, err
+	}
+
+	res.Body = &grpcBody{ReadCloser: res.Body, res: res, span: span}
+	return res, nil
+}
+
+// grpcMethod splits a gRPC path, /package.Service/Method, into its service
+// and method
+func grpcMethod(path string) (service, method string) {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// grpcBody ends the call's span once the response has been read, when the
+// trailers with the grpc-status are available
+type grpcBody struct {
+	io.ReadCloser
+
+	res  *http.Response
+	span trace.Span
+	once sync.Once
+}
+
+func (b *grpcBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.end(err)
+	}
+	return n, err
+}
+
+func (b *grpcBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end(nil)
+	return err
+}
+
+// end records the grpc-status from the trailers, or from the headers of a
+// trailers-only response, then ends the span
+func (b *grpcBody) end(err error) {
+	b.once.Do(func() {
+		defer b.span.End()
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			b.span.RecordError(err)
+			b.span.SetStatus(codes.Error, err.Error())
+			return
+		}
+
+		value := b.res.Trailer.Get("Grpc-Status")
+		if len(value) == 0 {
+			value = b.res.Header.Get("Grpc-Status")
+		}
+
+		status, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return
+		}
+
+		b.span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(status))
+		if code := grpccodes.Code(status); code != grpccodes.OK {
+			b.span.SetStatus(codes.Error, code.String())
+		}
+	})
+}
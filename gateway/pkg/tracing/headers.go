@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	envTraceCapturedHeaders = "FAAS_TRACE_CAPTURED_HEADERS"
+	envTraceDeniedHeaders   = "FAAS_TRACE_DENIED_HEADERS"
+
+	requestHeaderPrefix  = "http.request.header."
+	responseHeaderPrefix = "http.response.header."
+)
+
+// DefaultDeniedHeaders are not captured by Middleware, even with a
+// wildcard, since they carry credentials. A header named in the allow list
+// is captured anyway.
+var DefaultDeniedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// WithCapturedHeaders records the request and response headers named by
+// Middleware, as http.request.header.<name> and http.response.header.<name>
+// with the name in lower case, instead of FAAS_TRACE_CAPTURED_HEADERS. Give
+// "*" to capture every header except those denied. No headers are captured
+// by default.
+func WithCapturedHeaders(names ...string) Option {
+	return func(c *config) {
+		c.capturedHeaders = append(c.capturedHeaders, names...)
+	}
+}
+
+// WithDeniedHeaders replaces DefaultDeniedHeaders, instead of
+// FAAS_TRACE_DENIED_HEADERS. Headers named by WithCapturedHeaders take
+// precedence, so a header which is in both lists is captured.
+func WithDeniedHeaders(names ...string) Option {
+	return func(c *config) {
+		c.deniedHeaders = append([]string{}, names...)
+	}
+}
+
+// headerFilter decides which headers are captured on spans
+type headerFilter struct {
+	allow map[string]bool
+	all   bool
+	deny  map[string]bool
+}
+
+// headerFilter is made of the allow and deny lists given as options, or by
+// FAAS_TRACE_CAPTURED_HEADERS and FAAS_TRACE_DENIED_HEADERS
+func (c *config) headerFilter() headerFilter {
+	allow := c.capturedHeaders
+	if allow == nil {
+		allow = splitList(os.Getenv(envTraceCapturedHeaders))
+	}
+
+	deny := c.deniedHeaders
+	if deny == nil {
+		deny = DefaultDeniedHeaders
+		if val, ok := os.LookupEnv(envTraceDeniedHeaders); ok {
+			deny = splitList(val)
+		}
+	}
+
+	f := headerFilter{allow: map[string]bool{}, deny: map[string]bool{}}
+	for _, name := range allow {
+		if name == "*" {
+			f.all = true
+			continue
+		}
+		f.allow[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range deny {
+		f.deny[http.CanonicalHeaderKey(name)] = true
+	}
+	return f
+}
+
+// enabled is false when no headers are to be captured
+func (f headerFilter) enabled() bool {
+	return f.all || len(f.allow) > 0
+}
+
+func (f headerFilter) captures(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if f.allow[name] {
+		return true
+	}
+	return f.all && !f.deny[name]
+}
+
+// attributes for each captured header, in order of their names, with each
+// value shortened to limit
+func (f headerFilter) attributes(prefix string, header http.Header, limit int) []attribute.KeyValue {
+	if !f.enabled() {
+		return nil
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if f.captures(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	attrs := make([]attribute.KeyValue, 0, len(names))
+	for _, name := range names {
+		values := make([]string, 0, len(header[name]))
+		for _, value := range header[name] {
+			values = append(values, truncate(value, limit))
+		}
+		attrs = append(attrs, attribute.StringSlice(prefix+strings.ToLower(name), values))
+	}
+	return attrs
+}
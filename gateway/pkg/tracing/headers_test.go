@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// capturedHeaders serves a request with credentials through Middleware, and
+// returns the header attributes on its span
+func capturedHeaders(t *testing.T, opts ...Option) map[attribute.Key][]string {
+	t.Helper()
+	unsetEnv(t, envTraceCapturedHeaders)
+	unsetEnv(t, envTraceDeniedHeaders)
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.Header().Set("X-Served-By", "figlet-1")
+	}, opts...)
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set("Authorization", "Bearer t0k3n")
+	req.Header.Set("Cookie", "session=s3cr3t")
+	req.Header.Set("X-Api-Key", "k3y")
+	req.Header.Add("X-Tenant", "acme")
+	req.Header.Add("X-Tenant", "globex")
+	handler(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	got := map[attribute.Key][]string{}
+	for _, kv := range spans[0].Attributes() {
+		if kv.Value.Type() == attribute.STRINGSLICE {
+			got[kv.Key] = kv.Value.AsStringSlice()
+		}
+	}
+	return got
+}
+
+func Test_Middleware_CapturesAllowedHeaders(t *testing.T) {
+	got := capturedHeaders(t, WithCapturedHeaders("x-tenant", "X-Served-By"))
+
+	if v := got["http.request.header.x-tenant"]; len(v) != 2 || v[0] != "acme" || v[1] != "globex" {
+		t.Errorf("want every X-Tenant value, got: %v", v)
+	}
+	if v := got["http.response.header.x-served-by"]; len(v) != 1 || v[0] != "figlet-1" {
+		t.Errorf("want the X-Served-By response header, got: %v", v)
+	}
+	if _, ok := got["http.request.header.x-api-key"]; ok {
+		t.Errorf("want headers which are not allowed left out, got: %v", got)
+	}
+}
+
+func Test_Middleware_CapturesNoHeadersByDefault(t *testing.T) {
+	if got := capturedHeaders(t); len(got) != 0 {
+		t.Errorf("want no headers captured, got: %v", got)
+	}
+}
+
+func Test_Middleware_RedactsDefaultDeniedHeaders(t *testing.T) {
+	got := capturedHeaders(t, WithCapturedHeaders("*"))
+
+	for _, key := range []attribute.Key{"http.request.header.authorization", "http.request.header.cookie", "http.response.header.set-cookie"} {
+		if v, ok := got[key]; ok {
+			t.Errorf("want %s left out, got: %v", key, v)
+		}
+	}
+	if _, ok := got["http.request.header.x-api-key"]; !ok {
+		t.Errorf("want other headers captured with a wildcard, got: %v", got)
+	}
+}
+
+func Test_Middleware_DeniedHeaders(t *testing.T) {
+	got := capturedHeaders(t, WithCapturedHeaders("*"), WithDeniedHeaders("X-Api-Key", "Cookie"))
+
+	if v, ok := got["http.request.header.x-api-key"]; ok {
+		t.Errorf("want X-Api-Key denied, got: %v", v)
+	}
+	if _, ok := got["http.request.header.authorization"]; !ok {
+		t.Errorf("want the default deny list replaced, got: %v", got)
+	}
+}
+
+func Test_Middleware_AllowedHeadersTakePrecedence(t *testing.T) {
+	got := capturedHeaders(t, WithCapturedHeaders("*", "Authorization"))
+
+	if v := got["http.request.header.authorization"]; len(v) != 1 || v[0] != "Bearer t0k3n" {
+		t.Errorf("want an allowed header captured although it is denied, got: %v", v)
+	}
+	if v, ok := got["http.request.header.cookie"]; ok {
+		t.Errorf("want Cookie still denied, got: %v", v)
+	}
+}
+
+func Test_Middleware_CapturedHeadersFromEnv(t *testing.T) {
+	unsetEnv(t, envTraceCapturedHeaders)
+	unsetEnv(t, envTraceDeniedHeaders)
+	t.Setenv(envTraceCapturedHeaders, "X-Tenant, Cookie")
+	t.Setenv(envTraceDeniedHeaders, "X-Tenant")
+
+	filter := newConfig(nil).headerFilter()
+	for name, want := range map[string]bool{"X-Tenant": true, "cookie": true, "Authorization": false} {
+		if got := filter.captures(name); got != want {
+			t.Errorf("%s: want captured: %t, got: %t", name, want, got)
+		}
+	}
+}
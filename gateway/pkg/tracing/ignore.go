@@ -0,0 +1,34 @@
+package tracing
+
+import "strings"
+
+// DefaultIgnoredPaths are not traced by Middleware, since liveness probes and
+// Prometheus scrapes would otherwise drown out function invocations, and
+// profiles would be skewed by being traced.
+var DefaultIgnoredPaths = []string{"/healthz", "/readyz", "/metrics", "/debug/pprof"}
+
+// isIgnoredPath reports whether path is one of the prefixes, or below one of
+// them, so /metrics matches /metrics/ but not /metrics-exporter.
+func isIgnoredPath(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if len(prefix) == 0 {
+			continue
+		}
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList parses a comma separated list, dropping empty values.
+func splitList(val string) []string {
+	out := []string{}
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Middleware_IgnoredPaths(t *testing.T) {
+	cases := []struct {
+		name      string
+		path      string
+		env       string
+		opts      []Option
+		wantSpans int
+	}{
+		{name: "function path is traced", path: "/function/figlet", wantSpans: 1},
+		{name: "healthz is ignored", path: "/healthz", wantSpans: 0},
+		{name: "readyz is ignored", path: "/readyz", wantSpans: 0},
+		{name: "metrics is ignored", path: "/metrics", wantSpans: 0},
+		{name: "pprof is ignored", path: "/debug/pprof/heap", wantSpans: 0},
+		{name: "sub-path of an ignored prefix", path: "/metrics/extra", wantSpans: 0},
+		{name: "prefix must end on a segment", path: "/metrics-exporter", wantSpans: 1},
+		{name: "option replaces defaults", path: "/healthz", opts: []Option{WithIgnoredPaths("/system/info")}, wantSpans: 1},
+		{name: "option ignores its paths", path: "/system/info", opts: []Option{WithIgnoredPaths("/system/info")}, wantSpans: 0},
+		{name: "empty option traces everything", path: "/healthz", opts: []Option{WithIgnoredPaths()}, wantSpans: 1},
+		{name: "env replaces defaults", path: "/metrics", env: "/system/info, /ping", wantSpans: 1},
+		{name: "env ignores its paths", path: "/ping", env: "/system/info, /ping", wantSpans: 0},
+		{name: "option wins over env", path: "/ping", env: "/ping", opts: []Option{WithIgnoredPaths("/healthz")}, wantSpans: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unsetEnv(t, envTraceIgnoredPaths)
+			if len(tc.env) > 0 {
+				t.Setenv(envTraceIgnoredPaths, tc.env)
+			}
+			recorder := recordSpans(t)
+
+			called := false
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}, tc.opts...)
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if !called {
+				t.Fatalf("want the request to reach the next handler")
+			}
+			if got := len(recorder.Ended()); got != tc.wantSpans {
+				t.Errorf("want %d spans, got: %d", tc.wantSpans, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const envTraceLatencyThreshold = "FAAS_TRACE_LATENCY_THRESHOLD"
+
+var (
+	// latencyFilterMaxSpans bounds the spans held back while waiting for
+	// their local root span to end, past it spans are exported undecided
+	latencyFilterMaxSpans = 4096
+
+	// latencyFilterMaxAge is how long spans are held back for a local root
+	// span which has not ended, such as for a stream which is still open
+	latencyFilterMaxAge = 30 * time.Second
+)
+
+// WithLatencyThreshold only exports the spans of requests which errored or
+// took at least threshold, instead of FAAS_TRACE_LATENCY_THRESHOLD. Every
+// request still starts a span, so trace ids are propagated as usual.
+//
+// This is a best-effort heuristic, not tail sampling: the decision is made
+// within the gateway, from the spans it recorded, once the local root span of
+// a trace ends. Spans of the same trace recorded by other services are
+// exported or dropped by their own rules, so use a collector's tail sampling
+// for whole traces.
+func WithLatencyThreshold(threshold time.Duration) Option {
+	return func(c *config) {
+		c.latencyFilterThreshold = threshold
+	}
+}
+
+// latencyThreshold is 0 when every sampled span is exported
+func (c *config) latencyThreshold() time.Duration {
+	if c.latencyFilterThreshold > 0 {
+		return c.latencyFilterThreshold
+	}
+
+	val, ok := os.LookupEnv(envTraceLatencyThreshold)
+	if !ok {
+		return 0
+	}
+
+	threshold, err := time.ParseDuration(val)
+	if err != nil || threshold < 0 {
+		log.Printf("invalid %s value: %q, all sampled spans will be exported", envTraceLatencyThreshold, val)
+		return 0
+	}
+	return threshold
+}
+
+// latencyFilter holds back ended spans until the local root span of their
+// trace ends, then passes them on to the next processor only when one of the
+// spans errored or took at least the threshold.
+type latencyFilter struct {
+	tracesdk.SpanProcessor
+	threshold time.Duration
+
+	mu        sync.Mutex
+	traces    map[trace.TraceID]*heldTrace
+	held      int
+	lastSweep time.Time
+}
+
+// heldTrace is the ended spans of a trace whose local root is still open
+type heldTrace struct {
+	spans   []tracesdk.ReadOnlySpan
+	keep    bool
+	started time.Time
+}
+
+func newLatencyFilter(next tracesdk.SpanProcessor, threshold time.Duration) *latencyFilter {
+	return &latencyFilter{
+		SpanProcessor: next,
+		threshold:     threshold,
+		traces:        map[trace.TraceID]*heldTrace{},
+		lastSweep:     time.Now(),
+	}
+}
+
+func (f *latencyFilter) OnEnd(s tracesdk.ReadOnlySpan) {
+	// the batcher only queues sampled spans
+	if !s.SpanContext().IsSampled() {
+		f.SpanProcessor.OnEnd(s)
+		return
+	}
+
+	keep := f.interesting(s)
+	id := s.SpanContext().TraceID()
+	localRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+
+	f.mu.Lock()
+	t := f.traces[id]
+	if !localRoot {
+		if f.held >= latencyFilterMaxSpans {
+			f.mu.Unlock()
+			f.SpanProcessor.OnEnd(s)
+			return
+		}
+
+		if t == nil {
+			t = &heldTrace{started: time.Now()}
+			f.traces[id] = t
+		}
+		t.spans = append(t.spans, s)
+		t.keep = t.keep || keep
+		f.held++
+		f.mu.Unlock()
+		return
+	}
+
+	if t != nil {
+		delete(f.traces, id)
+		f.held -= len(t.spans)
+		keep = keep || t.keep
+	}
+	expired := f.sweep(time.Now())
+	f.mu.Unlock()
+
+	for _, e := range expired {
+		f.release(e)
+	}
+
+	if !keep {
+		return
+	}
+	if t != nil {
+		for _, child := range t.spans {
+			f.SpanProcessor.OnEnd(child)
+		}
+	}
+	f.SpanProcessor.OnEnd(s)
+}
+
+// interesting is true for a span which errored or took at least the threshold
+func (f *latencyFilter) interesting(s tracesdk.ReadOnlySpan) bool {
+	return s.Status().Code == codes.Error || s.EndTime().Sub(s.StartTime()) >= f.threshold
+}
+
+// sweep removes the traces held for longer than latencyFilterMaxAge, at
+// most once every latencyFilterMaxAge. It must be called with mu held.
+func (f *latencyFilter) sweep(now time.Time) []*heldTrace {
+	if now.Sub(f.lastSweep) < latencyFilterMaxAge {
+		return nil
+	}
+	f.lastSweep = now
+
+	var expired []*heldTrace
+	for id, t := range f.traces {
+		if now.Sub(t.started) >= latencyFilterMaxAge {
+			delete(f.traces, id)
+			f.held -= len(t.spans)
+			expired = append(expired, t)
+		}
+	}
+	return expired
+}
+
+// release passes on the spans of a trace whose local root never ended, when
+// one of them was interesting
+func (f *latencyFilter) release(t *heldTrace) {
+	if !t.keep {
+		return
+	}
+	for _, s := range t.spans {
+		f.SpanProcessor.OnEnd(s)
+	}
+}
+
+// Shutdown passes on the interesting spans still held, before the next
+// processor is flushed
+func (f *latencyFilter) Shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	held := f.traces
+	f.traces = map[trace.TraceID]*heldTrace{}
+	f.held = 0
+	f.mu.Unlock()
+
+	for _, t := range held {
+		f.release(t)
+	}
+	return f.SpanProcessor.Shutdown(ctx)
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// latencyFilterPipeline exports to an in-memory exporter, only keeping the
+// spans of requests which errored or took at least threshold
+func latencyFilterPipeline(t *testing.T, threshold time.Duration) (*Pipeline, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithExporter(exporter),
+		WithLatencyThreshold(threshold),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pipeline.Shutdown(context.Background()) })
+	return pipeline, exporter
+}
+
+func Test_LatencyFilter_ExportsSlowRequest(t *testing.T) {
+	pipeline, exporter := latencyFilterPipeline(t, 20*time.Millisecond)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		_, child := pipeline.TracerProvider.Tracer(TracerName).Start(r.Context(), "child")
+		child.End()
+		time.Sleep(30 * time.Millisecond)
+	}, WithTracerProvider(pipeline.TracerProvider))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 2 {
+		t.Fatalf("want the request and child spans of a slow request, got: %d", len(spans))
+	}
+}
+
+func Test_LatencyFilter_DropsFastSuccess(t *testing.T) {
+	pipeline, exporter := latencyFilterPipeline(t, time.Second)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		_, child := pipeline.TracerProvider.Tracer(TracerName).Start(r.Context(), "child")
+		child.End()
+	}, WithTracerProvider(pipeline.TracerProvider))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("want no spans for a fast successful request, got: %d", len(spans))
+	}
+}
+
+func Test_LatencyFilter_ExportsFastError(t *testing.T) {
+	pipeline, exporter := latencyFilterPipeline(t, time.Second)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		_, child := pipeline.TracerProvider.Tracer(TracerName).Start(r.Context(), "child")
+		child.SetStatus(codes.Error, "unreachable")
+		child.End()
+		w.WriteHeader(http.StatusOK)
+	}, WithTracerProvider(pipeline.TracerProvider))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 2 {
+		t.Fatalf("want the whole trace when a child span errored, got: %d", len(spans))
+	}
+}
+
+func Test_LatencyFilter_ExportsServerError(t *testing.T) {
+	pipeline, exporter := latencyFilterPipeline(t, time.Second)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, WithTracerProvider(pipeline.TracerProvider))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 1 {
+		t.Fatalf("want the span of a request which failed, got: %d", len(spans))
+	}
+}
+
+// endedSpans records the spans passed on to it
+type endedSpans struct {
+	spans []tracesdk.ReadOnlySpan
+}
+
+func (p *endedSpans) OnStart(context.Context, tracesdk.ReadWriteSpan) {}
+func (p *endedSpans) OnEnd(s tracesdk.ReadOnlySpan)                   { p.spans = append(p.spans, s) }
+func (p *endedSpans) Shutdown(context.Context) error                  { return nil }
+func (p *endedSpans) ForceFlush(context.Context) error                { return nil }
+
+func Test_LatencyFilter_ReleasesHeldSpansOnShutdown(t *testing.T) {
+	ended := &endedSpans{}
+	filter := newLatencyFilter(ended, time.Second)
+	provider := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(filter))
+	tracer := provider.Tracer(TracerName)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	defer root.End()
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+
+	if len(ended.spans) != 0 {
+		t.Fatalf("want the child held until its root ends, got: %d spans", len(ended.spans))
+	}
+
+	if err := filter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ended.spans) != 1 {
+		t.Fatalf("want the errored child passed on at shutdown, got: %d spans", len(ended.spans))
+	}
+}
+
+func Test_LatencyThreshold_FromEnv(t *testing.T) {
+	t.Setenv(envTraceLatencyThreshold, "250ms")
+
+	if got := newConfig(nil).latencyThreshold(); got != 250*time.Millisecond {
+		t.Errorf("want threshold: 250ms, got: %s", got)
+	}
+}
+
+func Test_LatencyThreshold_InvalidEnv(t *testing.T) {
+	t.Setenv(envTraceLatencyThreshold, "slow")
+
+	if got := newConfig(nil).latencyThreshold(); got != 0 {
+		t.Errorf("want no threshold, got: %s", got)
+	}
+}
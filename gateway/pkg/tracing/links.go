@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartLinkedSpan starts a span for one of several operations fanned out
+// from a request, such as each function called to build an aggregated
+// response. Rather than being nested under the span in ctx, the span starts
+// a new trace which is linked to it, and to each of links, so that a slow
+// or failed call can be followed back to the request which made it. The
+// returned context carries the new span, for the calls it makes.
+//
+// Spans are started with the global TracerProvider registered by Provider.
+func StartLinkedSpan(ctx context.Context, name string, links ...trace.Link) (context.Context, trace.Span) {
+	if parent := trace.SpanContextFromContext(ctx); parent.IsValid() {
+		links = append([]trace.Link{NewLink(parent)}, links...)
+	}
+
+	return otel.Tracer(TracerName).Start(ctx, name,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithLinks(links...),
+	)
+}
+
+// NewLink links to the span with sc, attributes describe how the spans are
+// related
+func NewLink(sc trace.SpanContext, attributes ...attribute.KeyValue) trace.Link {
+	return trace.Link{SpanContext: sc, Attributes: attributes}
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func Test_StartLinkedSpan_LinksToParentAndOthers(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "aggregate")
+	_, other := otel.Tracer("test").Start(context.Background(), "batch")
+	other.End()
+
+	_, span := StartLinkedSpan(ctx, "call figlet", NewLink(other.SpanContext(), attribute.String("faas.link", "batch")))
+	span.End()
+	parent.End()
+
+	var linked []string
+	for _, s := range recorder.Ended() {
+		if s.Name() != "call figlet" {
+			continue
+		}
+
+		if s.Parent().IsValid() {
+			t.Errorf("want a new root span, got parent: %s", s.Parent().SpanID())
+		}
+		if s.SpanContext().TraceID() == parent.SpanContext().TraceID() {
+			t.Errorf("want a new trace, got the parent's trace: %s", s.SpanContext().TraceID())
+		}
+
+		links := s.Links()
+		if len(links) != 2 {
+			t.Fatalf("want 2 links, got: %d", len(links))
+		}
+		if links[0].SpanContext.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("want the first link to the parent, got: %s", links[0].SpanContext.SpanID())
+		}
+		if links[1].SpanContext.SpanID() != other.SpanContext().SpanID() {
+			t.Errorf("want the second link to the batch span, got: %s", links[1].SpanContext.SpanID())
+		}
+		if len(links[1].Attributes) != 1 || links[1].Attributes[0].Value.AsString() != "batch" {
+			t.Errorf("want the link's attributes kept, got: %v", links[1].Attributes)
+		}
+		linked = append(linked, s.Name())
+	}
+
+	if len(linked) != 1 {
+		t.Fatalf("want 1 linked span, got: %d", len(linked))
+	}
+}
+
+func Test_StartLinkedSpan_WithoutParent(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := StartLinkedSpan(context.Background(), "call figlet")
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if got := len(spans[0].Links()); got != 0 {
+		t.Errorf("want no links without a parent, got: %d", got)
+	}
+}
@@ -0,0 +1,345 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	otelEnvLogsExporter     = "OTEL_LOGS_EXPORTER"
+	otelEnvOTLPLogsEndpoint = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+	otelEnvOTLPHeaders      = "OTEL_EXPORTER_OTLP_HEADERS"
+	otelEnvOTLPInsecure     = "OTEL_EXPORTER_OTLP_INSECURE"
+
+	defaultLogBatchInterval = time.Second
+	defaultLogBatchSize     = 512
+	defaultLogQueueSize     = 2048
+)
+
+// LogRecord is a line logged by the gateway
+type LogRecord struct {
+	Timestamp time.Time
+	Body      string
+}
+
+// LogExporter sends batches of log records to a backend
+type LogExporter interface {
+	Export(ctx context.Context, records []LogRecord) error
+	Shutdown(ctx context.Context) error
+}
+
+// LogProvider batches log records to a LogExporter. It is an io.Writer, with
+// each write being one record, so that it can bridge the standard logger,
+// which writes one line at a time, through log.SetOutput. Records are
+// dropped rather than blocking the caller when the queue is full.
+type LogProvider struct {
+	exporter LogExporter
+
+	mu      sync.Mutex
+	queue   []LogRecord
+	stopped bool
+
+	// exportMu serialises calls to the exporter
+	exportMu sync.Mutex
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewLogProvider starts batching records to exporter
+func NewLogProvider(exporter LogExporter) *LogProvider {
+	p := &LogProvider{
+		exporter: exporter,
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Emit queues a record for the next batch
+func (p *LogProvider) Emit(record LogRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped || len(p.queue) >= defaultLogQueueSize {
+		return
+	}
+	p.queue = append(p.queue, record)
+
+	if len(p.queue) >= defaultLogBatchSize {
+		select {
+		case p.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Write emits b as one record, without its trailing newline
+func (p *LogProvider) Write(b []byte) (int, error) {
+	p.Emit(LogRecord{
+		Timestamp: time.Now(),
+		Body:      strings.TrimSuffix(string(b), "\n"),
+	})
+	return len(b), nil
+}
+
+// ForceFlush exports the queued records
+func (p *LogProvider) ForceFlush(ctx context.Context) error {
+	p.mu.Lock()
+	records := p.queue
+	p.queue = nil
+	p.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	p.exportMu.Lock()
+	defer p.exportMu.Unlock()
+	return p.exporter.Export(ctx, records)
+}
+
+// Shutdown stops batching, exports the queued records and shuts down the
+// exporter. Records emitted afterwards are dropped.
+func (p *LogProvider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.done
+
+	return errors.Join(p.ForceFlush(ctx), p.exporter.Shutdown(ctx))
+}
+
+func (p *LogProvider) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(defaultLogBatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		case <-p.flush:
+		}
+
+		if err := p.ForceFlush(context.Background()); err != nil {
+			// not through the standard logger, which may be bridged to p
+			fmt.Fprintf(os.Stderr, "failed to export log records: %s\n", err)
+		}
+	}
+}
+
+// newLogProvider starts the log pipeline selected by WithLogExporter, or
+// OTEL_LOGS_EXPORTER=otlp, using the same resource and OTLP settings as the
+// traces. It returns nil when logs are not exported.
+func newLogProvider(ctx context.Context, name, version, commit string, cfg *config) (*LogProvider, error) {
+	exporter := cfg.logExporter
+	if exporter == nil {
+		if Exporter(strings.ToLower(get(otelEnvLogsExporter, "none"))) != OTELExporter {
+			return nil, nil
+		}
+
+		res, err := newResource(name, version, commit, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		exporter, err = newOTLPLogExporter(ctx, cfg.otlpProtocol(), res, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewLogProvider(exporter), nil
+}
+
+// withLogs bridges the standard logger to logs, and extends shutdown to
+// restore the logger then flush logs after the traces
+func withLogs(shutdown Shutdown, logs *LogProvider) Shutdown {
+	if logs == nil {
+		return shutdown
+	}
+
+	previous := log.Writer()
+	log.SetOutput(io.MultiWriter(previous, logs))
+
+	timeout := shutdownTimeout()
+	return func(ctx context.Context) {
+		shutdown(ctx)
+
+		log.SetOutput(previous)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := logs.Shutdown(ctx); err != nil {
+			log.Printf("failed to shutdown log provider: %v", err)
+		}
+	}
+}
+
+// withLogShutdown extends shutdown to flush logs after the traces, without
+// bridging the standard logger to them
+func withLogShutdown(shutdown Shutdown, logs *LogProvider) Shutdown {
+	if logs == nil {
+		return shutdown
+	}
+
+	timeout := shutdownTimeout()
+	return func(ctx context.Context) {
+		shutdown(ctx)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := logs.Shutdown(ctx); err != nil {
+			log.Printf("failed to shutdown log provider: %v", err)
+		}
+	}
+}
+
+// otlpLogExporter sends log records to an OTLP collector over gRPC or HTTP
+type otlpLogExporter struct {
+	resource *resourcepb.Resource
+	client   otlpLogClient
+}
+
+// otlpLogClient sends an export request with one of the OTLP protocols
+type otlpLogClient interface {
+	export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	shutdown(ctx context.Context) error
+}
+
+// newOTLPLogExporter creates an OTLP log exporter for the given protocol,
+// accepting the same values as newOTLPExporter
+func newOTLPLogExporter(ctx context.Context, protocol string, res *resource.Resource, cfg *config) (LogExporter, error) {
+	var client otlpLogClient
+	var err error
+
+	switch strings.ToLower(strings.TrimSpace(protocol)) {
+	case "", "grpc":
+		client, err = newOTLPLogGRPCClient(ctx, cfg)
+	case "http", "http/protobuf":
+		client, err = newOTLPLogHTTPClient(cfg)
+	default:
+		err = fmt.Errorf("unsupported %s value: %q, use one of grpc, http/protobuf", otelExpOTLPProtocol, protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &otlpLogExporter{
+		resource: &resourcepb.Resource{Attributes: otlpAttributes(res.Attributes())},
+		client:   client,
+	}, nil
+}
+
+func (e *otlpLogExporter) Export(ctx context.Context, records []LogRecord) error {
+	logRecords := make([]*logspb.LogRecord, 0, len(records))
+	observed := uint64(time.Now().UnixNano())
+
+	for _, record := range records {
+		logRecords = append(logRecords, &logspb.LogRecord{
+			TimeUnixNano:         uint64(record.Timestamp.UnixNano()),
+			ObservedTimeUnixNano: observed,
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: record.Body}},
+		})
+	}
+
+	return e.client.export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: TracerName},
+				LogRecords: logRecords,
+			}},
+		}},
+	})
+}
+
+func (e *otlpLogExporter) Shutdown(ctx context.Context) error {
+	return e.client.shutdown(ctx)
+}
+
+// otlpInsecure reports whether the collector is connected to without TLS,
+// from WithOTLPInsecure, OTEL_EXPORTER_OTLP_INSECURE or an http:// endpoint
+func otlpInsecure(cfg *config, endpoint string) bool {
+	if cfg.otlp.insecure || strings.HasPrefix(strings.ToLower(endpoint), "http://") {
+		return true
+	}
+	return strings.ToLower(get(otelEnvOTLPInsecure, "false")) == "true"
+}
+
+// otlpTLSConfig is the TLS configuration given with WithOTLPTLSConfig, or
+// the system defaults
+func otlpTLSConfig(cfg *config) *tls.Config {
+	if cfg.otlp.tlsConfig != nil {
+		return cfg.otlp.tlsConfig
+	}
+	return &tls.Config{}
+}
+
+// otlpHeaders merges OTEL_EXPORTER_OTLP_HEADERS, a comma separated list of
+// key=value pairs, with the headers given as options, which take precedence
+func otlpHeaders(cfg *config) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range splitList(os.Getenv(otelEnvOTLPHeaders)) {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	for k, v := range cfg.otlp.headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+// otlpAttributes converts attributes into their OTLP form
+func otlpAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: otlpValue(kv.Value)})
+	}
+	return out
+}
+
+func otlpValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
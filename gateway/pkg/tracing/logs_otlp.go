@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const otlpLogsExportTimeout = 10 * time.Second
+
+// signalEndpoint is the endpoint configured for one signal, following the
+// same precedence as collectorAddress, or "" when none is set
+func signalEndpoint(cfg *config, signalEnv string) string {
+	if len(cfg.otlp.endpoint) > 0 {
+		return cfg.otlp.endpoint
+	}
+	if endpoint := os.Getenv(signalEnv); len(endpoint) > 0 {
+		return endpoint
+	}
+	return os.Getenv(otelEnvOTLPEndpoint)
+}
+
+// otlpLogGRPCClient exports logs with the OTLP gRPC LogsService
+type otlpLogGRPCClient struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+}
+
+func newOTLPLogGRPCClient(ctx context.Context, cfg *config) (otlpLogClient, error) {
+	creds := credentials.NewTLS(otlpTLSConfig(cfg))
+	if otlpInsecure(cfg, signalEndpoint(cfg, otelEnvOTLPLogsEndpoint)) {
+		creds = insecure.NewCredentials()
+	}
+
+	// connects lazily, like the trace exporter
+	conn, err := grpc.DialContext(ctx, signalAddress("grpc", cfg, otelEnvOTLPLogsEndpoint), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &otlpLogGRPCClient{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(otlpHeaders(cfg)),
+	}, nil
+}
+
+func (c *otlpLogGRPCClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, otlpLogsExportTimeout)
+	defer cancel()
+
+	_, err := c.client.Export(metadata.NewOutgoingContext(ctx, c.headers), req)
+	return err
+}
+
+func (c *otlpLogGRPCClient) shutdown(ctx context.Context) error {
+	return c.conn.Close()
+}
+
+// otlpLogHTTPClient exports logs as protobuf to the collector's /v1/logs
+type otlpLogHTTPClient struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+func newOTLPLogHTTPClient(cfg *config) (otlpLogClient, error) {
+	endpoint := signalEndpoint(cfg, otelEnvOTLPLogsEndpoint)
+
+	scheme := "https://"
+	if otlpInsecure(cfg, endpoint) {
+		scheme = "http://"
+	}
+
+	// a logs endpoint is the full URL, any other endpoint is the base URL
+	logsURL := os.Getenv(otelEnvOTLPLogsEndpoint)
+	if len(cfg.otlp.endpoint) > 0 || len(logsURL) == 0 {
+		base := endpoint
+		if len(base) == 0 {
+			base = "localhost:4318"
+		}
+		logsURL = strings.TrimSuffix(base, "/") + "/v1/logs"
+	}
+	if !strings.Contains(logsURL, "://") {
+		logsURL = scheme + logsURL
+	}
+
+	return &otlpLogHTTPClient{
+		url: logsURL,
+		client: &http.Client{
+			Timeout:   otlpLogsExportTimeout,
+			Transport: &http.Transport{TLSClientConfig: otlpTLSConfig(cfg)},
+		},
+		headers: otlpHeaders(cfg),
+	}, nil
+}
+
+func (c *otlpLogHTTPClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	res, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from %s: %d", c.url, res.StatusCode)
+	}
+	return nil
+}
+
+func (c *otlpLogHTTPClient) shutdown(ctx context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
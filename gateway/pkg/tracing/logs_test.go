@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// inMemoryLogExporter keeps the records it is given
+type inMemoryLogExporter struct {
+	mu       sync.Mutex
+	records  []LogRecord
+	shutdown bool
+}
+
+func (e *inMemoryLogExporter) Export(ctx context.Context, records []LogRecord) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.records = append(e.records, records...)
+	return nil
+}
+
+func (e *inMemoryLogExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+func Test_Provider_BridgesLogsToLogExporter(t *testing.T) {
+	unsetEnv(t, otelEnvTraceSExporter)
+
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(previous)
+
+	exporter := &inMemoryLogExporter{}
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none", WithLogExporter(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Printf("function %s deployed", "figlet")
+	shutdown(context.Background())
+
+	log.Printf("logged after shutdown")
+
+	if len(exporter.records) != 1 {
+		t.Fatalf("want 1 record, got: %+v", exporter.records)
+	}
+	if body := exporter.records[0].Body; !strings.HasSuffix(body, "function figlet deployed") {
+		t.Errorf("want the logged line as the body, got: %q", body)
+	}
+	if !exporter.shutdown {
+		t.Errorf("want the exporter shut down with the provider")
+	}
+	if log.Writer() != io.Discard {
+		t.Errorf("want the logger's output restored on shutdown")
+	}
+}
+
+func Test_Provider_ExportsLogsWithOTLP(t *testing.T) {
+	received := make(chan *collogspb.ExportLogsServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		req := &collogspb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	unsetEnv(t, otelEnvTraceSExporter)
+	unsetEnv(t, otelEnvServiceName)
+	t.Setenv(otelEnvLogsExporter, "otlp")
+	t.Setenv(otelExpOTLPProtocol, "http/protobuf")
+	t.Setenv(otelEnvOTLPEndpoint, collector.URL)
+
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(previous)
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Print("hello from the gateway")
+	shutdown(context.Background())
+
+	var req *collogspb.ExportLogsServiceRequest
+	select {
+	case req = <-received:
+	default:
+		t.Fatal("want the record exported to the collector on shutdown")
+	}
+
+	resourceLogs := req.GetResourceLogs()[0]
+	record := resourceLogs.GetScopeLogs()[0].GetLogRecords()[0]
+	if !strings.HasSuffix(record.GetBody().GetStringValue(), "hello from the gateway") {
+		t.Errorf("want the logged line as the body, got: %q", record.GetBody().GetStringValue())
+	}
+
+	found := false
+	for _, kv := range resourceLogs.GetResource().GetAttributes() {
+		if kv.GetKey() == "service.name" && kv.GetValue().GetStringValue() == "gateway" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("want the traces' resource on the logs, got: %v", resourceLogs.GetResource().GetAttributes())
+	}
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc/credentials"
+)
+
+const otelEnvMetricsExporter = "OTEL_METRICS_EXPORTER"
+
+// MeterName is the instrumentation name for metrics recorded by the gateway
+const MeterName = "Gateway"
+
+// meterProvider creates, and registers as the global, the MeterProvider when WithMetricReader is
+// given, or OTEL_METRICS_EXPORTER=otlp, using the same resource and OTLP
+// settings as the traces. It returns nil when metrics are not exported
+// with OTLP, the Prometheus metrics are served either way.
+func meterProvider(ctx context.Context, name, version, commit string, cfg *config) (*metricsdk.MeterProvider, error) {
+	reader := cfg.metricReader
+	if reader == nil {
+		if Exporter(strings.ToLower(get(otelEnvMetricsExporter, "none"))) != OTELExporter {
+			return nil, nil
+		}
+
+		exporter, err := newOTLPMetricExporter(ctx, cfg.otlpProtocol(), cfg)
+		if err != nil {
+			return nil, err
+		}
+		// the interval is read from OTEL_METRIC_EXPORT_INTERVAL
+		reader = metricsdk.NewPeriodicReader(exporter)
+	}
+
+	res, err := newResource(name, version, commit, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := metricsdk.NewMeterProvider(
+		metricsdk.WithReader(reader),
+		metricsdk.WithResource(res),
+	)
+	if !cfg.withoutGlobals {
+		otel.SetMeterProvider(provider)
+	}
+
+	return provider, nil
+}
+
+// withMetrics extends shutdown to flush the meter provider after the traces
+func withMetrics(shutdown Shutdown, provider *metricsdk.MeterProvider) Shutdown {
+	if provider == nil {
+		return shutdown
+	}
+
+	timeout := shutdownTimeout()
+	return func(ctx context.Context) {
+		shutdown(ctx)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("failed to shutdown meter provider: %v", err)
+		}
+	}
+}
+
+// newOTLPMetricExporter creates an OTLP metric exporter for the given
+// protocol, accepting the same values as newOTLPExporter
+func newOTLPMetricExporter(ctx context.Context, protocol string, cfg *config) (metricsdk.Exporter, error) {
+	switch strings.ToLower(strings.TrimSpace(protocol)) {
+	case "", "grpc":
+		return otlpmetricgrpc.New(ctx, cfg.metricGRPCOptions()...)
+	case "http", "http/protobuf":
+		return otlpmetrichttp.New(ctx, cfg.metricHTTPOptions()...)
+	default:
+		return nil, fmt.Errorf("unsupported %s value: %q, use one of grpc, http/protobuf", otelExpOTLPProtocol, protocol)
+	}
+}
+
+// metricGRPCOptions converts the OTLP settings into options for the gRPC
+// metric client.
+func (c *config) metricGRPCOptions() []otlpmetricgrpc.Option {
+	opts := []otlpmetricgrpc.Option{}
+
+	if len(c.otlp.endpoint) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(c.otlp.endpoint))
+	}
+	if len(c.otlp.headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(c.otlp.headers))
+	}
+	if c.otlp.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else if c.otlp.tlsConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(c.otlp.tlsConfig)))
+	}
+
+	return opts
+}
+
+// metricHTTPOptions converts the OTLP settings into options for the HTTP
+// metric client.
+func (c *config) metricHTTPOptions() []otlpmetrichttp.Option {
+	opts := []otlpmetrichttp.Option{}
+
+	if len(c.otlp.endpoint) > 0 {
+		opts = append(opts, otlpmetrichttp.WithEndpoint(c.otlp.endpoint))
+	}
+	if len(c.otlp.headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(c.otlp.headers))
+	}
+	if c.otlp.insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if c.otlp.tlsConfig != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(c.otlp.tlsConfig))
+	}
+
+	return opts
+}
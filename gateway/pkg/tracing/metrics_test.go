@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func Test_Provider_RegistersMeterProvider(t *testing.T) {
+	unsetEnv(t, otelEnvTraceSExporter)
+
+	reader := metricsdk.NewManualReader()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none", WithMetricReader(reader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetMeterProvider(noop.NewMeterProvider())
+
+	if _, ok := otel.GetMeterProvider().(*metricsdk.MeterProvider); !ok {
+		t.Fatalf("want an SDK meter provider to be registered, got: %T", otel.GetMeterProvider())
+	}
+
+	counter, _ := otel.Meter(MeterName).Int64Counter("gateway.function.invocations")
+	counter.Add(context.Background(), 1)
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Metrics[0].Name != "gateway.function.invocations" {
+		t.Fatalf("want the counter collected, got: %+v", rm.ScopeMetrics)
+	}
+	if name, _ := rm.Resource.Set().Value("service.name"); name.AsString() != "gateway" {
+		t.Errorf("want the traces' resource on the metrics, got: %v", rm.Resource)
+	}
+
+	shutdown(context.Background())
+	if err := reader.Collect(context.Background(), &rm); err == nil {
+		t.Errorf("want the reader shut down with the provider")
+	}
+}
+
+func Test_Provider_MetricsDisabledByDefault(t *testing.T) {
+	unsetEnv(t, otelEnvTraceSExporter)
+	unsetEnv(t, otelEnvMetricsExporter)
+	otel.SetMeterProvider(noop.NewMeterProvider())
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	if _, ok := otel.GetMeterProvider().(*metricsdk.MeterProvider); ok {
+		t.Errorf("want no meter provider without OTEL_METRICS_EXPORTER")
+	}
+}
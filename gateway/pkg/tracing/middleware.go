@@ -2,27 +2,53 @@ package tracing
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	fhttputil "github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// TracerName is the instrumentation name for spans created by the gateway
+const TracerName = "Gateway"
+
+// FunctionNameKey is the span attribute for the name of the invoked function
+const FunctionNameKey = attribute.Key("faas.function")
+
+// NamespaceKey is the span attribute for the namespace of the function
+const NamespaceKey = attribute.Key("faas.namespace")
+
+// Attributes for the Content-Type of the request and response bodies, these
+// are left out rather than set to "" when there is no header.
+const (
+	RequestContentTypeKey  = attribute.Key("http.request.content_type")
+	ResponseContentTypeKey = attribute.Key("http.response.content_type")
+)
+
 type Exporter string
 
 const (
 	OTELExporter     Exporter = "otlp"
+	StdoutExporter   Exporter = "console"
 	DisabledExporter Exporter = "disabled"
 )
 
@@ -33,117 +59,582 @@ const (
 	otelEnvExporterLogTimestamps  = "OTEL_EXPORTER_LOG_TIMESTAMPS"
 	otelEnvServiceName            = "OTEL_SERVICE_NAME"
 	otelExpOTLPProtocol           = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	otelEnvTracesSampler          = "OTEL_TRACES_SAMPLER"
+	otelEnvTracesSamplerArg       = "OTEL_TRACES_SAMPLER_ARG"
+
+	envTraceShutdownTimeout = "FAAS_TRACE_SHUTDOWN_TIMEOUT"
+	envTraceIDHeader        = "FAAS_TRACE_ID_HEADER"
+	envTraceDebugBaggage    = "FAAS_TRACE_DEBUG_BAGGAGE"
+	envTraceJaegerDebug     = "FAAS_TRACE_JAEGER_DEBUG"
+	envTraceIgnoredPaths    = "FAAS_TRACE_IGNORED_PATHS"
+	defaultTraceIDHeader    = "X-Trace-Id"
+	defaultShutdownTimeout  = time.Second * 5
 )
 
+// Shutdown flushes and stops the tracing pipeline. Provider always returns
+// a non-nil Shutdown, so it is safe to defer without checking.
 type Shutdown func(context.Context)
 
-func Provider(ctx context.Context, name, version, commit string) (shutdown Shutdown, err error) {
-	var exporter Exporter
-	if val, exists := os.LookupEnv(otelEnvTraceSExporter); exists {
-		exporter = Exporter(val)
+// noopShutdown is returned when tracing is disabled or could not be configured.
+func noopShutdown(_ context.Context) {}
+
+// Provider configures and registers the global TracerProvider and propagator
+// for the gateway. Settings are read from the OTEL_* environment variables
+// unless they are given as an Option.
+//
+// With OTEL_LOGS_EXPORTER=otlp, or WithLogExporter, the standard logger is
+// also bridged to a LogProvider, which exports each line with the same
+// resource and OTLP settings as the spans. Likewise OTEL_METRICS_EXPORTER=otlp,
+// or WithMetricReader, registers the global MeterProvider, in addition to the
+// Prometheus metrics. The returned Shutdown flushes all of them.
+func Provider(ctx context.Context, name, version, commit string, opts ...Option) (Shutdown, error) {
+	pipeline, err := NewPipeline(ctx, name, version, commit, opts...)
+	if err != nil {
+		return noopShutdown, err
+	}
+	return pipeline.Shutdown, nil
+}
+
+// Pipeline is the telemetry configured by NewPipeline
+type Pipeline struct {
+	// TracerProvider is nil when tracing is disabled
+	TracerProvider *tracesdk.TracerProvider
+
+	// Propagator is nil when tracing is disabled
+	Propagator propagation.TextMapPropagator
+
+	// MeterProvider is nil unless metrics are exported with OTLP
+	MeterProvider *metricsdk.MeterProvider
+
+	// Logs is nil unless logs are exported
+	Logs *LogProvider
+
+	// Shutdown flushes and stops all of the above, it is never nil
+	Shutdown Shutdown
+
+	// gate is nil unless WithReadinessGate is given
+	gate *readinessGate
+}
+
+// NewPipeline configures the gateway's telemetry as Provider does, and
+// returns it to the caller. With WithoutGlobalRegistration the otel globals
+// and the standard logger are left as they are, so that more than one
+// pipeline can be used in a process, and each is given to Middleware and
+// Transport with Options.
+func NewPipeline(ctx context.Context, name, version, commit string, opts ...Option) (*Pipeline, error) {
+	cfg := newConfig(opts)
+	cfg.loadOTLPConfigFile()
+
+	pipeline, err := tracerProvider(ctx, name, version, commit, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	meters, err := meterProvider(ctx, name, version, commit, cfg)
+	if err != nil {
+		pipeline.Shutdown(ctx)
+		return nil, err
+	}
+	pipeline.MeterProvider = meters
+	pipeline.Shutdown = withMetrics(pipeline.Shutdown, meters)
+
+	logs, err := newLogProvider(ctx, name, version, commit, cfg)
+	if err != nil {
+		pipeline.Shutdown(ctx)
+		return nil, err
+	}
+	pipeline.Logs = logs
+	if cfg.withoutGlobals {
+		pipeline.Shutdown = withLogShutdown(pipeline.Shutdown, logs)
 	} else {
-		exporter = DisabledExporter
+		pipeline.Shutdown = withLogs(pipeline.Shutdown, logs)
 	}
 
-	var exp tracesdk.TracerProviderOption
-	switch exporter {
-	case OTELExporter:
-		// find available env variables for configuration
-		// see: https://github.com/open-telemetry/opentelemetry-go/tree/main/exporters/otlp/otlptrace#environment-variables
-		kind := get(otelExpOTLPProtocol, "grpc")
+	return pipeline, nil
+}
 
-		var client tracesdk.SpanExporter
-		switch kind {
-		case "grpc":
-			client, err = otlptracegrpc.New(ctx)
-		case "http":
-			client, err = otlptracehttp.New(ctx)
+// Options passes the pipeline's TracerProvider and Propagator to
+// Middleware and Transport, instead of the otel globals
+func (p *Pipeline) Options() []Option {
+	if p.TracerProvider == nil {
+		return nil
+	}
+	return []Option{WithTracerProvider(p.TracerProvider), WithPropagators(p.Propagator)}
+}
+
+// tracerProvider sets up the traces for NewPipeline
+func tracerProvider(ctx context.Context, name, version, commit string, cfg *config) (pipeline *Pipeline, err error) {
+	exporters := cfg.exporters
+	if len(exporters) == 0 {
+		exporters, err = envExporters(ctx, cfg)
+		if err != nil {
+			return nil, err
 		}
-		exp = tracesdk.WithBatcher(client)
-	default:
+	}
+	if len(exporters) == 0 {
 		log.Println("tracing disabled")
 		// We explicitly DO NOT set the global TracerProvider using otel.SetTracerProvider().
 		// The unset TracerProvider returns a "non-recording" span, but still passes through context.
 		// return no-op shutdown function
-		return func(_ context.Context) {}, nil
+		if !cfg.withoutGlobals && len(cfg.tracesExporter) > 0 {
+			globalTracesExporter.Store(&cfg.tracesExporter)
+		}
+		return &Pipeline{Shutdown: noopShutdown}, nil
 	}
-	if err != nil {
-		return nil, err
+
+	propagators := cfg.propagators
+	if len(propagators) == 0 {
+		propagators = withPropagators(strings.ToLower(get(otelEnvPropagators, "tracecontext,baggage")))
 	}
+	propagator := propagation.NewCompositeTextMapPropagator(propagators...)
 
-	propagators := strings.ToLower(get(otelEnvPropagators, "tracecontext,baggage"))
-	otel.SetTextMapPropagator(
-		propagation.NewCompositeTextMapPropagator(withPropagators(propagators)...),
-	)
-
-	resource, err := resource.New(
-		context.Background(),
-		resource.WithFromEnv(),
-		resource.WithHost(),
-		resource.WithOS(),
-		resource.WithTelemetrySDK(),
-		resource.WithAttributes(
-			semconv.ServiceVersionKey.String(version),
-			attribute.String("service.commit", commit),
-			semconv.ServiceNameKey.String(get(otelEnvServiceName, name)),
-		),
-	)
+	resource, err := newResource(name, version, commit, cfg)
 	if err != nil {
+		shutdownExporters(ctx, exporters)
 		return nil, err
 	}
 
-	provider := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		exp,
+	sampler := cfg.sampler
+	if sampler == nil {
+		sampler = samplerFromEnv()
+	}
+
+	sampler = newPathSampler(sampler, cfg.pathSamplingRules())
+
+	if cfg.debugBaggage || strings.ToLower(get(envTraceDebugBaggage, "false")) == "true" {
+		sampler = newDebugSampler(sampler)
+	}
+
+	if cfg.jaegerDebugEnabled() {
+		sampler = newJaegerDebugSampler(sampler)
+	}
+
+	startupSpan := cfg.startupSpanEnabled()
+	if startupSpan {
+		sampler = newStartupSampler(sampler)
+	}
+
+	providerOpts := []tracesdk.TracerProviderOption{
 		tracesdk.WithResource(resource),
-		tracesdk.WithSampler(tracesdk.AlwaysSample()),
-	)
+		tracesdk.WithSampler(sampler),
+	}
+
+	var gate *readinessGate
+	if timeout := cfg.readinessTimeout(); timeout > 0 {
+		gate = newReadinessGate()
+		address := ""
+		if len(cfg.exporters) == 0 && cfg.hasExporter(OTELExporter) {
+			address = collectorAddress(cfg.otlpProtocol(), cfg)
+		}
+		gate.watch(timeout, address)
+	}
+
+	// Always be sure to batch in production. Each exporter has its own
+	// batcher, so a slow or unavailable one does not hold up the others.
+	processors := make([]tracesdk.SpanProcessor, 0, len(exporters))
+	exportCounter := cfg.exportsCounter()
+	threshold := cfg.latencyThreshold()
+	for _, exporter := range exporters {
+		var batched tracesdk.SpanExporter = newMeteredExporter(batchedExporter{exporter}, exportCounter)
+		if gate != nil {
+			batched = gatedExporter{SpanExporter: batched, gate: gate}
+		}
+		drops := newDropCounter(cfg.droppedSpansCounter())
+		batched = countedExporter{SpanExporter: batched, drops: drops}
+
+		var processor tracesdk.SpanProcessor = countedProcessor{
+			SpanProcessor: tracesdk.NewBatchSpanProcessor(batched, cfg.batchOptions()...),
+			drops:         drops,
+		}
+		if threshold > 0 {
+			processor = newLatencyFilter(processor, threshold)
+		}
+		processors = append(processors, processor)
+		providerOpts = append(providerOpts, tracesdk.WithSpanProcessor(processor))
+	}
+
+	provider := tracesdk.NewTracerProvider(providerOpts...)
+	if startupSpan {
+		emitStartupSpan(ctx, provider, version, commit)
+	}
+
+	if !cfg.withoutGlobals {
+		otel.SetTextMapPropagator(propagator)
 
-	// Register our TracerProvider as the global so any imported
-	// instrumentation in the future will default to using it.
-	otel.SetTracerProvider(provider)
+		// Register our TracerProvider as the global so any imported
+		// instrumentation in the future will default to using it.
+		otel.SetTracerProvider(provider)
+		globalGate.Store(gate)
+		if len(cfg.tracesExporter) > 0 {
+			globalTracesExporter.Store(&cfg.tracesExporter)
+		}
+	}
 
-	shutdown = func(ctx context.Context) {
+	timeout := shutdownTimeout()
+	shutdown := func(ctx context.Context) {
 		// Do not let the application hang forever when it is shutdown.
-		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		// A deadline already set on ctx will win when it is sooner.
+		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
 
-		err := provider.Shutdown(ctx)
-		if err != nil {
+		gate.stop()
+
+		// every batcher is flushed and its exporter shut down, even once
+		// one has failed or the deadline has passed, and all of their
+		// errors are reported
+		var errs []error
+		for i, processor := range processors {
+			errs = append(errs, processor.Shutdown(ctx), exporters[i].Shutdown(ctx))
+		}
+		errs = append(errs, provider.Shutdown(ctx))
+
+		if err := errors.Join(errs...); err != nil {
 			log.Printf("failed to shutdown tracing provider: %v", err)
 		}
 	}
 
-	return shutdown, nil
+	return &Pipeline{
+		TracerProvider: provider,
+		Propagator:     propagator,
+		Shutdown:       shutdown,
+		gate:           gate,
+	}, nil
 }
 
-func Middleware(next http.HandlerFunc) http.HandlerFunc {
-	_, ok := os.LookupEnv("OTEL_EXPORTER")
-	if !ok {
+// batchedExporter leaves shutting down the exporter to the Provider's
+// Shutdown, the batcher would only pass its error to the otel error handler
+type batchedExporter struct {
+	tracesdk.SpanExporter
+}
+
+func (batchedExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// envExporters creates an exporter for each of the comma separated values
+// of WithTracesExporter or OTEL_TRACES_EXPORTER, i.e. "otlp,console". Blank and repeated values
+// are skipped, as are "none" and "disabled". No exporters are returned when
+// tracing is disabled.
+func envExporters(ctx context.Context, cfg *config) ([]tracesdk.SpanExporter, error) {
+	exporters := []tracesdk.SpanExporter{}
+
+	for _, exporter := range cfg.tracesExporterNames() {
+		var client tracesdk.SpanExporter
+		var err error
+
+		switch exporter {
+		case OTELExporter:
+			// find available env variables for configuration
+			// see: https://github.com/open-telemetry/opentelemetry-go/tree/main/exporters/otlp/otlptrace#environment-variables
+			protocol := cfg.otlpProtocol()
+			client, err = newOTLPExporter(ctx, protocol, cfg)
+			if err == nil && len(cfg.otlpConfigFilePath()) > 0 {
+				client = newReloadingExporter(client, cfg)
+			}
+
+			if err == nil && cfg.startupProbeTimeout > 0 {
+				address := collectorAddress(protocol, cfg)
+				if probeErr := probeCollector(ctx, address, cfg.startupProbeTimeout); probeErr != nil {
+					log.Printf("warning: OTLP collector at %s is unreachable, spans will be dropped until it is available: %s", address, probeErr)
+				}
+			}
+		case StdoutExporter:
+			client, err = stdouttrace.New(stdoutOptions()...)
+		default:
+			log.Printf("warning: unknown %s value: %q, use otlp or console", otelEnvTraceSExporter, exporter)
+			continue
+		}
+
+		if err != nil {
+			shutdownExporters(ctx, exporters)
+			return nil, err
+		}
+		exporters = append(exporters, client)
+	}
+
+	return exporters, nil
+}
+
+// exporterNames splits a list of exporters, without blanks, repeats or the
+// values which disable tracing
+func exporterNames(val string) []Exporter {
+	names := []Exporter{}
+	seen := map[Exporter]bool{}
+
+	for _, item := range splitList(val) {
+		name := Exporter(strings.ToLower(item))
+		if name == DisabledExporter || name == "none" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// hasExporter reports whether WithTracesExporter or OTEL_TRACES_EXPORTER
+// lists exporter
+func (c *config) hasExporter(exporter Exporter) bool {
+	for _, name := range c.tracesExporterNames() {
+		if name == exporter {
+			return true
+		}
+	}
+	return false
+}
+
+// shutdownExporters releases exporters which were created before an error
+// stopped the provider from being set up
+func shutdownExporters(ctx context.Context, exporters []tracesdk.SpanExporter) {
+	for _, exporter := range exporters {
+		exporter.Shutdown(ctx)
+	}
+}
+
+// Middleware starts a server span for each request and passes the span
+// context on to the upstream function. Function invocations are named after
+// their route, i.e. /function/{name}, so that every sub-path of a function is
+// aggregated together. Other spans are named after the URL path, with IDs
+// replaced using DefaultPathRules or WithPathRules.
+//
+// The caller's tracestate is kept by the span, whether or not it is
+// sampled, so its vendor entries are passed on with the span's own
+// traceparent, to the function and by Transport.
+//
+// When the span is sampled, its trace ID is written to the response in the
+// X-Trace-Id header, or the header set by FAAS_TRACE_ID_HEADER. Set
+// FAAS_TRACE_ID_HEADER to "" to disable this.
+//
+// With WithJaegerDebugID, a request with the jaeger-debug-id header is always
+// sampled and the header's value is recorded on its span.
+//
+// WithSpanNameFunc replaces the naming above, the function is called once
+// the request has been served, so that it can use the route matched by a
+// router, such as mux.CurrentRoute when Middleware wraps a route's handler.
+//
+// The request and response headers named by WithCapturedHeaders, or
+// FAAS_TRACE_CAPTURED_HEADERS, are recorded on the span. With "*" every
+// header is, apart from DefaultDeniedHeaders.
+//
+// A trace context header over FAAS_TRACE_MAX_HEADER_BYTES, or
+// WithMaxTraceHeaderBytes, is not extracted. The trace context headers are
+// removed from the request, and it starts a new trace.
+//
+// Requests for DefaultIgnoredPaths are not traced. The prefixes can be
+// changed with WithIgnoredPaths or a comma separated FAAS_TRACE_IGNORED_PATHS.
+//
+// Spans are started with the global TracerProvider and propagator, unless
+// WithTracerProvider and WithPropagators are given i.e. a Pipeline's Options.
+func Middleware(next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	cfg := newConfig(opts)
+	if !cfg.tracingEnabled() {
 		return next
 	}
 	log.Println("configuring proxy tracing middleware")
 
-	propagator := otel.GetTextMapPropagator()
+	traceIDHeader := cfg.traceIDHeader
+	if len(traceIDHeader) == 0 {
+		traceIDHeader = get(envTraceIDHeader, defaultTraceIDHeader)
+	}
+
+	pathRules := cfg.pathRules
+	if pathRules == nil {
+		pathRules = DefaultPathRules
+	}
+
+	ignoredPaths := cfg.ignoredPaths
+	if ignoredPaths == nil {
+		ignoredPaths = DefaultIgnoredPaths
+		if val, ok := os.LookupEnv(envTraceIgnoredPaths); ok {
+			ignoredPaths = splitList(val)
+		}
+	}
+
+	jaegerDebug := cfg.jaegerDebugEnabled()
+	limit := cfg.attributeLimit()
+	headers := cfg.headerFilter()
+
+	propagator := cfg.propagator()
+	tracer := cfg.tracer()
+	traceHeaderLimit := cfg.traceHeaderLimit()
+	droppedLogger := &droppedLog{interval: droppedLogInterval}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// get the parent span from the request headers
-		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		if isIgnoredPath(r.URL.Path, ignoredPaths) {
+			next(w, r)
+			return
+		}
+
 		opts := []trace.SpanStartOption{
 			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(truncatedString(semconv.URLPathKey, r.URL.Path, limit)),
 		}
 
-		ctx, span := otel.Tracer("Gateway").Start(ctx, r.URL.Path, opts...)
+		// an oversized trace context is dropped before it is parsed, so
+		// that it is not held in memory or exported with the spans
+		if field, dropped := dropOversizedTraceContext(r.Header, propagator.Fields(), traceHeaderLimit); dropped {
+			droppedLogger.log(time.Now(), field, traceHeaderLimit, r.URL.Path)
+			opts = append(opts, trace.WithAttributes(TraceContextDroppedKey.String(field)))
+		}
+
+		// get the parent span from the request headers
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = withSamplingPath(ctx, r.URL.Path)
+
+		if id := r.Header.Get(JaegerDebugHeader); jaegerDebug && len(id) > 0 {
+			ctx = withJaegerDebugID(ctx, id)
+			opts = append(opts, trace.WithAttributes(truncatedString(JaegerDebugIDKey, id, limit)))
+		}
+
+		spanName := templatePath(r.URL.Path, pathRules)
+
+		// group invocations of a function regardless of the sub-path called
+		if route, functionName := middleware.GetFunctionRoute(r.URL.Path); len(functionName) > 0 {
+			spanName = "/" + route + "/{name}"
+			if cfg.functionNameFunc != nil {
+				functionName = cfg.functionNameFunc(functionName)
+			}
+			opts = append(opts, trace.WithAttributes(
+				truncatedString(FunctionNameKey, functionName, limit),
+				truncatedString(semconv.HTTPRouteKey, spanName, limit),
+			))
+		}
+
+		ctx, span := tracer.Start(ctx, spanName, opts...)
 		defer span.End()
 
+		if sc := span.SpanContext(); len(traceIDHeader) > 0 && span.IsRecording() && sc.IsSampled() {
+			w.Header().Set(traceIDHeader, sc.TraceID().String())
+		}
+
+		if size, ok := requestBodySize(r); ok {
+			span.SetAttributes(semconv.HTTPRequestBodySize(int(size)))
+		}
+		if contentType := r.Header.Get("Content-Type"); len(contentType) > 0 {
+			span.SetAttributes(truncatedString(RequestContentTypeKey, contentType, limit))
+		}
+		span.SetAttributes(headers.attributes(requestHeaderPrefix, r.Header, limit)...)
+
+		ctx, recovered := withPanicFlag(ctx)
 		r = r.WithContext(ctx)
 		// set the new span as the parent span in the outgoing request context
 		// note that this will overwrite the uber-trace-id and traceparent headers
 		propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
-		next(w, r)
+
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		next(ww, r)
+
+		// named once the handlers, and any router in them, have run
+		if cfg.spanNameFunc != nil {
+			if name := cfg.spanNameFunc(r); len(name) > 0 {
+				span.SetName(name)
+			}
+		}
+
+		status := ww.Status()
+		span.SetAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPResponseStatusCode(status),
+		)
+
+		// the body of an upgraded connection is not written through ww
+		if status != http.StatusSwitchingProtocols {
+			span.SetAttributes(semconv.HTTPResponseBodySize(int(responseBodySize(ww))))
+		}
+		if contentType := ww.Header().Get("Content-Type"); len(contentType) > 0 {
+			span.SetAttributes(truncatedString(ResponseContentTypeKey, contentType, limit))
+		}
+		span.SetAttributes(headers.attributes(responseHeaderPrefix, ww.Header(), limit)...)
+
+		// a panic recorded by Recover is a better description than the 500
+		if status >= http.StatusInternalServerError && !*recovered {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// requestBodySize is the size of the request body from its Content-Length,
+// ok is false when it is not known, i.e. for a chunked request
+func requestBodySize(r *http.Request) (size int64, ok bool) {
+	if r.ContentLength > 0 {
+		return r.ContentLength, true
+	}
+
+	// a request without a body has a length of 0, only trust it when sent
+	if r.ContentLength == 0 && len(r.TransferEncoding) == 0 && len(r.Header.Get("Content-Length")) > 0 {
+		return 0, true
+	}
+	return 0, false
+}
+
+// responseBodySize is the Content-Length of the response when it was set,
+// otherwise the bytes written
+func responseBodySize(ww *fhttputil.HttpWriteInterceptor) int64 {
+	if size, err := strconv.ParseInt(ww.Header().Get("Content-Length"), 10, 64); err == nil && size >= 0 {
+		return size
+	}
+	return ww.BytesWritten()
+}
+
+// enabled reports whether Provider has registered an SDK TracerProvider as
+// the global provider. When tracing is disabled the global provider is left
+// unset, so there is no point in creating spans for each request.
+func enabled() bool {
+	_, ok := otel.GetTracerProvider().(*tracesdk.TracerProvider)
+	return ok
+}
+
+// shutdownTimeout reads the maximum time to wait for spans to be flushed on
+// shutdown from FAAS_TRACE_SHUTDOWN_TIMEOUT i.e. "10s", using 5s when unset
+// or invalid.
+func shutdownTimeout() time.Duration {
+	val, ok := os.LookupEnv(envTraceShutdownTimeout)
+	if !ok {
+		return defaultShutdownTimeout
+	}
+
+	timeout, err := time.ParseDuration(val)
+	if err != nil || timeout <= 0 {
+		log.Printf("invalid %s value: %q, using %s", envTraceShutdownTimeout, val, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+
+	return timeout
+}
+
+// newOTLPExporter creates an OTLP span exporter for the given protocol.
+// Both the short "grpc" and "http" names and the OTEL spec values of
+// "grpc" and "http/protobuf" are accepted, an empty value means "grpc".
+// Any OTLP client settings given as options override the environment.
+func newOTLPExporter(ctx context.Context, protocol string, cfg *config) (tracesdk.SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(protocol)) {
+	case "", "grpc":
+		return otlptracegrpc.New(ctx, cfg.grpcOptions()...)
+	case "http", "http/protobuf":
+		return otlptracehttp.New(ctx, cfg.httpOptions()...)
+	default:
+		return nil, fmt.Errorf("unsupported %s value: %q, use one of grpc, http/protobuf", otelExpOTLPProtocol, protocol)
 	}
 }
 
+// stdoutOptions configures the console exporter using
+// OTEL_EXPORTER_LOG_PRETTY_PRINT and OTEL_EXPORTER_LOG_TIMESTAMPS.
+// Pretty printing is off and timestamps are on by default.
+func stdoutOptions() []stdouttrace.Option {
+	opts := []stdouttrace.Option{}
+
+	if strings.ToLower(get(otelEnvExporterLogPrettyPrint, "false")) == "true" {
+		opts = append(opts, stdouttrace.WithPrettyPrint())
+	}
+
+	if strings.ToLower(get(otelEnvExporterLogTimestamps, "true")) == "false" {
+		opts = append(opts, stdouttrace.WithoutTimestamps())
+	}
+
+	return opts
+}
+
 func get(name, defaultValue string) string {
 	value, ok := os.LookupEnv(name)
 	if !ok {
@@ -152,15 +643,40 @@ func get(name, defaultValue string) string {
 	return value
 }
 
+// withPropagators parses a comma separated list of propagators as per
+// OTEL_PROPAGATORS. Unknown values are ignored, and when no known values
+// are found, the default of tracecontext,baggage is used.
 func withPropagators(propagators string) []propagation.TextMapPropagator {
 	out := []propagation.TextMapPropagator{}
+	seen := map[string]bool{}
 
-	if strings.Contains(propagators, "tracecontext") {
-		out = append(out, propagation.TraceContext{})
+	for _, name := range strings.Split(propagators, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 || seen[name] {
+			continue
+		}
+
+		switch name {
+		case "tracecontext":
+			out = append(out, propagation.TraceContext{})
+		case "baggage":
+			out = append(out, propagation.Baggage{})
+		case "b3":
+			out = append(out, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			out = append(out, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			out = append(out, jaeger.Jaeger{})
+		default:
+			log.Printf("ignoring unsupported propagator: %q", name)
+			continue
+		}
+		seen[name] = true
 	}
 
-	if strings.Contains(propagators, "baggage") {
-		out = append(out, propagation.Baggage{})
+	if len(out) == 0 {
+		log.Printf("no supported propagators found in %q, using tracecontext,baggage", propagators)
+		out = append(out, propagation.TraceContext{}, propagation.Baggage{})
 	}
 
 	return out
@@ -0,0 +1,673 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func Test_Middleware_StartsSpan_WhenOTLPExporterEnabled(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(OTELExporter))
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer func() {
+		// Cancel straight away so that shutdown does not wait on a collector.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		shutdown(ctx)
+	}()
+
+	var spanContext trace.SpanContext
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		spanContext = trace.SpanFromContext(r.Context()).SpanContext()
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if !spanContext.IsValid() {
+		t.Fatalf("want a valid span context in the request, got: %v", spanContext)
+	}
+}
+
+func Test_Middleware_PassesThrough_WhenTracingDisabled(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(DisabledExporter))
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	var spanContext trace.SpanContext
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		spanContext = trace.SpanFromContext(r.Context()).SpanContext()
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if spanContext.IsValid() {
+		t.Fatalf("want no span when tracing is disabled, got: %v", spanContext)
+	}
+}
+
+func Test_stdoutOptions(t *testing.T) {
+	cases := []struct {
+		name        string
+		prettyPrint string
+		timestamps  string
+		want        int
+	}{
+		{name: "defaults", want: 0},
+		{name: "pretty print", prettyPrint: "true", want: 1},
+		{name: "without timestamps", timestamps: "false", want: 1},
+		{name: "pretty print without timestamps", prettyPrint: "true", timestamps: "false", want: 2},
+		{name: "explicit defaults", prettyPrint: "false", timestamps: "true", want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.prettyPrint) > 0 {
+				t.Setenv(otelEnvExporterLogPrettyPrint, tc.prettyPrint)
+			}
+			if len(tc.timestamps) > 0 {
+				t.Setenv(otelEnvExporterLogTimestamps, tc.timestamps)
+			}
+
+			got := len(stdoutOptions())
+			if got != tc.want {
+				t.Errorf("want: %d options, got: %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_Provider_ConsoleExporter(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(StdoutExporter))
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	if !enabled() {
+		t.Fatalf("want the console exporter to register a tracer provider")
+	}
+}
+
+func Test_newOTLPExporter_Protocols(t *testing.T) {
+	cases := []struct {
+		protocol string
+		wantErr  bool
+	}{
+		{protocol: "grpc"},
+		{protocol: "http"},
+		{protocol: "http/protobuf"},
+		{protocol: "HTTP/Protobuf"},
+		{protocol: ""},
+		{protocol: "http/json", wantErr: true},
+		{protocol: "thrift", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.protocol, func(t *testing.T) {
+			exporter, err := newOTLPExporter(context.Background(), tc.protocol, &config{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("want error for protocol %q, got nil", tc.protocol)
+				}
+				if exporter != nil {
+					t.Fatalf("want nil exporter for protocol %q", tc.protocol)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want no error for protocol %q, got: %s", tc.protocol, err)
+			}
+			if exporter == nil {
+				t.Fatalf("want an exporter for protocol %q, got nil", tc.protocol)
+			}
+			exporter.Shutdown(context.Background())
+		})
+	}
+}
+
+func Test_Provider_UnsupportedOTLPProtocol(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(OTELExporter))
+	t.Setenv(otelExpOTLPProtocol, "http/json")
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err == nil {
+		t.Fatal("want an error for an unsupported protocol")
+	}
+
+	if shutdown == nil {
+		t.Fatal("want a non-nil shutdown when the exporter can not be created")
+	}
+	shutdown(context.Background())
+
+	if enabled() {
+		t.Errorf("want no tracer provider registered when the exporter can not be created")
+	}
+
+	if !strings.Contains(err.Error(), "http/json") {
+		t.Errorf("want error to mention the protocol, got: %s", err)
+	}
+}
+
+func Test_withPropagators(t *testing.T) {
+	cases := []struct {
+		name        string
+		propagators string
+		want        []string
+	}{
+		{
+			name:        "default",
+			propagators: "tracecontext,baggage",
+			want:        []string{"propagation.TraceContext", "propagation.Baggage"},
+		},
+		{
+			name:        "mixed with spaces and duplicates",
+			propagators: "b3, jaeger,b3multi ,tracecontext,jaeger",
+			want:        []string{"b3.propagator", "jaeger.Jaeger", "b3.propagator", "propagation.TraceContext"},
+		},
+		{
+			name:        "unknown values are ignored",
+			propagators: "xray,b3",
+			want:        []string{"b3.propagator"},
+		},
+		{
+			name:        "only unknown values fall back to the default",
+			propagators: "xray,ottrace",
+			want:        []string{"propagation.TraceContext", "propagation.Baggage"},
+		},
+		{
+			name:        "empty falls back to the default",
+			propagators: "",
+			want:        []string{"propagation.TraceContext", "propagation.Baggage"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := withPropagators(tc.propagators)
+			if len(got) != len(tc.want) {
+				t.Fatalf("want %d propagators, got: %d", len(tc.want), len(got))
+			}
+
+			for i, p := range got {
+				if name := fmt.Sprintf("%T", p); name != tc.want[i] {
+					t.Errorf("propagator %d want: %s, got: %s", i, tc.want[i], name)
+				}
+			}
+		})
+	}
+}
+
+func Test_withPropagators_B3Encodings(t *testing.T) {
+	single := propagation.NewCompositeTextMapPropagator(withPropagators("b3")...)
+	if fields := strings.Join(single.Fields(), ","); fields != "b3" {
+		t.Errorf("b3 want fields: b3, got: %s", fields)
+	}
+
+	multi := propagation.NewCompositeTextMapPropagator(withPropagators("b3multi")...)
+	if fields := multi.Fields(); len(fields) < 2 {
+		t.Errorf("b3multi want the multiple header fields, got: %v", fields)
+	}
+}
+
+// recordSpans registers an SDK TracerProvider which keeps finished spans in
+// memory as the global provider for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		provider.Shutdown(context.Background())
+	})
+
+	return recorder
+}
+
+// spanAttribute finds the value of an attribute on a finished span.
+func spanAttribute(span tracesdk.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func Test_Middleware_RecordsStatusAndMethod(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("function failed"))
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/function/figlet", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("want status: %d, got: %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	span := spans[0]
+
+	if span.Status().Code != codes.Error {
+		t.Errorf("want span status: %s, got: %s", codes.Error, span.Status().Code)
+	}
+
+	if v, ok := spanAttribute(span, semconv.HTTPResponseStatusCodeKey); !ok || v.AsInt64() != http.StatusInternalServerError {
+		t.Errorf("want %s: %d, got: %v", semconv.HTTPResponseStatusCodeKey, http.StatusInternalServerError, v.Emit())
+	}
+
+	if v, ok := spanAttribute(span, semconv.HTTPRequestMethodKey); !ok || v.AsString() != http.MethodPost {
+		t.Errorf("want %s: %s, got: %s", semconv.HTTPRequestMethodKey, http.MethodPost, v.Emit())
+	}
+
+	if v, ok := spanAttribute(span, semconv.HTTPResponseBodySizeKey); !ok || v.AsInt64() != int64(len("function failed")) {
+		t.Errorf("want %s: %d, got: %s", semconv.HTTPResponseBodySizeKey, len("function failed"), v.Emit())
+	}
+}
+
+func Test_Middleware_RecordsBodySizeAndContentType(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      func() *http.Request
+		wantSize int64
+		wantType string
+	}{
+		{
+			name: "known length",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader(`{"text":"hi"}`))
+				r.Header.Set("Content-Type", "application/json")
+				return r
+			},
+			wantSize: int64(len(`{"text":"hi"}`)),
+			wantType: "application/json",
+		},
+		{
+			name: "empty body with a length",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/function/figlet", nil)
+				r.Header.Set("Content-Length", "0")
+				return r
+			},
+			wantSize: 0,
+		},
+		{
+			name: "unknown length",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader("hi"))
+				r.ContentLength = -1
+				return r
+			},
+			wantSize: -1,
+		},
+		{
+			name: "chunked",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/function/figlet", strings.NewReader("hi"))
+				r.ContentLength = -1
+				r.TransferEncoding = []string{"chunked"}
+				return r
+			},
+			wantSize: -1,
+		},
+		{
+			name:     "no body",
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/function/figlet", nil) },
+			wantSize: -1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte("ok"))
+			})
+			handler(httptest.NewRecorder(), tc.req())
+
+			span := recorder.Ended()[0]
+
+			size, ok := spanAttribute(span, semconv.HTTPRequestBodySizeKey)
+			if tc.wantSize < 0 && ok {
+				t.Errorf("want no %s when the length is unknown, got: %d", semconv.HTTPRequestBodySizeKey, size.AsInt64())
+			}
+			if tc.wantSize >= 0 && (!ok || size.AsInt64() != tc.wantSize) {
+				t.Errorf("want %s: %d, got: %s", semconv.HTTPRequestBodySizeKey, tc.wantSize, size.Emit())
+			}
+
+			contentType, ok := spanAttribute(span, RequestContentTypeKey)
+			if len(tc.wantType) == 0 && ok {
+				t.Errorf("want no %s without the header, got: %s", RequestContentTypeKey, contentType.Emit())
+			}
+			if len(tc.wantType) > 0 && contentType.AsString() != tc.wantType {
+				t.Errorf("want %s: %s, got: %s", RequestContentTypeKey, tc.wantType, contentType.Emit())
+			}
+
+			if v, _ := spanAttribute(span, ResponseContentTypeKey); v.AsString() != "text/plain" {
+				t.Errorf("want %s: text/plain, got: %s", ResponseContentTypeKey, v.Emit())
+			}
+			if v, _ := spanAttribute(span, semconv.HTTPResponseBodySizeKey); v.AsInt64() != 2 {
+				t.Errorf("want %s: 2, got: %s", semconv.HTTPResponseBodySizeKey, v.Emit())
+			}
+		})
+	}
+}
+
+func Test_Middleware_ResponseContentLength(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "42")
+		w.WriteHeader(http.StatusOK)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/function/figlet", nil))
+
+	span := recorder.Ended()[0]
+	if v, _ := spanAttribute(span, semconv.HTTPResponseBodySizeKey); v.AsInt64() != 42 {
+		t.Errorf("want %s from the Content-Length: 42, got: %s", semconv.HTTPResponseBodySizeKey, v.Emit())
+	}
+	if _, ok := spanAttribute(span, ResponseContentTypeKey); ok {
+		t.Errorf("want no %s without the header", ResponseContentTypeKey)
+	}
+}
+
+func Test_Middleware_SuccessLeavesStatusUnset(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("want span status: %s, got: %s", codes.Unset, spans[0].Status().Code)
+	}
+
+	if v, _ := spanAttribute(spans[0], semconv.HTTPResponseStatusCodeKey); v.AsInt64() != http.StatusOK {
+		t.Errorf("want %s: %d, got: %s", semconv.HTTPResponseStatusCodeKey, http.StatusOK, v.Emit())
+	}
+}
+
+func Test_shutdownTimeout(t *testing.T) {
+	cases := []struct {
+		name  string
+		value *string
+		want  time.Duration
+	}{
+		{name: "unset", want: defaultShutdownTimeout},
+		{name: "valid", value: strPtr("30s"), want: time.Second * 30},
+		{name: "invalid", value: strPtr("soon"), want: defaultShutdownTimeout},
+		{name: "negative", value: strPtr("-1s"), want: defaultShutdownTimeout},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.value != nil {
+				t.Setenv(envTraceShutdownTimeout, *tc.value)
+			}
+
+			if got := shutdownTimeout(); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_Provider_Shutdown_WithCanceledContext(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(StdoutExporter))
+	t.Setenv(envTraceShutdownTimeout, "1m")
+
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	shutdown(ctx)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("want shutdown to return promptly for a canceled context, took: %s", elapsed)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func Test_Middleware_WritesTraceIDHeader(t *testing.T) {
+	recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	got := rr.Header().Get(defaultTraceIDHeader)
+	if len(got) != 32 {
+		t.Fatalf("want a trace ID in %s, got: %q", defaultTraceIDHeader, got)
+	}
+}
+
+func Test_Middleware_WritesTraceIDHeader_CustomName(t *testing.T) {
+	recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithTraceIDHeader("X-Request-Trace"))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if got := rr.Header().Get("X-Request-Trace"); len(got) == 0 {
+		t.Errorf("want a trace ID in X-Request-Trace")
+	}
+	if got := rr.Header().Get(defaultTraceIDHeader); len(got) > 0 {
+		t.Errorf("want no %s header when a custom name is set, got: %q", defaultTraceIDHeader, got)
+	}
+}
+
+func Test_Middleware_NoTraceIDHeader_WhenNotSampled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracesdk.NewTracerProvider(
+		tracesdk.WithSpanProcessor(recorder),
+		tracesdk.WithSampler(tracesdk.NeverSample()),
+	)
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if got := rr.Header().Get(defaultTraceIDHeader); len(got) > 0 {
+		t.Errorf("want no trace ID for an unsampled span, got: %q", got)
+	}
+}
+
+func Test_Middleware_NoTraceIDHeader_WhenTracingDisabled(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if got := rr.Header().Get(defaultTraceIDHeader); len(got) > 0 {
+		t.Errorf("want no trace ID when tracing is disabled, got: %q", got)
+	}
+}
+
+func Test_Middleware_FunctionNameAttribute(t *testing.T) {
+	cases := []struct {
+		path      string
+		wantName  string
+		wantRoute string
+	}{
+		{path: "/function/figlet", wantName: "figlet", wantRoute: "/function/{name}"},
+		{path: "/function/figlet/", wantName: "figlet", wantRoute: "/function/{name}"},
+		{path: "/function/figlet/employees/100", wantName: "figlet", wantRoute: "/function/{name}"},
+		{path: "/async-function/figlet.fn/employees", wantName: "figlet.fn", wantRoute: "/async-function/{name}"},
+		{path: "/"},
+		{path: "/function/"},
+		{path: "/system/functions"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			recorder := recordSpans(t)
+
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {})
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got: %d", len(spans))
+			}
+
+			name, ok := spanAttribute(spans[0], FunctionNameKey)
+			if len(tc.wantName) == 0 {
+				if ok {
+					t.Errorf("want no %s attribute, got: %s", FunctionNameKey, name.Emit())
+				}
+				return
+			}
+
+			if name.AsString() != tc.wantName {
+				t.Errorf("want %s: %s, got: %s", FunctionNameKey, tc.wantName, name.Emit())
+			}
+
+			if route, _ := spanAttribute(spans[0], semconv.HTTPRouteKey); route.AsString() != tc.wantRoute {
+				t.Errorf("want %s: %s, got: %s", semconv.HTTPRouteKey, tc.wantRoute, route.Emit())
+			}
+		})
+	}
+}
+
+func Test_Middleware_FunctionNameFunc(t *testing.T) {
+	recorder := recordSpans(t)
+
+	bucket := func(name string) string {
+		if name == "figlet" {
+			return name
+		}
+		return "__other__"
+	}
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {}, WithFunctionNameFunc(bucket))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/dyn-3f2a/sub", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+
+	for i, want := range []string{"figlet", "__other__"} {
+		if name, _ := spanAttribute(spans[i], FunctionNameKey); name.AsString() != want {
+			t.Errorf("want %s: %s, got: %s", FunctionNameKey, want, name.Emit())
+		}
+	}
+}
+
+func Test_Middleware_UnderBasePath(t *testing.T) {
+	for _, basePath := range []string{"", "/openfaas"} {
+		t.Run("base path "+basePath, func(t *testing.T) {
+			recorder := recordSpans(t)
+
+			handler := middleware.BasePath(Middleware(func(w http.ResponseWriter, r *http.Request) {}), basePath)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, basePath+"/function/figlet.openfaas-fn/greet", nil))
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got: %d", len(spans))
+			}
+
+			if got := spans[0].Name(); got != "/function/{name}" {
+				t.Errorf("want span name: /function/{name}, got: %s", got)
+			}
+			if v, _ := spanAttribute(spans[0], FunctionNameKey); v.AsString() != "figlet.openfaas-fn" {
+				t.Errorf("want %s: figlet.openfaas-fn, got: %s", FunctionNameKey, v.Emit())
+			}
+			if v, _ := spanAttribute(spans[0], semconv.URLPathKey); v.AsString() != "/function/figlet.openfaas-fn/greet" {
+				t.Errorf("want %s without the base path, got: %s", semconv.URLPathKey, v.Emit())
+			}
+		})
+	}
+}
+
+func Test_Middleware_FunctionFromHeader(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := middleware.FunctionHeader(Middleware(func(w http.ResponseWriter, r *http.Request) {}), "X-Function")
+	req := httptest.NewRequest(http.MethodGet, "/greet", nil)
+	req.Header.Set("X-Function", "figlet.openfaas-fn")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if got := spans[0].Name(); got != "/function/{name}" {
+		t.Errorf("want span name: /function/{name}, got: %s", got)
+	}
+	if v, _ := spanAttribute(spans[0], FunctionNameKey); v.AsString() != "figlet.openfaas-fn" {
+		t.Errorf("want %s from the header: figlet.openfaas-fn, got: %s", FunctionNameKey, v.Emit())
+	}
+}
@@ -0,0 +1,483 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+)
+
+// Option configures Provider, Middleware and Transport. Any setting which is
+// not given as an option is read from the environment.
+type Option func(*config)
+
+type config struct {
+	sampler        tracesdk.Sampler
+	exporters      []tracesdk.SpanExporter
+	tracesExporter string
+	logExporter    LogExporter
+	metricReader   metricsdk.Reader
+	propagators    []propagation.TextMapPropagator
+	attributes     []attribute.KeyValue
+
+	attributesFile string
+	serviceName    string
+
+	tracerProvider trace.TracerProvider
+	withoutGlobals bool
+
+	traceIDHeader string
+	debugBaggage  bool
+	jaegerDebug   bool
+	pathRules     []PathRule
+	samplingRules []SamplingRule
+	spanNameFunc  func(*http.Request) string
+	// functionNameFunc maps the name recorded as FunctionNameKey
+	functionNameFunc func(string) string
+	// maxAttributeLength is nil when not given, to fall back to the env
+	maxAttributeLength *int
+	// maxTraceHeaderBytes is nil when not given, to fall back to the env
+	maxTraceHeaderBytes *int
+	ignoredPaths        []string
+	capturedHeaders     []string
+	deniedHeaders       []string
+
+	startupProbeTimeout  time.Duration
+	readinessGateTimeout time.Duration
+	startupSpan          bool
+	// latencyFilterThreshold is 0 when not given, to fall back to the env
+	latencyFilterThreshold time.Duration
+
+	maxQueueSize       int
+	maxExportBatchSize int
+	droppedSpans       prometheus.Counter
+	// exportRetry is nil when not given, to fall back to the env
+	exportRetry   *ExportRetry
+	exportCounter *prometheus.CounterVec
+
+	otlp otlpConfig
+	// otlpGiven is otlp before the config file was merged into it
+	otlpGiven             otlpConfig
+	otlpFile              *otlpFile
+	otlpConfigFile        string
+	otlpConfigFileOverEnv bool
+}
+
+// otlpConfig overrides the OTEL_EXPORTER_OTLP_* client settings
+type otlpConfig struct {
+	endpoint    string
+	protocol    string
+	headers     map[string]string
+	tlsConfig   *tls.Config
+	insecure    bool
+	compression string
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithSampler sets the sampler used for new traces, instead of
+// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+func WithSampler(sampler tracesdk.Sampler) Option {
+	return func(c *config) {
+		c.sampler = sampler
+	}
+}
+
+// WithExporter adds an exporter that spans are batched to, instead of the
+// exporters selected by OTEL_TRACES_EXPORTER. It can be given more than
+// once to send each span to every exporter, with a batcher for each. Tracing
+// is enabled whenever an exporter is given.
+func WithExporter(exporter tracesdk.SpanExporter) Option {
+	return func(c *config) {
+		if exporter != nil {
+			c.exporters = append(c.exporters, exporter)
+		}
+	}
+}
+
+// WithTracesExporter selects the exporters by name, as a comma-separated
+// list of otlp, console or none, instead of OTEL_TRACES_EXPORTER. It is
+// ignored when WithExporter is given.
+func WithTracesExporter(names string) Option {
+	return func(c *config) {
+		c.tracesExporter = names
+	}
+}
+
+// tracesExporterNames are given by WithTracesExporter or OTEL_TRACES_EXPORTER
+func (c *config) tracesExporterNames() []Exporter {
+	if len(c.tracesExporter) > 0 {
+		return exporterNames(c.tracesExporter)
+	}
+	return exporterNames(os.Getenv(otelEnvTraceSExporter))
+}
+
+// WithLogExporter sets the exporter that the gateway's log lines are
+// batched to, instead of the OTLP exporter selected by OTEL_LOGS_EXPORTER.
+// Logs are exported whenever an exporter is given.
+func WithLogExporter(exporter LogExporter) Option {
+	return func(c *config) {
+		c.logExporter = exporter
+	}
+}
+
+// WithMetricReader sets the reader that collects the gateway's OTLP
+// metrics, instead of the periodic OTLP exporter selected by
+// OTEL_METRICS_EXPORTER. Metrics are recorded whenever a reader is given.
+func WithMetricReader(reader metricsdk.Reader) Option {
+	return func(c *config) {
+		c.metricReader = reader
+	}
+}
+
+// WithPropagators sets the propagators used to extract and inject trace
+// context, instead of OTEL_PROPAGATORS.
+func WithPropagators(propagators ...propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = append(c.propagators, propagators...)
+	}
+}
+
+// WithTracerProvider sets the TracerProvider that Middleware and Transport
+// start spans with, instead of the global one registered by Provider.
+// Tracing is enabled whenever a provider is given, with the propagators
+// given by WithPropagators or otherwise the global propagator.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithoutGlobalRegistration stops NewPipeline from registering the otel
+// TracerProvider, propagator and MeterProvider globals, and from bridging the
+// standard logger to the LogProvider. The Pipeline's Options pass its
+// TracerProvider and propagator to Middleware and Transport instead.
+func WithoutGlobalRegistration() Option {
+	return func(c *config) {
+		c.withoutGlobals = true
+	}
+}
+
+// WithResourceAttributes adds attributes to the resource describing the
+// gateway. These take precedence over the OTEL_RESOURCE_ATTRIBUTES variable.
+func WithResourceAttributes(attributes ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.attributes = append(c.attributes, attributes...)
+	}
+}
+
+// WithResourceAttributesFromFile adds the attributes in the file at path to
+// the resource, instead of FAAS_TRACE_RESOURCE_ATTRIBUTES_FILE. The file has
+// a key=value pair on each line, or is a flat YAML mapping of key: value.
+// OTEL_RESOURCE_ATTRIBUTES and WithResourceAttributes take precedence over
+// the file, which is skipped with a warning when it is missing or invalid.
+func WithResourceAttributesFromFile(path string) Option {
+	return func(c *config) {
+		c.attributesFile = path
+	}
+}
+
+// resourceAttributesFile is the path given by WithResourceAttributesFromFile
+// or FAAS_TRACE_RESOURCE_ATTRIBUTES_FILE
+func (c *config) resourceAttributesFile() string {
+	if len(c.attributesFile) > 0 {
+		return c.attributesFile
+	}
+	return os.Getenv(envResourceAttributesFile)
+}
+
+// WithTraceIDHeader sets the response header that Middleware writes the trace
+// ID into, instead of FAAS_TRACE_ID_HEADER. The default is X-Trace-Id.
+func WithTraceIDHeader(name string) Option {
+	return func(c *config) {
+		c.traceIDHeader = name
+	}
+}
+
+// WithOTLPEndpoint sets the host and port of the collector that the OTLP
+// exporter sends spans to, i.e. "otel-collector:4317". This takes precedence
+// over OTEL_EXPORTER_OTLP_ENDPOINT.
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(c *config) {
+		c.otlp.endpoint = endpoint
+	}
+}
+
+// WithOTLPHeaders adds headers to each export made by the OTLP exporter,
+// i.e. for authentication with a hosted collector.
+func WithOTLPHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		if c.otlp.headers == nil {
+			c.otlp.headers = map[string]string{}
+		}
+		for k, v := range headers {
+			c.otlp.headers[k] = v
+		}
+	}
+}
+
+// WithOTLPTLSConfig sets the TLS configuration used to connect to the collector.
+func WithOTLPTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *config) {
+		c.otlp.tlsConfig = tlsConfig
+	}
+}
+
+// WithOTLPInsecure disables TLS when connecting to the collector.
+func WithOTLPInsecure() Option {
+	return func(c *config) {
+		c.otlp.insecure = true
+	}
+}
+
+const (
+	otelEnvOTLPCompression       = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	otelEnvOTLPTracesCompression = "OTEL_EXPORTER_OTLP_TRACES_COMPRESSION"
+)
+
+// WithOTLPCompression sets the compression of exports made by the OTLP
+// exporter to "gzip" or "none", instead of OTEL_EXPORTER_OTLP_COMPRESSION.
+func WithOTLPCompression(compression string) Option {
+	return func(c *config) {
+		c.otlp.compression = compression
+	}
+}
+
+// otlpCompression is given by WithOTLPCompression,
+// OTEL_EXPORTER_OTLP_TRACES_COMPRESSION or OTEL_EXPORTER_OTLP_COMPRESSION,
+// spans are compressed with gzip when none is set or the value is invalid
+func (c *config) otlpCompression() string {
+	val := c.otlp.compression
+	if len(val) == 0 {
+		val = envOTLPCompression()
+	}
+
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "", "gzip":
+		return "gzip"
+	case "none":
+		return "none"
+	default:
+		log.Printf("invalid OTLP compression: %q, use one of gzip, none", val)
+		return "gzip"
+	}
+}
+
+// envOTLPCompression is OTEL_EXPORTER_OTLP_TRACES_COMPRESSION, or
+// OTEL_EXPORTER_OTLP_COMPRESSION when that is not set
+func envOTLPCompression() string {
+	if val := os.Getenv(otelEnvOTLPTracesCompression); len(val) > 0 {
+		return val
+	}
+	return os.Getenv(otelEnvOTLPCompression)
+}
+
+// grpcOptions converts the OTLP settings into options for the gRPC client.
+func (c *config) grpcOptions() []otlptracegrpc.Option {
+	opts := []otlptracegrpc.Option{}
+
+	if len(c.otlp.endpoint) > 0 {
+		opts = append(opts, otlptracegrpc.WithEndpoint(c.otlp.endpoint))
+	}
+	if len(c.otlp.headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(c.otlp.headers))
+	}
+	if c.otlp.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if c.otlp.tlsConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(c.otlp.tlsConfig)))
+	}
+	if c.otlpCompression() == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	} else if envOTLPCompression() == "gzip" {
+		opts = append(opts, withoutGRPCCompression())
+	}
+	opts = append(opts, c.retry().grpc())
+
+	return opts
+}
+
+// withoutGRPCCompression turns off the gzip compression which the gRPC client
+// reads from the environment. WithCompressor only accepts "gzip", and the
+// client's default call option is added after any dial option, so each call
+// is given the identity compressor last instead.
+func withoutGRPCCompression() otlptracegrpc.Option {
+	identity := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(ctx, method, req, reply, cc, append(opts, grpc.UseCompressor(encoding.Identity))...)
+	}
+
+	// WithDialOption replaces the client's own dial options, which only set
+	// its user agent
+	return otlptracegrpc.WithDialOption(
+		grpc.WithUserAgent("OTel OTLP Exporter Go/"+otlptrace.Version()),
+		grpc.WithChainUnaryInterceptor(identity),
+	)
+}
+
+// httpOptions converts the OTLP settings into options for the HTTP client.
+func (c *config) httpOptions() []otlptracehttp.Option {
+	opts := []otlptracehttp.Option{}
+
+	if len(c.otlp.endpoint) > 0 {
+		opts = append(opts, otlptracehttp.WithEndpoint(c.otlp.endpoint))
+	}
+	if len(c.otlp.headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.otlp.headers))
+	}
+	if c.otlp.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if c.otlp.tlsConfig != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(c.otlp.tlsConfig))
+	}
+	if c.otlpCompression() == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	} else {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.NoCompression))
+	}
+	opts = append(opts, c.retry().http())
+
+	return opts
+}
+
+// WithDebugBaggage force samples any request carrying the faas.debug baggage
+// member, whatever the configured sampler decides. This can also be enabled
+// with FAAS_TRACE_DEBUG_BAGGAGE=true.
+func WithDebugBaggage() Option {
+	return func(c *config) {
+		c.debugBaggage = true
+	}
+}
+
+// WithJaegerDebugID force samples any request with the jaeger-debug-id
+// header, and records its value on the span, as Jaeger clients do. Pass it
+// to both Provider and Middleware, or set FAAS_TRACE_JAEGER_DEBUG=true.
+func WithJaegerDebugID() Option {
+	return func(c *config) {
+		c.jaegerDebug = true
+	}
+}
+
+// jaegerDebugEnabled reports whether the jaeger-debug-id header is honoured
+func (c *config) jaegerDebugEnabled() bool {
+	return c.jaegerDebug || strings.ToLower(get(envTraceJaegerDebug, "false")) == "true"
+}
+
+// WithPathRules replaces DefaultPathRules, which Middleware uses to remove
+// high-cardinality segments such as IDs from the names of non-function spans.
+func WithPathRules(rules ...PathRule) Option {
+	return func(c *config) {
+		c.pathRules = append(c.pathRules, rules...)
+	}
+}
+
+// WithSamplingRules samples the requests whose path starts with the prefix
+// of a rule by its ratio, instead of FAAS_TRACE_SAMPLING_RULES. The rule
+// with the longest prefix is used, and requests which match none, or which
+// continue a trace from the caller, are left to the sampler.
+func WithSamplingRules(rules ...SamplingRule) Option {
+	return func(c *config) {
+		c.samplingRules = append(c.samplingRules, rules...)
+	}
+}
+
+// pathSamplingRules are given by WithSamplingRules or FAAS_TRACE_SAMPLING_RULES
+func (c *config) pathSamplingRules() []SamplingRule {
+	if c.samplingRules != nil {
+		return c.samplingRules
+	}
+	return parseSamplingRules(os.Getenv(envTraceSamplingRules))
+}
+
+// WithSpanNameFunc names the server spans created by Middleware with fn,
+// instead of the path or function route, i.e. to use the raw path, or
+// "METHOD route" with the template matched by a router. fn is called after
+// the wrapped handler has returned, so samplers see the default name, and
+// an empty name keeps it.
+func WithSpanNameFunc(fn func(*http.Request) string) Option {
+	return func(c *config) {
+		c.spanNameFunc = fn
+	}
+}
+
+// WithFunctionNameFunc records fn(name) as the FunctionNameKey of the spans
+// created by Middleware, instead of the function's name, i.e. to bucket
+// dynamically named functions with metrics.FunctionLabel.
+func WithFunctionNameFunc(fn func(string) string) Option {
+	return func(c *config) {
+		c.functionNameFunc = fn
+	}
+}
+
+// WithIgnoredPaths replaces DefaultIgnoredPaths, the path prefixes which
+// Middleware passes through without creating a span. Call it with no
+// prefixes to trace every request.
+func WithIgnoredPaths(prefixes ...string) Option {
+	return func(c *config) {
+		c.ignoredPaths = append([]string{}, prefixes...)
+	}
+}
+
+// WithStartupProbe checks that the OTLP collector can be reached when Provider
+// is called, waiting for up to timeout. An unreachable collector is logged as
+// a warning, and does not stop the gateway from starting.
+func WithStartupProbe(timeout time.Duration) Option {
+	return func(c *config) {
+		c.startupProbeTimeout = timeout
+	}
+}
+
+// WithReadinessGate holds back readiness, as reported by Check and
+// Pipeline.Ready, until the first spans have been exported or the OTLP
+// collector can be connected to, for up to timeout. This is instead of
+// FAAS_TRACE_READINESS_TIMEOUT, and is off by default so that the gateway
+// does not wait for a collector which is started after it.
+func WithReadinessGate(timeout time.Duration) Option {
+	return func(c *config) {
+		c.readinessGateTimeout = timeout
+	}
+}
+
+// tracingEnabled reports whether Middleware and Transport record spans,
+// with the given TracerProvider or the global one registered by Provider
+func (c *config) tracingEnabled() bool {
+	return c.tracerProvider != nil || enabled()
+}
+
+// tracer starts spans with the given TracerProvider, or the global one
+func (c *config) tracer() trace.Tracer {
+	if c.tracerProvider != nil {
+		return c.tracerProvider.Tracer(TracerName)
+	}
+	return otel.Tracer(TracerName)
+}
+
+// propagator is made of the given propagators, or is the global propagator
+func (c *config) propagator() propagation.TextMapPropagator {
+	if len(c.propagators) > 0 {
+		return propagation.NewCompositeTextMapPropagator(c.propagators...)
+	}
+	return otel.GetTextMapPropagator()
+}
@@ -0,0 +1,538 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/stats"
+)
+
+// unsetEnv removes an environment variable for the duration of a test.
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func Test_Provider_ConfiguredFromOptions(t *testing.T) {
+	for _, key := range []string{otelEnvTraceSExporter, otelEnvPropagators, otelEnvTracesSampler, otelEnvServiceName} {
+		unsetEnv(t, key)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithPropagators(propagation.TraceContext{}),
+		WithResourceAttributes(attribute.String("team", "platform")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	provider, ok := otel.GetTracerProvider().(*tracesdk.TracerProvider)
+	if !ok {
+		t.Fatalf("want an SDK tracer provider to be registered, got: %T", otel.GetTracerProvider())
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "invoke")
+	span.End()
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span exported, got: %d", len(spans))
+	}
+
+	found := false
+	for _, kv := range spans[0].Resource.Attributes() {
+		if kv.Key == "team" && kv.Value.AsString() == "platform" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("want resource attribute team=platform, got: %v", spans[0].Resource.Attributes())
+	}
+
+	if fields := otel.GetTextMapPropagator().Fields(); len(fields) != 2 {
+		t.Errorf("want only the tracecontext fields, got: %v", fields)
+	}
+}
+
+func Test_Provider_OptionsOverrideEnv(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(DisabledExporter))
+	t.Setenv(otelEnvTracesSampler, samplerAlwaysOff)
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.AlwaysSample()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	_, span := otel.Tracer("test").Start(context.Background(), "invoke")
+	if !span.SpanContext().IsSampled() {
+		t.Errorf("want the sampler option to take precedence over %s", otelEnvTracesSampler)
+	}
+	span.End()
+}
+
+// failingExporter returns err when it is shut down
+type failingExporter struct {
+	*tracetest.InMemoryExporter
+	err error
+}
+
+func (e failingExporter) Shutdown(ctx context.Context) error {
+	return e.err
+}
+
+func Test_Provider_FansOutToEveryExporter(t *testing.T) {
+	unsetEnv(t, otelEnvTracesSampler)
+
+	local := tracetest.NewInMemoryExporter()
+	central := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(local),
+		WithExporter(nil),
+		WithExporter(central),
+		WithSampler(tracesdk.AlwaysSample()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	_, span := otel.Tracer("test").Start(context.Background(), "invoke")
+	span.End()
+
+	if err := otel.GetTracerProvider().(*tracesdk.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	localSpans, centralSpans := local.GetSpans(), central.GetSpans()
+	if len(localSpans) != 1 || len(centralSpans) != 1 {
+		t.Fatalf("want 1 span sent to each exporter, got: %d and %d", len(localSpans), len(centralSpans))
+	}
+	if localSpans[0].SpanContext.SpanID() != centralSpans[0].SpanContext.SpanID() {
+		t.Errorf("want the same span sent to both exporters, got: %s and %s",
+			localSpans[0].SpanContext.SpanID(), centralSpans[0].SpanContext.SpanID())
+	}
+}
+
+func Test_Provider_ShutdownReportsEveryExporter(t *testing.T) {
+	var logged strings.Builder
+	previous := log.Writer()
+	log.SetOutput(&logged)
+	defer log.SetOutput(previous)
+
+	first := failingExporter{InMemoryExporter: tracetest.NewInMemoryExporter(), err: errors.New("first unavailable")}
+	second := failingExporter{InMemoryExporter: tracetest.NewInMemoryExporter(), err: errors.New("second unavailable")}
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(first),
+		WithExporter(second),
+		WithSampler(tracesdk.AlwaysSample()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	_, span := otel.Tracer("test").Start(context.Background(), "invoke")
+	span.End()
+	shutdown(context.Background())
+
+	if len(second.GetSpans()) != 1 {
+		t.Errorf("want the second exporter flushed after the first failed, got: %d spans", len(second.GetSpans()))
+	}
+	for _, want := range []string{"first unavailable", "second unavailable"} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("want %q logged on shutdown, got: %q", want, logged.String())
+		}
+	}
+}
+
+func Test_NewPipeline_WithoutGlobalRegistration(t *testing.T) {
+	unsetEnv(t, otelEnvTracesSampler)
+
+	previousPropagator := otel.GetTextMapPropagator()
+	defer otel.SetTextMapPropagator(previousPropagator)
+
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.Baggage{})
+
+	newPipeline := func(exporter tracesdk.SpanExporter) *Pipeline {
+		pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+			WithExporter(exporter),
+			WithSampler(tracesdk.AlwaysSample()),
+			WithPropagators(propagation.TraceContext{}),
+			WithoutGlobalRegistration(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { pipeline.Shutdown(context.Background()) })
+		return pipeline
+	}
+
+	first, second := tracetest.NewInMemoryExporter(), tracetest.NewInMemoryExporter()
+	firstPipeline, secondPipeline := newPipeline(first), newPipeline(second)
+
+	if _, ok := otel.GetTracerProvider().(noop.TracerProvider); !ok {
+		t.Errorf("want the global tracer provider left alone, got: %T", otel.GetTracerProvider())
+	}
+	if _, ok := otel.GetTextMapPropagator().(propagation.Baggage); !ok {
+		t.Errorf("want the global propagator left alone, got: %T", otel.GetTextMapPropagator())
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	firstHandler := Middleware(ok, firstPipeline.Options()...)
+	secondHandler := Middleware(ok, secondPipeline.Options()...)
+
+	firstHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+	secondHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/env", nil))
+	secondHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/env", nil))
+
+	for _, pipeline := range []*Pipeline{firstPipeline, secondPipeline} {
+		if err := pipeline.TracerProvider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(first.GetSpans()); got != 1 {
+		t.Errorf("want 1 span exported by the first pipeline, got: %d", got)
+	}
+	if got := len(second.GetSpans()); got != 2 {
+		t.Errorf("want 2 spans exported by the second pipeline, got: %d", got)
+	}
+}
+
+func Test_exporterNames(t *testing.T) {
+	cases := map[string][]Exporter{
+		"":                        {},
+		"none":                    {},
+		"otlp":                    {OTELExporter},
+		"otlp,console":            {OTELExporter, StdoutExporter},
+		" OTLP , ,otlp,disabled,": {OTELExporter},
+		"console,none,otlp":       {StdoutExporter, OTELExporter},
+	}
+
+	for val, want := range cases {
+		got := exporterNames(val)
+		if len(got) != len(want) {
+			t.Errorf("%q: want %v, got: %v", val, want, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: want %v, got: %v", val, want, got)
+			}
+		}
+	}
+}
+
+func Test_config_tracesExporterNames_OptionOverridesEnv(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, "otlp")
+
+	if got := newConfig(nil).tracesExporterNames(); len(got) != 1 || got[0] != OTELExporter {
+		t.Errorf("want exporters from %s: [otlp], got: %v", otelEnvTraceSExporter, got)
+	}
+	if got := newConfig([]Option{WithTracesExporter("none")}).tracesExporterNames(); len(got) != 0 {
+		t.Errorf("want no exporters, got: %v", got)
+	}
+	if got := newConfig([]Option{WithTracesExporter("console")}).tracesExporterNames(); len(got) != 1 || got[0] != StdoutExporter {
+		t.Errorf("want exporters from the option: [console], got: %v", got)
+	}
+}
+
+func Test_config_OTLPClientOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		// compression and retry are always set
+		{name: "none", want: 2},
+		{name: "endpoint", opts: []Option{WithOTLPEndpoint("collector:4317")}, want: 3},
+		{name: "endpoint and headers", opts: []Option{WithOTLPEndpoint("collector:4317"), WithOTLPHeaders(map[string]string{"api-key": "secret"})}, want: 4},
+		{name: "tls", opts: []Option{WithOTLPTLSConfig(&tls.Config{ServerName: "collector"})}, want: 3},
+		{name: "insecure wins over tls", opts: []Option{WithOTLPTLSConfig(&tls.Config{}), WithOTLPInsecure()}, want: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newConfig(tc.opts)
+
+			if got := len(cfg.grpcOptions()); got != tc.want {
+				t.Errorf("grpc want: %d options, got: %d", tc.want, got)
+			}
+			if got := len(cfg.httpOptions()); got != tc.want {
+				t.Errorf("http want: %d options, got: %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_config_otlpCompression(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   []Option
+		env    string
+		traces string
+		want   string
+	}{
+		{name: "default", want: "gzip"},
+		{name: "env", env: "none", want: "none"},
+		{name: "traces env wins", env: "none", traces: "gzip", want: "gzip"},
+		{name: "option wins", opts: []Option{WithOTLPCompression("none")}, env: "gzip", want: "none"},
+		{name: "invalid", env: "zstd", want: "gzip"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(otelEnvOTLPCompression, tc.env)
+			t.Setenv(otelEnvOTLPTracesCompression, tc.traces)
+
+			if got := newConfig(tc.opts).otlpCompression(); got != tc.want {
+				t.Errorf("want compression: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// exportOneSpan sends a span to the collector at endpoint over protocol
+func exportOneSpan(t *testing.T, protocol, endpoint string, opts ...Option) {
+	t.Helper()
+
+	opts = append([]Option{WithOTLPEndpoint(endpoint), WithOTLPInsecure()}, opts...)
+	exporter, err := newOTLPExporter(context.Background(), protocol, newConfig(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exporter))
+	_, span := provider.Tracer("test").Start(context.Background(), "invoke")
+	span.End()
+	provider.Shutdown(context.Background())
+}
+
+func Test_newOTLPExporter_HTTP_Compression(t *testing.T) {
+	unsetEnv(t, otelEnvOTLPCompression)
+	unsetEnv(t, otelEnvOTLPTracesCompression)
+
+	encodings := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case encodings <- r.Header.Get("Content-Encoding"):
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+	endpoint := strings.TrimPrefix(collector.URL, "http://")
+
+	cases := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "default", want: "gzip"},
+		{name: "none", opts: []Option{WithOTLPCompression("none")}, want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exportOneSpan(t, "http/protobuf", endpoint, tc.opts...)
+
+			select {
+			case got := <-encodings:
+				if got != tc.want {
+					t.Errorf("want Content-Encoding: %q, got: %q", tc.want, got)
+				}
+			default:
+				t.Fatalf("want spans to be exported to %s", endpoint)
+			}
+		})
+	}
+}
+
+// compressionStats records the compression of each request to a gRPC server
+type compressionStats struct {
+	compressions chan string
+}
+
+func (s *compressionStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (s *compressionStats) HandleRPC(_ context.Context, rs stats.RPCStats) {
+	if header, ok := rs.(*stats.InHeader); ok {
+		select {
+		case s.compressions <- header.Compression:
+		default:
+		}
+	}
+}
+
+func (s *compressionStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (s *compressionStats) HandleConn(context.Context, stats.ConnStats) {}
+
+func Test_newOTLPExporter_GRPC_Compression(t *testing.T) {
+	unsetEnv(t, otelEnvOTLPCompression)
+	unsetEnv(t, otelEnvOTLPTracesCompression)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressions := &compressionStats{compressions: make(chan string, 1)}
+	server := grpc.NewServer(grpc.StatsHandler(compressions))
+	coltracepb.RegisterTraceServiceServer(server, &coltracepb.UnimplementedTraceServiceServer{})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// the export fails as the service is unimplemented, but the request's
+	// headers are still received
+	cases := []struct {
+		name string
+		opts []Option
+		env  string
+		want string
+	}{
+		{name: "default", want: "gzip"},
+		{name: "none", opts: []Option{WithOTLPCompression("none")}, want: ""},
+		{name: "none overrides env", opts: []Option{WithOTLPCompression("none")}, env: "gzip", want: encoding.Identity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(otelEnvOTLPCompression, tc.env)
+			exportOneSpan(t, "grpc", listener.Addr().String(), tc.opts...)
+
+			select {
+			case got := <-compressions.compressions:
+				if got != tc.want {
+					t.Errorf("want grpc-encoding: %q, got: %q", tc.want, got)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatalf("want spans to be exported to %s", listener.Addr())
+			}
+		})
+	}
+}
+
+func Test_newOTLPExporter_GRPC_NoneOverridesEnvWithoutError(t *testing.T) {
+	t.Setenv(otelEnvOTLPCompression, "gzip")
+	unsetEnv(t, otelEnvOTLPTracesCompression)
+
+	var handled []error
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		handled = append(handled, err)
+	}))
+	defer otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Print(err)
+	}))
+
+	cfg := newConfig([]Option{WithOTLPEndpoint("127.0.0.1:4317"), WithOTLPInsecure(), WithOTLPCompression("none")})
+	exporter, err := newOTLPExporter(context.Background(), "grpc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Shutdown(context.Background())
+
+	if len(handled) > 0 {
+		t.Errorf("want no errors given to otel.Handle, got: %v", handled)
+	}
+}
+
+func Test_config_WithOTLPHeaders_Merges(t *testing.T) {
+	cfg := newConfig([]Option{
+		WithOTLPHeaders(map[string]string{"a": "1"}),
+		WithOTLPHeaders(map[string]string{"b": "2"}),
+	})
+
+	if len(cfg.otlp.headers) != 2 {
+		t.Errorf("want 2 headers, got: %v", cfg.otlp.headers)
+	}
+}
+
+func Test_newOTLPExporter_HTTP_UsesConfiguredEndpointAndHeaders(t *testing.T) {
+	unsetEnv(t, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	unsetEnv(t, "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+
+	type received struct {
+		path   string
+		apiKey string
+	}
+	requests := make(chan received, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- received{path: r.URL.Path, apiKey: r.Header.Get("api-key")}:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	endpoint := strings.TrimPrefix(collector.URL, "http://")
+	cfg := newConfig([]Option{
+		WithOTLPEndpoint(endpoint),
+		WithOTLPHeaders(map[string]string{"api-key": "secret"}),
+		WithOTLPInsecure(),
+	})
+
+	exporter, err := newOTLPExporter(context.Background(), "http/protobuf", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exporter))
+	_, span := provider.Tracer("test").Start(context.Background(), "invoke")
+	span.End()
+	provider.Shutdown(context.Background())
+
+	select {
+	case got := <-requests:
+		if got.path != "/v1/traces" {
+			t.Errorf("want path: /v1/traces, got: %s", got.path)
+		}
+		if got.apiKey != "secret" {
+			t.Errorf("want api-key header: secret, got: %q", got.apiKey)
+		}
+	default:
+		t.Fatalf("want spans to be exported to the configured endpoint %s", endpoint)
+	}
+}
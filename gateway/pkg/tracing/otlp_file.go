@@ -0,0 +1,317 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	envTraceOTLPConfigFile       = "FAAS_TRACE_OTLP_CONFIG_FILE"
+	envTraceOTLPConfigPrecedence = "FAAS_TRACE_OTLP_CONFIG_PRECEDENCE"
+
+	otelEnvOTLPTracesProtocol = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	otelEnvOTLPTracesHeaders  = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+	otelEnvOTLPTracesInsecure = "OTEL_EXPORTER_OTLP_TRACES_INSECURE"
+)
+
+// otlpConfigReloadInterval is how often the file given to WithOTLPConfigFile
+// is checked for changes
+var otlpConfigReloadInterval = time.Second * 10
+
+// otlpFile is the OTLP exporter settings read from the file given to
+// WithOTLPConfigFile, i.e.
+//
+//	{
+//	  "endpoint": "otel-collector.monitoring:4317",
+//	  "protocol": "grpc",
+//	  "headers": {"x-api-key": "..."},
+//	  "insecure": true
+//	}
+type otlpFile struct {
+	Endpoint string            `json:"endpoint"`
+	Protocol string            `json:"protocol"`
+	Headers  map[string]string `json:"headers"`
+	Insecure bool              `json:"insecure"`
+
+	modTime time.Time
+}
+
+// WithOTLPConfigFile reads the OTLP exporter's endpoint, protocol, headers
+// and insecure flag from a JSON file, such as a mounted ConfigMap, instead
+// of FAAS_TRACE_OTLP_CONFIG_FILE. The file takes precedence over the
+// defaults, the OTEL_EXPORTER_OTLP_* variables take precedence over the
+// file unless WithOTLPConfigFileOverEnv is given, and options always do.
+// The file is watched, and the trace exporter is rebuilt when it changes.
+func WithOTLPConfigFile(path string) Option {
+	return func(c *config) {
+		c.otlpConfigFile = path
+	}
+}
+
+// WithOTLPConfigFileOverEnv gives the file from WithOTLPConfigFile
+// precedence over the OTEL_EXPORTER_OTLP_* variables, instead of
+// FAAS_TRACE_OTLP_CONFIG_PRECEDENCE=file.
+func WithOTLPConfigFileOverEnv() Option {
+	return func(c *config) {
+		c.otlpConfigFileOverEnv = true
+	}
+}
+
+// otlpConfigFilePath is given by WithOTLPConfigFile or
+// FAAS_TRACE_OTLP_CONFIG_FILE
+func (c *config) otlpConfigFilePath() string {
+	if len(c.otlpConfigFile) > 0 {
+		return c.otlpConfigFile
+	}
+	return os.Getenv(envTraceOTLPConfigFile)
+}
+
+// otlpFileOverEnv reports whether the file takes precedence over the env
+func (c *config) otlpFileOverEnv() bool {
+	return c.otlpConfigFileOverEnv || strings.ToLower(os.Getenv(envTraceOTLPConfigPrecedence)) == "file"
+}
+
+// otlpProtocol is the protocol from the config file, or
+// OTEL_EXPORTER_OTLP_PROTOCOL, "grpc" when neither is set
+func (c *config) otlpProtocol() string {
+	if len(c.otlp.protocol) > 0 {
+		return c.otlp.protocol
+	}
+	return get(otelExpOTLPProtocol, "grpc")
+}
+
+// readOTLPFile reads and validates the OTLP config file
+func readOTLPFile(path string) (*otlpFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &otlpFile{modTime: info.ModTime()}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(file.Protocol)) {
+	case "", "grpc", "http", "http/protobuf":
+	default:
+		return nil, fmt.Errorf("unsupported protocol in %s: %q, use one of grpc, http/protobuf", path, file.Protocol)
+	}
+
+	return file, nil
+}
+
+// loadOTLPConfigFile merges the config file into the OTLP settings, a file
+// which is missing or invalid is logged and skipped, as with the resource
+// attributes file
+func (c *config) loadOTLPConfigFile() {
+	c.otlpGiven = c.otlp
+
+	path := c.otlpConfigFilePath()
+	if len(path) == 0 {
+		return
+	}
+
+	file, err := readOTLPFile(path)
+	if err != nil {
+		log.Printf("warning: unable to read OTLP config, skipping %s: %s", path, err)
+		return
+	}
+
+	c.otlpFile = file
+	c.otlp = c.withOTLPFile(file)
+}
+
+// withOTLPFile is the OTLP settings given as options, with the settings
+// from file filling in those which were not. Unless the file takes
+// precedence, a setting in the env is left for the exporter to read.
+func (c *config) withOTLPFile(file *otlpFile) otlpConfig {
+	merged := c.otlp
+	fileWins := c.otlpFileOverEnv()
+
+	unset := func(names ...string) bool {
+		if fileWins {
+			return true
+		}
+		for _, name := range names {
+			if _, ok := os.LookupEnv(name); ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	if len(merged.endpoint) == 0 && len(file.Endpoint) > 0 && unset(otelEnvOTLPEndpoint, otelEnvOTLPTracesEndpoint) {
+		merged.endpoint = file.Endpoint
+	}
+	if len(merged.protocol) == 0 && len(file.Protocol) > 0 && unset(otelExpOTLPProtocol, otelEnvOTLPTracesProtocol) {
+		merged.protocol = file.Protocol
+	}
+	if !merged.insecure && merged.tlsConfig == nil && file.Insecure && unset(otelEnvOTLPInsecure, otelEnvOTLPTracesInsecure) {
+		merged.insecure = true
+	}
+
+	if len(file.Headers) > 0 {
+		// headers given to the exporter replace those in the env, so the
+		// env's are merged in here rather than left for it to read
+		headers := map[string]string{}
+		env := envOTLPHeaders()
+		if fileWins {
+			mergeHeaders(headers, env, file.Headers)
+		} else {
+			mergeHeaders(headers, file.Headers, env)
+		}
+		merged.headers = mergeHeaders(headers, c.otlp.headers)
+	}
+
+	return merged
+}
+
+// envOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS, then
+// OTEL_EXPORTER_OTLP_TRACES_HEADERS, lists of key=value pairs
+func envOTLPHeaders() map[string]string {
+	headers := map[string]string{}
+	for _, name := range []string{otelEnvOTLPHeaders, otelEnvOTLPTracesHeaders} {
+		for _, pair := range splitList(os.Getenv(name)) {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return headers
+}
+
+// mergeHeaders copies each of from into to in turn, so the last wins
+func mergeHeaders(to map[string]string, from ...map[string]string) map[string]string {
+	for _, headers := range from {
+		for k, v := range headers {
+			to[k] = v
+		}
+	}
+	return to
+}
+
+// reloadingExporter rebuilds the OTLP trace exporter when the file given to
+// WithOTLPConfigFile changes. The exporter in use is kept when the file can
+// not be read, or a new exporter can not be created from it.
+type reloadingExporter struct {
+	// cfg holds the settings given as options, without the file
+	cfg     *config
+	path    string
+	modTime time.Time
+
+	lock     sync.RWMutex
+	exporter tracesdk.SpanExporter
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// newReloadingExporter wraps the exporter built from cfg, and watches the
+// config file until Shutdown
+func newReloadingExporter(exporter tracesdk.SpanExporter, cfg *config) *reloadingExporter {
+	base := *cfg
+	base.otlp = cfg.otlpGiven
+
+	e := &reloadingExporter{
+		cfg:      &base,
+		path:     cfg.otlpConfigFilePath(),
+		exporter: exporter,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.otlpFile != nil {
+		e.modTime = cfg.otlpFile.modTime
+	}
+
+	go e.watch()
+	return e
+}
+
+func (e *reloadingExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.exporter.ExportSpans(ctx, spans)
+}
+
+func (e *reloadingExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	<-e.done
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e *reloadingExporter) watch() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otlpConfigReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.reload(context.Background())
+		}
+	}
+}
+
+// reload rebuilds the exporter when the file has been modified since it was
+// last read, and reports whether it was
+func (e *reloadingExporter) reload(ctx context.Context) bool {
+	info, err := os.Stat(e.path)
+	if err != nil || info.ModTime().Equal(e.modTime) {
+		return false
+	}
+
+	file, err := readOTLPFile(e.path)
+	if err != nil {
+		log.Printf("warning: unable to reload OTLP config, keeping the current exporter: %s", err)
+		e.modTime = info.ModTime()
+		return false
+	}
+	e.modTime = file.modTime
+
+	cfg := *e.cfg
+	cfg.otlp = e.cfg.withOTLPFile(file)
+
+	exporter, err := newOTLPExporter(ctx, cfg.otlpProtocol(), &cfg)
+	if err != nil {
+		log.Printf("warning: unable to apply the OTLP config from %s, restart the gateway to apply it: %s", e.path, err)
+		return false
+	}
+
+	e.lock.Lock()
+	previous := e.exporter
+	e.exporter = exporter
+	e.lock.Unlock()
+
+	if err := previous.Shutdown(ctx); err != nil {
+		log.Printf("failed to shutdown the previous OTLP exporter: %s", err)
+	}
+
+	log.Printf("reloaded the OTLP trace exporter from %s, OTLP metrics and logs keep their settings until a restart", e.path)
+	return true
+}
@@ -0,0 +1,231 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func writeOTLPFile(t *testing.T, dir, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "otlp.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetOTLPEnv clears the variables which take precedence over the file
+func unsetOTLPEnv(t *testing.T) {
+	t.Helper()
+
+	for _, name := range []string{
+		otelEnvOTLPEndpoint, otelEnvOTLPTracesEndpoint,
+		otelExpOTLPProtocol, otelEnvOTLPTracesProtocol,
+		otelEnvOTLPHeaders, otelEnvOTLPTracesHeaders,
+		otelEnvOTLPInsecure, otelEnvOTLPTracesInsecure,
+		envTraceOTLPConfigFile, envTraceOTLPConfigPrecedence,
+	} {
+		unsetEnv(t, name)
+	}
+}
+
+func Test_loadOTLPConfigFile_OverDefaults(t *testing.T) {
+	unsetOTLPEnv(t)
+	path := writeOTLPFile(t, t.TempDir(), `{
+		"endpoint": "collector.monitoring:4318",
+		"protocol": "http/protobuf",
+		"headers": {"x-api-key": "secret"},
+		"insecure": true
+	}`)
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(path)})
+	cfg.loadOTLPConfigFile()
+
+	if cfg.otlp.endpoint != "collector.monitoring:4318" {
+		t.Errorf("want endpoint from the file, got: %q", cfg.otlp.endpoint)
+	}
+	if got := cfg.otlpProtocol(); got != "http/protobuf" {
+		t.Errorf("want protocol from the file, got: %q", got)
+	}
+	if got := cfg.otlp.headers["x-api-key"]; got != "secret" {
+		t.Errorf("want header from the file, got: %q", got)
+	}
+	if !cfg.otlp.insecure {
+		t.Errorf("want insecure from the file")
+	}
+	if got := collectorAddress(cfg.otlpProtocol(), cfg); got != "collector.monitoring:4318" {
+		t.Errorf("want the probe to use the endpoint from the file, got: %s", got)
+	}
+}
+
+func Test_loadOTLPConfigFile_FromEnvPath(t *testing.T) {
+	unsetOTLPEnv(t)
+	t.Setenv(envTraceOTLPConfigFile, writeOTLPFile(t, t.TempDir(), `{"endpoint": "collector:4317"}`))
+
+	cfg := newConfig(nil)
+	cfg.loadOTLPConfigFile()
+
+	if cfg.otlp.endpoint != "collector:4317" {
+		t.Errorf("want endpoint from %s, got: %q", envTraceOTLPConfigFile, cfg.otlp.endpoint)
+	}
+}
+
+func Test_loadOTLPConfigFile_EnvOverFile(t *testing.T) {
+	unsetOTLPEnv(t)
+	t.Setenv(otelEnvOTLPEndpoint, "env-collector:4317")
+	t.Setenv(otelExpOTLPProtocol, "grpc")
+	t.Setenv(otelEnvOTLPHeaders, "x-api-key=from-env,x-team=a")
+	path := writeOTLPFile(t, t.TempDir(), `{
+		"endpoint": "file-collector:4318",
+		"protocol": "http/protobuf",
+		"headers": {"x-api-key": "from-file", "x-tenant": "b"}
+	}`)
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(path)})
+	cfg.loadOTLPConfigFile()
+
+	if len(cfg.otlp.endpoint) != 0 {
+		t.Errorf("want the endpoint left for the exporter to read from the env, got: %q", cfg.otlp.endpoint)
+	}
+	if got := cfg.otlpProtocol(); got != "grpc" {
+		t.Errorf("want protocol from the env, got: %q", got)
+	}
+
+	want := map[string]string{"x-api-key": "from-env", "x-team": "a", "x-tenant": "b"}
+	for k, v := range want {
+		if got := cfg.otlp.headers[k]; got != v {
+			t.Errorf("want header %s: %q, got: %q", k, v, got)
+		}
+	}
+}
+
+func Test_loadOTLPConfigFile_FileOverEnv(t *testing.T) {
+	unsetOTLPEnv(t)
+	t.Setenv(otelEnvOTLPEndpoint, "env-collector:4317")
+	t.Setenv(otelEnvOTLPHeaders, "x-api-key=from-env,x-team=a")
+	t.Setenv(envTraceOTLPConfigPrecedence, "file")
+	path := writeOTLPFile(t, t.TempDir(), `{
+		"endpoint": "file-collector:4317",
+		"headers": {"x-api-key": "from-file"}
+	}`)
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(path)})
+	cfg.loadOTLPConfigFile()
+
+	if cfg.otlp.endpoint != "file-collector:4317" {
+		t.Errorf("want endpoint from the file, got: %q", cfg.otlp.endpoint)
+	}
+	if got := cfg.otlp.headers["x-api-key"]; got != "from-file" {
+		t.Errorf("want header from the file, got: %q", got)
+	}
+	if got := cfg.otlp.headers["x-team"]; got != "a" {
+		t.Errorf("want headers only in the env to be kept, got: %q", got)
+	}
+}
+
+func Test_loadOTLPConfigFile_OptionsOverFile(t *testing.T) {
+	unsetOTLPEnv(t)
+	path := writeOTLPFile(t, t.TempDir(), `{
+		"endpoint": "file-collector:4317",
+		"headers": {"x-api-key": "from-file"}
+	}`)
+
+	cfg := newConfig([]Option{
+		WithOTLPConfigFile(path),
+		WithOTLPConfigFileOverEnv(),
+		WithOTLPEndpoint("option-collector:4317"),
+		WithOTLPHeaders(map[string]string{"x-api-key": "from-option"}),
+	})
+	cfg.loadOTLPConfigFile()
+
+	if cfg.otlp.endpoint != "option-collector:4317" {
+		t.Errorf("want endpoint from the option, got: %q", cfg.otlp.endpoint)
+	}
+	if got := cfg.otlp.headers["x-api-key"]; got != "from-option" {
+		t.Errorf("want header from the option, got: %q", got)
+	}
+}
+
+func Test_loadOTLPConfigFile_SkipsInvalidFile(t *testing.T) {
+	unsetOTLPEnv(t)
+	dir := t.TempDir()
+
+	for _, contents := range []string{
+		`{"endpoint": `,
+		`{"endpoint": "collector:4317", "timeout": "10s"}`,
+		`{"endpoint": "collector:4317", "protocol": "udp"}`,
+	} {
+		cfg := newConfig([]Option{WithOTLPConfigFile(writeOTLPFile(t, dir, contents))})
+		cfg.loadOTLPConfigFile()
+
+		if cfg.otlpFile != nil || len(cfg.otlp.endpoint) != 0 {
+			t.Errorf("want %s to be skipped, got endpoint: %q", contents, cfg.otlp.endpoint)
+		}
+	}
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(filepath.Join(dir, "missing.json"))})
+	cfg.loadOTLPConfigFile()
+	if cfg.otlpFile != nil {
+		t.Errorf("want a missing file to be skipped")
+	}
+}
+
+func Test_reloadingExporter_RebuildsOnChange(t *testing.T) {
+	unsetOTLPEnv(t)
+	path := writeOTLPFile(t, t.TempDir(), `{"endpoint": "collector-a:4317", "insecure": true}`)
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(path)})
+	cfg.loadOTLPConfigFile()
+
+	initial := tracetest.NewInMemoryExporter()
+	exporter := newReloadingExporter(initial, cfg)
+	defer exporter.Shutdown(context.Background())
+
+	if exporter.reload(context.Background()) {
+		t.Fatalf("want no reload when the file has not changed")
+	}
+
+	writeOTLPFile(t, filepath.Dir(path), `{"endpoint": "collector-b:4317", "insecure": true}`)
+	modified := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exporter.reload(context.Background()) {
+		t.Fatalf("want a reload when the file has changed")
+	}
+	if exporter.exporter == initial {
+		t.Errorf("want the exporter to be replaced")
+	}
+}
+
+func Test_reloadingExporter_KeepsExporterWhenInvalid(t *testing.T) {
+	unsetOTLPEnv(t)
+	path := writeOTLPFile(t, t.TempDir(), `{"endpoint": "collector-a:4317"}`)
+
+	cfg := newConfig([]Option{WithOTLPConfigFile(path)})
+	cfg.loadOTLPConfigFile()
+
+	initial := tracetest.NewInMemoryExporter()
+	exporter := newReloadingExporter(initial, cfg)
+	defer exporter.Shutdown(context.Background())
+
+	writeOTLPFile(t, filepath.Dir(path), `{"endpoint": `)
+	modified := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	if exporter.reload(context.Background()) {
+		t.Fatalf("want no reload for an invalid file")
+	}
+	if exporter.exporter != initial {
+		t.Errorf("want the current exporter to be kept")
+	}
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	otelEnvOTLPEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otelEnvOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// collectorAddress finds the host:port of the collector that the OTLP
+// exporter will connect to, following the same precedence as the exporter:
+// an option, then the traces endpoint, then the general OTLP endpoint.
+func collectorAddress(protocol string, cfg *config) string {
+	return signalAddress(protocol, cfg, otelEnvOTLPTracesEndpoint)
+}
+
+// signalAddress finds the host:port of the collector for one signal, with
+// signalEnv being its OTEL_EXPORTER_OTLP_<SIGNAL>_ENDPOINT variable
+func signalAddress(protocol string, cfg *config, signalEnv string) string {
+	defaultPort := "4317"
+	if strings.HasPrefix(strings.ToLower(protocol), "http") {
+		defaultPort = "4318"
+	}
+
+	endpoint := cfg.otlp.endpoint
+	if len(endpoint) == 0 {
+		endpoint = os.Getenv(signalEnv)
+	}
+	if len(endpoint) == 0 {
+		endpoint = os.Getenv(otelEnvOTLPEndpoint)
+	}
+	if len(endpoint) == 0 {
+		return net.JoinHostPort("localhost", defaultPort)
+	}
+
+	if strings.Contains(endpoint, "://") {
+		if u, err := url.Parse(endpoint); err == nil && len(u.Host) > 0 {
+			endpoint = u.Host
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		endpoint = net.JoinHostPort(strings.Trim(endpoint, "[]"), defaultPort)
+	}
+
+	return endpoint
+}
+
+// probeCollector checks that a TCP connection can be made to the collector
+// within the timeout. The exporters connect lazily, so without this an
+// unreachable collector is not noticed until spans start to be dropped.
+func probeCollector(ctx context.Context, address string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func Test_collectorAddress(t *testing.T) {
+	cases := []struct {
+		name     string
+		protocol string
+		option   string
+		traces   string
+		general  string
+		want     string
+	}{
+		{name: "grpc default", protocol: "grpc", want: "localhost:4317"},
+		{name: "http default", protocol: "http/protobuf", want: "localhost:4318"},
+		{name: "option wins", protocol: "grpc", option: "collector:9000", traces: "http://traces:4317", want: "collector:9000"},
+		{name: "traces endpoint wins over general", protocol: "grpc", traces: "http://traces:4317", general: "http://general:4317", want: "traces:4317"},
+		{name: "general endpoint", protocol: "http", general: "https://general:443/v1/traces", want: "general:443"},
+		{name: "url without port", protocol: "http", general: "http://general", want: "general:4318"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unsetEnv(t, otelEnvOTLPTracesEndpoint)
+			unsetEnv(t, otelEnvOTLPEndpoint)
+			if len(tc.traces) > 0 {
+				t.Setenv(otelEnvOTLPTracesEndpoint, tc.traces)
+			}
+			if len(tc.general) > 0 {
+				t.Setenv(otelEnvOTLPEndpoint, tc.general)
+			}
+
+			cfg := &config{}
+			cfg.otlp.endpoint = tc.option
+
+			if got := collectorAddress(tc.protocol, cfg); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_probeCollector_ReachableEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := probeCollector(context.Background(), listener.Addr().String(), time.Second); err != nil {
+		t.Errorf("want no error for a listening endpoint, got: %s", err)
+	}
+}
+
+func Test_Provider_StartupProbe_DeadEndpointStillStarts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddress := listener.Addr().String()
+	listener.Close()
+
+	t.Setenv(otelEnvTraceSExporter, string(OTELExporter))
+	t.Setenv(otelExpOTLPProtocol, "grpc")
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	start := time.Now()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithOTLPEndpoint(deadAddress),
+		WithOTLPInsecure(),
+		WithStartupProbe(time.Millisecond*500),
+	)
+	if err != nil {
+		t.Fatalf("want the provider to start with an unreachable collector, got: %s", err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		shutdown(ctx)
+	}()
+
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("want the probe to be bounded by its timeout, took: %s", elapsed)
+	}
+
+	if !strings.Contains(logs.String(), "unreachable") || !strings.Contains(logs.String(), deadAddress) {
+		t.Errorf("want a warning about the unreachable collector, got: %q", logs.String())
+	}
+}
@@ -0,0 +1,211 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dropWarningInterval is the least time between warnings about dropped spans
+var dropWarningInterval = time.Minute
+
+// droppedSpans counts the spans dropped by every pipeline which is not given
+// WithDroppedSpansCounter
+var droppedSpans = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "gateway",
+	Subsystem: "tracing",
+	Name:      "dropped_spans_total",
+	Help:      "Spans dropped because the export queue was full.",
+})
+
+var registerDroppedSpans sync.Once
+
+// WithMaxQueueSize sets how many ended spans each exporter's batcher holds
+// while waiting to export them, instead of OTEL_BSP_MAX_QUEUE_SIZE. Spans
+// which end while the queue is full are dropped. The default is 2048.
+func WithMaxQueueSize(size int) Option {
+	return func(c *config) {
+		c.maxQueueSize = size
+	}
+}
+
+// WithMaxExportBatchSize sets the most spans sent in each export, instead of
+// OTEL_BSP_MAX_EXPORT_BATCH_SIZE. The default is 512.
+func WithMaxExportBatchSize(size int) Option {
+	return func(c *config) {
+		c.maxExportBatchSize = size
+	}
+}
+
+// WithDroppedSpansCounter counts the spans dropped because the export queue
+// was full with counter, instead of gateway_tracing_dropped_spans_total.
+func WithDroppedSpansCounter(counter prometheus.Counter) Option {
+	return func(c *config) {
+		c.droppedSpans = counter
+	}
+}
+
+// batchOptions are the queue and batch sizes given as options, the
+// batcher reads the OTEL_BSP_* variables for those which are not
+func (c *config) batchOptions() []tracesdk.BatchSpanProcessorOption {
+	opts := []tracesdk.BatchSpanProcessorOption{}
+	if c.maxQueueSize > 0 {
+		opts = append(opts, tracesdk.WithMaxQueueSize(c.maxQueueSize))
+	}
+	if c.maxExportBatchSize > 0 {
+		opts = append(opts, tracesdk.WithMaxExportBatchSize(c.maxExportBatchSize))
+	}
+	return opts
+}
+
+// droppedSpansCounter is given by WithDroppedSpansCounter, or is
+// gateway_tracing_dropped_spans_total in the default Prometheus registry
+func (c *config) droppedSpansCounter() prometheus.Counter {
+	if c.droppedSpans != nil {
+		return c.droppedSpans
+	}
+	registerDroppedSpans.Do(func() {
+		prometheus.MustRegister(droppedSpans)
+	})
+	return droppedSpans
+}
+
+// dropCounter works out how many spans the batcher dropped, which it does
+// not report. Each sampled span is numbered as it ends, and the batcher
+// exports spans in the order that they ended, so any span numbered before
+// the last one in the previous export, which has not been exported since,
+// was dropped. Waiting for the next export allows for spans which were
+// numbered in one order and queued in the other by concurrent requests.
+type dropCounter struct {
+	seq     atomic.Uint64
+	pending sync.Map // trace.SpanID to its sequence number
+
+	// mu is only taken on the export path, the batcher exports one batch
+	// at a time
+	mu      sync.Mutex
+	settled uint64
+
+	counter     prometheus.Counter
+	unreported  atomic.Int64
+	lastWarning atomic.Int64
+}
+
+func newDropCounter(counter prometheus.Counter) *dropCounter {
+	return &dropCounter{counter: counter}
+}
+
+// ended numbers a span given to the batcher
+func (d *dropCounter) ended(id trace.SpanID) {
+	d.pending.Store(id, d.seq.Add(1))
+}
+
+// exported removes spans from pending, and counts those left over from
+// before the previous export as dropped
+func (d *dropCounter) exported(spans []tracesdk.ReadOnlySpan) {
+	if len(spans) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var last uint64
+	for _, s := range spans {
+		if seq, ok := d.pending.LoadAndDelete(s.SpanContext().SpanID()); ok && seq.(uint64) > last {
+			last = seq.(uint64)
+		}
+	}
+
+	dropped := 0
+	d.pending.Range(func(id, seq any) bool {
+		if seq.(uint64) < d.settled {
+			d.pending.Delete(id)
+			dropped++
+		}
+		return true
+	})
+	if last > d.settled {
+		d.settled = last
+	}
+
+	d.drop(dropped)
+}
+
+// stopped counts the spans still pending once the batcher has exported
+// everything it queued
+func (d *dropCounter) stopped() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dropped := 0
+	d.pending.Range(func(id, _ any) bool {
+		d.pending.Delete(id)
+		dropped++
+		return true
+	})
+
+	d.drop(dropped)
+}
+
+// drop adds n to the counter, and logs a warning no more than once in each
+// dropWarningInterval
+func (d *dropCounter) drop(n int) {
+	if n <= 0 {
+		return
+	}
+	d.counter.Add(float64(n))
+	d.unreported.Add(int64(n))
+
+	now := time.Now().UnixNano()
+	last := d.lastWarning.Load()
+	if last > 0 && now-last < int64(dropWarningInterval) {
+		return
+	}
+	if !d.lastWarning.CompareAndSwap(last, now) {
+		return
+	}
+
+	log.Printf("warning: tracing: dropped %d spans as the export queue was full, raise OTEL_BSP_MAX_QUEUE_SIZE or sample fewer traces", d.unreported.Swap(0))
+}
+
+// countedProcessor numbers the spans given to the batcher
+type countedProcessor struct {
+	tracesdk.SpanProcessor
+	drops *dropCounter
+}
+
+func (p countedProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	// the batcher only queues sampled spans
+	if !s.SpanContext().IsSampled() {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+
+	p.drops.ended(s.SpanContext().SpanID())
+	p.SpanProcessor.OnEnd(s)
+}
+
+func (p countedProcessor) Shutdown(ctx context.Context) error {
+	err := p.SpanProcessor.Shutdown(ctx)
+	if err == nil {
+		p.drops.stopped()
+	}
+	return err
+}
+
+// countedExporter tells the dropCounter which spans reached the exporter
+type countedExporter struct {
+	tracesdk.SpanExporter
+	drops *dropCounter
+}
+
+func (e countedExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	e.drops.exported(spans)
+	return e.SpanExporter.ExportSpans(ctx, spans)
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// slowExporter holds each export until release is closed
+type slowExporter struct {
+	release  chan struct{}
+	exported atomic.Int64
+}
+
+func (e *slowExporter) ExportSpans(_ context.Context, spans []tracesdk.ReadOnlySpan) error {
+	<-e.release
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+func (e *slowExporter) Shutdown(context.Context) error { return nil }
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := counter.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func Test_Pipeline_CountsSpansDroppedByFullQueue(t *testing.T) {
+	exporter := &slowExporter{release: make(chan struct{})}
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped_spans_total"})
+
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithExporter(exporter),
+		WithMaxQueueSize(2),
+		WithMaxExportBatchSize(1),
+		WithDroppedSpansCounter(counter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const total = 20
+	tracer := pipeline.TracerProvider.Tracer("test")
+	for i := 0; i < total; i++ {
+		_, span := tracer.Start(context.Background(), "request")
+		span.End()
+	}
+
+	close(exporter.release)
+	pipeline.Shutdown(context.Background())
+
+	dropped := counterValue(t, counter)
+	if dropped == 0 {
+		t.Fatalf("want dropped spans to be counted")
+	}
+	if got := int64(dropped) + exporter.exported.Load(); got != total {
+		t.Errorf("want dropped and exported spans to add up to %d, got: %d (dropped: %.0f)", total, got, dropped)
+	}
+}
+
+func Test_Pipeline_CountsNoDropsWhenQueueHasRoom(t *testing.T) {
+	exporter := &collectorExporter{}
+	exporter.available.Store(true)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped_spans_total"})
+
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithExporter(exporter),
+		WithMaxExportBatchSize(2),
+		WithDroppedSpansCounter(counter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		exportSpan(t, pipeline)
+	}
+	pipeline.Shutdown(context.Background())
+
+	if got := exporter.exported.Load(); got != 5 {
+		t.Errorf("want 5 spans exported, got: %d", got)
+	}
+	if dropped := counterValue(t, counter); dropped != 0 {
+		t.Errorf("want no dropped spans, got: %.0f", dropped)
+	}
+}
+
+func Test_Pipeline_CountsNoDropsFromConcurrentRequests(t *testing.T) {
+	exporter := &collectorExporter{}
+	exporter.available.Store(true)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped_spans_total"})
+
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithExporter(exporter),
+		WithMaxQueueSize(1000),
+		WithMaxExportBatchSize(10),
+		WithDroppedSpansCounter(counter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, spans = 10, 50
+	tracer := pipeline.TracerProvider.Tracer("test")
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < spans; i++ {
+				_, span := tracer.Start(context.Background(), "request")
+				span.End()
+			}
+		}()
+	}
+	wg.Wait()
+	pipeline.Shutdown(context.Background())
+
+	dropped := counterValue(t, counter)
+	if got := int64(dropped) + exporter.exported.Load(); got != workers*spans {
+		t.Errorf("want dropped and exported spans to add up to %d, got: %d (dropped: %.0f)", workers*spans, got, dropped)
+	}
+}
+
+func Test_batchOptions_OnlyGivenSizes(t *testing.T) {
+	if opts := newConfig(nil).batchOptions(); len(opts) != 0 {
+		t.Errorf("want no options so that OTEL_BSP_* is read, got: %d", len(opts))
+	}
+
+	opts := newConfig([]Option{WithMaxQueueSize(100), WithMaxExportBatchSize(10)}).batchOptions()
+	if len(opts) != 2 {
+		t.Fatalf("want 2 options, got: %d", len(opts))
+	}
+
+	o := &tracesdk.BatchSpanProcessorOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.MaxQueueSize != 100 || o.MaxExportBatchSize != 10 {
+		t.Errorf("want queue size 100 and batch size 10, got: %d and %d", o.MaxQueueSize, o.MaxExportBatchSize)
+	}
+}
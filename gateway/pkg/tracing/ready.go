@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const envTraceReadinessTimeout = "FAAS_TRACE_READINESS_TIMEOUT"
+
+// readinessProbeInterval is how often the gate tries to connect to the OTLP
+// collector until it can be reached
+var readinessProbeInterval = time.Second
+
+// errNotReady is returned by a readiness gate which is still closed
+var errNotReady = errors.New("waiting for spans to be exported to the collector")
+
+// globalGate is the gate of the pipeline registered by Provider, which
+// Check consults
+var globalGate atomic.Pointer[readinessGate]
+
+// readinessGate opens once spans can be exported, so that the gateway does
+// not take traffic whose traces would be lost while the collector is
+// starting. It opens on the first successful export, when the OTLP collector
+// can be connected to, or once its timeout has passed, whichever is first.
+type readinessGate struct {
+	once   sync.Once
+	ready  chan struct{}
+	cancel context.CancelFunc
+}
+
+func newReadinessGate() *readinessGate {
+	return &readinessGate{ready: make(chan struct{}), cancel: func() {}}
+}
+
+// open lets readiness pass from now on, reason is logged the first time
+func (g *readinessGate) open(reason string) {
+	if g == nil {
+		return
+	}
+	g.once.Do(func() {
+		log.Printf("tracing: ready, %s", reason)
+		close(g.ready)
+	})
+}
+
+// Ready fails while the gate is closed, a nil gate is always ready
+func (g *readinessGate) Ready(_ context.Context) error {
+	if g == nil {
+		return nil
+	}
+
+	select {
+	case <-g.ready:
+		return nil
+	default:
+		return errNotReady
+	}
+}
+
+// watch opens the gate after timeout, or sooner when address is set and a
+// connection can be made to it. It returns straight away, stop ends the
+// watch before then.
+func (g *readinessGate) watch(timeout time.Duration, address string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+
+	go func() {
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+
+		ticker := time.NewTicker(readinessProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			if len(address) > 0 && probeCollector(ctx, address, readinessProbeInterval) == nil {
+				g.open("the OTLP collector at " + address + " can be reached")
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.ready:
+				return
+			case <-deadline.C:
+				g.open("no spans exported within " + timeout.String() + ", early traces may be lost")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop ends the watch, and is safe to call on a nil gate
+func (g *readinessGate) stop() {
+	if g != nil {
+		g.cancel()
+	}
+}
+
+// readinessTimeout is given by WithReadinessGate or
+// FAAS_TRACE_READINESS_TIMEOUT, zero leaves readiness ungated
+func (c *config) readinessTimeout() time.Duration {
+	if c.readinessGateTimeout > 0 {
+		return c.readinessGateTimeout
+	}
+
+	val, ok := os.LookupEnv(envTraceReadinessTimeout)
+	if !ok {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(val)
+	if err != nil || timeout < 0 {
+		log.Printf("invalid %s value: %q, readiness will not wait for the collector", envTraceReadinessTimeout, val)
+		return 0
+	}
+	return timeout
+}
+
+// Ready fails until the pipeline's first spans have been exported, or its
+// readiness timeout has passed, when it was created with WithReadinessGate.
+// Otherwise it always passes.
+func (p *Pipeline) Ready(ctx context.Context) error {
+	return p.gate.Ready(ctx)
+}
+
+// gatedExporter opens the gate when spans are exported
+type gatedExporter struct {
+	tracesdk.SpanExporter
+	gate *readinessGate
+}
+
+func (e gatedExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err == nil && len(spans) > 0 {
+		e.gate.open("spans were exported")
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// collectorExporter fails to export until the collector is available
+type collectorExporter struct {
+	available atomic.Bool
+	exported  atomic.Int64
+}
+
+func (e *collectorExporter) ExportSpans(_ context.Context, spans []tracesdk.ReadOnlySpan) error {
+	if !e.available.Load() {
+		return errors.New("connection refused")
+	}
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+func (e *collectorExporter) Shutdown(context.Context) error { return nil }
+
+func gatedPipeline(t *testing.T, opts ...Option) *Pipeline {
+	t.Helper()
+	unsetEnv(t, envTraceReadinessTimeout)
+
+	opts = append([]Option{WithSampler(tracesdk.AlwaysSample()), WithoutGlobalRegistration()}, opts...)
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pipeline.Shutdown(context.Background()) })
+	return pipeline
+}
+
+// exportSpan ends a span and waits for it to be sent to the exporters
+func exportSpan(t *testing.T, pipeline *Pipeline) {
+	t.Helper()
+
+	_, span := pipeline.TracerProvider.Tracer("test").Start(context.Background(), "request")
+	span.End()
+	pipeline.TracerProvider.ForceFlush(context.Background())
+}
+
+func Test_Pipeline_Ready_WaitsForDelayedCollector(t *testing.T) {
+	exporter := &collectorExporter{}
+	pipeline := gatedPipeline(t, WithExporter(exporter), WithReadinessGate(time.Minute))
+
+	if err := pipeline.Ready(context.Background()); err == nil {
+		t.Fatalf("want not ready before any spans are exported")
+	}
+
+	exportSpan(t, pipeline)
+	if err := pipeline.Ready(context.Background()); err == nil {
+		t.Fatalf("want not ready while the collector is unavailable")
+	}
+
+	exporter.available.Store(true)
+	exportSpan(t, pipeline)
+
+	if exporter.exported.Load() == 0 {
+		t.Fatalf("want spans exported once the collector is available")
+	}
+	if err := pipeline.Ready(context.Background()); err != nil {
+		t.Errorf("want ready once spans are exported, got: %s", err)
+	}
+}
+
+func Test_Pipeline_Ready_AfterTimeout(t *testing.T) {
+	pipeline := gatedPipeline(t, WithExporter(&collectorExporter{}), WithReadinessGate(time.Millisecond*20))
+
+	deadline := time.Now().Add(time.Second * 2)
+	for pipeline.Ready(context.Background()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("want ready once the timeout has passed")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func Test_Pipeline_Ready_WhenCollectorCanBeReached(t *testing.T) {
+	previous := readinessProbeInterval
+	readinessProbeInterval = time.Millisecond * 10
+	defer func() { readinessProbeInterval = previous }()
+
+	// find a free port for the collector, which starts later
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	t.Setenv(otelEnvTraceSExporter, string(OTELExporter))
+	t.Setenv(otelExpOTLPProtocol, "grpc")
+	t.Setenv(otelEnvOTLPEndpoint, "http://"+address)
+	unsetEnv(t, otelEnvOTLPTracesEndpoint)
+
+	pipeline := gatedPipeline(t, WithOTLPInsecure(), WithReadinessGate(time.Minute))
+
+	time.Sleep(time.Millisecond * 50)
+	if err := pipeline.Ready(context.Background()); err == nil {
+		t.Fatalf("want not ready before the collector is listening")
+	}
+
+	collector, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("the port was taken before the collector started: %s", err)
+	}
+	defer collector.Close()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for pipeline.Ready(context.Background()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("want ready once the collector can be reached")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func Test_Pipeline_Ready_NotGatedByDefault(t *testing.T) {
+	pipeline := gatedPipeline(t, WithExporter(&collectorExporter{}))
+
+	if err := pipeline.Ready(context.Background()); err != nil {
+		t.Errorf("want ready without a readiness gate, got: %s", err)
+	}
+}
+
+func Test_Check_WaitsForReadinessGate(t *testing.T) {
+	unsetEnv(t, envTraceReadinessTimeout)
+	unsetEnv(t, otelEnvTraceSExporter)
+
+	exporter := &collectorExporter{}
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter), WithSampler(tracesdk.AlwaysSample()), WithReadinessGate(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		globalGate.Store(nil)
+	})
+
+	if err := Check(context.Background()); err == nil {
+		t.Fatalf("want the check to fail before any spans are exported")
+	}
+
+	exporter.available.Store(true)
+	_, span := otel.Tracer("test").Start(context.Background(), "request")
+	span.End()
+	otel.GetTracerProvider().(*tracesdk.TracerProvider).ForceFlush(context.Background())
+
+	if err := Check(context.Background()); err != nil {
+		t.Errorf("want the check to pass once spans are exported, got: %s", err)
+	}
+}
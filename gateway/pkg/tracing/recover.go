@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// panicContextKey holds the *bool set by Recover when it has recorded a
+// panic, so that Middleware keeps the panic as the span's status
+type panicContextKey struct{}
+
+// withPanicFlag returns ctx with a flag for Recover to set
+func withPanicFlag(ctx context.Context) (context.Context, *bool) {
+	recovered := new(bool)
+	return context.WithValue(ctx, panicContextKey{}, recovered), recovered
+}
+
+// Recover turns a panic in next into a 500, instead of the connection being
+// dropped. The panic is logged with its trace ID, and recorded on the span
+// as an exception event with the stack trace and an error status. Chain it
+// inside Middleware, so that the panic is recorded on the request's span.
+//
+// http.ErrAbortHandler is panicked again, as it is used to abort a
+// response on purpose.
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			ctx := r.Context()
+			span := trace.SpanFromContext(ctx)
+			msg := fmt.Sprint(p)
+
+			log.Printf("panic serving %s %s, trace_id=%s: %s\n%s", r.Method, r.URL.Path, span.SpanContext().TraceID(), msg, debug.Stack())
+
+			span.RecordError(fmt.Errorf("panic: %s", msg), trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, msg)
+			if recovered, ok := ctx.Value(panicContextKey{}).(*bool); ok {
+				*recovered = true
+			}
+
+			httperror.Write(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}()
+
+		next(w, r)
+	}
+}
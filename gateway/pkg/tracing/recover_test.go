@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func Test_Recover_RecordsPanicOnSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var logged bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logged)
+	defer log.SetOutput(previous)
+
+	handler := Middleware(Recover(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map in figlet")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("want status: %d, got: %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	span := spans[0]
+
+	if span.Status().Code != codes.Error || span.Status().Description != "nil map in figlet" {
+		t.Errorf("want an error status with the panic, got: %+v", span.Status())
+	}
+
+	var stack string
+	for _, event := range span.Events() {
+		if event.Name != semconv.ExceptionEventName {
+			continue
+		}
+		for _, kv := range event.Attributes {
+			if kv.Key == semconv.ExceptionStacktraceKey {
+				stack = kv.Value.AsString()
+			}
+		}
+	}
+	if !strings.Contains(stack, "Test_Recover_RecordsPanicOnSpan") {
+		t.Errorf("want an exception event with the stack trace, got: %q", stack)
+	}
+
+	traceID := span.SpanContext().TraceID().String()
+	if !strings.Contains(logged.String(), "trace_id="+traceID) || !strings.Contains(logged.String(), "nil map in figlet") {
+		t.Errorf("want the panic logged with trace_id=%s, got: %q", traceID, logged.String())
+	}
+}
+
+func Test_Recover_PassesThroughWithoutPanic(t *testing.T) {
+	handler := Recover(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("want status: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+}
+
+func Test_Recover_RepanicsAbortHandler(t *testing.T) {
+	handler := Recover(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("want %v panicked again, got: %v", http.ErrAbortHandler, p)
+		}
+	}()
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+}
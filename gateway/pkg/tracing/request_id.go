@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/docker/distribution/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request's ID from the caller, to the function
+// and back in the response
+const RequestIDHeader = "X-Request-Id"
+
+// RequestIDKey is the span attribute for the request's ID
+const RequestIDKey = attribute.Key("faas.request_id")
+
+// maxRequestIDLength is the longest ID accepted from a caller, longer IDs
+// are replaced
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// RequestID gives each request an ID, the caller's X-Request-Id or otherwise
+// a new UUID. The ID is passed on to the function in the same header,
+// written to the response, and recorded on the span. Chain it inside
+// Middleware so that the span has been started, and outside the access log
+// so that the ID can be logged.
+//
+// An ID with control characters, or longer than 128 bytes, is replaced so
+// that it can be written to logs as it is.
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.Generate().String()
+			r.Header.Set(RequestIDHeader, id)
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey{}, id)
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			span.SetAttributes(RequestIDKey.String(id))
+		}
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// RequestIDFromContext returns the ID given to the request by RequestID, or
+// "" when there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether id can be kept as the request's ID
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x20 || id[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
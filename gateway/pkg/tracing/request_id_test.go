@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_RequestID_PassesThroughCallerID(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var upstream, fromContext string
+	handler := Middleware(RequestID(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Get(RequestIDHeader)
+		fromContext = RequestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set(RequestIDHeader, "01HF2Z4J3W6Q8R7T5Y9X0V1BCD")
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	want := "01HF2Z4J3W6Q8R7T5Y9X0V1BCD"
+	if upstream != want || fromContext != want {
+		t.Errorf("want the caller's ID passed on, got header: %q, context: %q", upstream, fromContext)
+	}
+	if got := rr.Header().Get(RequestIDHeader); got != want {
+		t.Errorf("want %s: %q in the response, got: %q", RequestIDHeader, want, got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+	if val, ok := spanAttribute(spans[0], RequestIDKey); !ok || val.AsString() != want {
+		t.Errorf("want %s: %q, got: %q", RequestIDKey, want, val.AsString())
+	}
+}
+
+func Test_RequestID_GeneratesID(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+	}{
+		{name: "no header", id: ""},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "control characters", id: "abc\ndef"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var upstream, fromContext string
+			handler := RequestID(func(w http.ResponseWriter, r *http.Request) {
+				upstream = r.Header.Get(RequestIDHeader)
+				fromContext = RequestIDFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+			if len(tc.id) > 0 {
+				req.Header.Set(RequestIDHeader, tc.id)
+			}
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if len(got) != 36 || got == tc.id {
+				t.Errorf("want a new UUID, got: %q", got)
+			}
+			if upstream != got || fromContext != got {
+				t.Errorf("want %q passed on, got header: %q, context: %q", got, upstream, fromContext)
+			}
+		})
+	}
+}
+
+func Test_RequestIDFromContext_Empty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		t.Errorf("want no ID outside RequestID, got: %q", id)
+	}
+}
@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Environment variables set through the Kubernetes downward API in the
+// gateway's Deployment, i.e.
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.name
+const (
+	envPodName      = "POD_NAME"
+	envPodNamespace = "POD_NAMESPACE"
+	envNodeName     = "NODE_NAME"
+)
+
+// kubernetesDetector adds the pod, namespace and node of the gateway replica
+// to the resource, for whichever of the downward API variables are set.
+type kubernetesDetector struct{}
+
+func (kubernetesDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{}
+
+	if val := os.Getenv(envPodName); len(val) > 0 {
+		attrs = append(attrs, semconv.K8SPodName(val))
+	}
+	if val := os.Getenv(envPodNamespace); len(val) > 0 {
+		attrs = append(attrs, semconv.K8SNamespaceName(val))
+	}
+	if val := os.Getenv(envNodeName); len(val) > 0 {
+		attrs = append(attrs, semconv.K8SNodeName(val))
+	}
+
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// envResourceAttributesFile names a file of resource attributes, when
+// WithResourceAttributesFromFile is not given
+const envResourceAttributesFile = "FAAS_TRACE_RESOURCE_ATTRIBUTES_FILE"
+
+// fileDetector adds the attributes from a file shared between deployments,
+// such as the team or cost-center. A file which is missing or can not be
+// parsed is logged and skipped, so that it does not stop the gateway.
+type fileDetector struct {
+	path string
+}
+
+func (d fileDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	if len(d.path) == 0 {
+		return resource.Empty(), nil
+	}
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		log.Printf("warning: unable to read resource attributes, skipping %s: %s", d.path, err)
+		return resource.Empty(), nil
+	}
+
+	attrs, err := parseResourceAttributes(data)
+	if err != nil {
+		log.Printf("warning: unable to parse resource attributes, skipping %s: %s", d.path, err)
+		return resource.Empty(), nil
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// parseResourceAttributes reads one attribute per line, as key=value or as
+// a flat YAML mapping of key: value. Blank lines and lines starting with #
+// are skipped, and values may be quoted.
+func parseResourceAttributes(data []byte) ([]attribute.KeyValue, error) {
+	attrs := []attribute.KeyValue{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+
+		sep := strings.IndexAny(text, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: want key=value or key: value, got: %q", line, text)
+		}
+
+		key := strings.TrimSpace(text[:sep])
+		value := strings.TrimSpace(text[sep+1:])
+		if strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("line %d: invalid key: %q", line, key)
+		}
+		if len(value) == 0 {
+			// a YAML key with no value starts a nested mapping, which is not
+			// supported
+			return nil, fmt.Errorf("line %d: no value for %s", line, key)
+		}
+
+		if value[0] == '"' || value[0] == '\'' {
+			unquoted, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			value = unquoted
+		}
+
+		attrs = append(attrs, attribute.String(key, value))
+	}
+
+	return attrs, scanner.Err()
+}
+
+// unquote removes the double or single quotes around a value
+func unquote(value string) (string, error) {
+	if value[0] == '"' {
+		return strconv.Unquote(value)
+	}
+	if len(value) < 2 || value[len(value)-1] != '\'' {
+		return "", fmt.Errorf("unterminated quote: %s", value)
+	}
+	return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+}
+
+// otelEnvResourceAttributes is read by resource.WithFromEnv, and for the
+// service name by resourceServiceName
+const otelEnvResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+
+// WithServiceName sets the service.name of the resource, instead of
+// OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES or the name given to Provider.
+func WithServiceName(name string) Option {
+	return func(c *config) {
+		c.serviceName = name
+	}
+}
+
+// resourceServiceName resolves the service.name of the resource, as each
+// of these can give one, in order of precedence:
+//
+//  1. WithServiceName, or else service.name in WithResourceAttributes
+//  2. OTEL_SERVICE_NAME
+//  3. service.name in OTEL_RESOURCE_ATTRIBUTES
+//  4. name, given by the program to Provider
+//
+// A service.name in the resource attributes file is always overridden.
+func (c *config) resourceServiceName(name string) string {
+	if len(c.serviceName) > 0 {
+		return c.serviceName
+	}
+	for i := len(c.attributes) - 1; i >= 0; i-- {
+		if kv := c.attributes[i]; kv.Key == semconv.ServiceNameKey && len(kv.Value.Emit()) > 0 {
+			return kv.Value.Emit()
+		}
+	}
+
+	if val := strings.TrimSpace(os.Getenv(otelEnvServiceName)); len(val) > 0 {
+		return val
+	}
+	if val := envResourceServiceName(); len(val) > 0 {
+		return val
+	}
+	return name
+}
+
+// envResourceServiceName finds service.name in OTEL_RESOURCE_ATTRIBUTES,
+// whose values are percent-encoded. The last one wins, as it does for the
+// SDK, and a value which can not be decoded is skipped.
+func envResourceServiceName() string {
+	name := ""
+	for _, pair := range strings.Split(os.Getenv(otelEnvResourceAttributes), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) != string(semconv.ServiceNameKey) {
+			continue
+		}
+		if decoded, err := url.PathUnescape(strings.TrimSpace(value)); err == nil && len(decoded) > 0 {
+			name = decoded
+		}
+	}
+	return name
+}
+
+// newResource describes the gateway to the tracing backend. Detectors later
+// in the list take precedence, so the attributes from the file come before
+// the Kubernetes attributes and OTEL_RESOURCE_ATTRIBUTES, which can still
+// override them, and WithResourceAttributes overrides them all. The
+// service.name, which several of these can set, is resolved by
+// resourceServiceName.
+func newResource(name, version, commit string, cfg *config) (*resource.Resource, error) {
+	return resource.New(
+		context.Background(),
+		resource.WithDetectors(fileDetector{path: cfg.resourceAttributesFile()}, kubernetesDetector{}),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceVersionKey.String(version),
+			attribute.String("service.commit", commit),
+		),
+		resource.WithAttributes(cfg.attributes...),
+		resource.WithAttributes(semconv.ServiceName(cfg.resourceServiceName(name))),
+	)
+}
@@ -0,0 +1,262 @@
+package tracing
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func resourceAttribute(res *resource.Resource, key attribute.Key) (string, bool) {
+	val, ok := res.Set().Value(key)
+	return val.AsString(), ok
+}
+
+func Test_newResource_KubernetesAttributes(t *testing.T) {
+	unsetEnv(t, "OTEL_RESOURCE_ATTRIBUTES")
+	t.Setenv(envPodName, "gateway-7d9f8b6c5-x2k4p")
+	t.Setenv(envPodNamespace, "openfaas")
+	t.Setenv(envNodeName, "worker-1")
+
+	res, err := newResource("gateway", "dev", "none", newConfig(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[attribute.Key]string{
+		semconv.K8SPodNameKey:       "gateway-7d9f8b6c5-x2k4p",
+		semconv.K8SNamespaceNameKey: "openfaas",
+		semconv.K8SNodeNameKey:      "worker-1",
+	}
+	for key, wantVal := range want {
+		if got, _ := resourceAttribute(res, key); got != wantVal {
+			t.Errorf("want %s: %s, got: %s", key, wantVal, got)
+		}
+	}
+}
+
+func Test_newResource_KubernetesAttributes_Unset(t *testing.T) {
+	unsetEnv(t, "OTEL_RESOURCE_ATTRIBUTES")
+	unsetEnv(t, envPodName)
+	unsetEnv(t, envPodNamespace)
+	unsetEnv(t, envNodeName)
+
+	res, err := newResource("gateway", "dev", "none", newConfig(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []attribute.Key{semconv.K8SPodNameKey, semconv.K8SNamespaceNameKey, semconv.K8SNodeNameKey} {
+		if got, ok := resourceAttribute(res, key); ok {
+			t.Errorf("want no %s attribute, got: %s", key, got)
+		}
+	}
+
+	if got, _ := resourceAttribute(res, semconv.ServiceNameKey); got != "gateway" {
+		t.Errorf("want %s: gateway, got: %s", semconv.ServiceNameKey, got)
+	}
+}
+
+func Test_newResource_EnvAttributesWinOverKubernetes(t *testing.T) {
+	t.Setenv(envPodName, "gateway-7d9f8b6c5-x2k4p")
+	t.Setenv(envPodNamespace, "openfaas")
+	unsetEnv(t, envNodeName)
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "k8s.namespace.name=openfaas-prod")
+
+	res, err := newResource("gateway", "dev", "none", newConfig(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := resourceAttribute(res, semconv.K8SNamespaceNameKey); got != "openfaas-prod" {
+		t.Errorf("want %s from OTEL_RESOURCE_ATTRIBUTES: openfaas-prod, got: %s", semconv.K8SNamespaceNameKey, got)
+	}
+	if got, _ := resourceAttribute(res, semconv.K8SPodNameKey); got != "gateway-7d9f8b6c5-x2k4p" {
+		t.Errorf("want %s: gateway-7d9f8b6c5-x2k4p, got: %s", semconv.K8SPodNameKey, got)
+	}
+}
+
+func writeAttributesFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "resource.conf")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// captureLog returns the log output written until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+func Test_newResource_AttributesFromFile(t *testing.T) {
+	unsetEnv(t, "OTEL_RESOURCE_ATTRIBUTES")
+	unsetEnv(t, envResourceAttributesFile)
+
+	for name, content := range map[string]string{
+		"key=value": "# shared by every gateway\nteam=platform\ncost.center = \"cc-1234\"\n\nregion=eu-west-1\n",
+		"yaml":      "---\nteam: platform\ncost.center: 'cc-1234'\nregion: eu-west-1\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := writeAttributesFile(t, content)
+
+			res, err := newResource("gateway", "dev", "none", newConfig([]Option{WithResourceAttributesFromFile(path)}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[attribute.Key]string{
+				"team":        "platform",
+				"cost.center": "cc-1234",
+				"region":      "eu-west-1",
+			}
+			for key, wantVal := range want {
+				if got, _ := resourceAttribute(res, key); got != wantVal {
+					t.Errorf("want %s: %s, got: %q", key, wantVal, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_newResource_AttributesFromFile_Precedence(t *testing.T) {
+	path := writeAttributesFile(t, "team=platform\ncost.center=cc-1234\nregion=eu-west-1\n")
+	t.Setenv(envResourceAttributesFile, path)
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=functions")
+
+	res, err := newResource("gateway", "dev", "none", newConfig([]Option{
+		WithResourceAttributes(attribute.String("region", "us-east-1")),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[attribute.Key]string{
+		"team":        "functions",
+		"cost.center": "cc-1234",
+		"region":      "us-east-1",
+	}
+	for key, wantVal := range want {
+		if got, _ := resourceAttribute(res, key); got != wantVal {
+			t.Errorf("want %s: %s, got: %q", key, wantVal, got)
+		}
+	}
+}
+
+func Test_newResource_AttributesFromFile_Missing(t *testing.T) {
+	unsetEnv(t, "OTEL_RESOURCE_ATTRIBUTES")
+	output := captureLog(t)
+
+	path := filepath.Join(t.TempDir(), "missing.conf")
+	res, err := newResource("gateway", "dev", "none", newConfig([]Option{WithResourceAttributesFromFile(path)}))
+	if err != nil {
+		t.Fatalf("want a missing file skipped, got: %s", err)
+	}
+
+	if got, _ := resourceAttribute(res, semconv.ServiceNameKey); got != "gateway" {
+		t.Errorf("want the rest of the resource, got %s: %q", semconv.ServiceNameKey, got)
+	}
+	if !strings.Contains(output.String(), "warning: unable to read resource attributes") {
+		t.Errorf("want a warning logged, got: %q", output.String())
+	}
+}
+
+func Test_newResource_AttributesFromFile_Malformed(t *testing.T) {
+	unsetEnv(t, "OTEL_RESOURCE_ATTRIBUTES")
+
+	for name, content := range map[string]string{
+		"no separator":   "team=platform\nplatform\n",
+		"nested mapping": "team:\n  name: platform\n",
+		"bad quote":      "team=\"platform\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			output := captureLog(t)
+			path := writeAttributesFile(t, content)
+
+			res, err := newResource("gateway", "dev", "none", newConfig([]Option{WithResourceAttributesFromFile(path)}))
+			if err != nil {
+				t.Fatalf("want a malformed file skipped, got: %s", err)
+			}
+
+			if got, ok := resourceAttribute(res, "team"); ok {
+				t.Errorf("want nothing from a malformed file, got team: %q", got)
+			}
+			if !strings.Contains(output.String(), "warning: unable to parse resource attributes") {
+				t.Errorf("want a warning logged, got: %q", output.String())
+			}
+		})
+	}
+}
+
+func Test_newResource_ServiceNamePrecedence(t *testing.T) {
+	cases := []struct {
+		name         string
+		option       string
+		attribute    string
+		envName      string
+		envResource  string
+		fileResource string
+		want         string
+	}{
+		{name: "program name", want: "gateway"},
+		{name: "resource attributes over the program name", envResource: "service.name=from-attributes", want: "from-attributes"},
+		{name: "percent-encoded resource attribute", envResource: "team=faas,service.name=faas%20gateway", want: "faas gateway"},
+		{name: "OTEL_SERVICE_NAME over resource attributes", envName: "from-env", envResource: "service.name=from-attributes", want: "from-env"},
+		{name: "option over OTEL_SERVICE_NAME", option: "from-option", envName: "from-env", envResource: "service.name=from-attributes", want: "from-option"},
+		{name: "resource attribute option over OTEL_SERVICE_NAME", attribute: "from-attribute-option", envName: "from-env", want: "from-attribute-option"},
+		{name: "option over resource attribute option", option: "from-option", attribute: "from-attribute-option", want: "from-option"},
+		{name: "file never wins", fileResource: "service.name=from-file", want: "gateway"},
+		{name: "file under resource attributes", fileResource: "service.name=from-file", envResource: "service.name=from-attributes", want: "from-attributes"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unsetEnv(t, otelEnvServiceName)
+			unsetEnv(t, otelEnvResourceAttributes)
+			unsetEnv(t, envResourceAttributesFile)
+			if len(tc.envName) > 0 {
+				t.Setenv(otelEnvServiceName, tc.envName)
+			}
+			if len(tc.envResource) > 0 {
+				t.Setenv(otelEnvResourceAttributes, tc.envResource)
+			}
+
+			opts := []Option{}
+			if len(tc.option) > 0 {
+				opts = append(opts, WithServiceName(tc.option))
+			}
+			if len(tc.attribute) > 0 {
+				opts = append(opts, WithResourceAttributes(semconv.ServiceName(tc.attribute)))
+			}
+			if len(tc.fileResource) > 0 {
+				path := filepath.Join(t.TempDir(), "resource.properties")
+				if err := os.WriteFile(path, []byte(tc.fileResource), 0600); err != nil {
+					t.Fatal(err)
+				}
+				opts = append(opts, WithResourceAttributesFromFile(path))
+			}
+
+			res, err := newResource("gateway", "dev", "none", newConfig(opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, _ := resourceAttribute(res, semconv.ServiceNameKey); got != tc.want {
+				t.Errorf("want %s: %q, got: %q", semconv.ServiceNameKey, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	envTraceExportRetry                = "FAAS_TRACE_EXPORT_RETRY"
+	envTraceExportRetryInitialInterval = "FAAS_TRACE_EXPORT_RETRY_INITIAL_INTERVAL"
+	envTraceExportRetryMaxInterval     = "FAAS_TRACE_EXPORT_RETRY_MAX_INTERVAL"
+	envTraceExportRetryMaxElapsedTime  = "FAAS_TRACE_EXPORT_RETRY_MAX_ELAPSED_TIME"
+)
+
+// DefaultExportRetry is the backoff of the OTLP clients, which is used for
+// any setting which is not given
+var DefaultExportRetry = ExportRetry{
+	Enabled:         true,
+	InitialInterval: 5 * time.Second,
+	MaxInterval:     30 * time.Second,
+	MaxElapsedTime:  time.Minute,
+}
+
+// ExportRetry is how an OTLP export which failed, because the collector
+// could not be reached or asked for it to be sent again, is retried. The
+// wait doubles from InitialInterval up to MaxInterval, and the spans are
+// dropped once MaxElapsedTime has passed.
+type ExportRetry struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// exports counts the span exports of every pipeline which is not given
+// WithExportCounter, by their result
+var exports = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "gateway",
+	Subsystem: "tracing",
+	Name:      "exports_total",
+	Help:      "Batches of spans exported, by result: success or failure, once retries are exhausted.",
+}, []string{"result"})
+
+var registerExports sync.Once
+
+// WithExportRetry sets how OTLP exports are retried, instead of
+// FAAS_TRACE_EXPORT_RETRY, FAAS_TRACE_EXPORT_RETRY_INITIAL_INTERVAL,
+// FAAS_TRACE_EXPORT_RETRY_MAX_INTERVAL and
+// FAAS_TRACE_EXPORT_RETRY_MAX_ELAPSED_TIME. Durations which are 0 keep the
+// defaults of 5s, 30s and 1m.
+func WithExportRetry(retry ExportRetry) Option {
+	return func(c *config) {
+		c.exportRetry = &retry
+	}
+}
+
+// WithExportCounter counts each batch of spans exported with a "result"
+// label of success or failure, instead of gateway_tracing_exports_total in
+// the default Prometheus registry.
+func WithExportCounter(counter *prometheus.CounterVec) Option {
+	return func(c *config) {
+		c.exportCounter = counter
+	}
+}
+
+// retry is the backoff for the OTLP clients, from the option or the env
+func (c *config) retry() ExportRetry {
+	retry := DefaultExportRetry
+	if c.exportRetry != nil {
+		retry.Enabled = c.exportRetry.Enabled
+		if c.exportRetry.InitialInterval > 0 {
+			retry.InitialInterval = c.exportRetry.InitialInterval
+		}
+		if c.exportRetry.MaxInterval > 0 {
+			retry.MaxInterval = c.exportRetry.MaxInterval
+		}
+		if c.exportRetry.MaxElapsedTime > 0 {
+			retry.MaxElapsedTime = c.exportRetry.MaxElapsedTime
+		}
+		return retry
+	}
+
+	if strings.ToLower(get(envTraceExportRetry, "true")) == "false" {
+		retry.Enabled = false
+	}
+	retry.InitialInterval = envDuration(envTraceExportRetryInitialInterval, retry.InitialInterval)
+	retry.MaxInterval = envDuration(envTraceExportRetryMaxInterval, retry.MaxInterval)
+	retry.MaxElapsedTime = envDuration(envTraceExportRetryMaxElapsedTime, retry.MaxElapsedTime)
+	return retry
+}
+
+// envDuration reads a positive duration from the env, or returns fallback
+func envDuration(name string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s value: %q, using %s", name, val, fallback)
+		return fallback
+	}
+	return d
+}
+
+func (r ExportRetry) grpc() otlptracegrpc.Option {
+	return otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+		Enabled:         r.Enabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	})
+}
+
+func (r ExportRetry) http() otlptracehttp.Option {
+	return otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+		Enabled:         r.Enabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	})
+}
+
+// exportsCounter is given by WithExportCounter, or is
+// gateway_tracing_exports_total in the default Prometheus registry
+func (c *config) exportsCounter() *prometheus.CounterVec {
+	if c.exportCounter != nil {
+		return c.exportCounter
+	}
+	registerExports.Do(func() {
+		prometheus.MustRegister(exports)
+	})
+	return exports
+}
+
+// meteredExporter counts each export by its result, after the client's
+// own retries
+type meteredExporter struct {
+	tracesdk.SpanExporter
+	success prometheus.Counter
+	failure prometheus.Counter
+}
+
+func newMeteredExporter(exporter tracesdk.SpanExporter, counter *prometheus.CounterVec) meteredExporter {
+	return meteredExporter{
+		SpanExporter: exporter,
+		success:      counter.WithLabelValues("success"),
+		failure:      counter.WithLabelValues("failure"),
+	}
+}
+
+func (e meteredExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.failure.Inc()
+	} else {
+		e.success.Inc()
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyCollector is down, then fails the next requests as it restarts
+type flakyCollector struct {
+	down     atomic.Bool
+	failures atomic.Int32
+	requests atomic.Int32
+}
+
+// fail counts a request and reports whether it should fail
+func (c *flakyCollector) fail() bool {
+	c.requests.Add(1)
+	return c.down.Load() || c.failures.Add(-1) >= 0
+}
+
+// flakyTraceService fails exports with Unavailable while the collector is
+// down or restarting
+type flakyTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	collector *flakyCollector
+}
+
+func (s *flakyTraceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if s.collector.fail() {
+		return nil, status.Error(codes.Unavailable, "collector restarting")
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func Test_Pipeline_RetriesIntermittentExportFailures(t *testing.T) {
+	t.Run("http/protobuf", func(t *testing.T) {
+		collector := &flakyCollector{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if collector.fail() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		testExportRetries(t, "http/protobuf", strings.TrimPrefix(server.URL, "http://"), collector)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		collector := &flakyCollector{}
+		server := grpc.NewServer()
+		coltracepb.RegisterTraceServiceServer(server, &flakyTraceService{collector: collector})
+		go server.Serve(listener)
+		defer server.Stop()
+
+		testExportRetries(t, "grpc", listener.Addr().String(), collector)
+	})
+}
+
+// testExportRetries exports a span while the collector is down, and another
+// as it restarts, each export is retried until it succeeds or gives up
+func testExportRetries(t *testing.T, protocol, endpoint string, collector *flakyCollector) {
+	t.Helper()
+	t.Setenv(otelExpOTLPProtocol, protocol)
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "exports_total"}, []string{"result"})
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithTracesExporter(string(OTELExporter)),
+		WithOTLPEndpoint(endpoint),
+		WithOTLPInsecure(),
+		WithExportRetry(ExportRetry{
+			Enabled:         true,
+			InitialInterval: 5 * time.Millisecond,
+			MaxInterval:     10 * time.Millisecond,
+			MaxElapsedTime:  100 * time.Millisecond,
+		}),
+		WithExportCounter(counter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeline.Shutdown(context.Background())
+
+	export := func() {
+		_, span := pipeline.TracerProvider.Tracer("test").Start(context.Background(), "request")
+		span.End()
+		pipeline.TracerProvider.ForceFlush(context.Background())
+	}
+
+	collector.down.Store(true)
+	export()
+
+	if got := counterValue(t, counter.WithLabelValues("failure")); got != 1 {
+		t.Errorf("want 1 failed export once retries are exhausted, got: %v", got)
+	}
+	if got := collector.requests.Load(); got < 2 {
+		t.Errorf("want the export retried while the collector is down, got %d requests", got)
+	}
+
+	collector.down.Store(false)
+	collector.failures.Store(2)
+	collector.requests.Store(0)
+	export()
+
+	if got := counterValue(t, counter.WithLabelValues("success")); got != 1 {
+		t.Errorf("want 1 successful export after the collector recovers, got: %v", got)
+	}
+	if got := collector.requests.Load(); got != 3 {
+		t.Errorf("want two retries before the export succeeds, got %d requests", got)
+	}
+	if got := counterValue(t, counter.WithLabelValues("failure")); got != 1 {
+		t.Errorf("want no more failures once the export succeeded, got: %v", got)
+	}
+}
+
+func Test_ExportRetry_FromEnv(t *testing.T) {
+	t.Setenv(envTraceExportRetryInitialInterval, "1s")
+	t.Setenv(envTraceExportRetryMaxInterval, "10s")
+	t.Setenv(envTraceExportRetryMaxElapsedTime, "slow")
+
+	got := newConfig(nil).retry()
+	want := ExportRetry{
+		Enabled:         true,
+		InitialInterval: time.Second,
+		MaxInterval:     10 * time.Second,
+		MaxElapsedTime:  DefaultExportRetry.MaxElapsedTime,
+	}
+	if got != want {
+		t.Errorf("want retry: %+v, got: %+v", want, got)
+	}
+
+	t.Setenv(envTraceExportRetry, "false")
+	if newConfig(nil).retry().Enabled {
+		t.Errorf("want retries turned off with %s=false", envTraceExportRetry)
+	}
+}
+
+func Test_ExportRetry_OptionKeepsDefaults(t *testing.T) {
+	t.Setenv(envTraceExportRetry, "false")
+
+	got := newConfig([]Option{WithExportRetry(ExportRetry{Enabled: true, MaxElapsedTime: time.Second})}).retry()
+	want := DefaultExportRetry
+	want.MaxElapsedTime = time.Second
+	if got != want {
+		t.Errorf("want retry: %+v, got: %+v", want, got)
+	}
+}
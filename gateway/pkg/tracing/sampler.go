@@ -0,0 +1,250 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DebugBaggageKey is the baggage member which forces a request to be sampled
+// when the debug sampler is enabled, i.e. "baggage: faas.debug=1"
+const DebugBaggageKey = "faas.debug"
+
+// JaegerDebugHeader is the request header used by Jaeger clients to force a
+// trace to be sampled, its value is an ID used to find the trace in Jaeger.
+const JaegerDebugHeader = "jaeger-debug-id"
+
+// JaegerDebugIDKey is the span attribute for the value of JaegerDebugHeader.
+const JaegerDebugIDKey = attribute.Key("jaeger.debug_id")
+
+const (
+	samplerAlwaysOn                = "always_on"
+	samplerAlwaysOff               = "always_off"
+	samplerTraceIDRatio            = "traceidratio"
+	samplerParentBasedAlwaysOn     = "parentbased_always_on"
+	samplerParentBasedAlwaysOff    = "parentbased_always_off"
+	samplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// samplerFromEnv builds a sampler from OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG. When the sampler is unset, unknown or has an
+// invalid ratio, parentbased_always_on is used.
+// see: https://opentelemetry.io/docs/concepts/sdk-configuration/general-sdk-configuration/#otel_traces_sampler
+func samplerFromEnv() tracesdk.Sampler {
+	defaultSampler := tracesdk.ParentBased(tracesdk.AlwaysSample())
+
+	name := strings.ToLower(strings.TrimSpace(get(otelEnvTracesSampler, samplerParentBasedAlwaysOn)))
+	arg := get(otelEnvTracesSamplerArg, "")
+
+	switch name {
+	case samplerAlwaysOn:
+		return tracesdk.AlwaysSample()
+	case samplerAlwaysOff:
+		return tracesdk.NeverSample()
+	case samplerParentBasedAlwaysOn:
+		return defaultSampler
+	case samplerParentBasedAlwaysOff:
+		return tracesdk.ParentBased(tracesdk.NeverSample())
+	case samplerTraceIDRatio, samplerParentBasedTraceIDRatio:
+		ratio, err := parseRatio(arg)
+		if err != nil {
+			log.Printf("invalid %s value %q for sampler %s, using %s: %s",
+				otelEnvTracesSamplerArg, arg, name, samplerParentBasedAlwaysOn, err)
+			return defaultSampler
+		}
+
+		if name == samplerTraceIDRatio {
+			return tracesdk.TraceIDRatioBased(ratio)
+		}
+		return tracesdk.ParentBased(tracesdk.TraceIDRatioBased(ratio))
+	default:
+		log.Printf("unsupported %s value %q, using %s", otelEnvTracesSampler, name, samplerParentBasedAlwaysOn)
+		return defaultSampler
+	}
+}
+
+// parseRatio parses a sampling ratio, which must be between 0 and 1.
+func parseRatio(value string) (float64, error) {
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if ratio < 0 || ratio > 1 {
+		return 0, strconv.ErrRange
+	}
+
+	return ratio, nil
+}
+
+// debugSampler samples any trace which carries the DebugBaggageKey baggage
+// member, and otherwise defers to the parent sampler.
+type debugSampler struct {
+	parent tracesdk.Sampler
+}
+
+// newDebugSampler wraps parent so that requests with the DebugBaggageKey
+// baggage member are always sampled.
+func newDebugSampler(parent tracesdk.Sampler) tracesdk.Sampler {
+	return debugSampler{parent: parent}
+}
+
+// ShouldSample implements tracesdk.Sampler
+func (s debugSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if member := baggage.FromContext(p.ParentContext).Member(DebugBaggageKey); len(member.Key()) > 0 {
+		return tracesdk.SamplingResult{
+			Decision:   tracesdk.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	return s.parent.ShouldSample(p)
+}
+
+// Description implements tracesdk.Sampler
+func (s debugSampler) Description() string {
+	return fmt.Sprintf("DebugBaggage{%s}", s.parent.Description())
+}
+
+type jaegerDebugIDContextKey struct{}
+
+// withJaegerDebugID marks ctx so that the jaegerDebugSampler samples the
+// spans started from it.
+func withJaegerDebugID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jaegerDebugIDContextKey{}, id)
+}
+
+// jaegerDebugSampler samples any trace started by Middleware for a request
+// with the JaegerDebugHeader, and otherwise defers to the parent sampler.
+type jaegerDebugSampler struct {
+	parent tracesdk.Sampler
+}
+
+// newJaegerDebugSampler wraps parent so that requests with the
+// JaegerDebugHeader are always sampled.
+func newJaegerDebugSampler(parent tracesdk.Sampler) tracesdk.Sampler {
+	return jaegerDebugSampler{parent: parent}
+}
+
+// ShouldSample implements tracesdk.Sampler
+func (s jaegerDebugSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if id, ok := p.ParentContext.Value(jaegerDebugIDContextKey{}).(string); ok && len(id) > 0 {
+		return tracesdk.SamplingResult{
+			Decision:   tracesdk.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	return s.parent.ShouldSample(p)
+}
+
+// Description implements tracesdk.Sampler
+func (s jaegerDebugSampler) Description() string {
+	return fmt.Sprintf("JaegerDebugID{%s}", s.parent.Description())
+}
+
+// envTraceSamplingRules sets the SamplingRules when WithSamplingRules is
+// not given, i.e. "/function/payments=1,/healthz=0.01"
+const envTraceSamplingRules = "FAAS_TRACE_SAMPLING_RULES"
+
+// SamplingRule samples a Ratio of the requests whose path starts with
+// Prefix, between 0 and 1
+type SamplingRule struct {
+	Prefix string
+	Ratio  float64
+}
+
+// parseSamplingRules reads prefix=ratio pairs separated by commas, pairs
+// which are invalid are logged and skipped
+func parseSamplingRules(val string) []SamplingRule {
+	rules := []SamplingRule{}
+	for _, item := range splitList(val) {
+		sep := strings.LastIndex(item, "=")
+		if sep <= 0 {
+			log.Printf("invalid %s rule %q, want prefix=ratio", envTraceSamplingRules, item)
+			continue
+		}
+
+		ratio, err := parseRatio(item[sep+1:])
+		if err != nil {
+			log.Printf("invalid %s rule %q: %s", envTraceSamplingRules, item, err)
+			continue
+		}
+
+		rules = append(rules, SamplingRule{Prefix: strings.TrimSpace(item[:sep]), Ratio: ratio})
+	}
+	return rules
+}
+
+type samplingPathContextKey struct{}
+
+// withSamplingPath gives the pathSampler the path of the request for the
+// spans started from ctx, since a sampler runs before the span has any
+// attributes set by the middleware after it has started
+func withSamplingPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, samplingPathContextKey{}, path)
+}
+
+// pathRule is a SamplingRule with its sampler
+type pathRule struct {
+	SamplingRule
+	sampler tracesdk.Sampler
+}
+
+// pathSampler samples the root spans of requests by the rule with the
+// longest prefix of their path, and otherwise defers to the parent sampler.
+// Spans with a parent keep the parent's decision, so a trace is not split.
+type pathSampler struct {
+	parent tracesdk.Sampler
+	rules  []pathRule
+}
+
+// newPathSampler wraps parent with rules, when there are none parent is
+// returned
+func newPathSampler(parent tracesdk.Sampler, rules []SamplingRule) tracesdk.Sampler {
+	if len(rules) == 0 {
+		return parent
+	}
+
+	s := pathSampler{parent: parent}
+	for _, rule := range rules {
+		s.rules = append(s.rules, pathRule{SamplingRule: rule, sampler: tracesdk.TraceIDRatioBased(rule.Ratio)})
+	}
+	return s
+}
+
+// ShouldSample implements tracesdk.Sampler
+func (s pathSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	path, ok := p.ParentContext.Value(samplingPathContextKey{}).(string)
+	if !ok || trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return s.parent.ShouldSample(p)
+	}
+
+	var matched *pathRule
+	for i, rule := range s.rules {
+		if strings.HasPrefix(path, rule.Prefix) && (matched == nil || len(rule.Prefix) > len(matched.Prefix)) {
+			matched = &s.rules[i]
+		}
+	}
+	if matched == nil {
+		return s.parent.ShouldSample(p)
+	}
+
+	return matched.sampler.ShouldSample(p)
+}
+
+// Description implements tracesdk.Sampler
+func (s pathSampler) Description() string {
+	rules := make([]string, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, fmt.Sprintf("%s=%g", rule.Prefix, rule.Ratio))
+	}
+	return fmt.Sprintf("PathRules{%s,%s}", strings.Join(rules, ","), s.parent.Description())
+}
@@ -0,0 +1,352 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func Test_samplerFromEnv(t *testing.T) {
+	defaultSampler := tracesdk.ParentBased(tracesdk.AlwaysSample()).Description()
+
+	cases := []struct {
+		name    string
+		sampler string
+		arg     string
+		want    string
+	}{
+		{
+			name: "unset uses the parent based always on default",
+			want: defaultSampler,
+		},
+		{
+			name:    "always_on",
+			sampler: "always_on",
+			want:    "AlwaysOnSampler",
+		},
+		{
+			name:    "always_off",
+			sampler: "always_off",
+			want:    "AlwaysOffSampler",
+		},
+		{
+			name:    "traceidratio",
+			sampler: "traceidratio",
+			arg:     "0.25",
+			want:    "TraceIDRatioBased{0.25}",
+		},
+		{
+			name:    "parentbased_traceidratio",
+			sampler: "parentbased_traceidratio",
+			arg:     "0.5",
+			want:    "ParentBased{root:TraceIDRatioBased{0.5},remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}",
+		},
+		{
+			name:    "malformed ratio falls back to the default",
+			sampler: "traceidratio",
+			arg:     "half",
+			want:    defaultSampler,
+		},
+		{
+			name:    "out of range ratio falls back to the default",
+			sampler: "parentbased_traceidratio",
+			arg:     "1.5",
+			want:    defaultSampler,
+		},
+		{
+			name:    "unknown sampler falls back to the default",
+			sampler: "sometimes",
+			want:    defaultSampler,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.sampler) > 0 {
+				t.Setenv(otelEnvTracesSampler, tc.sampler)
+			}
+			if len(tc.arg) > 0 {
+				t.Setenv(otelEnvTracesSamplerArg, tc.arg)
+			}
+
+			got := samplerFromEnv().Description()
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_debugSampler_SamplesWithDebugBaggage(t *testing.T) {
+	sampler := newDebugSampler(tracesdk.NeverSample())
+
+	member, _ := baggage.NewMember(DebugBaggageKey, "1")
+	bag, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	res := sampler.ShouldSample(tracesdk.SamplingParameters{ParentContext: ctx, Name: "/function/figlet"})
+	if res.Decision != tracesdk.RecordAndSample {
+		t.Errorf("want decision: %v, got: %v", tracesdk.RecordAndSample, res.Decision)
+	}
+}
+
+func Test_debugSampler_DelegatesWithoutDebugBaggage(t *testing.T) {
+	sampler := newDebugSampler(tracesdk.NeverSample())
+
+	member, _ := baggage.NewMember("tenant", "acme")
+	bag, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	res := sampler.ShouldSample(tracesdk.SamplingParameters{ParentContext: ctx, Name: "/function/figlet"})
+	if res.Decision != tracesdk.Drop {
+		t.Errorf("want decision: %v, got: %v", tracesdk.Drop, res.Decision)
+	}
+
+	if got := sampler.Description(); got != "DebugBaggage{AlwaysOffSampler}" {
+		t.Errorf("want description: DebugBaggage{AlwaysOffSampler}, got: %s", got)
+	}
+}
+
+func Test_Middleware_DebugBaggageFlipsSamplingDecision(t *testing.T) {
+	t.Setenv(otelEnvPropagators, "tracecontext,baggage")
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.NeverSample()),
+		WithDebugBaggage(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	var sampled []bool
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		sampled = append(sampled, trace.SpanFromContext(r.Context()).SpanContext().IsSampled())
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set("baggage", DebugBaggageKey+"=1")
+	handler(httptest.NewRecorder(), req)
+
+	if len(sampled) != 2 {
+		t.Fatalf("want 2 requests, got: %d", len(sampled))
+	}
+	if sampled[0] {
+		t.Errorf("want a request without debug baggage to be dropped")
+	}
+	if !sampled[1] {
+		t.Errorf("want a request with debug baggage to be sampled")
+	}
+}
+
+func Test_Middleware_JaegerDebugIDForcesSampling(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.NeverSample()),
+		WithJaegerDebugID(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	var sampled []bool
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		sampled = append(sampled, trace.SpanFromContext(r.Context()).SpanContext().IsSampled())
+	}, WithJaegerDebugID())
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set(JaegerDebugHeader, "debug-1234")
+	handler(httptest.NewRecorder(), req)
+
+	otel.GetTracerProvider().(*tracesdk.TracerProvider).ForceFlush(context.Background())
+
+	if len(sampled) != 2 {
+		t.Fatalf("want 2 requests, got: %d", len(sampled))
+	}
+	if sampled[0] {
+		t.Errorf("want a request without %s to be dropped", JaegerDebugHeader)
+	}
+	if !sampled[1] {
+		t.Errorf("want a request with %s to be sampled", JaegerDebugHeader)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 exported span, got: %d", len(spans))
+	}
+
+	found := false
+	for _, kv := range spans[0].Attributes {
+		if kv.Key == JaegerDebugIDKey {
+			found = kv.Value.AsString() == "debug-1234"
+		}
+	}
+	if !found {
+		t.Errorf("want %s: debug-1234 on the span", JaegerDebugIDKey)
+	}
+}
+
+func Test_Middleware_IgnoresJaegerDebugIDUnlessEnabled(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.NeverSample()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	sampled := false
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		sampled = trace.SpanFromContext(r.Context()).SpanContext().IsSampled()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+	req.Header.Set(JaegerDebugHeader, "debug-1234")
+	handler(httptest.NewRecorder(), req)
+
+	if sampled {
+		t.Errorf("want %s to be ignored when it is not enabled", JaegerDebugHeader)
+	}
+}
+
+// sampledPath reports whether sampler samples a root span for path
+func sampledPath(sampler tracesdk.Sampler, path string) bool {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	result := sampler.ShouldSample(tracesdk.SamplingParameters{
+		ParentContext: withSamplingPath(context.Background(), path),
+		TraceID:       traceID,
+		Name:          path,
+	})
+	return result.Decision == tracesdk.RecordAndSample
+}
+
+func Test_pathSampler_MatchingRules(t *testing.T) {
+	sampler := newPathSampler(tracesdk.NeverSample(), []SamplingRule{
+		{Prefix: "/function/", Ratio: 0},
+		{Prefix: "/function/payments", Ratio: 1},
+		{Prefix: "/healthz", Ratio: 0},
+	})
+
+	if !sampledPath(sampler, "/function/payments/refund") {
+		t.Errorf("want the longest matching prefix to sample /function/payments")
+	}
+	if sampledPath(sampler, "/function/figlet") {
+		t.Errorf("want /function/figlet dropped by the /function/ rule")
+	}
+}
+
+func Test_pathSampler_NonMatchingUsesParent(t *testing.T) {
+	sampler := newPathSampler(tracesdk.AlwaysSample(), []SamplingRule{
+		{Prefix: "/healthz", Ratio: 0},
+	})
+
+	if sampledPath(sampler, "/healthz") {
+		t.Errorf("want /healthz dropped by its rule")
+	}
+	if !sampledPath(sampler, "/function/figlet") {
+		t.Errorf("want a path without a rule left to the parent sampler")
+	}
+
+	// spans started without the middleware's path are left to the parent
+	result := sampler.ShouldSample(tracesdk.SamplingParameters{ParentContext: context.Background(), Name: "enqueue"})
+	if result.Decision != tracesdk.RecordAndSample {
+		t.Errorf("want a span without a path left to the parent sampler")
+	}
+}
+
+func Test_pathSampler_KeepsParentDecision(t *testing.T) {
+	sampler := newPathSampler(tracesdk.ParentBased(tracesdk.AlwaysSample()), []SamplingRule{
+		{Prefix: "/healthz", Ratio: 0},
+	})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	parent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+
+	result := sampler.ShouldSample(tracesdk.SamplingParameters{
+		ParentContext: withSamplingPath(parent, "/healthz"),
+		TraceID:       traceID,
+	})
+	if result.Decision != tracesdk.RecordAndSample {
+		t.Errorf("want the caller's sampled trace continued, got: %v", result.Decision)
+	}
+}
+
+func Test_newPathSampler_WithoutRules(t *testing.T) {
+	parent := tracesdk.AlwaysSample()
+	if got := newPathSampler(parent, nil); got != parent {
+		t.Errorf("want the parent sampler without rules, got: %s", got.Description())
+	}
+}
+
+func Test_parseSamplingRules(t *testing.T) {
+	rules := parseSamplingRules("/function/payments=1, /healthz=0.01,/bad,/worse=2")
+
+	want := []SamplingRule{{Prefix: "/function/payments", Ratio: 1}, {Prefix: "/healthz", Ratio: 0.01}}
+	if len(rules) != len(want) {
+		t.Fatalf("want rules: %v, got: %v", want, rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("want rule: %v, got: %v", want[i], rules[i])
+		}
+	}
+}
+
+func Test_Middleware_SamplesByPathRule(t *testing.T) {
+	unsetEnv(t, envTraceSamplingRules)
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Provider(context.Background(), "gateway", "dev", "none",
+		WithExporter(exporter),
+		WithSampler(tracesdk.AlwaysSample()),
+		WithSamplingRules(SamplingRule{Prefix: "/system/", Ratio: 0}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer shutdown(context.Background())
+
+	sampled := map[string]bool{}
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		sampled[r.URL.Path] = trace.SpanFromContext(r.Context()).SpanContext().IsSampled()
+	})
+
+	for _, path := range []string{"/system/functions", "/function/figlet"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if sampled["/system/functions"] {
+		t.Errorf("want /system/functions dropped by its rule")
+	}
+	if !sampled["/function/figlet"] {
+		t.Errorf("want /function/figlet sampled by the default sampler")
+	}
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PathRule replaces any segment of a URL path which matches Pattern with
+// Placeholder when naming spans, so that a path such as /users/12345 does
+// not create a new span name for every ID.
+type PathRule struct {
+	Pattern     *regexp.Regexp
+	Placeholder string
+}
+
+// DefaultPathRules replace UUIDs and integers with {id}
+var DefaultPathRules = []PathRule{
+	{
+		Pattern:     regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+		Placeholder: "{id}",
+	},
+	{
+		Pattern:     regexp.MustCompile(`^[0-9]+$`),
+		Placeholder: "{id}",
+	},
+}
+
+// templatePath applies the first matching rule to each segment of the path.
+func templatePath(path string, rules []PathRule) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) == 0 {
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(segment) {
+				segments[i] = rule.Placeholder
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
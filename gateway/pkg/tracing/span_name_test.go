@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func Test_templatePath_DefaultRules(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: "/function/figlet", want: "/function/figlet"},
+		{path: "/function/figlet/users/12345", want: "/function/figlet/users/{id}"},
+		{path: "/function/figlet/users/3f2504e0-4f89-11d3-9a0c-0305e82c3301/", want: "/function/figlet/users/{id}/"},
+		{path: "/function/figlet/users/12345/orders/987", want: "/function/figlet/users/{id}/orders/{id}"},
+		{path: "/function/figlet/users/user12345", want: "/function/figlet/users/user12345"},
+		{path: "/function/figlet/v2/3f2504e0-4f89", want: "/function/figlet/v2/3f2504e0-4f89"},
+		{path: "/", want: "/"},
+		{path: "", want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := templatePath(tc.path, DefaultPathRules); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_templatePath_CustomRules(t *testing.T) {
+	rules := []PathRule{
+		{Pattern: regexp.MustCompile(`^sku-[a-z0-9]+$`), Placeholder: "{sku}"},
+	}
+
+	got := templatePath("/function/store/products/sku-a1b2/12345", rules)
+	want := "/function/store/products/{sku}/12345"
+	if got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func Test_Middleware_TemplatesSpanName(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/system/users/12345", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if got := spans[0].Name(); got != "/system/users/{id}" {
+		t.Errorf("want span name: /system/users/{id}, got: %s", got)
+	}
+
+	if v, _ := spanAttribute(spans[0], semconv.URLPathKey); v.AsString() != "/system/users/12345" {
+		t.Errorf("want %s: /system/users/12345, got: %s", semconv.URLPathKey, v.Emit())
+	}
+}
+
+func Test_Middleware_WithPathRules(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {},
+		WithPathRules(PathRule{Pattern: regexp.MustCompile(`^[a-z]{2}-[A-Z]{2}$`), Placeholder: "{locale}"}))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/system/docs/en-GB/12345", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if got := spans[0].Name(); got != "/system/docs/{locale}/12345" {
+		t.Errorf("want span name: /system/docs/{locale}/12345, got: %s", got)
+	}
+}
+
+func Test_Middleware_FunctionSpanNamedAfterRoute(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	for _, path := range []string{"/function/figlet/foo", "/function/figlet/bar", "/function/figlet/users/12345"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/async-function/figlet/baz", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("want 4 spans, got: %d", len(spans))
+	}
+
+	for _, span := range spans[:3] {
+		if got := span.Name(); got != "/function/{name}" {
+			t.Errorf("want span name: /function/{name}, got: %s", got)
+		}
+	}
+	if got := spans[3].Name(); got != "/async-function/{name}" {
+		t.Errorf("want span name: /async-function/{name}, got: %s", got)
+	}
+}
+
+func Test_Middleware_WithSpanNameFunc(t *testing.T) {
+	recorder := recordSpans(t)
+
+	routeName := func(r *http.Request) string {
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ := route.GetPathTemplate()
+			return r.Method + " " + template
+		}
+		return ""
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/system/users/{user}", Middleware(func(w http.ResponseWriter, r *http.Request) {}, WithSpanNameFunc(routeName)))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/system/users/alex", nil))
+
+	// without a matched route the default name is kept
+	Middleware(func(w http.ResponseWriter, r *http.Request) {}, WithSpanNameFunc(routeName))(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/system/users/12345", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+
+	if got := spans[0].Name(); got != "DELETE /system/users/{user}" {
+		t.Errorf("want span name: DELETE /system/users/{user}, got: %s", got)
+	}
+	if got := spans[1].Name(); got != "/system/users/{id}" {
+		t.Errorf("want span name: /system/users/{id}, got: %s", got)
+	}
+}
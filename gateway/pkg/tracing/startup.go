@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartupSpanName is the name of the span emitted by WithStartupSpan
+const StartupSpanName = "gateway.startup"
+
+// envTraceStartupSpan enables the startup span when WithStartupSpan is not
+// given, i.e. "true"
+const envTraceStartupSpan = "FAAS_TRACE_STARTUP_SPAN"
+
+// WithStartupSpan emits one StartupSpanName span, with the gateway's
+// version and commit, as soon as the pipeline is set up. It is always
+// sampled, so that its arrival in the backend confirms the pipeline works
+// before there is any traffic. This is instead of FAAS_TRACE_STARTUP_SPAN.
+func WithStartupSpan() Option {
+	return func(c *config) {
+		c.startupSpan = true
+	}
+}
+
+// startupSpanEnabled reports whether the startup span is emitted
+func (c *config) startupSpanEnabled() bool {
+	return c.startupSpan || strings.ToLower(get(envTraceStartupSpan, "false")) == "true"
+}
+
+type startupContextKey struct{}
+
+// startupSampler samples the startup span, and otherwise defers to the
+// parent sampler.
+type startupSampler struct {
+	parent tracesdk.Sampler
+}
+
+// newStartupSampler wraps parent so that the startup span is always sampled
+func newStartupSampler(parent tracesdk.Sampler) tracesdk.Sampler {
+	return startupSampler{parent: parent}
+}
+
+// ShouldSample implements tracesdk.Sampler
+func (s startupSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if startup, _ := p.ParentContext.Value(startupContextKey{}).(bool); startup {
+		return tracesdk.SamplingResult{Decision: tracesdk.RecordAndSample}
+	}
+
+	return s.parent.ShouldSample(p)
+}
+
+// Description implements tracesdk.Sampler
+func (s startupSampler) Description() string {
+	return fmt.Sprintf("Startup{%s}", s.parent.Description())
+}
+
+// emitStartupSpan ends the startup span straight away, it is exported with
+// the next batch
+func emitStartupSpan(ctx context.Context, provider trace.TracerProvider, version, commit string) {
+	ctx = context.WithValue(ctx, startupContextKey{}, true)
+
+	_, span := provider.Tracer(TracerName).Start(ctx, StartupSpanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			semconv.ServiceVersionKey.String(version),
+			attribute.String("service.commit", commit),
+		),
+	)
+	span.End()
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func Test_StartupSpan_RecordedWhenNotSampled(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	pipeline, err := NewPipeline(context.Background(), "gateway", "0.27.0", "abc123",
+		WithoutGlobalRegistration(),
+		WithSampler(tracesdk.NeverSample()),
+		WithExporter(exporter),
+		WithStartupSpan(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeline.Shutdown(context.Background())
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != StartupSpanName {
+		t.Errorf("want span name: %s, got: %s", StartupSpanName, span.Name())
+	}
+	if got, _ := spanAttribute(span, semconv.ServiceVersionKey); got.AsString() != "0.27.0" {
+		t.Errorf("want version: 0.27.0, got: %q", got.AsString())
+	}
+	if got, _ := spanAttribute(span, "service.commit"); got.AsString() != "abc123" {
+		t.Errorf("want commit: abc123, got: %q", got.AsString())
+	}
+}
+
+func Test_StartupSpan_FromEnv(t *testing.T) {
+	t.Setenv(envTraceStartupSpan, "true")
+
+	exporter := tracetest.NewInMemoryExporter()
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithExporter(exporter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeline.Shutdown(context.Background())
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != StartupSpanName {
+		t.Errorf("want the startup span with %s=true, got: %v", envTraceStartupSpan, spans)
+	}
+}
+
+func Test_StartupSpan_OffByDefault(t *testing.T) {
+	unsetEnv(t, envTraceStartupSpan)
+
+	exporter := tracetest.NewInMemoryExporter()
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithExporter(exporter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeline.Shutdown(context.Background())
+	pipeline.TracerProvider.ForceFlush(context.Background())
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("want no spans, got: %d", len(spans))
+	}
+}
+
+func Test_StartupSpan_NoopWhenTracingDisabled(t *testing.T) {
+	t.Setenv(otelEnvTraceSExporter, string(DisabledExporter))
+
+	pipeline, err := NewPipeline(context.Background(), "gateway", "dev", "none",
+		WithoutGlobalRegistration(),
+		WithStartupSpan(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeline.Shutdown(context.Background())
+
+	if pipeline.TracerProvider != nil {
+		t.Errorf("want no tracer provider when tracing is disabled")
+	}
+	if enabled() {
+		t.Errorf("want the global tracer provider to be left unset, got: %T", otel.GetTracerProvider())
+	}
+}
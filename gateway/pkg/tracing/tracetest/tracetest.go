@@ -0,0 +1,70 @@
+// Package tracetest captures the spans created by the gateway in memory, so
+// that tests can assert on the spans from tracing.Middleware and
+// tracing.Transport without running a collector.
+package tracetest
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder holds the spans recorded since Install was called
+type Recorder struct {
+	recorder *sdktracetest.SpanRecorder
+}
+
+// Install registers a TracerProvider which samples and records every span,
+// along with the tracecontext and baggage propagators, as the globals. Call
+// the returned teardown func to restore the previous globals.
+//
+// Install must be called before tracing.Middleware and tracing.Transport are
+// constructed, since both pass-through when tracing is disabled.
+func Install() (*Recorder, func()) {
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+
+	recorder := sdktracetest.NewSpanRecorder()
+	provider := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(tracesdk.AlwaysSample()),
+		tracesdk.WithSpanProcessor(recorder),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	teardown := func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}
+
+	return &Recorder{recorder: recorder}, teardown
+}
+
+// Ended returns the spans which have ended, in the order they ended
+func (r *Recorder) Ended() []tracesdk.ReadOnlySpan {
+	return r.recorder.Ended()
+}
+
+// Started returns the spans which have started, in the order they started
+func (r *Recorder) Started() []tracesdk.ReadWriteSpan {
+	return r.recorder.Started()
+}
+
+// Named returns the ended spans with the given name
+func (r *Recorder) Named(name string) []tracesdk.ReadOnlySpan {
+	spans := []tracesdk.ReadOnlySpan{}
+	for _, span := range r.recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
@@ -0,0 +1,95 @@
+package tracetest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+)
+
+// This example records the server span created by tracing.Middleware and the
+// client span created by tracing.Transport for a call to a function.
+func Example() {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	handler := tracing.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		res, err := client.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	for _, span := range recorder.Ended() {
+		fmt.Println(span.SpanKind(), span.Name())
+	}
+	// Output:
+	// client GET
+	// server /function/{name}
+}
+
+func Test_Install_RecordsParentChildLinks(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	handler := tracing.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Errorf("upstream request failed: %s", err)
+			return
+		}
+		res.Body.Close()
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	servers := recorder.Named("/function/{name}")
+	clients := recorder.Named(http.MethodGet)
+	if len(servers) != 1 || len(clients) != 1 {
+		t.Fatalf("want 1 server and 1 client span, got: %d and %d", len(servers), len(clients))
+	}
+
+	if clients[0].Parent().SpanID() != servers[0].SpanContext().SpanID() {
+		t.Errorf("want the client span to be a child of the server span")
+	}
+}
+
+func Test_Install_TeardownRestoresGlobals(t *testing.T) {
+	before := otel.GetTracerProvider()
+
+	_, teardown := tracetest.Install()
+	if otel.GetTracerProvider() == before {
+		t.Fatalf("want Install to register a new tracer provider")
+	}
+	teardown()
+
+	if otel.GetTracerProvider() != before {
+		t.Errorf("want teardown to restore the previous tracer provider")
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "after")
+	if span.IsRecording() {
+		t.Errorf("want spans created after teardown to not be recorded")
+	}
+}
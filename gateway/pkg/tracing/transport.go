@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transport starts a client span for each request made to a function
+type transport struct {
+	base       http.RoundTripper
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	limit      int
+}
+
+// Transport wraps base so that each outbound request is recorded as a client
+// span, which is a child of any span in the request's context. The span
+// context is injected into the outgoing headers with the global propagator,
+// or those given by WithPropagators, and spans are started with the global
+// TracerProvider or the one given by WithTracerProvider.
+// When tracing is disabled base is returned unchanged, and when base is nil
+// http.DefaultTransport is used.
+func Transport(base http.RoundTripper, opts ...Option) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	cfg := newConfig(opts)
+	if !cfg.tracingEnabled() {
+		return base
+	}
+
+	return &transport{
+		base:       base,
+		tracer:     cfg.tracer(),
+		propagator: cfg.propagator(),
+		limit:      cfg.attributeLimit(),
+	}
+}
+
+// RoundTrip records the time taken for the function to return its response
+// headers, along with the status code.
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(r.Context(), r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			truncatedString(semconv.ServerAddressKey, r.URL.Hostname(), t.limit),
+			semconv.ServerPort(serverPort(r.URL)),
+			// the query string is left out as callers often pass tokens in it
+			truncatedString(semconv.URLPathKey, r.URL.Path, t.limit),
+		),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the request it was given.
+	r = r.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	res, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return res, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.TLS != nil {
+		span.SetAttributes(tlsAttributes(res.TLS)...)
+	}
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+
+	return res, nil
+}
+
+// tlsAttributes record the TLS version and cipher suite negotiated with the
+// function, for auditing which connections met the gateway's TLS policy
+func tlsAttributes(state *tls.ConnectionState) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.TLSProtocolNameTLS,
+		semconv.TLSProtocolVersion(strings.TrimPrefix(tls.VersionName(state.Version), "TLS ")),
+		semconv.TLSCipher(tls.CipherSuiteName(state.CipherSuite)),
+		semconv.TLSResumed(state.DidResume),
+	}
+}
+
+// serverPort returns the port for u, or the default port for its scheme
+func serverPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
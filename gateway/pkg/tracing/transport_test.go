@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_Transport_ClientSpanIsChildOfServerSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Errorf("upstream request failed: %s", err)
+			return
+		}
+		res.Body.Close()
+		w.WriteHeader(res.StatusCode)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/function/figlet", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got: %d", len(spans))
+	}
+
+	clientSpan, server := spans[0], spans[1]
+	if clientSpan.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("want first span to be a client span, got: %s", clientSpan.SpanKind())
+	}
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("want second span to be a server span, got: %s", server.SpanKind())
+	}
+
+	if clientSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("want client span parent: %s, got: %s", server.SpanContext().SpanID(), clientSpan.Parent().SpanID())
+	}
+	if clientSpan.SpanContext().TraceID() != server.SpanContext().TraceID() {
+		t.Errorf("want client and server spans to share a trace ID")
+	}
+
+	if v, ok := spanAttribute(clientSpan, semconv.HTTPResponseStatusCodeKey); !ok || v.AsInt64() != http.StatusAccepted {
+		t.Errorf("want %s: %d, got: %s", semconv.HTTPResponseStatusCodeKey, http.StatusAccepted, v.Emit())
+	}
+
+	want := "00-" + clientSpan.SpanContext().TraceID().String() + "-" + clientSpan.SpanContext().SpanID().String() + "-01"
+	if upstreamTraceparent != want {
+		t.Errorf("want upstream traceparent: %s, got: %s", want, upstreamTraceparent)
+	}
+}
+
+func Test_Transport_PreservesTraceState(t *testing.T) {
+	const (
+		traceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID   = "00f067aa0ba902b7"
+		tracestate = "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"
+	)
+
+	for _, flags := range []string{"01", "00"} {
+		t.Run("flags "+flags, func(t *testing.T) {
+			recordSpans(t)
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+			defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+			var upstreamHeader http.Header
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamHeader = r.Header.Clone()
+			}))
+			defer upstream.Close()
+
+			client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+			var proxiedHeader http.Header
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+				proxiedHeader = r.Header.Clone()
+
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+				res, err := client.Do(req)
+				if err != nil {
+					t.Errorf("upstream request failed: %s", err)
+					return
+				}
+				res.Body.Close()
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/function/figlet", nil)
+			req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-"+flags)
+			req.Header.Set("tracestate", tracestate)
+			handler(httptest.NewRecorder(), req)
+
+			for name, header := range map[string]http.Header{"proxied": proxiedHeader, "upstream": upstreamHeader} {
+				if got := header.Get("tracestate"); got != tracestate {
+					t.Errorf("%s: want tracestate: %s, got: %q", name, tracestate, got)
+				}
+
+				// the vendor entries go with the gateway's span, in the same trace
+				traceparent := header.Get("traceparent")
+				if !strings.HasPrefix(traceparent, "00-"+traceID+"-") || strings.Contains(traceparent, parentID) {
+					t.Errorf("%s: want a traceparent for a new span in trace %s, got: %q", name, traceID, traceparent)
+				}
+				if !strings.HasSuffix(traceparent, "-"+flags) {
+					t.Errorf("%s: want the sampled flag %s kept, got: %q", name, flags, traceparent)
+				}
+			}
+		})
+	}
+}
+
+func Test_Transport_RecordsConnectionErrors(t *testing.T) {
+	recorder := recordSpans(t)
+
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	if _, err := client.Get(upstream.URL); err == nil {
+		t.Fatal("want an error calling a closed server")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("want span status: %s, got: %s", codes.Error, spans[0].Status().Code)
+	}
+}
+
+func Test_Transport_ReturnsBase_WhenTracingDisabled(t *testing.T) {
+	base := &http.Transport{}
+	if got := Transport(base); got != base {
+		t.Errorf("want the base transport when tracing is disabled, got: %T", got)
+	}
+}
+
+func Test_Transport_DoesNotRecordQueryString(t *testing.T) {
+	recorder := recordSpans(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	res, err := client.Get(upstream.URL + "/employees?api_key=secret-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	for _, kv := range spans[0].Attributes() {
+		if strings.Contains(kv.Value.Emit(), "secret-value") {
+			t.Errorf("want no query string in attributes, got %s: %s", kv.Key, kv.Value.Emit())
+		}
+	}
+
+	if v, _ := spanAttribute(spans[0], semconv.URLPathKey); v.AsString() != "/employees" {
+		t.Errorf("want %s: /employees, got: %s", semconv.URLPathKey, v.Emit())
+	}
+
+	u, _ := url.Parse(upstream.URL)
+	if v, _ := spanAttribute(spans[0], semconv.ServerPortKey); strconv.Itoa(int(v.AsInt64())) != u.Port() {
+		t.Errorf("want %s: %s, got: %s", semconv.ServerPortKey, u.Port(), v.Emit())
+	}
+}
+
+func Test_Transport_RecordsNegotiatedTLS(t *testing.T) {
+	recorder := recordSpans(t)
+
+	upstream := httptest.NewUnstartedServer(http.NotFoundHandler())
+	upstream.TLS = &tls.Config{MinVersion: tls.VersionTLS13}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	client := &http.Client{Transport: Transport(upstream.Client().Transport)}
+	res, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	want := map[attribute.Key]string{
+		semconv.TLSProtocolNameKey:    "tls",
+		semconv.TLSProtocolVersionKey: "1.3",
+		semconv.TLSCipherKey:          tls.CipherSuiteName(res.TLS.CipherSuite),
+	}
+	for key, val := range want {
+		if v, _ := spanAttribute(spans[0], key); v.AsString() != val {
+			t.Errorf("want %s: %q, got: %q", key, val, v.Emit())
+		}
+	}
+}
+
+func Test_serverPort(t *testing.T) {
+	cases := map[string]int{
+		"http://figlet.openfaas-fn:8080/": 8080,
+		"http://figlet.openfaas-fn/":      80,
+		"https://figlet.openfaas-fn/":     443,
+	}
+
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := serverPort(u); got != want {
+			t.Errorf("%s: want port %d, got: %d", raw, want, got)
+		}
+	}
+}
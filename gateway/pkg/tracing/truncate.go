@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	envTraceMaxAttributeLength = "FAAS_TRACE_MAX_ATTRIBUTE_LENGTH"
+
+	// defaultMaxAttributeLength is the longest string attribute value, in
+	// bytes, set by Middleware and Transport
+	defaultMaxAttributeLength = 1024
+
+	// truncatedMarker ends a value which was cut short
+	truncatedMarker = "..."
+)
+
+// WithMaxAttributeLength limits the string attribute values set by
+// Middleware and Transport to n bytes, instead of
+// FAAS_TRACE_MAX_ATTRIBUTE_LENGTH or the default of 1024. Longer values are
+// cut short and end with "...". A limit of 0 or less keeps values whole.
+func WithMaxAttributeLength(n int) Option {
+	return func(c *config) {
+		c.maxAttributeLength = &n
+	}
+}
+
+// attributeLimit is the configured limit for string attribute values
+func (c *config) attributeLimit() int {
+	if c.maxAttributeLength != nil {
+		return *c.maxAttributeLength
+	}
+
+	val, ok := os.LookupEnv(envTraceMaxAttributeLength)
+	if !ok {
+		return defaultMaxAttributeLength
+	}
+
+	limit, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("invalid %s value: %q, using %d", envTraceMaxAttributeLength, val, defaultMaxAttributeLength)
+		return defaultMaxAttributeLength
+	}
+	return limit
+}
+
+// truncate shortens value to at most limit bytes, including the marker,
+// without splitting a multi-byte rune. Values within the limit, or any
+// value when limit is 0 or less, are returned as they are.
+func truncate(value string, limit int) string {
+	if limit <= 0 || len(value) <= limit {
+		return value
+	}
+
+	marker := truncatedMarker
+	if limit <= len(marker) {
+		marker = ""
+	}
+
+	cut := limit - len(marker)
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + marker
+}
+
+// truncatedString is a string attribute with its value shortened to limit
+func truncatedString(key attribute.Key, value string, limit int) attribute.KeyValue {
+	return key.String(truncate(value, limit))
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func Test_truncate(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		limit int
+		want  string
+	}{
+		{name: "under limit", value: "text/plain", limit: 16, want: "text/plain"},
+		{name: "at limit", value: "text/plain", limit: 10, want: "text/plain"},
+		{name: "over limit ascii", value: "/function/figlet/abcdefgh", limit: 16, want: "/function/fig..."},
+		{name: "no limit", value: "/function/figlet/abcdefgh", limit: 0, want: "/function/figlet/abcdefgh"},
+		{name: "limit shorter than marker", value: "figlet", limit: 2, want: "fi"},
+		// each "é" is 2 bytes, so cutting at 8 would split the fourth
+		{name: "over limit utf-8", value: "/éééééé", limit: 11, want: "/ééé..."},
+		{name: "over limit 4 byte runes", value: "🙂🙂🙂", limit: 9, want: "🙂..."},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := truncate(tc.value, tc.limit)
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("want valid UTF-8, got: %q", got)
+			}
+			if tc.limit > 0 && len(got) > tc.limit {
+				t.Errorf("want at most %d bytes, got: %d", tc.limit, len(got))
+			}
+		})
+	}
+}
+
+func Test_config_attributeLimit(t *testing.T) {
+	unsetEnv(t, envTraceMaxAttributeLength)
+	if got := newConfig(nil).attributeLimit(); got != defaultMaxAttributeLength {
+		t.Errorf("want the default of %d, got: %d", defaultMaxAttributeLength, got)
+	}
+
+	t.Setenv(envTraceMaxAttributeLength, "256")
+	if got := newConfig(nil).attributeLimit(); got != 256 {
+		t.Errorf("want %s to be used, got: %d", envTraceMaxAttributeLength, got)
+	}
+
+	if got := newConfig([]Option{WithMaxAttributeLength(64)}).attributeLimit(); got != 64 {
+		t.Errorf("want the option to win over the env, got: %d", got)
+	}
+}
+
+func Test_Middleware_TruncatesLongAttributes(t *testing.T) {
+	recorder := recordSpans(t)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {}, WithMaxAttributeLength(32))
+
+	path := "/function/figlet/" + strings.Repeat("a", 100)
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got: %d", len(spans))
+	}
+
+	got, _ := spanAttribute(spans[0], semconv.URLPathKey)
+	if want := path[:29] + "..."; got.AsString() != want {
+		t.Errorf("want %s: %q, got: %q", semconv.URLPathKey, want, got.AsString())
+	}
+}
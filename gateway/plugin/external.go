@@ -110,12 +110,38 @@ func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string)
 	maxReplicas := uint64(scaling.DefaultMaxReplicas)
 	scalingFactor := uint64(scaling.DefaultScalingFactor)
 	availableReplicas := function.AvailableReplicas
+	maxInflight := uint64(0)
+	maxBodyBytes := uint64(0)
+	execTimeout := time.Duration(0)
+	coalesce := false
+	cacheTTL := time.Duration(0)
+	canary := ""
+	canaryWeight := uint64(0)
+	var contentRoutes []scaling.ContentRoute
 
 	if function.Labels != nil {
 		labels := *function.Labels
 
 		minReplicas = extractLabelValue(labels[scaling.MinScaleLabel], minReplicas)
 		maxReplicas = extractLabelValue(labels[scaling.MaxScaleLabel], maxReplicas)
+		maxInflight = extractLabelValue(labels[scaling.MaxInflightLabel], maxInflight)
+		maxBodyBytes = extractLabelValue(labels[scaling.MaxBodyBytesLabel], maxBodyBytes)
+		execTimeout = extractDurationLabelValue(labels[scaling.ExecTimeoutLabel], execTimeout)
+		coalesce = extractBoolLabelValue(labels[scaling.CoalesceLabel], coalesce)
+		cacheTTL = extractDurationLabelValue(labels[scaling.CacheTTLLabel], cacheTTL)
+		canary = labels[scaling.CanaryLabel]
+		canaryWeight = extractLabelValue(labels[scaling.CanaryWeightLabel], canaryWeight)
+		if canaryWeight > 100 {
+			log.Printf("Provided label value %d for %s should be between 0 and 100", canaryWeight, scaling.CanaryWeightLabel)
+			canaryWeight = 0
+		}
+		if value := labels[scaling.ContentRoutesLabel]; len(value) > 0 {
+			routes, err := scaling.ParseContentRoutes(value)
+			if err != nil {
+				log.Printf("Invalid routes in the label value for %s ignored: %s", scaling.ContentRoutesLabel, err)
+			}
+			contentRoutes = routes
+		}
 		extractedScalingFactor := extractLabelValue(labels[scaling.ScalingFactorLabel], scalingFactor)
 
 		if extractedScalingFactor > 0 && extractedScalingFactor <= 100 {
@@ -131,7 +157,16 @@ func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string)
 		MinReplicas:       minReplicas,
 		ScalingFactor:     scalingFactor,
 		AvailableReplicas: availableReplicas,
+		MaxInflight:       maxInflight,
+		MaxBodyBytes:      maxBodyBytes,
+		ExecTimeout:       execTimeout,
+		Coalesce:          coalesce,
+		CacheTTL:          cacheTTL,
+		Canary:            canary,
+		CanaryWeight:      canaryWeight,
+		ContentRoutes:     contentRoutes,
 		Annotations:       function.Annotations,
+		Image:             function.Image,
 	}, err
 }
 
@@ -194,3 +229,38 @@ func extractLabelValue(rawLabelValue string, fallback uint64) uint64 {
 
 	return uint64(value)
 }
+
+// extractDurationLabelValue parses a label given in seconds or as a duration
+// i.e. "1m30s"
+func extractDurationLabelValue(rawLabelValue string, fallback time.Duration) time.Duration {
+	if len(rawLabelValue) <= 0 {
+		return fallback
+	}
+
+	if seconds, err := strconv.Atoi(rawLabelValue); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	value, err := time.ParseDuration(rawLabelValue)
+	if err != nil || value < 0 {
+		log.Printf("Provided label value %s should be a duration or a number of seconds", rawLabelValue)
+		return fallback
+	}
+
+	return value
+}
+
+// extractBoolLabelValue parses a label given as "true" or "false"
+func extractBoolLabelValue(rawLabelValue string, fallback bool) bool {
+	if len(rawLabelValue) <= 0 {
+		return fallback
+	}
+
+	value, err := strconv.ParseBool(rawLabelValue)
+	if err != nil {
+		log.Printf("Provided label value %s should be true or false", rawLabelValue)
+		return fallback
+	}
+
+	return value
+}
@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	middleware "github.com/openfaas/faas/gateway/pkg/middleware"
 	"github.com/openfaas/faas/gateway/scaling"
@@ -39,6 +41,38 @@ func TestLabelValueWasInValid(t *testing.T) {
 		t.Fail()
 	}
 }
+func TestDurationLabelValue(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":       time.Minute,
+		"30":     30 * time.Second,
+		"1m30s":  90 * time.Second,
+		"thirty": time.Minute,
+		"-5s":    time.Minute,
+	}
+
+	for raw, want := range cases {
+		if got := extractDurationLabelValue(raw, time.Minute); got != want {
+			t.Errorf("%q: want %s, got: %s", raw, want, got)
+		}
+	}
+}
+
+func TestBoolLabelValue(t *testing.T) {
+	cases := map[string]bool{
+		"":      false,
+		"true":  true,
+		"1":     true,
+		"false": false,
+		"yes":   false,
+	}
+
+	for raw, want := range cases {
+		if got := extractBoolLabelValue(raw, false); got != want {
+			t.Errorf("%q: want %t, got: %t", raw, want, got)
+		}
+	}
+}
+
 func TestGetReplicasNonExistentFn(t *testing.T) {
 
 	testServer := httptest.NewServer(
@@ -88,7 +122,7 @@ func TestGetReplicasExistentFn(t *testing.T) {
 		t.Logf("Expected err to be nil got: %s ", err.Error())
 		t.Fail()
 	}
-	if svcQryResp != expectedSvcQryResp {
+	if !reflect.DeepEqual(svcQryResp, expectedSvcQryResp) {
 		t.Logf("Unexpected return values - wanted\n%+v\ngot\n%+v ", expectedSvcQryResp, svcQryResp)
 		t.Fail()
 	}
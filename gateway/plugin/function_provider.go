@@ -0,0 +1,166 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	types "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/handlers"
+	middleware "github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// ExternalFunctionProvider manages functions through the faas-provider's
+// /system/functions API
+type ExternalFunctionProvider struct {
+	URL          url.URL
+	Client       *http.Client
+	AuthInjector middleware.AuthInjector
+}
+
+// NewExternalFunctionProvider manages functions on the provider at
+// externalURL, making its requests with client
+func NewExternalFunctionProvider(externalURL url.URL, client *http.Client, authInjector middleware.AuthInjector) handlers.FunctionProvider {
+	return &ExternalFunctionProvider{
+		URL:          externalURL,
+		Client:       client,
+		AuthInjector: authInjector,
+	}
+}
+
+// Deploy creates a new function
+func (p *ExternalFunctionProvider) Deploy(ctx context.Context, spec types.FunctionDeployment) (*handlers.ProviderResponse, error) {
+	return p.do(ctx, http.MethodPost, "system/functions", spec)
+}
+
+// Update changes the spec of an existing function
+func (p *ExternalFunctionProvider) Update(ctx context.Context, spec types.FunctionDeployment) (*handlers.ProviderResponse, error) {
+	return p.do(ctx, http.MethodPut, "system/functions", spec)
+}
+
+// List returns the functions deployed to namespace, or the provider's
+// default namespace when it is empty
+func (p *ExternalFunctionProvider) List(ctx context.Context, namespace string) ([]types.FunctionStatus, error) {
+	res, err := p.ListResponse(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	functions := []types.FunctionStatus{}
+	if err := json.Unmarshal(res.Body, &functions); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal functions: %q, %w", string(res.Body), err)
+	}
+	return functions, nil
+}
+
+// Get returns the function named functionName in namespace, or a
+// handlers.ProviderError with the provider's 404 when it is not deployed
+func (p *ExternalFunctionProvider) Get(ctx context.Context, functionName, namespace string) (types.FunctionStatus, error) {
+	path := "system/function/" + url.PathEscape(functionName)
+	if len(namespace) > 0 {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+
+	res, err := p.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return types.FunctionStatus{}, err
+	}
+
+	function := types.FunctionStatus{}
+	if err := json.Unmarshal(res.Body, &function); err != nil {
+		return types.FunctionStatus{}, fmt.Errorf("unable to unmarshal function: %q, %w", string(res.Body), err)
+	}
+	return function, nil
+}
+
+// ListResponse returns the provider's response to a list of the functions
+// deployed to namespace
+func (p *ExternalFunctionProvider) ListResponse(ctx context.Context, namespace string) (*handlers.ProviderResponse, error) {
+	path := "system/functions"
+	if len(namespace) > 0 {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+
+	return p.do(ctx, http.MethodGet, path, nil)
+}
+
+// Delete removes a function
+func (p *ExternalFunctionProvider) Delete(ctx context.Context, functionName, namespace string) (*handlers.ProviderResponse, error) {
+	return p.do(ctx, http.MethodDelete, "system/functions", types.DeleteFunctionRequest{
+		FunctionName: functionName,
+		Namespace:    namespace,
+	})
+}
+
+func (p *ExternalFunctionProvider) do(ctx context.Context, method, path string, payload interface{}) (*handlers.ProviderResponse, error) {
+	return callProviderResponse(ctx, p.Client, p.AuthInjector, p.URL, method, path, payload)
+}
+
+// callProvider sends payload as JSON to the provider's API at path, returning
+// the response body, or a handlers.ProviderError when it was rejected
+func callProvider(ctx context.Context, client *http.Client, authInjector middleware.AuthInjector, providerURL url.URL, method, path string, payload interface{}) ([]byte, error) {
+	res, err := callProviderResponse(ctx, client, authInjector, providerURL, method, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// callProviderResponse is callProvider, returning the provider's status and
+// content type along with its body
+func callProviderResponse(ctx context.Context, client *http.Client, authInjector middleware.AuthInjector, providerURL url.URL, method, path string, payload interface{}) (*handlers.ProviderResponse, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	base := providerURL.String()
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, base+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if authInjector != nil {
+		authInjector.Inject(req)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, &handlers.ProviderError{
+			StatusCode: res.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+		}
+	}
+
+	return &handlers.ProviderResponse{
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        body,
+	}, nil
+}
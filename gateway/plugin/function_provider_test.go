@@ -0,0 +1,102 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	types "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/handlers"
+)
+
+func Test_ExternalFunctionProvider(t *testing.T) {
+	var gotDelete types.DeleteFunctionRequest
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/system/function/figlet" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(types.FunctionStatus{Name: "figlet", Namespace: r.URL.Query().Get("namespace")})
+			return
+		}
+		if r.URL.Path != "/system/functions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode([]types.FunctionStatus{{Name: "figlet", Namespace: r.URL.Query().Get("namespace")}})
+		case http.MethodPut:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"rollout":"started"}`))
+		case http.MethodPost:
+			http.Error(w, "function figlet already exists", http.StatusConflict)
+		case http.MethodDelete:
+			json.NewDecoder(r.Body).Decode(&gotDelete)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer provider.Close()
+
+	providerURL, _ := url.Parse(provider.URL)
+	client := NewExternalFunctionProvider(*providerURL, http.DefaultClient, nil)
+
+	functions, err := client.List(context.Background(), "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(functions) != 1 || functions[0].Namespace != "staging" {
+		t.Errorf("want figlet in staging, got: %+v", functions)
+	}
+
+	function, err := client.Get(context.Background(), "figlet", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if function.Name != "figlet" || function.Namespace != "staging" {
+		t.Errorf("want figlet in staging, got: %+v", function)
+	}
+
+	_, err = client.Get(context.Background(), "missing", "staging")
+	var notFound *handlers.ProviderError
+	if !errors.As(err, &notFound) || notFound.StatusCode != http.StatusNotFound {
+		t.Errorf("want a 404 ProviderError for a missing function, got: %v", err)
+	}
+
+	_, err = client.Deploy(context.Background(), types.FunctionDeployment{Service: "figlet", Image: "figlet"})
+	var providerErr *handlers.ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != http.StatusConflict {
+		t.Fatalf("want a 409 ProviderError, got: %v", err)
+	}
+	if providerErr.Message != "function figlet already exists" {
+		t.Errorf("want the provider's message, got: %q", providerErr.Message)
+	}
+
+	res, err := client.Update(context.Background(), types.FunctionDeployment{Service: "figlet", Image: "figlet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.ContentType != "application/json" || string(res.Body) != `{"rollout":"started"}` {
+		t.Errorf("want the provider's response, got: %d %q %s", res.StatusCode, res.ContentType, res.Body)
+	}
+
+	res, err = client.Delete(context.Background(), "figlet", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusAccepted {
+		t.Errorf("want the provider's status: %d, got: %d", http.StatusAccepted, res.StatusCode)
+	}
+	if gotDelete.FunctionName != "figlet" || gotDelete.Namespace != "staging" {
+		t.Errorf("want figlet.staging deleted, got: %+v", gotDelete)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/faas/gateway/handlers"
+	middleware "github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// ExternalLogProvider streams logs from the faas-provider's /system/logs
+// API, which returns newline delimited JSON
+type ExternalLogProvider struct {
+	URL          url.URL
+	Client       *http.Client
+	AuthInjector middleware.AuthInjector
+}
+
+// NewExternalLogProvider streams logs from the provider at externalURL. The
+// client should not have a timeout, or followed streams are cut off by it.
+func NewExternalLogProvider(externalURL url.URL, client *http.Client, authInjector middleware.AuthInjector) handlers.LogProvider {
+	return &ExternalLogProvider{
+		URL:          externalURL,
+		Client:       client,
+		AuthInjector: authInjector,
+	}
+}
+
+// Logs starts a stream from the provider, the channel is closed when the
+// provider ends the stream or ctx is done
+func (p *ExternalLogProvider) Logs(ctx context.Context, req handlers.LogRequest) (<-chan handlers.LogMessage, error) {
+	query := url.Values{}
+	query.Set("name", req.Name)
+	if len(req.Namespace) > 0 {
+		query.Set("namespace", req.Namespace)
+	}
+	if req.Since != nil {
+		query.Set("since", req.Since.Format(time.RFC3339))
+	}
+	if req.Tail > 0 {
+		query.Set("tail", strconv.Itoa(req.Tail))
+	}
+	query.Set("follow", strconv.FormatBool(req.Follow))
+
+	logsURL := strings.TrimSuffix(p.URL.String(), "/") + "/system/logs?" + query.Encode()
+	logReq, err := http.NewRequestWithContext(ctx, http.MethodGet, logsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.AuthInjector != nil {
+		p.AuthInjector.Inject(logReq)
+	}
+
+	res, err := p.Client.Do(logReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+
+		status := res.StatusCode
+		if status == http.StatusNotFound {
+			// the provider does not implement logs
+			status = http.StatusNotImplemented
+		}
+		return nil, &handlers.ProviderError{StatusCode: status, Message: strings.TrimSpace(string(body))}
+	}
+
+	messages := make(chan handlers.LogMessage)
+	go func() {
+		defer close(messages)
+		defer res.Body.Close()
+
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			msg := handlers.LogMessage{}
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				log.Printf("[Logs] unable to parse a line from the provider: %s", err)
+				continue
+			}
+
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
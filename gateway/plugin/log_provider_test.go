@@ -0,0 +1,70 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/handlers"
+)
+
+func Test_ExternalLogProvider(t *testing.T) {
+	var gotQuery url.Values
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/system/logs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		gotQuery = r.URL.Query()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(w, `{"name":"figlet","namespace":"dev","text":"line %d"}`+"\n", i)
+		}
+		fmt.Fprintln(w, "not json")
+	}))
+	defer provider.Close()
+
+	providerURL, _ := url.Parse(provider.URL)
+	client := NewExternalLogProvider(*providerURL, http.DefaultClient, nil)
+
+	messages, err := client.Logs(context.Background(), handlers.LogRequest{Name: "figlet", Namespace: "dev", Tail: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for msg := range messages {
+		texts = append(texts, msg.Text)
+	}
+	if len(texts) != 2 || texts[1] != "line 1" {
+		t.Errorf("want the two JSON lines, got: %q", texts)
+	}
+
+	if gotQuery.Get("name") != "figlet" || gotQuery.Get("namespace") != "dev" || gotQuery.Get("tail") != "2" || gotQuery.Get("follow") != "false" {
+		t.Errorf("want the request passed as the query, got: %s", gotQuery.Encode())
+	}
+}
+
+func Test_ExternalLogProvider_NotImplemented(t *testing.T) {
+	provider := httptest.NewServer(http.NotFoundHandler())
+	defer provider.Close()
+
+	providerURL, _ := url.Parse(provider.URL)
+	client := NewExternalLogProvider(*providerURL, http.DefaultClient, nil)
+
+	_, err := client.Logs(context.Background(), handlers.LogRequest{Name: "figlet"})
+
+	var providerErr *handlers.ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want a %d from a provider without logs, got: %v", http.StatusNotImplemented, err)
+	}
+}
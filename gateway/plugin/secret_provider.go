@@ -0,0 +1,75 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	types "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/handlers"
+	middleware "github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// ExternalSecretProvider manages secrets through the faas-provider's
+// /system/secrets API
+type ExternalSecretProvider struct {
+	URL          url.URL
+	Client       *http.Client
+	AuthInjector middleware.AuthInjector
+}
+
+// NewExternalSecretProvider manages secrets on the provider at externalURL,
+// making its requests with client
+func NewExternalSecretProvider(externalURL url.URL, client *http.Client, authInjector middleware.AuthInjector) handlers.SecretProvider {
+	return &ExternalSecretProvider{
+		URL:          externalURL,
+		Client:       client,
+		AuthInjector: authInjector,
+	}
+}
+
+// List returns the secrets in namespace, or the provider's default
+// namespace when it is empty
+func (p *ExternalSecretProvider) List(ctx context.Context, namespace string) ([]types.Secret, error) {
+	path := "system/secrets"
+	if len(namespace) > 0 {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+
+	body, err := callProvider(ctx, p.Client, p.AuthInjector, p.URL, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := []types.Secret{}
+	if err := json.Unmarshal(body, &secrets); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// Create adds a new secret
+func (p *ExternalSecretProvider) Create(ctx context.Context, secret types.Secret) error {
+	_, err := callProvider(ctx, p.Client, p.AuthInjector, p.URL, http.MethodPost, "system/secrets", secret)
+	return err
+}
+
+// Update replaces the value of an existing secret
+func (p *ExternalSecretProvider) Update(ctx context.Context, secret types.Secret) error {
+	_, err := callProvider(ctx, p.Client, p.AuthInjector, p.URL, http.MethodPut, "system/secrets", secret)
+	return err
+}
+
+// Delete removes a secret
+func (p *ExternalSecretProvider) Delete(ctx context.Context, name, namespace string) error {
+	_, err := callProvider(ctx, p.Client, p.AuthInjector, p.URL, http.MethodDelete, "system/secrets", types.Secret{
+		Name:      name,
+		Namespace: namespace,
+	})
+	return err
+}
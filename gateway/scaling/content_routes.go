@@ -0,0 +1,84 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// ContentRoute sends calls with a Content-Type matching MediaType to
+// Function. A MediaType of "type/*" matches any subtype.
+type ContentRoute struct {
+	MediaType string
+	Function  string
+}
+
+// ParseContentRoutes parses the value of ContentRoutesLabel. Routes which
+// are invalid, or whose function is not a valid function name in the same
+// namespace, are dropped and returned in the error along with the rest.
+func ParseContentRoutes(value string) ([]ContentRoute, error) {
+	var routes []ContentRoute
+	var errs []error
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		mediaType, function, ok := strings.Cut(pair, "=")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		function = strings.TrimSpace(function)
+		if !ok || len(function) == 0 || !validMediaRange(mediaType) {
+			errs = append(errs, fmt.Errorf("invalid content route: %q, want media-type=function", pair))
+			continue
+		}
+
+		// the function is called in the namespace of the one with the label
+		if err := middleware.ValidateFunctionName(function); err != nil || strings.Contains(function, ".") {
+			errs = append(errs, fmt.Errorf("invalid content route: %q, the function must be a name in the same namespace", pair))
+			continue
+		}
+
+		routes = append(routes, ContentRoute{MediaType: mediaType, Function: function})
+	}
+	return routes, errors.Join(errs...)
+}
+
+// MatchContentRoute returns the first route matching contentType, ignoring
+// its parameters such as charset, and false when none match or it is empty.
+func MatchContentRoute(routes []ContentRoute, contentType string) (ContentRoute, bool) {
+	if len(contentType) == 0 {
+		return ContentRoute{}, false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ContentRoute{}, false
+	}
+
+	for _, route := range routes {
+		if prefix, ok := strings.CutSuffix(route.MediaType, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return route, true
+			}
+			continue
+		}
+
+		if mediaType == route.MediaType {
+			return route, true
+		}
+	}
+	return ContentRoute{}, false
+}
+
+// validMediaRange accepts "type/subtype" and "type/*"
+func validMediaRange(mediaType string) bool {
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	return ok && len(typ) > 0 && typ != "*" && len(subtype) > 0 && !strings.ContainsAny(subtype, "/ ")
+}
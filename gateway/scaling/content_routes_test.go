@@ -0,0 +1,78 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseContentRoutes(t *testing.T) {
+	routes, err := ParseContentRoutes("Application/JSON=figlet-json, multipart/*=figlet-upload,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ContentRoute{
+		{MediaType: "application/json", Function: "figlet-json"},
+		{MediaType: "multipart/*", Function: "figlet-upload"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("want routes: %v, got: %v", want, routes)
+	}
+}
+
+func Test_ParseContentRoutes_Invalid(t *testing.T) {
+	for _, value := range []string{
+		"application/json",
+		"application/json=",
+		"json=figlet-json",
+		"*/*=figlet-any",
+		"application/json=../system/functions",
+		"application/json=figlet.other-ns",
+		"application/json=Figlet",
+	} {
+		if _, err := ParseContentRoutes(value); err == nil {
+			t.Errorf("want an error for: %q", value)
+		}
+	}
+}
+
+func Test_ParseContentRoutes_DropsInvalidRoutes(t *testing.T) {
+	routes, err := ParseContentRoutes("application/json=../system/secrets,text/*=figlet-text")
+	if err == nil {
+		t.Errorf("want an error for the invalid route")
+	}
+
+	want := []ContentRoute{{MediaType: "text/*", Function: "figlet-text"}}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("want only the valid routes: %v, got: %v", want, routes)
+	}
+}
+
+func Test_MatchContentRoute(t *testing.T) {
+	routes := []ContentRoute{
+		{MediaType: "application/json", Function: "figlet-json"},
+		{MediaType: "application/*", Function: "figlet-app"},
+	}
+
+	cases := []struct {
+		contentType string
+		want        string
+	}{
+		{contentType: "application/json", want: "figlet-json"},
+		{contentType: "APPLICATION/JSON; charset=utf-8", want: "figlet-json"},
+		{contentType: "application/xml", want: "figlet-app"},
+		{contentType: "text/plain", want: ""},
+		{contentType: "", want: ""},
+		{contentType: "not a media type", want: ""},
+	}
+
+	for _, tc := range cases {
+		route, ok := MatchContentRoute(routes, tc.contentType)
+		if ok != (len(tc.want) > 0) || route.Function != tc.want {
+			t.Errorf("%q: want function: %q, got: %q", tc.contentType, tc.want, route.Function)
+		}
+	}
+}
@@ -32,6 +32,10 @@ type FunctionScaleResult struct {
 	Error     error
 	Found     bool
 	Duration  time.Duration
+
+	// ColdStart is set when the request had to wait for a replica
+	// to become available
+	ColdStart bool
 }
 
 // Scale scales a function from zero replicas to 1 or the value set in
@@ -150,17 +154,14 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 
 	}
 
-	// Holding pattern for at least one function replica to be available
+	// Holding pattern for at least one function replica to be available,
+	// polling less often the longer the function takes to become ready
+	interval := f.Config.FunctionPollInterval
 	for i := 0; i < int(f.Config.MaxPollCount); i++ {
 
 		res, err, _ := f.SingleFlight.Do(getKey, func() (interface{}, error) {
 			return f.Config.ServiceQuery.GetReplicas(functionName, namespace)
 		})
-		queryResponse := res.(ServiceQueryResponse)
-
-		if err == nil {
-			f.Cache.Set(functionName, namespace, queryResponse)
-		}
 
 		totalTime := time.Since(start)
 
@@ -173,6 +174,9 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 			}
 		}
 
+		queryResponse := res.(ServiceQueryResponse)
+		f.Cache.Set(functionName, namespace, queryResponse)
+
 		if queryResponse.AvailableReplicas > 0 {
 
 			log.Printf("[Ready] function=%s waited for - %.4fs", functionName, totalTime.Seconds())
@@ -182,15 +186,21 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 				Available: true,
 				Found:     true,
 				Duration:  totalTime,
+				ColdStart: true,
 			}
 		}
 
-		time.Sleep(f.Config.FunctionPollInterval)
+		if f.Config.ScaleTimeout > 0 && totalTime+interval > f.Config.ScaleTimeout {
+			break
+		}
+
+		time.Sleep(interval)
+		interval = f.Config.nextPollInterval(interval)
 	}
 
 	return FunctionScaleResult{
 		Error:     nil,
-		Available: true,
+		Available: false,
 		Found:     true,
 		Duration:  time.Since(start),
 	}
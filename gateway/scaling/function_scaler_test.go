@@ -0,0 +1,126 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockServiceQuery reports a function with no replicas until SetReplicas is
+// called, then reports a ready replica after readyAfter polls
+type mockServiceQuery struct {
+	lock sync.Mutex
+
+	replicas   uint64
+	available  uint64
+	readyAfter int
+	polls      int
+	scaledTo   uint64
+}
+
+func (q *mockServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.replicas > 0 && q.available == 0 {
+		q.polls++
+		if q.readyAfter >= 0 && q.polls > q.readyAfter {
+			q.available = q.replicas
+		}
+	}
+
+	return ServiceQueryResponse{
+		Replicas:          q.replicas,
+		AvailableReplicas: q.available,
+		MinReplicas:       1,
+		MaxReplicas:       DefaultMaxReplicas,
+	}, nil
+}
+
+func (q *mockServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.replicas = count
+	q.scaledTo = count
+	return nil
+}
+
+func newTestScaler(query ServiceQuery, timeout time.Duration) FunctionScaler {
+	config := ScalingConfig{
+		MaxPollCount:         1000,
+		FunctionPollInterval: time.Millisecond,
+		MaxPollInterval:      time.Millisecond * 8,
+		ScaleTimeout:         timeout,
+		SetScaleRetries:      3,
+		ServiceQuery:         query,
+	}
+	return NewFunctionScaler(config, NewFunctionCache(time.Millisecond))
+}
+
+func Test_Scale_ImmediatelyReady(t *testing.T) {
+	query := &mockServiceQuery{replicas: 1, available: 1}
+	scaler := newTestScaler(query, time.Second)
+
+	res := scaler.Scale("figlet", "openfaas-fn")
+
+	if !res.Available || !res.Found || res.Error != nil {
+		t.Fatalf("want the function available, got: %+v", res)
+	}
+	if res.ColdStart {
+		t.Errorf("want no cold start for a function with a ready replica")
+	}
+	if query.scaledTo != 0 {
+		t.Errorf("want no scale up, got: %d", query.scaledTo)
+	}
+}
+
+func Test_Scale_ReadyAfterScaleUp(t *testing.T) {
+	query := &mockServiceQuery{readyAfter: 5}
+	scaler := newTestScaler(query, time.Second*5)
+
+	res := scaler.Scale("figlet", "openfaas-fn")
+
+	if !res.Available || !res.ColdStart || res.Error != nil {
+		t.Fatalf("want the function available after a cold start, got: %+v", res)
+	}
+	if query.scaledTo != 1 {
+		t.Errorf("want a scale up to 1 replica, got: %d", query.scaledTo)
+	}
+}
+
+func Test_Scale_TimesOut(t *testing.T) {
+	query := &mockServiceQuery{readyAfter: -1}
+	timeout := time.Millisecond * 50
+	scaler := newTestScaler(query, timeout)
+
+	res := scaler.Scale("figlet", "openfaas-fn")
+
+	if res.Available || !res.Found || res.Error != nil {
+		t.Fatalf("want the function found but unavailable, got: %+v", res)
+	}
+	if res.Duration > timeout*2 {
+		t.Errorf("want Scale to give up after about %s, took: %s", timeout, res.Duration)
+	}
+}
+
+func Test_nextPollInterval(t *testing.T) {
+	config := ScalingConfig{MaxPollInterval: time.Millisecond * 300}
+
+	interval := time.Millisecond * 100
+	want := []time.Duration{time.Millisecond * 200, time.Millisecond * 300, time.Millisecond * 300}
+	for _, w := range want {
+		interval = config.nextPollInterval(interval)
+		if interval != w {
+			t.Errorf("want: %s, got: %s", w, interval)
+		}
+	}
+
+	fixed := ScalingConfig{}
+	if got := fixed.nextPollInterval(time.Millisecond * 100); got != time.Millisecond*100 {
+		t.Errorf("want a fixed interval without MaxPollInterval, got: %s", got)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 
 	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 )
 
 const (
@@ -29,29 +31,68 @@ const (
 
 	// ScalingFactorLabel label indicates the scaling factor for a function
 	ScalingFactorLabel = "com.openfaas.scale.factor"
+
+	// MaxInflightLabel label limits the concurrent requests the gateway will
+	// proxy to a function, leave unset or 0 for no limit
+	MaxInflightLabel = "com.faas.max_inflight"
+
+	// MaxBodyBytesLabel label overrides the gateway's FAAS_MAX_BODY_BYTES
+	// for a function
+	MaxBodyBytesLabel = "com.faas.max_body_bytes"
+
+	// ExecTimeoutLabel label overrides the gateway's FAAS_EXEC_TIMEOUT for
+	// a function, as seconds or a duration i.e. "30s"
+	ExecTimeoutLabel = "com.faas.exec_timeout"
+
+	// CoalesceLabel label set to "true" lets concurrent identical GETs to a
+	// function share one upstream call and its response
+	CoalesceLabel = "com.faas.coalesce"
+
+	// CacheTTLLabel label caches a function's GET responses for the given
+	// seconds or duration i.e. "5m", when the response cache is enabled
+	CacheTTLLabel = "com.faas.cache_ttl"
+
+	// CanaryLabel label names the function, in the same namespace, which
+	// serves the canary version of a function
+	CanaryLabel = "com.faas.canary"
+
+	// CanaryWeightLabel label is the percentage of calls, from 0 to 100,
+	// sent to the function named by CanaryLabel
+	CanaryWeightLabel = "com.faas.canary_weight"
+
+	// ContentRoutesLabel label sends calls to a function on to another
+	// function in the same namespace by their Content-Type, as
+	// comma-separated media-type=function pairs i.e.
+	// "application/json=figlet-json,multipart/*=figlet-upload"
+	ContentRoutesLabel = "com.faas.content_routes"
+
+	// WarmIntervalLabel label pings a function's health endpoint every
+	// given seconds or duration i.e. "5m", while it has replicas, to keep
+	// its connections and replicas warm
+	WarmIntervalLabel = "com.faas.warm_interval"
 )
 
 func MakeHorizontalScalingHandler(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+			httperror.Write(w, r, http.StatusMethodNotAllowed, "Only POST is allowed")
 			return
 		}
 
 		if r.Body == nil {
-			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			httperror.Write(w, r, http.StatusBadRequest, "Error reading request body")
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
+		body, err := tracing.ReadRequestBody(r)
 		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			httperror.Write(w, r, http.StatusBadRequest, "Error reading request body")
 			return
 		}
 
 		scaleRequest := types.ScaleServiceRequest{}
 		if err := json.Unmarshal(body, &scaleRequest); err != nil {
-			http.Error(w, "Error unmarshalling request body", http.StatusBadRequest)
+			httperror.Write(w, r, http.StatusBadRequest, "Error unmarshalling request body")
 			return
 		}
 
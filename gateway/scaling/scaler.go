@@ -0,0 +1,23 @@
+package scaling
+
+// Scaler reads and sets the replicas of a function, ServiceQuery satisfies
+// it with the bounds set by the function's labels at deploy time
+type Scaler interface {
+	GetReplicas(service, namespace string) (response ServiceQueryResponse, err error)
+	SetReplicas(service, namespace string, count uint64) error
+}
+
+// ReplicaBounds returns the min and max replicas of a function, from its
+// com.openfaas.scale.min and com.openfaas.scale.max labels. A max below
+// the min is raised to the min, and a max of 0 means the default.
+func ReplicaBounds(res ServiceQueryResponse) (min, max uint64) {
+	min, max = res.MinReplicas, res.MaxReplicas
+
+	if max == 0 {
+		max = DefaultMaxReplicas
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
@@ -13,6 +13,15 @@ type ScalingConfig struct {
 	// readiness status
 	FunctionPollInterval time.Duration
 
+	// MaxPollInterval caps the delay between polls, which doubles after
+	// each poll from FunctionPollInterval. Leave as 0 to poll at a fixed
+	// interval.
+	MaxPollInterval time.Duration
+
+	// ScaleTimeout is the longest a request waits for a function to become
+	// ready after scaling from zero, 0 means only MaxPollCount applies
+	ScaleTimeout time.Duration
+
 	// CacheExpiry life-time for a cache entry before considering invalid
 	CacheExpiry time.Duration
 
@@ -23,3 +32,16 @@ type ScalingConfig struct {
 	// giving up due to errors
 	SetScaleRetries uint
 }
+
+// nextPollInterval doubles interval, up to MaxPollInterval
+func (c ScalingConfig) nextPollInterval(interval time.Duration) time.Duration {
+	if c.MaxPollInterval <= interval {
+		return interval
+	}
+
+	interval *= 2
+	if interval > c.MaxPollInterval {
+		return c.MaxPollInterval
+	}
+	return interval
+}
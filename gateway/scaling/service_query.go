@@ -3,6 +3,8 @@
 
 package scaling
 
+import "time"
+
 // ServiceQuery provides interface for replica querying/setting
 type ServiceQuery interface {
 	GetReplicas(service, namespace string) (response ServiceQueryResponse, err error)
@@ -16,5 +18,14 @@ type ServiceQueryResponse struct {
 	MinReplicas       uint64
 	ScalingFactor     uint64
 	AvailableReplicas uint64
+	MaxInflight       uint64
+	MaxBodyBytes      uint64
+	ExecTimeout       time.Duration
+	Coalesce          bool
+	CacheTTL          time.Duration
+	Canary            string
+	CanaryWeight      uint64
+	ContentRoutes     []ContentRoute
 	Annotations       *map[string]string
+	Image             string
 }
@@ -31,6 +31,9 @@ type HandlerSet struct {
 	// ScaleFunction enables a function to be scaled
 	ScaleFunction http.HandlerFunc
 
+	// SetReplicas scales a function within its min and max replicas
+	SetReplicas http.HandlerFunc
+
 	// InfoHandler provides version and build info
 	InfoHandler http.HandlerFunc
 
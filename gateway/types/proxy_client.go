@@ -4,25 +4,88 @@
 package types
 
 import (
+	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 // NewHTTPClientReverseProxy proxies to an upstream host through the use of a http.Client
 func NewHTTPClientReverseProxy(baseURL *url.URL, timeout time.Duration, maxIdleConns, maxIdleConnsPerHost int) *HTTPClientReverseProxy {
+	transport := DefaultTransportConfig(timeout)
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	return NewHTTPClientReverseProxyWithTransport(baseURL, timeout, transport)
+}
+
+// NewHTTPClientReverseProxyWithTransport proxies to an upstream host with
+// a transport tuned by transportConfig. The proxy has a http.Client of its
+// own, so that its transport can be wrapped, i.e. with retries and spans,
+// without changing http.DefaultClient for the rest of the process.
+func NewHTTPClientReverseProxyWithTransport(baseURL *url.URL, timeout time.Duration, transportConfig TransportConfig) *HTTPClientReverseProxy {
 	h := HTTPClientReverseProxy{
 		BaseURL: baseURL,
 		Timeout: timeout,
 	}
 
-	h.Client = http.DefaultClient
-	h.Timeout = timeout
-	h.Client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
+	h.Client = &http.Client{
+		Transport: NewTransport(transportConfig),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
+	h.GRPCTransport = newGRPCTransport(transportConfig)
+
+	return &h
+}
+
+// TransportConfig tunes the connections made to functions
+type TransportConfig struct {
+	// MaxIdleConns kept open across every function
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost kept open to each function, or to the provider
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout closes connections which have been idle for longer
+	IdleConnTimeout time.Duration
+
+	// DialTimeout is the longest a new connection can take, it is also the
+	// keep-alive period of each connection
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout is the longest a TLS handshake can take
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the longest to wait for a function's response
+	// headers once the request has been sent, 0 leaves it to the upstream
+	// timeout
+	ResponseHeaderTimeout time.Duration
+
+	// TLSClientConfig is used for functions called over https, such as the
+	// mutual TLS config of an mtls.Reloader, nil for Go's defaults
+	TLSClientConfig *tls.Config
+}
 
+// DefaultTransportConfig keeps many idle connections open, for a gateway
+// in front of many functions, and dials within the upstream timeout
+func DefaultTransportConfig(timeout time.Duration) TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        1024,
+		MaxIdleConnsPerHost: 1024,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         timeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// NewTransport creates the transport for calls to functions
+func NewTransport(config TransportConfig) *http.Transport {
 	// These overrides for the default client enable re-use of connections and prevent
 	// CoreDNS from rate limiting the gateway under high traffic
 	//
@@ -31,21 +94,60 @@ func NewHTTPClientReverseProxy(baseURL *url.URL, timeout time.Duration, maxIdleC
 	// https://github.com/minio/minio/pull/5860
 
 	// Taken from http.DefaultTransport in Go 1.11
-	h.Client.Transport = &http.Transport{
+	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   timeout,
-			KeepAlive: timeout,
+			Timeout:   config.DialTimeout,
+			KeepAlive: config.DialTimeout,
 			DualStack: true,
 		}).DialContext,
-		MaxIdleConns:          maxIdleConns,
-		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       config.TLSClientConfig,
 	}
+}
 
-	return &h
+// newGRPCTransport speaks HTTP/2 to the function's address, without TLS as
+// gRPC functions usually do, or over TLS with config's TLSClientConfig for
+// functions called over https, the same as the transport for other calls
+func newGRPCTransport(config TransportConfig) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.DialTimeout,
+	}
+
+	return &grpcTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		tls: &http2.Transport{
+			TLSClientConfig: config.TLSClientConfig,
+			DialTLSContext: func(ctx context.Context, network, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+				tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+				return tlsDialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+// grpcTransport picks the HTTP/2 transport for the scheme of each call
+type grpcTransport struct {
+	h2c *http2.Transport
+	tls *http2.Transport
+}
+
+func (t *grpcTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme == "https" {
+		return t.tls.RoundTrip(r)
+	}
+	return t.h2c.RoundTrip(r)
 }
 
 // HTTPClientReverseProxy proxy to a remote BaseURL using a http.Client
@@ -53,4 +155,8 @@ type HTTPClientReverseProxy struct {
 	BaseURL *url.URL
 	Client  *http.Client
 	Timeout time.Duration
+
+	// GRPCTransport carries gRPC calls to functions over HTTP/2, when it is
+	// nil gRPC calls are proxied with Client like any other request
+	GRPCTransport http.RoundTripper
 }
@@ -0,0 +1,160 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+)
+
+func Test_NewHTTPClientReverseProxyWithTransport_AppliesConfig(t *testing.T) {
+	baseURL, _ := url.Parse("http://gateway-provider:8080")
+
+	want := TransportConfig{
+		MaxIdleConns:          500,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       time.Second * 30,
+		DialTimeout:           time.Second * 2,
+		TLSHandshakeTimeout:   time.Second * 3,
+		ResponseHeaderTimeout: time.Second * 4,
+	}
+
+	proxy := NewHTTPClientReverseProxyWithTransport(baseURL, time.Minute, want)
+
+	transport, ok := proxy.Client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("want an *http.Transport, got: %T", proxy.Client.Transport)
+	}
+
+	got := TransportConfig{
+		MaxIdleConns:          transport.MaxIdleConns,
+		MaxIdleConnsPerHost:   transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:       transport.IdleConnTimeout,
+		DialTimeout:           want.DialTimeout,
+		TLSHandshakeTimeout:   transport.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: transport.ResponseHeaderTimeout,
+	}
+	if got != want {
+		t.Errorf("want the transport configured with:\n%+v\ngot:\n%+v", want, got)
+	}
+
+	if proxy.Timeout != time.Minute {
+		t.Errorf("want the upstream timeout: %s, got: %s", time.Minute, proxy.Timeout)
+	}
+}
+
+func Test_NewHTTPClientReverseProxy_Defaults(t *testing.T) {
+	baseURL, _ := url.Parse("http://gateway-provider:8080")
+
+	proxy := NewHTTPClientReverseProxy(baseURL, time.Minute, 100, 10)
+	transport := proxy.Client.Transport.(*http.Transport)
+
+	if transport.MaxIdleConns != 100 || transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("want the idle connection limits: 100 and 10, got: %d and %d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != time.Second*90 || transport.TLSHandshakeTimeout != time.Second*10 {
+		t.Errorf("want the default timeouts, got idle: %s, TLS handshake: %s", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+	if transport.ResponseHeaderTimeout != 0 {
+		t.Errorf("want no response header timeout by default, got: %s", transport.ResponseHeaderTimeout)
+	}
+}
+
+func Test_NewHTTPClientReverseProxy_LeavesDefaultClient(t *testing.T) {
+	baseURL, _ := url.Parse("http://127.0.0.1:8081")
+	transport, checkRedirect := http.DefaultClient.Transport, http.DefaultClient.CheckRedirect
+
+	proxy := NewHTTPClientReverseProxy(baseURL, time.Minute, 100, 10)
+
+	if proxy.Client == http.DefaultClient {
+		t.Fatalf("want a client of the proxy's own")
+	}
+	if http.DefaultClient.Transport != transport || (http.DefaultClient.CheckRedirect == nil) != (checkRedirect == nil) {
+		t.Errorf("want http.DefaultClient left as it was")
+	}
+	if proxy.Client.CheckRedirect == nil {
+		t.Errorf("want redirects passed back to the caller")
+	}
+}
+
+func Test_NewHTTPClientReverseProxyWithTransport_TracedTransport(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer function.Close()
+
+	baseURL, _ := url.Parse(function.URL)
+	config := DefaultTransportConfig(time.Second)
+	config.ResponseHeaderTimeout = time.Second
+
+	proxy := NewHTTPClientReverseProxyWithTransport(baseURL, time.Second, config)
+	client := &http.Client{Transport: tracing.Transport(proxy.Client.Transport)}
+
+	res, err := client.Get(function.URL + "/function/figlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if spans := recorder.Ended(); len(spans) != 1 {
+		t.Errorf("want 1 client span from the tuned transport, got: %d", len(spans))
+	}
+}
+
+func Test_NewHTTPClientReverseProxyWithTransport_GRPCOverTLS(t *testing.T) {
+	function := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("want HTTP/2, got: %s", r.Proto)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+	}))
+	function.EnableHTTP2 = true
+	function.StartTLS()
+	defer function.Close()
+
+	baseURL, _ := url.Parse(function.URL)
+	config := DefaultTransportConfig(time.Second)
+	config.TLSClientConfig = function.Client().Transport.(*http.Transport).TLSClientConfig
+
+	proxy := NewHTTPClientReverseProxyWithTransport(baseURL, time.Second, config)
+
+	req, _ := http.NewRequest(http.MethodPost, function.URL+"/echo.Echo/Chat", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	res, err := proxy.GRPCTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("want the call made with the transport's TLS config, got: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+}
+
+func Test_NewHTTPClientReverseProxyWithTransport_GRPCOverTLSVerifies(t *testing.T) {
+	function := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	function.EnableHTTP2 = true
+	function.StartTLS()
+	defer function.Close()
+
+	baseURL, _ := url.Parse(function.URL)
+	proxy := NewHTTPClientReverseProxyWithTransport(baseURL, time.Second, DefaultTransportConfig(time.Second))
+
+	req, _ := http.NewRequest(http.MethodPost, function.URL+"/echo.Echo/Chat", nil)
+	if _, err := proxy.GRPCTransport.RoundTrip(req); err == nil {
+		t.Fatalf("want the function's certificate verified without a TLS config which trusts it")
+	}
+}
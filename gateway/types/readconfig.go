@@ -5,10 +5,16 @@ package types
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
 )
 
 // OsEnv implements interface to wrap os.Getenv
@@ -51,18 +57,69 @@ func parseIntOrDurationValue(val string, fallback time.Duration) time.Duration {
 	return duration
 }
 
+// parseTrustedProxies reads a comma-separated list of networks in CIDR
+// notation, or single addresses
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("not an IP address or network: %s", entry)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 // Read fetches gateway server configuration from environmental variables
 func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 	cfg := GatewayConfig{
+		ListenAddress:  DefaultListenAddress,
 		PrometheusHost: "prometheus",
 		PrometheusPort: 9090,
 	}
 
+	if address := hasEnv.Getenv("FAAS_LISTEN_ADDRESS"); len(address) > 0 {
+		cfg.ListenAddress = address
+	}
+	cfg.AdminListenAddress = hasEnv.Getenv("FAAS_ADMIN_LISTEN_ADDRESS")
+
+	basePath, err := middleware.NormalizeBasePath(hasEnv.Getenv("FAAS_BASE_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for FAAS_BASE_PATH: %s", err)
+	}
+	cfg.BasePath = basePath
+
+	if header := strings.TrimSpace(hasEnv.Getenv("FAAS_FUNCTION_HEADER")); len(header) > 0 {
+		cfg.FunctionHeader = http.CanonicalHeaderKey(header)
+	}
+
 	defaultDuration := time.Second * 60
 
 	cfg.ReadTimeout = parseIntOrDurationValue(hasEnv.Getenv("read_timeout"), defaultDuration)
 	cfg.WriteTimeout = parseIntOrDurationValue(hasEnv.Getenv("write_timeout"), defaultDuration)
 	cfg.UpstreamTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_timeout"), defaultDuration)
+	cfg.DrainTimeout = parseIntOrDurationValue(hasEnv.Getenv("drain_timeout"), time.Second*30)
 
 	if len(hasEnv.Getenv("functions_provider_url")) > 0 {
 		var err error
@@ -113,6 +170,12 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 		cfg.NATSChannel = &v
 	}
 
+	cfg.NATSMonitorURL = hasEnv.Getenv("faas_nats_monitor_url")
+	cfg.NATSQueueGroup = "faas"
+	if queueGroup := hasEnv.Getenv("faas_nats_queue_group"); len(queueGroup) > 0 {
+		cfg.NATSQueueGroup = queueGroup
+	}
+
 	prometheusPort := hasEnv.Getenv("faas_prometheus_port")
 	if len(prometheusPort) > 0 {
 		prometheusPortVal, err := strconv.Atoi(prometheusPort)
@@ -128,6 +191,20 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 		cfg.PrometheusHost = prometheusHost
 	}
 
+	cfg.DirectFunctions = parseBoolValue(hasEnv.Getenv("direct_functions"))
+	cfg.DirectFunctionsSuffix = hasEnv.Getenv("direct_functions_suffix")
+
+	cfg.LoadBalancerStrategy = hasEnv.Getenv("load_balancer_strategy")
+	switch cfg.LoadBalancerStrategy {
+	case "":
+		cfg.LoadBalancerStrategy = "round_robin"
+	case "round_robin", "random":
+	default:
+		return nil, fmt.Errorf("invalid value for load_balancer_strategy: %s, use round_robin or random", cfg.LoadBalancerStrategy)
+	}
+	cfg.LoadBalancerHealthInterval = parseIntOrDurationValue(hasEnv.Getenv("load_balancer_health_interval"), time.Second*10)
+	cfg.WarmerRefreshInterval = parseIntOrDurationValue(hasEnv.Getenv("FAAS_WARMER_REFRESH_INTERVAL"), 0)
+
 	cfg.UseBasicAuth = parseBoolValue(hasEnv.Getenv("basic_auth"))
 
 	secretPath := hasEnv.Getenv("secret_mount_path")
@@ -136,6 +213,47 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 	}
 	cfg.SecretMountPath = secretPath
 	cfg.ScaleFromZero = parseBoolValue(hasEnv.Getenv("scale_from_zero"))
+	cfg.ScaleFromZeroTimeout = parseIntOrDurationValue(hasEnv.Getenv("scale_from_zero_timeout"), time.Minute*2)
+
+	if coldStartBuckets := hasEnv.Getenv("cold_start_buckets"); len(coldStartBuckets) > 0 {
+		for _, bucket := range strings.Split(coldStartBuckets, ",") {
+			val, err := strconv.ParseFloat(strings.TrimSpace(bucket), 64)
+			if err != nil || val <= 0 || (len(cfg.ColdStartBuckets) > 0 && val <= cfg.ColdStartBuckets[len(cfg.ColdStartBuckets)-1]) {
+				return nil, fmt.Errorf("invalid value for cold_start_buckets: %s, use increasing seconds i.e. 0.1,1,10", coldStartBuckets)
+			}
+			cfg.ColdStartBuckets = append(cfg.ColdStartBuckets, val)
+		}
+	}
+
+	if allowList := hasEnv.Getenv("FAAS_METRICS_FUNCTION_ALLOWLIST"); len(allowList) > 0 {
+		for _, name := range strings.Split(allowList, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				cfg.MetricsFunctionAllowList = append(cfg.MetricsFunctionAllowList, name)
+			}
+		}
+	}
+
+	if pattern := hasEnv.Getenv("FAAS_METRICS_FUNCTION_PATTERN"); len(pattern) > 0 {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for FAAS_METRICS_FUNCTION_PATTERN: %s", err)
+		}
+		cfg.MetricsFunctionPattern = re
+	}
+
+	cfg.WebhookSecretPath = hasEnv.Getenv("webhook_secret_path")
+
+	cfg.AuthProtectedPaths = []string{"/system", "/ui"}
+	if protectedPaths := hasEnv.Getenv("auth_protected_paths"); len(protectedPaths) > 0 {
+		cfg.AuthProtectedPaths = []string{}
+		for _, path := range strings.Split(protectedPaths, ",") {
+			if path = strings.TrimSpace(path); len(path) > 0 {
+				cfg.AuthProtectedPaths = append(cfg.AuthProtectedPaths, path)
+			}
+		}
+	}
+
+	cfg.EnablePprof = parseBoolValue(hasEnv.Getenv("FAAS_ENABLE_PPROF"))
 
 	cfg.MaxIdleConns = 1024
 	cfg.MaxIdleConnsPerHost = 1024
@@ -160,17 +278,201 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 
 	}
 
+	transportDefaults := DefaultTransportConfig(cfg.UpstreamTimeout)
+	cfg.UpstreamIdleConnTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_idle_conn_timeout"), transportDefaults.IdleConnTimeout)
+	cfg.UpstreamDialTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_dial_timeout"), transportDefaults.DialTimeout)
+	cfg.UpstreamTLSHandshakeTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_tls_handshake_timeout"), transportDefaults.TLSHandshakeTimeout)
+	cfg.UpstreamResponseHeaderTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_response_header_timeout"), transportDefaults.ResponseHeaderTimeout)
+
+	cfg.UpstreamTLSCAFile = hasEnv.Getenv("upstream_tls_ca_file")
+	cfg.UpstreamTLSCertFile = hasEnv.Getenv("upstream_tls_cert_file")
+	cfg.UpstreamTLSKeyFile = hasEnv.Getenv("upstream_tls_key_file")
+	cfg.UpstreamTLSServerName = hasEnv.Getenv("upstream_tls_server_name")
+	cfg.UpstreamTLSReloadInterval = parseIntOrDurationValue(hasEnv.Getenv("upstream_tls_reload_interval"), time.Second*30)
+
+	maxBodyBytes := hasEnv.Getenv("FAAS_MAX_BODY_BYTES")
+	if len(maxBodyBytes) > 0 {
+		val, err := strconv.ParseInt(maxBodyBytes, 10, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for FAAS_MAX_BODY_BYTES: %s", maxBodyBytes)
+		}
+		cfg.MaxBodyBytes = val
+	}
+	cfg.LimitResponseBody = parseBoolValue(hasEnv.Getenv("FAAS_LIMIT_RESPONSE_BODY"))
+
+	cfg.ExecTimeout = parseIntOrDurationValue(hasEnv.Getenv("FAAS_EXEC_TIMEOUT"), 0)
+
+	if rateLimit := hasEnv.Getenv("rate_limit_rps"); len(rateLimit) > 0 {
+		val, err := strconv.ParseFloat(rateLimit, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for rate_limit_rps: %s", rateLimit)
+		}
+		cfg.RateLimitRPS = val
+	}
+
+	cfg.RateLimitBurst = 1
+	if rateLimitBurst := hasEnv.Getenv("rate_limit_burst"); len(rateLimitBurst) > 0 {
+		val, err := strconv.Atoi(rateLimitBurst)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("invalid value for rate_limit_burst: %s", rateLimitBurst)
+		}
+		cfg.RateLimitBurst = val
+	}
+
+	cfg.RateLimitKey = hasEnv.Getenv("rate_limit_key")
+	switch cfg.RateLimitKey {
+	case "":
+		cfg.RateLimitKey = "ip"
+	case "ip", "api_key":
+	default:
+		return nil, fmt.Errorf("invalid value for rate_limit_key: %s, use ip or api_key", cfg.RateLimitKey)
+	}
+
+	cfg.RateLimitMaxKeys = 10000
+	if maxKeys := hasEnv.Getenv("rate_limit_max_keys"); len(maxKeys) > 0 {
+		val, err := strconv.Atoi(maxKeys)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("invalid value for rate_limit_max_keys: %s", maxKeys)
+		}
+		cfg.RateLimitMaxKeys = val
+	}
+
+	cfg.CompressResponses = parseBoolValue(hasEnv.Getenv("compress_responses"))
+	cfg.CompressMinBytes = 1024
+	if compressMinBytes := hasEnv.Getenv("compress_min_bytes"); len(compressMinBytes) > 0 {
+		val, err := strconv.Atoi(compressMinBytes)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for compress_min_bytes: %s", compressMinBytes)
+		}
+		cfg.CompressMinBytes = val
+	}
+
+	if cacheSize := hasEnv.Getenv("response_cache_size"); len(cacheSize) > 0 {
+		val, err := strconv.Atoi(cacheSize)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for response_cache_size: %s", cacheSize)
+		}
+		cfg.ResponseCacheSize = val
+	}
+
+	cfg.ResponseCacheMaxBytes = 64 * 1024 * 1024
+	if maxBytes := hasEnv.Getenv("response_cache_max_bytes"); len(maxBytes) > 0 {
+		val, err := strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("invalid value for response_cache_max_bytes: %s", maxBytes)
+		}
+		cfg.ResponseCacheMaxBytes = val
+	}
+
+	cfg.ResponseCacheMaxBodyBytes = 1024 * 1024
+	if maxBodyBytes := hasEnv.Getenv("response_cache_max_body_bytes"); len(maxBodyBytes) > 0 {
+		val, err := strconv.ParseInt(maxBodyBytes, 10, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("invalid value for response_cache_max_body_bytes: %s", maxBodyBytes)
+		}
+		cfg.ResponseCacheMaxBodyBytes = val
+	}
+
+	cfg.StickySessions = parseBoolValue(hasEnv.Getenv("sticky_sessions"))
+
+	cfg.CanarySessionHeader = "X-Session-Id"
+	if header := hasEnv.Getenv("canary_session_header"); len(header) > 0 {
+		cfg.CanarySessionHeader = header
+	}
+
+	if trustedProxies := hasEnv.Getenv("trusted_proxies"); len(trustedProxies) > 0 {
+		networks, err := parseTrustedProxies(trustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for trusted_proxies: %s", err)
+		}
+		cfg.TrustedProxies = networks
+	}
+
+	cfg.FilterErrorStatus = http.StatusInternalServerError
+	if status := hasEnv.Getenv("filter_error_status"); len(status) > 0 {
+		val, err := strconv.Atoi(status)
+		if err != nil || val < 400 || val > 599 {
+			return nil, fmt.Errorf("invalid value for filter_error_status: %s, use a 4xx or 5xx status", status)
+		}
+		cfg.FilterErrorStatus = val
+	}
+
+	cfg.RetryAttempts = 1
+	if retryAttempts := hasEnv.Getenv("upstream_retry_attempts"); len(retryAttempts) > 0 {
+		val, err := strconv.Atoi(retryAttempts)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for upstream_retry_attempts: %s", retryAttempts)
+		}
+		cfg.RetryAttempts = val
+	}
+	cfg.RetryBackoff = parseIntOrDurationValue(hasEnv.Getenv("upstream_retry_backoff"), time.Millisecond*100)
+
+	if ratio := hasEnv.Getenv("circuit_breaker_failure_ratio"); len(ratio) > 0 {
+		val, err := strconv.ParseFloat(ratio, 64)
+		if err != nil || val < 0 || val > 1 {
+			return nil, fmt.Errorf("invalid value for circuit_breaker_failure_ratio: %s, use a value between 0 and 1", ratio)
+		}
+		cfg.CircuitBreakerFailureRatio = val
+	}
+
+	cfg.CircuitBreakerMinRequests = 10
+	if minRequests := hasEnv.Getenv("circuit_breaker_min_requests"); len(minRequests) > 0 {
+		val, err := strconv.Atoi(minRequests)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("invalid value for circuit_breaker_min_requests: %s", minRequests)
+		}
+		cfg.CircuitBreakerMinRequests = val
+	}
+
+	cfg.CircuitBreakerWindow = parseIntOrDurationValue(hasEnv.Getenv("circuit_breaker_window"), time.Second*10)
+	cfg.CircuitBreakerCooldown = parseIntOrDurationValue(hasEnv.Getenv("circuit_breaker_cooldown"), time.Second*30)
+
+	cfg.AccessLogFormat = hasEnv.Getenv("access_log_format")
+	switch cfg.AccessLogFormat {
+	case "", "text", "json":
+	default:
+		return nil, fmt.Errorf("invalid value for access_log_format: %s, use text or json", cfg.AccessLogFormat)
+	}
+	cfg.AccessLogPath = hasEnv.Getenv("access_log_path")
+
 	cfg.AuthProxyURL = hasEnv.Getenv("auth_proxy_url")
 	cfg.AuthProxyPassBody = parseBoolValue(hasEnv.Getenv("auth_proxy_pass_body"))
 
 	cfg.Namespace = hasEnv.Getenv("function_namespace")
+	if namespace := hasEnv.Getenv("FAAS_DEFAULT_NAMESPACE"); len(namespace) > 0 {
+		cfg.Namespace = namespace
+	}
+	if err := middleware.ValidateNamespace(cfg.Namespace); err != nil {
+		return nil, fmt.Errorf("invalid default namespace for functions: %w", err)
+	}
 
 	return &cfg, nil
 }
 
+// DefaultListenAddress is where the gateway serves its API and functions
+const DefaultListenAddress = ":8080"
+
 // GatewayConfig provides config for the API Gateway server process
 type GatewayConfig struct {
 
+	// ListenAddress is the host and port the gateway listens on, read from
+	// FAAS_LISTEN_ADDRESS with a default of :8080
+	ListenAddress string
+
+	// AdminListenAddress serves the admin API and UI on a separate host and
+	// port from function invocations, read from FAAS_ADMIN_LISTEN_ADDRESS.
+	// When empty, both are served on ListenAddress.
+	AdminListenAddress string
+
+	// BasePath mounts every route under a sub-path, i.e. /openfaas, read
+	// from FAAS_BASE_PATH. When empty, routes are served from the root.
+	BasePath string
+
+	// FunctionHeader names the header, i.e. X-Function, which selects the
+	// function to invoke with the path as its sub-path, read from
+	// FAAS_FUNCTION_HEADER. When empty, functions are only selected by path.
+	FunctionHeader string
+
 	// HTTP timeout for reading a request from clients.
 	ReadTimeout time.Duration
 
@@ -180,6 +482,10 @@ type GatewayConfig struct {
 	// UpstreamTimeout maximum duration of HTTP call to upstream URL
 	UpstreamTimeout time.Duration
 
+	// DrainTimeout is how long in-flight requests are given to complete
+	// after a SIGTERM, before the gateway exits
+	DrainTimeout time.Duration
+
 	// URL for alternate functions provider.
 	FunctionsProviderURL *url.URL
 
@@ -198,35 +504,233 @@ type GatewayConfig struct {
 	// NATSChannel is the name of the NATS Streaming channel used for asynchronous function invocations.
 	NATSChannel *string
 
+	// NATSMonitorURL is the NATS Streaming monitoring endpoint, i.e.
+	// http://nats:8222, which the depth of the async queue is read from
+	NATSMonitorURL string
+
+	// NATSQueueGroup is the queue group of the queue-workers, whose lag is
+	// the depth of the async queue
+	NATSQueueGroup string
+
 	// Host to connect to Prometheus.
 	PrometheusHost string
 
 	// Port to connect to Prometheus.
 	PrometheusPort int
 
+	// DirectFunctions calls functions by their service DNS name, instead of
+	// through the functions provider
+	DirectFunctions bool
+
+	// DirectFunctionsSuffix follows the namespace in the DNS name of a
+	// function, i.e. svc.cluster.local
+	DirectFunctionsSuffix string
+
+	// LoadBalancerStrategy picks between the endpoints of a function called
+	// directly, round_robin or random
+	LoadBalancerStrategy string
+
+	// LoadBalancerHealthInterval is how often the endpoints of functions
+	// called directly are health checked
+	LoadBalancerHealthInterval time.Duration
+
+	// WarmerRefreshInterval is how often the functions to keep warm are
+	// listed again, the warmer is off when it is 0
+	WarmerRefreshInterval time.Duration
+
 	// If set, reads secrets from file-system for enabling basic auth.
 	UseBasicAuth bool
 
 	// SecretMountPath specifies where to read secrets from for embedded basic auth
 	SecretMountPath string
 
+	// WebhookSecretPath is a directory of HMAC secrets named after each
+	// function, whose requests must then carry a valid X-Hub-Signature-256
+	WebhookSecretPath string
+
+	// AuthProtectedPaths are the path prefixes which require basic auth when
+	// it is enabled, defaults to /system and /ui
+	AuthProtectedPaths []string
+
+	// EnablePprof serves the net/http/pprof profiles under /debug/pprof/,
+	// which always need basic auth, so they are only served when it is on
+	EnablePprof bool
+
 	// Enable the gateway to scale any service from 0 replicas to its configured "min replicas"
 	ScaleFromZero bool
 
+	// ScaleFromZeroTimeout is the longest a request is held while its
+	// function scales from 0 replicas, before a 503 is returned
+	ScaleFromZeroTimeout time.Duration
+
+	// ColdStartBuckets are the upper bounds in seconds of the cold start
+	// histogram, when empty the metrics package defaults are used
+	ColdStartBuckets []float64
+
+	// MetricsFunctionAllowList and MetricsFunctionPattern limit the
+	// functions labelled by name in metrics and spans, the rest share the
+	// "__other__" label. When both are empty every function is labelled.
+	MetricsFunctionAllowList []string
+	MetricsFunctionPattern   *regexp.Regexp
+
 	// MaxIdleConns with a default value of 1024, can be used for tuning HTTP proxy performance
 	MaxIdleConns int
 
 	// MaxIdleConnsPerHost with a default value of 1024, can be used for tuning HTTP proxy performance
 	MaxIdleConnsPerHost int
 
+	// UpstreamIdleConnTimeout closes idle connections to functions, with a
+	// default of 90s
+	UpstreamIdleConnTimeout time.Duration
+
+	// UpstreamDialTimeout is the longest a connection to a function can take
+	// to open, with a default of the UpstreamTimeout
+	UpstreamDialTimeout time.Duration
+
+	// UpstreamTLSHandshakeTimeout with a default of 10s
+	UpstreamTLSHandshakeTimeout time.Duration
+
+	// UpstreamResponseHeaderTimeout is the longest to wait for a function's
+	// response headers, 0 leaves it to the UpstreamTimeout
+	UpstreamResponseHeaderTimeout time.Duration
+
+	// UpstreamTLSCAFile is a PEM bundle used to verify the certificates of
+	// functions called over https, instead of the system's roots
+	UpstreamTLSCAFile string
+
+	// UpstreamTLSCertFile and UpstreamTLSKeyFile are the client certificate
+	// sent to functions which require mutual TLS
+	UpstreamTLSCertFile string
+	UpstreamTLSKeyFile  string
+
+	// UpstreamTLSServerName overrides the name sent with SNI and verified
+	// against functions' certificates
+	UpstreamTLSServerName string
+
+	// UpstreamTLSReloadInterval is how often the TLS files are checked for
+	// changes, with a default of 30s
+	UpstreamTLSReloadInterval time.Duration
+
+	// MaxBodyBytes limits the size of request bodies sent to functions, 0
+	// for no limit. Functions can override it with com.faas.max_body_bytes
+	MaxBodyBytes int64
+
+	// LimitResponseBody applies the same limit to function responses with
+	// a known Content-Length
+	LimitResponseBody bool
+
+	// ExecTimeout is the longest a function may take to respond before the
+	// gateway cancels the call with a 504, 0 for no limit. Functions can
+	// override it with com.faas.exec_timeout
+	ExecTimeout time.Duration
+
+	// RateLimitRPS is the requests per second allowed for each caller of a
+	// function, 0 disables the rate limiter
+	RateLimitRPS float64
+
+	// RateLimitBurst is the number of requests a caller can make at once
+	RateLimitBurst int
+
+	// RateLimitKey identifies callers by ip or api_key
+	RateLimitKey string
+
+	// RateLimitMaxKeys bounds the number of callers tracked
+	RateLimitMaxKeys int
+
+	// CompressResponses compresses function responses with gzip or deflate
+	// for clients which accept them
+	CompressResponses bool
+
+	// CompressMinBytes is the smallest response which is compressed, with a
+	// default of 1024
+	CompressMinBytes int
+
+	// ResponseCacheSize is the most function responses kept in memory by
+	// the response cache, 0 disables the cache
+	ResponseCacheSize int
+
+	// ResponseCacheMaxBytes is the most memory, in bytes, held by the
+	// responses in the cache, with a default of 64MiB
+	ResponseCacheMaxBytes int64
+
+	// ResponseCacheMaxBodyBytes is the largest response body which is
+	// cached, larger bodies are passed through without being held in
+	// memory, with a default of 1MiB
+	ResponseCacheMaxBodyBytes int64
+
+	// StickySessions sends each client of a function to the same endpoint,
+	// with an affinity cookie
+	StickySessions bool
+
+	// CanarySessionHeader keeps a caller on the same version of a function
+	// with a canary, calls without it are split at random
+	CanarySessionHeader string
+
+	// TrustedProxies are the networks of proxies in front of the gateway,
+	// whose X-Forwarded-For header is passed on to functions
+	TrustedProxies []*net.IPNet
+
+	// FilterErrorStatus is sent to the caller when a registered filter
+	// returns an error, with a default of 500
+	FilterErrorStatus int
+
+	// RetryAttempts is how many times an idempotent request to a function is
+	// tried, with a default of 1 which disables retries
+	RetryAttempts int
+
+	// RetryBackoff is the delay before the first retry, which doubles for
+	// each attempt after
+	RetryBackoff time.Duration
+
+	// CircuitBreakerFailureRatio of failed requests to a function within
+	// CircuitBreakerWindow which opens its circuit, 0 disables the breaker
+	CircuitBreakerFailureRatio float64
+
+	// CircuitBreakerMinRequests within the window before a circuit can open
+	CircuitBreakerMinRequests int
+
+	// CircuitBreakerWindow is the period over which failures are counted
+	CircuitBreakerWindow time.Duration
+
+	// CircuitBreakerCooldown is how long a circuit stays open before a probe
+	// request is let through
+	CircuitBreakerCooldown time.Duration
+
+	// AccessLogFormat enables an access log for function invocations when set
+	// to text or json
+	AccessLogFormat string
+
+	// AccessLogPath is a file to append the access log to, when blank the
+	// log is written to stdout
+	AccessLogPath string
+
 	// AuthProxyURL specifies URL for an authenticating proxy, disabled when blank, enabled when valid URL i.e. http://basic-auth.openfaas:8080/validate
 	AuthProxyURL string
 
 	// AuthProxyPassBody pass body to validation proxy
 	AuthProxyPassBody bool
 
-	// Namespace for endpoints
+	// Namespace is the default for functions named without one, when
+	// calling them and in the deploy API. It is read from
+	// FAAS_DEFAULT_NAMESPACE, or else function_namespace, and when empty the
+	// provider's default is used.
 	Namespace string
+
+	// TracesExporter selects the span exporters instead of
+	// OTEL_TRACES_EXPORTER, which the tracing package reads when it is blank.
+	// It is only set with the -traces-exporter flag.
+	TracesExporter string
+}
+
+// SetDefaultNamespace replaces the Namespace read from the environment,
+// i.e. with a command-line flag, as long as it is valid
+func (g *GatewayConfig) SetDefaultNamespace(namespace string) error {
+	if err := middleware.ValidateNamespace(namespace); err != nil {
+		return fmt.Errorf("invalid default namespace for functions: %w", err)
+	}
+
+	g.Namespace = namespace
+	return nil
 }
 
 // UseNATS Use NATSor not
@@ -239,3 +743,15 @@ func (g *GatewayConfig) UseNATS() bool {
 func (g *GatewayConfig) UseExternalProvider() bool {
 	return g.FunctionsProviderURL != nil
 }
+
+// TransportConfig tunes the proxy's connections to functions
+func (g *GatewayConfig) TransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:          g.MaxIdleConns,
+		MaxIdleConnsPerHost:   g.MaxIdleConnsPerHost,
+		IdleConnTimeout:       g.UpstreamIdleConnTimeout,
+		DialTimeout:           g.UpstreamDialTimeout,
+		TLSHandshakeTimeout:   g.UpstreamTLSHandshakeTimeout,
+		ResponseHeaderTimeout: g.UpstreamResponseHeaderTimeout,
+	}
+}
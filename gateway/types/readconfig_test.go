@@ -5,6 +5,8 @@ package types
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -102,6 +104,38 @@ func TestRead_ScaleZeroDefaultAndOverride(t *testing.T) {
 
 }
 
+func TestRead_ScaleFromZeroTimeout(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if want := time.Minute * 2; config.ScaleFromZeroTimeout != want {
+		t.Errorf("want default ScaleFromZeroTimeout: %s, got: %s", want, config.ScaleFromZeroTimeout)
+	}
+
+	defaults.Setenv("scale_from_zero_timeout", "30s")
+	config, _ = readConfig.Read(defaults)
+	if want := time.Second * 30; config.ScaleFromZeroTimeout != want {
+		t.Errorf("want ScaleFromZeroTimeout: %s, got: %s", want, config.ScaleFromZeroTimeout)
+	}
+}
+
+func TestRead_DrainTimeout(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if want := time.Second * 30; config.DrainTimeout != want {
+		t.Errorf("want default DrainTimeout: %s, got: %s", want, config.DrainTimeout)
+	}
+
+	defaults.Setenv("drain_timeout", "10")
+	config, _ = readConfig.Read(defaults)
+	if want := time.Second * 10; config.DrainTimeout != want {
+		t.Errorf("want DrainTimeout: %s, got: %s", want, config.DrainTimeout)
+	}
+}
+
 func TestRead_EmptyTimeoutConfig(t *testing.T) {
 	defaults := NewEnvBucket()
 	readConfig := ReadConfig{}
@@ -223,6 +257,18 @@ func TestRead_UseNATS(t *testing.T) {
 		t.Logf("faas_nats_channel: want %s, got %s", wantNATSChannel, *config.NATSChannel)
 		t.Fail()
 	}
+
+	if len(config.NATSMonitorURL) > 0 || config.NATSQueueGroup != "faas" {
+		t.Errorf("want no monitor URL and queue group: faas by default, got: %q %q", config.NATSMonitorURL, config.NATSQueueGroup)
+	}
+
+	defaults.Setenv("faas_nats_monitor_url", "http://nats:8222")
+	defaults.Setenv("faas_nats_queue_group", "workers")
+	config, _ = readConfig.Read(defaults)
+
+	if config.NATSMonitorURL != "http://nats:8222" || config.NATSQueueGroup != "workers" {
+		t.Errorf("want monitor URL and queue group set, got: %q %q", config.NATSMonitorURL, config.NATSQueueGroup)
+	}
 }
 
 func TestRead_UseNATSBadPort(t *testing.T) {
@@ -323,6 +369,141 @@ func TestRead_BasicAuth_SetTrue(t *testing.T) {
 	}
 }
 
+func TestRead_DirectFunctions(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.DirectFunctions {
+		t.Fatalf("config.DirectFunctions, want: false, got: true")
+	}
+
+	defaults.Setenv("direct_functions", "true")
+	defaults.Setenv("direct_functions_suffix", "svc.cluster.local")
+	config, _ = readConfig.Read(defaults)
+
+	if !config.DirectFunctions {
+		t.Errorf("config.DirectFunctions, want: true, got: false")
+	}
+	if config.DirectFunctionsSuffix != "svc.cluster.local" {
+		t.Errorf("config.DirectFunctionsSuffix, want: svc.cluster.local, got: %s", config.DirectFunctionsSuffix)
+	}
+}
+
+func TestRead_LoadBalancer(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.LoadBalancerStrategy != "round_robin" {
+		t.Errorf("config.LoadBalancerStrategy, want: round_robin, got: %s", config.LoadBalancerStrategy)
+	}
+	if config.LoadBalancerHealthInterval != time.Second*10 {
+		t.Errorf("config.LoadBalancerHealthInterval, want: %s, got: %s", time.Second*10, config.LoadBalancerHealthInterval)
+	}
+
+	defaults.Setenv("load_balancer_strategy", "random")
+	config, _ = readConfig.Read(defaults)
+	if config.LoadBalancerStrategy != "random" {
+		t.Errorf("config.LoadBalancerStrategy, want: random, got: %s", config.LoadBalancerStrategy)
+	}
+
+	defaults.Setenv("load_balancer_strategy", "least_connections")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an unknown load_balancer_strategy")
+	}
+}
+
+func TestRead_WarmerRefreshInterval(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.WarmerRefreshInterval != 0 {
+		t.Errorf("config.WarmerRefreshInterval, want the warmer off by default, got: %s", config.WarmerRefreshInterval)
+	}
+
+	defaults.Setenv("FAAS_WARMER_REFRESH_INTERVAL", "30s")
+	config, _ = readConfig.Read(defaults)
+	if config.WarmerRefreshInterval != time.Second*30 {
+		t.Errorf("config.WarmerRefreshInterval, want: %s, got: %s", time.Second*30, config.WarmerRefreshInterval)
+	}
+}
+
+func TestRead_AuthProtectedPaths(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+
+	want := "/system,/ui"
+	if got := strings.Join(config.AuthProtectedPaths, ","); got != want {
+		t.Fatalf("config.AuthProtectedPaths, want: %s, got: %s\n", want, got)
+	}
+
+	defaults.Setenv("auth_protected_paths", "/system, /function/admin-tools,")
+	config, _ = readConfig.Read(defaults)
+
+	want = "/system,/function/admin-tools"
+	if got := strings.Join(config.AuthProtectedPaths, ","); got != want {
+		t.Fatalf("config.AuthProtectedPaths, want: %s, got: %s\n", want, got)
+	}
+}
+
+func TestRead_MetricsFunctionLabels(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.MetricsFunctionAllowList) != 0 || config.MetricsFunctionPattern != nil {
+		t.Fatalf("want every function labelled by default, got: %v and %v", config.MetricsFunctionAllowList, config.MetricsFunctionPattern)
+	}
+
+	defaults.Setenv("FAAS_METRICS_FUNCTION_ALLOWLIST", "echo, figlet.openfaas-fn,")
+	defaults.Setenv("FAAS_METRICS_FUNCTION_PATTERN", "^team-a-")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(config.MetricsFunctionAllowList); got != "[echo figlet.openfaas-fn]" {
+		t.Errorf("config.MetricsFunctionAllowList, want: [echo figlet.openfaas-fn], got: %s", got)
+	}
+	if config.MetricsFunctionPattern == nil || config.MetricsFunctionPattern.String() != "^team-a-" {
+		t.Errorf("config.MetricsFunctionPattern, want: ^team-a-, got: %v", config.MetricsFunctionPattern)
+	}
+
+	defaults.Setenv("FAAS_METRICS_FUNCTION_PATTERN", "team-(")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid FAAS_METRICS_FUNCTION_PATTERN")
+	}
+}
+
+func TestRead_ColdStartBuckets(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.ColdStartBuckets) != 0 {
+		t.Fatalf("config.ColdStartBuckets, want none by default, got: %v", config.ColdStartBuckets)
+	}
+
+	defaults.Setenv("cold_start_buckets", "0.1, 1,10")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(config.ColdStartBuckets); got != "[0.1 1 10]" {
+		t.Errorf("config.ColdStartBuckets, want: [0.1 1 10], got: %s", got)
+	}
+
+	for _, invalid := range []string{"1,ten", "10,1", "0,1"} {
+		defaults.Setenv("cold_start_buckets", invalid)
+		if _, err := readConfig.Read(defaults); err == nil {
+			t.Errorf("want an error for cold_start_buckets: %s", invalid)
+		}
+	}
+}
+
 func TestRead_MaxIdleConnsDefaults(t *testing.T) {
 	defaults := NewEnvBucket()
 
@@ -361,6 +542,404 @@ func TestRead_MaxIdleConns_Override(t *testing.T) {
 	}
 }
 
+func TestRead_MaxBodyBytes(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.MaxBodyBytes != 0 || config.LimitResponseBody {
+		t.Fatalf("want no body limit by default, got: %d, response: %v", config.MaxBodyBytes, config.LimitResponseBody)
+	}
+
+	defaults.Setenv("FAAS_MAX_BODY_BYTES", "1048576")
+	defaults.Setenv("FAAS_LIMIT_RESPONSE_BODY", "true")
+
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxBodyBytes != 1048576 {
+		t.Errorf("config.MaxBodyBytes, want: %d, got: %d", 1048576, config.MaxBodyBytes)
+	}
+	if !config.LimitResponseBody {
+		t.Errorf("config.LimitResponseBody, want: true, got: false")
+	}
+
+	defaults.Setenv("FAAS_MAX_BODY_BYTES", "1MB")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid FAAS_MAX_BODY_BYTES")
+	}
+}
+
+func TestRead_ExecTimeout(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.ExecTimeout != 0 {
+		t.Fatalf("config.ExecTimeout, want no timeout by default, got: %s", config.ExecTimeout)
+	}
+
+	defaults.Setenv("FAAS_EXEC_TIMEOUT", "90s")
+	config, _ = readConfig.Read(defaults)
+	if config.ExecTimeout != time.Second*90 {
+		t.Errorf("config.ExecTimeout, want: %s, got: %s", time.Second*90, config.ExecTimeout)
+	}
+}
+
+func TestRead_RateLimit(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.RateLimitRPS != 0 || config.RateLimitBurst != 1 || config.RateLimitKey != "ip" || config.RateLimitMaxKeys != 10000 {
+		t.Fatalf("want the rate limiter disabled by default, got: %v, %d, %s, %d",
+			config.RateLimitRPS, config.RateLimitBurst, config.RateLimitKey, config.RateLimitMaxKeys)
+	}
+
+	defaults.Setenv("rate_limit_rps", "2.5")
+	defaults.Setenv("rate_limit_burst", "10")
+	defaults.Setenv("rate_limit_key", "api_key")
+	defaults.Setenv("rate_limit_max_keys", "500")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RateLimitRPS != 2.5 || config.RateLimitBurst != 10 || config.RateLimitKey != "api_key" || config.RateLimitMaxKeys != 500 {
+		t.Errorf("want the rate limiter settings from the environment, got: %v, %d, %s, %d",
+			config.RateLimitRPS, config.RateLimitBurst, config.RateLimitKey, config.RateLimitMaxKeys)
+	}
+
+	defaults.Setenv("rate_limit_key", "user")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an unknown rate_limit_key")
+	}
+}
+
+func TestRead_Compression(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.CompressResponses || config.CompressMinBytes != 1024 {
+		t.Fatalf("want compression disabled with a 1024 byte threshold by default, got: %v, %d", config.CompressResponses, config.CompressMinBytes)
+	}
+
+	defaults.Setenv("compress_responses", "true")
+	defaults.Setenv("compress_min_bytes", "256")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.CompressResponses || config.CompressMinBytes != 256 {
+		t.Errorf("want compression enabled with a 256 byte threshold, got: %v, %d", config.CompressResponses, config.CompressMinBytes)
+	}
+
+	defaults.Setenv("compress_min_bytes", "1KB")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid compress_min_bytes")
+	}
+}
+
+func TestRead_ResponseCache(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.ResponseCacheSize != 0 {
+		t.Errorf("want the response cache disabled by default, got: %d", config.ResponseCacheSize)
+	}
+
+	defaults.Setenv("response_cache_size", "500")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ResponseCacheSize != 500 {
+		t.Errorf("config.ResponseCacheSize, want: %d, got: %d", 500, config.ResponseCacheSize)
+	}
+
+	defaults.Setenv("response_cache_size", "-1")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid response_cache_size")
+	}
+}
+
+func TestRead_ResponseCacheLimits(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.ResponseCacheMaxBytes != 64*1024*1024 || config.ResponseCacheMaxBodyBytes != 1024*1024 {
+		t.Errorf("want defaults of 64MiB and 1MiB, got: %d and %d", config.ResponseCacheMaxBytes, config.ResponseCacheMaxBodyBytes)
+	}
+
+	defaults.Setenv("response_cache_max_bytes", "1048576")
+	defaults.Setenv("response_cache_max_body_bytes", "4096")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ResponseCacheMaxBytes != 1048576 || config.ResponseCacheMaxBodyBytes != 4096 {
+		t.Errorf("want 1048576 and 4096, got: %d and %d", config.ResponseCacheMaxBytes, config.ResponseCacheMaxBodyBytes)
+	}
+
+	for _, name := range []string{"response_cache_max_bytes", "response_cache_max_body_bytes"} {
+		invalid := NewEnvBucket()
+		invalid.Setenv(name, "0")
+		if _, err := readConfig.Read(invalid); err == nil {
+			t.Errorf("want an error for %s of 0", name)
+		}
+	}
+}
+
+func TestRead_UpstreamTransport(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	defaults.Setenv("upstream_timeout", "20s")
+	config, _ := readConfig.Read(defaults)
+
+	want := TransportConfig{
+		MaxIdleConns:        1024,
+		MaxIdleConnsPerHost: 1024,
+		IdleConnTimeout:     time.Second * 90,
+		DialTimeout:         time.Second * 20,
+		TLSHandshakeTimeout: time.Second * 10,
+	}
+	if got := config.TransportConfig(); got != want {
+		t.Errorf("want the default transport:\n%+v\ngot:\n%+v", want, got)
+	}
+
+	defaults.Setenv("upstream_idle_conn_timeout", "30s")
+	defaults.Setenv("upstream_dial_timeout", "2")
+	defaults.Setenv("upstream_tls_handshake_timeout", "3s")
+	defaults.Setenv("upstream_response_header_timeout", "4s")
+	config, _ = readConfig.Read(defaults)
+
+	want.IdleConnTimeout = time.Second * 30
+	want.DialTimeout = time.Second * 2
+	want.TLSHandshakeTimeout = time.Second * 3
+	want.ResponseHeaderTimeout = time.Second * 4
+	if got := config.TransportConfig(); got != want {
+		t.Errorf("want the transport:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestRead_UpstreamTLS(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.UpstreamTLSCAFile) > 0 || len(config.UpstreamTLSCertFile) > 0 || len(config.UpstreamTLSKeyFile) > 0 {
+		t.Errorf("want no upstream TLS files by default")
+	}
+	if want := time.Second * 30; config.UpstreamTLSReloadInterval != want {
+		t.Errorf("want reload interval: %s, got: %s", want, config.UpstreamTLSReloadInterval)
+	}
+
+	defaults.Setenv("upstream_tls_ca_file", "/var/secrets/upstream/ca.crt")
+	defaults.Setenv("upstream_tls_cert_file", "/var/secrets/upstream/tls.crt")
+	defaults.Setenv("upstream_tls_key_file", "/var/secrets/upstream/tls.key")
+	defaults.Setenv("upstream_tls_server_name", "functions.openfaas-fn")
+	defaults.Setenv("upstream_tls_reload_interval", "5s")
+	config, _ = readConfig.Read(defaults)
+
+	if config.UpstreamTLSCAFile != "/var/secrets/upstream/ca.crt" {
+		t.Errorf("want CA file, got: %q", config.UpstreamTLSCAFile)
+	}
+	if config.UpstreamTLSCertFile != "/var/secrets/upstream/tls.crt" || config.UpstreamTLSKeyFile != "/var/secrets/upstream/tls.key" {
+		t.Errorf("want cert and key files, got: %q %q", config.UpstreamTLSCertFile, config.UpstreamTLSKeyFile)
+	}
+	if config.UpstreamTLSServerName != "functions.openfaas-fn" {
+		t.Errorf("want server name, got: %q", config.UpstreamTLSServerName)
+	}
+	if want := time.Second * 5; config.UpstreamTLSReloadInterval != want {
+		t.Errorf("want reload interval: %s, got: %s", want, config.UpstreamTLSReloadInterval)
+	}
+}
+
+func TestRead_TrustedProxies(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.TrustedProxies) > 0 {
+		t.Errorf("want no trusted proxies by default, got: %v", config.TrustedProxies)
+	}
+
+	defaults.Setenv("trusted_proxies", "10.0.0.0/8, 192.168.1.10,fd00::/8")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"10.0.0.0/8", "192.168.1.10/32", "fd00::/8"}
+	if len(config.TrustedProxies) != len(want) {
+		t.Fatalf("want trusted proxies: %v, got: %v", want, config.TrustedProxies)
+	}
+	for i, network := range config.TrustedProxies {
+		if network.String() != want[i] {
+			t.Errorf("want trusted proxy: %s, got: %s", want[i], network.String())
+		}
+	}
+
+	defaults.Setenv("trusted_proxies", "10.0.0.0/8,load-balancer")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for a trusted proxy which is not an address")
+	}
+}
+
+func TestRead_FilterErrorStatus(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.FilterErrorStatus != http.StatusInternalServerError {
+		t.Errorf("want filter error status: %d, got: %d", http.StatusInternalServerError, config.FilterErrorStatus)
+	}
+
+	defaults.Setenv("filter_error_status", "403")
+	config, _ = readConfig.Read(defaults)
+	if config.FilterErrorStatus != http.StatusForbidden {
+		t.Errorf("want filter error status: %d, got: %d", http.StatusForbidden, config.FilterErrorStatus)
+	}
+
+	for _, invalid := range []string{"200", "forbidden"} {
+		defaults.Setenv("filter_error_status", invalid)
+		if _, err := readConfig.Read(defaults); err == nil {
+			t.Errorf("want an error for filter_error_status: %s", invalid)
+		}
+	}
+}
+
+func TestRead_StickySessions(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.StickySessions {
+		t.Errorf("want sticky sessions disabled by default")
+	}
+
+	defaults.Setenv("sticky_sessions", "true")
+	config, _ = readConfig.Read(defaults)
+	if !config.StickySessions {
+		t.Errorf("want sticky sessions enabled")
+	}
+}
+
+func TestRead_CanarySessionHeader(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.CanarySessionHeader != "X-Session-Id" {
+		t.Errorf("config.CanarySessionHeader, want: %s, got: %s", "X-Session-Id", config.CanarySessionHeader)
+	}
+
+	defaults.Setenv("canary_session_header", "X-User")
+	config, _ = readConfig.Read(defaults)
+	if config.CanarySessionHeader != "X-User" {
+		t.Errorf("config.CanarySessionHeader, want: %s, got: %s", "X-User", config.CanarySessionHeader)
+	}
+}
+
+func TestRead_Retry(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.RetryAttempts != 1 {
+		t.Errorf("config.RetryAttempts, want: %d, got: %d", 1, config.RetryAttempts)
+	}
+	if config.RetryBackoff != time.Millisecond*100 {
+		t.Errorf("config.RetryBackoff, want: %s, got: %s", time.Millisecond*100, config.RetryBackoff)
+	}
+
+	defaults.Setenv("upstream_retry_attempts", "5")
+	defaults.Setenv("upstream_retry_backoff", "250ms")
+
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RetryAttempts != 5 {
+		t.Errorf("config.RetryAttempts, want: %d, got: %d", 5, config.RetryAttempts)
+	}
+	if config.RetryBackoff != time.Millisecond*250 {
+		t.Errorf("config.RetryBackoff, want: %s, got: %s", time.Millisecond*250, config.RetryBackoff)
+	}
+
+	defaults.Setenv("upstream_retry_attempts", "three")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid upstream_retry_attempts")
+	}
+}
+
+func TestRead_CircuitBreaker(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.CircuitBreakerFailureRatio != 0 {
+		t.Errorf("want the circuit breaker disabled by default, got ratio: %f", config.CircuitBreakerFailureRatio)
+	}
+	if config.CircuitBreakerMinRequests != 10 || config.CircuitBreakerWindow != time.Second*10 || config.CircuitBreakerCooldown != time.Second*30 {
+		t.Errorf("want defaults of 10 requests, 10s window and 30s cooldown, got: %d, %s, %s",
+			config.CircuitBreakerMinRequests, config.CircuitBreakerWindow, config.CircuitBreakerCooldown)
+	}
+
+	defaults.Setenv("circuit_breaker_failure_ratio", "0.5")
+	defaults.Setenv("circuit_breaker_min_requests", "20")
+	defaults.Setenv("circuit_breaker_window", "1m")
+	defaults.Setenv("circuit_breaker_cooldown", "5s")
+
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CircuitBreakerFailureRatio != 0.5 || config.CircuitBreakerMinRequests != 20 ||
+		config.CircuitBreakerWindow != time.Minute || config.CircuitBreakerCooldown != time.Second*5 {
+		t.Errorf("want overrides of 0.5, 20, 1m and 5s, got: %f, %d, %s, %s", config.CircuitBreakerFailureRatio,
+			config.CircuitBreakerMinRequests, config.CircuitBreakerWindow, config.CircuitBreakerCooldown)
+	}
+
+	defaults.Setenv("circuit_breaker_failure_ratio", "1.5")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for a circuit_breaker_failure_ratio over 1")
+	}
+}
+
+func TestRead_AccessLog(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.AccessLogFormat != "" || config.AccessLogPath != "" {
+		t.Errorf("want the access log disabled by default, got format: %q, path: %q", config.AccessLogFormat, config.AccessLogPath)
+	}
+
+	defaults.Setenv("access_log_format", "json")
+	defaults.Setenv("access_log_path", "/var/log/gateway/access.log")
+
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessLogFormat != "json" {
+		t.Errorf("config.AccessLogFormat, want: %s, got: %s", "json", config.AccessLogFormat)
+	}
+	if config.AccessLogPath != "/var/log/gateway/access.log" {
+		t.Errorf("config.AccessLogPath, want: %s, got: %s", "/var/log/gateway/access.log", config.AccessLogPath)
+	}
+
+	defaults.Setenv("access_log_format", "xml")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid access_log_format")
+	}
+}
+
 func TestRead_AuthProxy_Defaults(t *testing.T) {
 	defaults := NewEnvBucket()
 
@@ -436,3 +1015,109 @@ func TestRead_LogsProviderURL(t *testing.T) {
 		}
 	})
 }
+
+func TestRead_ListenAddress(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.ListenAddress != ":8080" {
+		t.Errorf("want default listen address: :8080, got: %q", config.ListenAddress)
+	}
+
+	defaults.Setenv("FAAS_LISTEN_ADDRESS", "127.0.0.1:9000")
+	config, _ = readConfig.Read(defaults)
+	if config.ListenAddress != "127.0.0.1:9000" {
+		t.Errorf("want listen address from FAAS_LISTEN_ADDRESS: 127.0.0.1:9000, got: %q", config.ListenAddress)
+	}
+}
+
+func TestRead_BasePath(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.BasePath != "" {
+		t.Errorf("want routes served from the root by default, got: %q", config.BasePath)
+	}
+
+	defaults.Setenv("FAAS_BASE_PATH", "openfaas/")
+	config, _ = readConfig.Read(defaults)
+	if config.BasePath != "/openfaas" {
+		t.Errorf("want base path: /openfaas, got: %q", config.BasePath)
+	}
+
+	defaults.Setenv("FAAS_BASE_PATH", "/openfaas?x=1")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid FAAS_BASE_PATH")
+	}
+}
+
+func TestRead_FunctionHeader(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.FunctionHeader != "" {
+		t.Errorf("want functions selected only by path by default, got header: %q", config.FunctionHeader)
+	}
+
+	defaults.Setenv("FAAS_FUNCTION_HEADER", " x-function ")
+	config, _ = readConfig.Read(defaults)
+	if config.FunctionHeader != "X-Function" {
+		t.Errorf("want function header: X-Function, got: %q", config.FunctionHeader)
+	}
+}
+
+func TestRead_AdminListenAddress(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.AdminListenAddress != "" {
+		t.Errorf("want the admin API on the listen address by default, got: %q", config.AdminListenAddress)
+	}
+
+	defaults.Setenv("FAAS_ADMIN_LISTEN_ADDRESS", "127.0.0.1:8081")
+	config, _ = readConfig.Read(defaults)
+	if config.AdminListenAddress != "127.0.0.1:8081" {
+		t.Errorf("want admin listen address from FAAS_ADMIN_LISTEN_ADDRESS: 127.0.0.1:8081, got: %q", config.AdminListenAddress)
+	}
+}
+
+func TestRead_DefaultNamespace(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.Namespace != "" {
+		t.Errorf("want the provider's default namespace, got: %q", config.Namespace)
+	}
+
+	defaults.Setenv("function_namespace", "openfaas-fn")
+	config, _ = readConfig.Read(defaults)
+	if config.Namespace != "openfaas-fn" {
+		t.Errorf("want namespace from function_namespace: openfaas-fn, got: %q", config.Namespace)
+	}
+
+	defaults.Setenv("FAAS_DEFAULT_NAMESPACE", "tenant-a")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Namespace != "tenant-a" {
+		t.Errorf("want FAAS_DEFAULT_NAMESPACE to take precedence, got: %q", config.Namespace)
+	}
+
+	if err := config.SetDefaultNamespace("tenant-b"); err != nil || config.Namespace != "tenant-b" {
+		t.Errorf("want namespace from the flag: tenant-b, got: %q %v", config.Namespace, err)
+	}
+	if err := config.SetDefaultNamespace("Tenant_B"); err == nil || config.Namespace != "tenant-b" {
+		t.Errorf("want an invalid flag rejected, got: %q %v", config.Namespace, err)
+	}
+
+	defaults.Setenv("FAAS_DEFAULT_NAMESPACE", "not.a.namespace")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Errorf("want an error for an invalid namespace")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// IdempotencyKeyHeader marks a request as safe to retry whatever its method
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxRetryBackoff caps the exponential backoff between attempts
+const maxRetryBackoff = time.Second * 5
+
+// retryTransport retries idempotent requests which fail to connect, or get a
+// 502 or 503 from a gateway in front of the function, i.e. while a function
+// is restarted during a rolling update. A function's own 502, 503 or 504 is
+// passed on, as the function has already handled the request.
+type retryTransport struct {
+	base     http.RoundTripper
+	attempts int
+	backoff  time.Duration
+}
+
+// NewRetryTransport wraps base so that GET and HEAD requests, or requests
+// with an Idempotency-Key header, are tried up to attempts times. The delay
+// starts at backoff and doubles for each attempt, with jitter so that callers
+// do not retry in step. With attempts of 1 or less base is returned.
+func NewRetryTransport(base http.RoundTripper, attempts int, backoff time.Duration) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if attempts <= 1 {
+		return base
+	}
+
+	return &retryTransport{
+		base:     base,
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.base.RoundTrip(r)
+	}
+
+	// buffer the body so that it can be sent again
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		r = r.Clone(r.Context())
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		r.Body, _ = r.GetBody()
+	}
+
+	var res *http.Response
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		res, err = t.base.RoundTrip(r)
+		retry := shouldRetry(res, err)
+		if !retry || attempt == t.attempts {
+			tracing.SetRetryAttempt(r.Context(), attempt, t.attempts)
+			if !retry && attempt > 1 {
+				tracing.AddRetrySucceededEvent(r.Context(), attempt)
+			}
+			return res, err
+		}
+
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		delay := t.delay(attempt)
+		tracing.AddRetryEvent(r.Context(), attempt, delay, retryReason(res, err))
+
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if r.GetBody != nil {
+			r = r.Clone(r.Context())
+			if r.Body, err = r.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// delay is the exponential backoff for attempt, randomised between half and
+// all of its value
+func (t *retryTransport) delay(attempt int) time.Duration {
+	backoff := t.backoff << (attempt - 1)
+	if backoff > maxRetryBackoff || backoff <= 0 {
+		backoff = maxRetryBackoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func retryable(r *http.Request) bool {
+	return r.Method == http.MethodGet ||
+		r.Method == http.MethodHead ||
+		len(r.Header.Get(IdempotencyKeyHeader)) > 0
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		// only when the request was never sent
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return len(res.Header.Get(httperror.CodeHeader)) > 0
+	}
+	return false
+}
+
+func retryReason(res *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return http.StatusText(res.StatusCode)
+}
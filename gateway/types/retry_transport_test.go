@@ -0,0 +1,254 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/httperror"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/openfaas/faas/gateway/pkg/tracing/tracetest"
+	"go.opentelemetry.io/otel"
+)
+
+// flakyTransport fails the first failures requests with a connection error,
+// recording the body sent with each attempt
+type flakyTransport struct {
+	failures int
+	bodies   []string
+}
+
+func (f *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := ""
+	if r.Body != nil {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+
+	if len(f.bodies) <= f.failures {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("ok")),
+		Request:    r,
+	}, nil
+}
+
+func Test_RetryTransport_SucceedsOnRetry(t *testing.T) {
+	base := &flakyTransport{failures: 2}
+	client := &http.Client{Transport: NewRetryTransport(base, 3, time.Millisecond)}
+
+	res, err := client.Get("http://figlet.openfaas-fn:8080/")
+	if err != nil {
+		t.Fatalf("want success on the third attempt, got: %s", err)
+	}
+	res.Body.Close()
+
+	if len(base.bodies) != 3 {
+		t.Errorf("want 3 attempts, got: %d", len(base.bodies))
+	}
+}
+
+func Test_RetryTransport_ExhaustsRetries(t *testing.T) {
+	base := &flakyTransport{failures: 10}
+	client := &http.Client{Transport: NewRetryTransport(base, 3, time.Millisecond)}
+
+	if _, err := client.Get("http://figlet.openfaas-fn:8080/"); err == nil {
+		t.Fatal("want an error once retries are exhausted")
+	}
+
+	if len(base.bodies) != 3 {
+		t.Errorf("want 3 attempts, got: %d", len(base.bodies))
+	}
+}
+
+func Test_RetryTransport_RetriesGatewayUnavailableStatus(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			httperror.Write(w, r, http.StatusServiceUnavailable, "no endpoints available")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewRetryTransport(nil, 3, time.Millisecond)}
+	res, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("want status 200 after 2 calls, got: %d after %d", res.StatusCode, calls)
+	}
+}
+
+func Test_RetryTransport_PassesOnFunctionStatus(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		calls := 0
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(status)
+		}))
+
+		client := &http.Client{Transport: NewRetryTransport(nil, 3, time.Millisecond)}
+		res, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		upstream.Close()
+
+		if res.StatusCode != status || calls != 1 {
+			t.Errorf("want the function's %d from 1 call, got: %d after %d", status, res.StatusCode, calls)
+		}
+	}
+}
+
+func Test_RetryTransport_DoesNotRetryAfterSending(t *testing.T) {
+	base := &failingTransport{err: errors.New("read tcp: connection reset by peer")}
+	client := &http.Client{Transport: NewRetryTransport(base, 3, time.Millisecond)}
+
+	if _, err := client.Get("http://figlet.openfaas-fn:8080/"); err == nil {
+		t.Fatal("want the error from the first attempt")
+	}
+	if base.calls != 1 {
+		t.Errorf("want 1 attempt once the request may have been sent, got: %d", base.calls)
+	}
+}
+
+// failingTransport fails every request with err
+type failingTransport struct {
+	err   error
+	calls int
+}
+
+func (f *failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func Test_RetryTransport_DoesNotRetryNonIdempotent(t *testing.T) {
+	base := &flakyTransport{failures: 1}
+	client := &http.Client{Transport: NewRetryTransport(base, 3, time.Millisecond)}
+
+	if _, err := client.Post("http://figlet.openfaas-fn:8080/", "text/plain", strings.NewReader("hello")); err == nil {
+		t.Fatal("want the error from the first attempt")
+	}
+
+	if len(base.bodies) != 1 {
+		t.Errorf("want 1 attempt for a POST, got: %d", len(base.bodies))
+	}
+}
+
+func Test_RetryTransport_ReplaysBodyWithIdempotencyKey(t *testing.T) {
+	base := &flakyTransport{failures: 1}
+	client := &http.Client{Transport: NewRetryTransport(base, 3, time.Millisecond)}
+
+	// hide the body's type so that http.NewRequest cannot set GetBody
+	req, _ := http.NewRequest(http.MethodPost, "http://figlet.openfaas-fn:8080/", io.NopCloser(strings.NewReader("hello")))
+	req.Header.Set(IdempotencyKeyHeader, "order-1234")
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if len(base.bodies) != 2 {
+		t.Fatalf("want 2 attempts, got: %d", len(base.bodies))
+	}
+	for i, body := range base.bodies {
+		if body != "hello" {
+			t.Errorf("attempt %d: want body: hello, got: %q", i+1, body)
+		}
+	}
+}
+
+func Test_RetryTransport_Disabled(t *testing.T) {
+	base := &flakyTransport{}
+	if got := NewRetryTransport(base, 1, time.Millisecond); got != base {
+		t.Errorf("want the base transport when attempts is 1, got: %T", got)
+	}
+}
+
+func Test_RetryTransport_RecordsRetryEvents(t *testing.T) {
+	recorder, teardown := tracetest.Install()
+	defer teardown()
+
+	base := &flakyTransport{failures: 2}
+	client := &http.Client{Transport: tracing.Transport(NewRetryTransport(base, 3, time.Millisecond))}
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "server")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://figlet.openfaas-fn:8080/", nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	span.End()
+
+	clientSpans := recorder.Named(http.MethodGet)
+	if len(clientSpans) != 1 {
+		t.Fatalf("want 1 client span, got: %d", len(clientSpans))
+	}
+
+	spanAttrs := map[string]string{}
+	for _, kv := range clientSpans[0].Attributes() {
+		spanAttrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if got := spanAttrs[string(tracing.RetryAttemptKey)]; got != "3" {
+		t.Errorf("want %s: 3 on the span, got: %q", tracing.RetryAttemptKey, got)
+	}
+	if got := spanAttrs[string(tracing.RetryMaxKey)]; got != "3" {
+		t.Errorf("want %s: 3 on the span, got: %q", tracing.RetryMaxKey, got)
+	}
+
+	events := clientSpans[0].Events()
+	if len(events) != 3 {
+		t.Fatalf("want 2 retry events and 1 success event, got: %d", len(events))
+	}
+
+	succeeded := events[2]
+	if succeeded.Name != tracing.RetrySucceededEvent {
+		t.Errorf("want the last event to be %s, got: %s", tracing.RetrySucceededEvent, succeeded.Name)
+	}
+	if len(succeeded.Attributes) != 1 || succeeded.Attributes[0].Value.Emit() != "3" {
+		t.Errorf("want %s: 3 on the success event, got: %v", tracing.RetryAttemptKey, succeeded.Attributes)
+	}
+
+	for i, event := range events[:2] {
+		if event.Name != tracing.RetryEvent {
+			t.Errorf("want event %s, got: %s", tracing.RetryEvent, event.Name)
+		}
+
+		attrs := map[string]string{}
+		for _, kv := range event.Attributes {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		if got, want := attrs[string(tracing.RetryAttemptKey)], []string{"1", "2"}[i]; got != want {
+			t.Errorf("want %s: %s, got: %s", tracing.RetryAttemptKey, want, got)
+		}
+		if len(attrs[string(tracing.RetryDelayKey)]) == 0 {
+			t.Errorf("want %s to be set", tracing.RetryDelayKey)
+		}
+		if got := attrs[string(tracing.RetryReasonKey)]; !strings.Contains(got, "connection refused") {
+			t.Errorf("want %s to be the error, got: %q", tracing.RetryReasonKey, got)
+		}
+	}
+}